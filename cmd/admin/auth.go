@@ -8,6 +8,7 @@ import (
 	"github.com/jmpsec/osctrl/cmd/admin/sessions"
 	"github.com/jmpsec/osctrl/pkg/config"
 	"github.com/jmpsec/osctrl/pkg/users"
+	"github.com/jmpsec/osctrl/pkg/utils"
 	"github.com/rs/zerolog/log"
 )
 
@@ -19,7 +20,7 @@ const (
 func handlerAuthCheck(h http.Handler, auth string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch auth {
-		case config.AuthDB:
+		case config.AuthDB, config.AuthOIDC:
 			// Check if user is already authenticated
 			authenticated, session := sessionsmgr.CheckAuth(r)
 			if !authenticated {
@@ -70,7 +71,7 @@ func handlerAuthCheck(h http.Handler, auth string) http.Handler {
 						http.Redirect(w, r, forbiddenPath, http.StatusFound)
 						return
 					}
-					u, err = adminUsers.New(samlUser, "", samlUser, "", false, false)
+					u, err = adminUsers.NewSSO(samlUser, samlUser, "", false)
 					if err != nil {
 						log.Err(err).Msgf("error creating user %s", samlUser)
 						http.Redirect(w, r, forbiddenPath, http.StatusFound)
@@ -89,6 +90,13 @@ func handlerAuthCheck(h http.Handler, auth string) http.Handler {
 						return
 					}
 				}
+				// Permissions are evaluated on every login, so revoked groups lose access
+				groups := jwtSessionClaims.Attributes[samlConfig.GroupsAttribute]
+				if err := syncGroupAccess(adminUsers, envs, auditLog, samlConfig.GroupMappings, u.Username, utils.GetIP(r), groups); err != nil {
+					log.Err(err).Msgf("error mapping groups for user %s", u.Username)
+					http.Redirect(w, r, forbiddenPath, http.StatusFound)
+					return
+				}
 				// Create new session
 				session, err = sessionsmgr.Save(r, w, u)
 				if err != nil {
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmpsec/osctrl/pkg/auditlog"
+	"github.com/jmpsec/osctrl/pkg/environments"
+	"github.com/jmpsec/osctrl/pkg/users"
+)
+
+const (
+	// Value for the granted by field in permissions from group mappings
+	groupsGrantedBy = "osctrl-sso"
+)
+
+// Function to apply the group mappings to a user after login, so access always follows the IdP groups
+func syncGroupAccess(usersmgr *users.UserManager, envsmgr *environments.EnvManager, auditlogmgr *auditlog.AuditLogManager, mappings []users.GroupMapping, username, ip string, groups []string) error {
+	// Without mappings, permissions are managed manually
+	if len(mappings) == 0 {
+		return nil
+	}
+	envs, err := envsmgr.All()
+	if err != nil {
+		return fmt.Errorf("error getting environments - %w", err)
+	}
+	access := users.MapGroupsAccess(mappings, groups, envs)
+	changes, err := usersmgr.SyncAccess(username, groupsGrantedBy, access, envs)
+	if err != nil {
+		return fmt.Errorf("error syncing access for %s - %w", username, err)
+	}
+	if auditlogmgr == nil {
+		return nil
+	}
+	for _, c := range changes {
+		action := fmt.Sprintf("groups [%s] changed access for %s in %s from [%s] to [%s]", strings.Join(groups, ","), username, c.Environment.Name, c.Previous, c.Current)
+		auditlogmgr.Permissions(username, action, ip, c.Environment.ID)
+	}
+	return nil
+}
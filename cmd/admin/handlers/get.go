@@ -4,6 +4,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/jmpsec/osctrl/cmd/admin/sessions"
@@ -116,9 +117,17 @@ func (h *HandlersAdmin) CarvesDownloadHandler(w http.ResponseWriter, r *http.Req
 		log.Err(err).Msgf("error getting carve")
 		return
 	}
-	var archived *carves.CarveResult
+	archived := &carves.CarveResult{
+		Size: int64(carve.CarveSize),
+		File: carve.ArchivePath,
+	}
 	if !carve.Archived {
-		archived, err = h.Carves.Archive(carveSession, h.CarvesFolder)
+		// Local carver uses its own directory for archives
+		destPath := h.CarvesFolder
+		if h.Carves.Carver == config.CarverLocal {
+			destPath = ""
+		}
+		archived, err = h.Carves.Archive(carveSession, destPath)
 		if err != nil {
 			log.Err(err).Msgf("error archiving results")
 			return
@@ -131,10 +140,6 @@ func (h *HandlersAdmin) CarvesDownloadHandler(w http.ResponseWriter, r *http.Req
 			log.Err(err).Msgf("error archiving carve")
 		}
 	}
-	archived = &carves.CarveResult{
-		Size: int64(carve.CarveSize),
-		File: carve.ArchivePath,
-	}
 	log.Debug().Msg("Initiating carve download")
 	if h.Carves.Carver == config.CarverS3 {
 		downloadURL, err := h.Carves.S3.GetDownloadLink(carve)
@@ -154,3 +159,60 @@ func (h *HandlersAdmin) CarvesDownloadHandler(w http.ResponseWriter, r *http.Req
 	// Audit log visit
 	h.AuditLog.Visit(ctx[sessions.CtxUser], r.URL.Path, strings.Split(r.RemoteAddr, ":")[0], env.ID)
 }
+
+// CarvesExtractHandler for GET requests to download one file from inside a carve
+func (h *HandlersAdmin) CarvesExtractHandler(w http.ResponseWriter, r *http.Request) {
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		log.Info().Msg("environment is missing")
+		return
+	}
+	// Get environment
+	env, err := h.Envs.Get(envVar)
+	if err != nil {
+		log.Err(err).Msgf("error getting environment %s", envVar)
+		return
+	}
+	// Get context data
+	ctx := r.Context().Value(sessions.ContextKey(sessions.CtxSession)).(sessions.ContextValue)
+	// Check permissions
+	if !h.Users.CheckPermissions(ctx[sessions.CtxUser], users.CarveLevel, env.UUID) {
+		log.Info().Msgf("%s has insufficient permissions", ctx[sessions.CtxUser])
+		return
+	}
+	// Extract session and path to extract
+	carveSession := r.PathValue("sessionid")
+	if carveSession == "" {
+		log.Info().Msg("empty carve session")
+		return
+	}
+	carvePath := r.URL.Query().Get("path")
+	if carvePath == "" {
+		log.Info().Msg("empty path to extract")
+		return
+	}
+	carve, err := h.Carves.GetBySession(carveSession)
+	if err != nil || carve.ID == 0 || carve.EnvironmentID != env.ID {
+		adminErrorResponse(w, "carve not found", http.StatusNotFound, err)
+		return
+	}
+	entry, err := h.Carves.OpenEntry(carve, carvePath)
+	if err != nil {
+		adminErrorResponse(w, "error extracting file", http.StatusNotFound, err)
+		return
+	}
+	defer entry.Close()
+	log.Debug().Msgf("Extracting %s from carve %s", carvePath, carveSession)
+	// Send response
+	utils.HTTPDownload(w, "File Carve Extract", path.Base(entry.Header.Name), entry.Header.Size)
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, entry); err != nil {
+		log.Err(err).Msgf("error extracting %s", carvePath)
+	}
+	// Audit log visit
+	h.AuditLog.Visit(ctx[sessions.CtxUser], r.URL.Path+"?path="+carvePath, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+}
@@ -44,6 +44,7 @@ type HandlersAdmin struct {
 	OsqueryTables   []types.OsqueryTable
 	AdminConfig     *config.JSONConfigurationService
 	AuditLog        *auditlog.AuditLogManager
+	LoginThrottle   *users.LoginThrottle
 	DBLogger        *logging.LoggerDB
 	DebugHTTP       *zerolog.Logger
 	DebugHTTPConfig *config.DebugHTTPConfiguration
@@ -192,6 +193,12 @@ func WithDBLogger(dbfile string, config *backend.JSONConfigurationDB) HandlersOp
 	}
 }
 
+func WithLoginThrottle(throttle *users.LoginThrottle) HandlersOption {
+	return func(h *HandlersAdmin) {
+		h.LoginThrottle = throttle
+	}
+}
+
 func WithDebugHTTP(cfg *config.DebugHTTPConfiguration) HandlersOption {
 	return func(h *HandlersAdmin) {
 		h.DebugHTTPConfig = cfg
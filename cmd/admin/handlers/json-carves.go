@@ -136,3 +136,55 @@ func (h *HandlersAdmin) JSONCarvesHandler(w http.ResponseWriter, r *http.Request
 	// Serve JSON
 	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, returned)
 }
+
+// ReturnedCarveFiles to return a JSON with the files inside a carve
+type ReturnedCarveFiles struct {
+	Data []carves.CarveEntry `json:"data"`
+}
+
+// JSONCarveFilesHandler for JSON with the files inside a carve
+func (h *HandlersAdmin) JSONCarveFilesHandler(w http.ResponseWriter, r *http.Request) {
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		log.Info().Msg("environment is missing")
+		return
+	}
+	// Get environment
+	env, err := h.Envs.Get(envVar)
+	if err != nil {
+		log.Err(err).Msgf("error getting environment %s", envVar)
+		return
+	}
+	// Get context data
+	ctx := r.Context().Value(sessions.ContextKey(sessions.CtxSession)).(sessions.ContextValue)
+	// Check permissions
+	if !h.Users.CheckPermissions(ctx[sessions.CtxUser], users.CarveLevel, env.UUID) {
+		log.Info().Msgf("%s has insufficient permissions", ctx[sessions.CtxUser])
+		return
+	}
+	// Extract session
+	carveSession := r.PathValue("sessionid")
+	if carveSession == "" {
+		log.Info().Msg("empty carve session")
+		return
+	}
+	carve, err := h.Carves.GetBySession(carveSession)
+	if err != nil || carve.ID == 0 || carve.EnvironmentID != env.ID {
+		adminErrorResponse(w, "carve not found", http.StatusNotFound, err)
+		return
+	}
+	entries, err := h.Carves.ListEntries(carve)
+	if err != nil {
+		adminErrorResponse(w, "error listing carve", http.StatusInternalServerError, err)
+		return
+	}
+	returned := ReturnedCarveFiles{
+		Data: entries,
+	}
+	// Serve JSON
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, returned)
+}
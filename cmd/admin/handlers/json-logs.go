@@ -7,10 +7,13 @@ import (
 
 	"github.com/jmpsec/osctrl/cmd/admin/sessions"
 	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/jmpsec/osctrl/pkg/logging"
+	"github.com/jmpsec/osctrl/pkg/queries"
 	"github.com/jmpsec/osctrl/pkg/types"
 	"github.com/jmpsec/osctrl/pkg/users"
 	"github.com/jmpsec/osctrl/pkg/utils"
 	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
 )
 
 // Define log types to be used
@@ -39,8 +42,12 @@ type LogJSON struct {
 	Second  string        `json:"second"`
 }
 
-// ReturnedQueryLogs to return a JSON with query logs
-type ReturnedQueryLogs struct {
+// PaginatedQueryLogs to return a JSON with query logs by target node, paginated
+type PaginatedQueryLogs struct {
+	Draw     int            `json:"draw"`
+	Total    int            `json:"recordsTotal"`
+	Filtered int            `json:"recordsFiltered"`
+	Columns  []string       `json:"columns"`
 	Data     []QueryLogJSON `json:"data"`
 	Download string         `json:"download"`
 }
@@ -53,9 +60,13 @@ type QueryTargetNode struct {
 
 // QueryLogJSON to be used to populate JSON data for a query log
 type QueryLogJSON struct {
-	Created CreationTimes   `json:"created"`
-	Target  QueryTargetNode `json:"target"`
-	Data    string          `json:"data"`
+	Created CreationTimes            `json:"created"`
+	Target  QueryTargetNode          `json:"target"`
+	Data    string                   `json:"data"`
+	Status  string                   `json:"status,omitempty"`
+	Message string                   `json:"message,omitempty"`
+	Columns []string                 `json:"columns,omitempty"`
+	Rows    []logging.QueryResultRow `json:"rows,omitempty"`
 }
 
 // JSONLogsHandler GET requests for JSON status/result logs by node and environment
@@ -203,50 +214,63 @@ func (h *HandlersAdmin) JSONQueryLogsHandler(w http.ResponseWriter, r *http.Requ
 		log.Info().Msg("error getting name")
 		return
 	}
-	// Iterate through targets to get logs
-	queryLogJSON := []QueryLogJSON{}
-	var downloadUrl string
-	// Get logs
+	// Get query by name
+	query, err := h.Queries.Get(name, env.ID)
+	if err != nil || query.ID == 0 {
+		log.Err(err).Msgf("error getting query %s", name)
+		return
+	}
+	// Extract DataTables parameters, the search value filters by node
+	draw, _ := strconv.Atoi(r.URL.Query().Get("draw"))
+	start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+	length, _ := strconv.Atoi(r.URL.Query().Get("length"))
+	filter := queries.NodeQueryFilter{
+		UUID:     r.URL.Query().Get("uuid"),
+		Hostname: r.URL.Query().Get("hostname"),
+		Status:   r.URL.Query().Get("status"),
+		Search:   r.URL.Query().Get("search"),
+	}
+	// Results are only available with the DB logger
+	var logsDB *gorm.DB
 	if h.DBLogger != nil {
-		queryLogs, err := h.DBLogger.QueryLogs(name)
-		if err != nil {
-			log.Err(err).Msg("error getting logs")
-			return
-		}
-		// TODO customize max number of logs to show
-		if len(queryLogs) > 100 {
-			downloadUrl = "/json-download/query/" + envVar + "/" + name
-		} else {
-			// Prepare data to be returned
-			for _, q := range queryLogs {
-				// Get target node
-				node, err := h.Nodes.GetByUUID(q.UUID)
-				if err != nil {
-					node.UUID = q.UUID
-					node.Localname = ""
-				}
-				_c := CreationTimes{
-					Display:   utils.PastFutureTimes(q.CreatedAt),
-					Timestamp: strconv.Itoa(int(q.CreatedAt.Unix())),
-				}
-				qData, err := json.Marshal(q.Data)
-				if err != nil {
-					log.Err(err).Msg("error serializing logs")
-					continue
-				}
-				_l := QueryLogJSON{
-					Created: _c,
-					Target: QueryTargetNode{
-						UUID: node.UUID,
-						Name: node.Localname,
-					},
-					Data: string(qData),
-				}
-				queryLogJSON = append(queryLogJSON, _l)
-			}
+		logsDB = h.DBLogger.Database.Conn
+	}
+	results, err := logging.QueryResultsPage(logsDB, h.Queries, query, filter, start, length)
+	if err != nil {
+		log.Err(err).Msg("error getting query results")
+		return
+	}
+	// Prepare data to be returned
+	queryLogJSON := []QueryLogJSON{}
+	for _, n := range results.Nodes {
+		nodeName := n.Localname
+		if nodeName == "" {
+			nodeName = n.Hostname
 		}
+		queryLogJSON = append(queryLogJSON, QueryLogJSON{
+			Created: CreationTimes{
+				Display:   utils.PastFutureTimes(n.UpdatedAt),
+				Timestamp: utils.TimeTimestamp(n.UpdatedAt),
+			},
+			Target: QueryTargetNode{
+				UUID: n.UUID,
+				Name: nodeName,
+			},
+			Status:  n.Status,
+			Message: n.Message,
+			Columns: n.Columns,
+			Rows:    n.Rows,
+		})
+	}
+	var downloadUrl string
+	if h.DBLogger != nil && results.Total > int64(results.Limit) {
+		downloadUrl = "/json-download/query/" + envVar + "/" + query.Name
 	}
-	returned := ReturnedQueryLogs{
+	returned := PaginatedQueryLogs{
+		Draw:     draw,
+		Total:    int(results.Total),
+		Filtered: int(results.Filtered),
+		Columns:  results.Columns,
 		Data:     queryLogJSON,
 		Download: downloadUrl,
 	}
@@ -51,6 +51,7 @@ type NodeJSON struct {
 	Osquery   string        `json:"osquery"`
 	LastSeen  CreationTimes `json:"lastseen"`
 	FirstSeen CreationTimes `json:"firstseen"`
+	Pending   bool          `json:"pending"`
 }
 
 // JSONEnvironmentPagingHandler - Handler for JSON endpoints by environment, with pagination
@@ -144,6 +145,7 @@ func (h *HandlersAdmin) JSONEnvironmentPagingHandler(w http.ResponseWriter, r *h
 			Platform:  n.Platform,
 			Version:   n.PlatformVersion,
 			Osquery:   n.OsqueryVersion,
+			Pending:   n.Pending,
 			LastSeen: CreationTimes{
 				Display:   utils.PastFutureTimes(n.LastSeen),
 				Timestamp: utils.TimeTimestamp(n.LastSeen),
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/jmpsec/osctrl/cmd/admin/sessions"
@@ -18,6 +19,7 @@ var (
 		queries.TargetCompleted: true,
 		queries.TargetSaved:     true,
 		queries.TargetExpired:   true,
+		queries.TargetRecurring: true,
 	}
 )
 
@@ -31,6 +33,11 @@ type ReturnedSaved struct {
 	Data []SavedJSON `json:"data"`
 }
 
+// ReturnedRecurring to return a JSON with recurring queries
+type ReturnedRecurring struct {
+	Data []RecurringJSON `json:"data"`
+}
+
 // QueryProgress to be used to show progress for a query
 type QueryProgress map[string]int
 
@@ -58,6 +65,20 @@ type SavedJSON struct {
 	Created  CreationTimes `json:"created"`
 }
 
+// RecurringJSON to be used to populate JSON data for a recurring query
+type RecurringJSON struct {
+	Checkbox string        `json:"checkbox"`
+	Name     string        `json:"name"`
+	Creator  string        `json:"creator"`
+	Query    string        `json:"query"`
+	Schedule string        `json:"schedule"`
+	Paused   bool          `json:"paused"`
+	Runs     int           `json:"runs"`
+	LastRun  CreationTimes `json:"last_run"`
+	NextRun  CreationTimes `json:"next_run"`
+	Created  CreationTimes `json:"created"`
+}
+
 // QueryTarget to be returned with the JSON data for a query
 type QueryTarget struct {
 	Type  string `json:"type"`
@@ -111,6 +132,38 @@ func (h *HandlersAdmin) JSONSavedJSON(q queries.SavedQuery) SavedJSON {
 	}
 }
 
+// JSONRecurringJSON - Helper to convert recurring queries to serialized JSON
+func (h *HandlersAdmin) JSONRecurringJSON(q queries.RecurringQuery) RecurringJSON {
+	schedule := q.Cron
+	if schedule == "" {
+		schedule = fmt.Sprintf("every %d minutes", q.Interval)
+	}
+	lastRun := CreationTimes{Display: "never"}
+	if !q.LastRun.IsZero() {
+		lastRun = CreationTimes{
+			Display:   utils.PastFutureTimes(q.LastRun),
+			Timestamp: utils.TimeTimestamp(q.LastRun),
+		}
+	}
+	return RecurringJSON{
+		Creator:  q.Creator,
+		Name:     q.Name,
+		Query:    q.Query,
+		Schedule: schedule,
+		Paused:   q.Paused,
+		Runs:     q.Runs,
+		LastRun:  lastRun,
+		NextRun: CreationTimes{
+			Display:   utils.PastFutureTimes(q.NextRun),
+			Timestamp: utils.TimeTimestamp(q.NextRun),
+		},
+		Created: CreationTimes{
+			Display:   utils.PastFutureTimes(q.CreatedAt),
+			Timestamp: utils.TimeTimestamp(q.CreatedAt),
+		},
+	}
+}
+
 // JSONQueryHandler - Handler for JSON queries by target
 func (h *HandlersAdmin) JSONQueryHandler(w http.ResponseWriter, r *http.Request) {
 	if h.DebugHTTPConfig.Enabled {
@@ -166,6 +219,25 @@ func (h *HandlersAdmin) JSONQueryHandler(w http.ResponseWriter, r *http.Request)
 		utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, returned)
 		return
 	}
+	// If the target is recurring queries, get them
+	if target == queries.TargetRecurring {
+		rqs, err := h.Queries.GetAllRecurring(env.ID)
+		if err != nil {
+			log.Err(err).Msg("error getting recurring queries")
+			return
+		}
+		// Prepare data to be returned
+		rJSON := []RecurringJSON{}
+		for _, q := range rqs {
+			rJSON = append(rJSON, h.JSONRecurringJSON(q))
+		}
+		returned := ReturnedRecurring{
+			Data: rJSON,
+		}
+		// Serve JSON
+		utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, returned)
+		return
+	}
 	// If we are here, retrieve distributed queries for that target
 	qs, err := h.Queries.GetQueries(target, env.ID)
 	if err != nil {
@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"strings"
 	"time"
 
@@ -34,19 +35,76 @@ func (h *HandlersAdmin) LoginPOSTHandler(w http.ResponseWriter, r *http.Request)
 		adminErrorResponse(w, "error parsing POST body", http.StatusInternalServerError, err)
 		return
 	}
+	ip := strings.Split(r.RemoteAddr, ":")[0]
+	// Check login attempts from this IP and lockout of the user
+	if !h.LoginThrottle.Allow(ip) {
+		h.AuditLog.LoginFailed(l.Username, "too many login attempts from "+ip, ip)
+		adminErrorResponse(w, "too many login attempts", http.StatusTooManyRequests, nil)
+		return
+	}
+	if h.Users.IsLocked(l.Username) {
+		h.AuditLog.LoginFailed(l.Username, "user is locked", ip)
+		adminErrorResponse(w, "user is temporarily locked", http.StatusForbidden, nil)
+		return
+	}
 	// Check credentials
 	access, user := h.Users.CheckLoginCredentials(l.Username, l.Password)
 	if !access {
+		h.loginFailed(l.Username, "invalid credentials", ip)
 		adminErrorResponse(w, "invalid credentials", http.StatusForbidden, nil)
 		return
 	}
+	// Check second factor, enrolling it first if it is enforced and the user does not have it yet
+	var recoveryCodes []string
+	if users.MFANeeded(user, h.Settings.RequireMFA()) {
+		switch {
+		case !user.MFAEnabled && l.OTP == "":
+			secret, err := h.Users.NewMFASecret(user.Username)
+			if err != nil {
+				adminErrorResponse(w, "error enrolling second factor", http.StatusInternalServerError, err)
+				return
+			}
+			utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, MFAResponse{
+				Message: users.ErrMFAEnrollment.Error(),
+				MFA:     mfaEnroll,
+				Secret:  secret,
+				URI:     users.MFAURI(h.mfaIssuer(), user.Username, secret),
+			})
+			return
+		case !user.MFAEnabled:
+			codes, err := h.Users.EnableMFA(user.Username, l.OTP)
+			if err != nil {
+				h.loginFailed(user.Username, users.ErrMFAInvalid.Error(), ip)
+				adminErrorResponse(w, users.ErrMFAInvalid.Error(), http.StatusForbidden, err)
+				return
+			}
+			recoveryCodes = codes
+		case l.OTP == "":
+			utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, MFAResponse{
+				Message: users.ErrMFARequired.Error(),
+				MFA:     mfaRequired,
+			})
+			return
+		case !h.Users.CheckMFA(user.Username, l.OTP):
+			h.loginFailed(user.Username, users.ErrMFAInvalid.Error(), ip)
+			adminErrorResponse(w, users.ErrMFAInvalid.Error(), http.StatusForbidden, nil)
+			return
+		}
+	}
 	_, err := h.Sessions.Save(r, w, user)
 	if err != nil {
 		adminErrorResponse(w, "session error", http.StatusForbidden, err)
 		return
 	}
+	if err := h.Users.LoginSucceeded(user.Username); err != nil {
+		log.Err(err).Msgf("error resetting failed logins for %s", user.Username)
+	}
 	// Serialize and send response
-	h.AuditLog.NewLogin(user.Username, strings.Split(r.RemoteAddr, ":")[0])
+	h.AuditLog.NewLogin(user.Username, ip)
+	if len(recoveryCodes) > 0 {
+		utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, MFAResponse{Message: "/dashboard", RecoveryCodes: recoveryCodes})
+		return
+	}
 	adminOKResponse(w, "/dashboard")
 }
 
@@ -79,6 +137,72 @@ func (h *HandlersAdmin) LogoutPOSTHandler(w http.ResponseWriter, r *http.Request
 	adminOKResponse(w, "OK")
 }
 
+// QueryPreviewPOSTHandler for POST requests to preview the nodes targeted by queries and carves
+func (h *HandlersAdmin) QueryPreviewPOSTHandler(w http.ResponseWriter, r *http.Request) {
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		log.Info().Msg("environment is missing")
+		return
+	}
+	// Get environment
+	env, err := h.Envs.Get(envVar)
+	if err != nil {
+		log.Err(err).Msgf("error getting environment %s", envVar)
+		return
+	}
+	// Get context data
+	ctx := r.Context().Value(sessions.ContextKey(sessions.CtxSession)).(sessions.ContextValue)
+	// Check permissions for query
+	if !h.Users.CheckPermissions(ctx[sessions.CtxUser], users.QueryLevel, env.UUID) {
+		adminErrorResponse(w, fmt.Sprintf("%s has insufficient permissions", ctx[sessions.CtxUser]), http.StatusForbidden, nil)
+		return
+	}
+	// Parse request JSON body
+	log.Debug().Msg("Decoding POST body")
+	var q DistributedQueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+		adminErrorResponse(w, "error parsing POST body", http.StatusInternalServerError, err)
+		return
+	}
+	// Check CSRF Token
+	if !sessions.CheckCSRFToken(ctx[sessions.CtxCSRF], q.CSRFToken) {
+		adminErrorResponse(w, "invalid CSRF token", http.StatusInternalServerError, nil)
+		return
+	}
+	if err := handlers.ValidateSelector(q.Selector); err != nil {
+		adminErrorResponse(w, "error with selector", http.StatusBadRequest, err)
+		return
+	}
+	// Prepare data for the handler code
+	data := handlers.ProcessingQuery{
+		Envs:          q.Environments,
+		Platforms:     q.Platforms,
+		UUIDs:         q.UUIDs,
+		Hosts:         q.Hosts,
+		Tags:          q.Tags,
+		Selector:      q.Selector,
+		EnvID:         env.ID,
+		InactiveHours: h.Settings.InactiveHours(settings.NoEnvironmentID),
+	}
+	manager := handlers.Managers{
+		Nodes: h.Nodes,
+		Envs:  h.Envs,
+		Tags:  h.Tags,
+	}
+	preview, err := handlers.PreviewQueryCarve(data, manager)
+	if err != nil {
+		adminErrorResponse(w, "error getting target nodes", http.StatusInternalServerError, err)
+		return
+	}
+	// Serialize and send response
+	log.Debug().Msgf("Preview with %d target nodes", preview.Count)
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, preview)
+}
+
 // QueryRunPOSTHandler for POST requests to run queries
 func (h *HandlersAdmin) QueryRunPOSTHandler(w http.ResponseWriter, r *http.Request) {
 	if h.DebugHTTPConfig.Enabled {
@@ -121,6 +245,10 @@ func (h *HandlersAdmin) QueryRunPOSTHandler(w http.ResponseWriter, r *http.Reque
 		adminErrorResponse(w, "query can not be empty", http.StatusInternalServerError, nil)
 		return
 	}
+	if err := handlers.ValidateSelector(q.Selector); err != nil {
+		adminErrorResponse(w, "error with selector", http.StatusBadRequest, err)
+		return
+	}
 	// FIXME check if query is carve and user has permissions to carve
 	// Prepare and create new query
 	expTime := queries.QueryExpiration(q.ExpHours)
@@ -139,6 +267,7 @@ func (h *HandlersAdmin) QueryRunPOSTHandler(w http.ResponseWriter, r *http.Reque
 		UUIDs:         q.UUIDs,
 		Hosts:         q.Hosts,
 		Tags:          q.Tags,
+		Selector:      q.Selector,
 		EnvID:         env.ID,
 		InactiveHours: h.Settings.InactiveHours(settings.NoEnvironmentID),
 	}
@@ -219,6 +348,10 @@ func (h *HandlersAdmin) CarvesRunPOSTHandler(w http.ResponseWriter, r *http.Requ
 		adminErrorResponse(w, "path can not be empty", http.StatusInternalServerError, nil)
 		return
 	}
+	if err := handlers.ValidateSelector(c.Selector); err != nil {
+		adminErrorResponse(w, "error with selector", http.StatusBadRequest, err)
+		return
+	}
 	// Set query expiration
 	expTime := queries.QueryExpiration(c.ExpHours)
 	if c.ExpHours == 0 {
@@ -236,6 +369,7 @@ func (h *HandlersAdmin) CarvesRunPOSTHandler(w http.ResponseWriter, r *http.Requ
 		UUIDs:         c.UUIDs,
 		Hosts:         c.Hosts,
 		Tags:          c.Tags,
+		Selector:      c.Selector,
 		EnvID:         env.ID,
 		InactiveHours: h.Settings.InactiveHours(settings.NoEnvironmentID),
 	}
@@ -345,10 +479,111 @@ func (h *HandlersAdmin) QueryActionsPOSTHandler(w http.ResponseWriter, r *http.R
 			}
 		}
 		adminOKResponse(w, "queries delete successfully")
+	case "recurring_pause", "recurring_resume":
+		if !h.Users.CheckPermissions(ctx[sessions.CtxUser], users.AdminLevel, env.UUID) {
+			adminErrorResponse(w, fmt.Sprintf("%s has insufficient permissions to update recurring queries", ctx[sessions.CtxUser]), http.StatusForbidden, nil)
+			return
+		}
+		for _, n := range q.Names {
+			if err := h.Queries.PauseRecurring(n, env.ID, q.Action == "recurring_pause"); err != nil {
+				adminErrorResponse(w, "error updating recurring query", http.StatusInternalServerError, err)
+				return
+			}
+		}
+		adminOKResponse(w, "recurring queries updated successfully")
+	case "recurring_delete":
+		if !h.Users.CheckPermissions(ctx[sessions.CtxUser], users.AdminLevel, env.UUID) {
+			adminErrorResponse(w, fmt.Sprintf("%s has insufficient permissions to delete recurring queries", ctx[sessions.CtxUser]), http.StatusForbidden, nil)
+			return
+		}
+		for _, n := range q.Names {
+			if err := h.Queries.DeleteRecurring(n, env.ID); err != nil {
+				adminErrorResponse(w, "error deleting recurring query", http.StatusInternalServerError, err)
+				return
+			}
+		}
+		adminOKResponse(w, "recurring queries delete successfully")
 	}
 	h.AuditLog.QueryAction(ctx[sessions.CtxUser], q.Action, strings.Split(r.RemoteAddr, ":")[0], env.ID)
 }
 
+// RecurringQueryPOSTHandler for POST requests to create recurring queries
+func (h *HandlersAdmin) RecurringQueryPOSTHandler(w http.ResponseWriter, r *http.Request) {
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		log.Info().Msg("environment is missing")
+		return
+	}
+	// Get environment
+	env, err := h.Envs.Get(envVar)
+	if err != nil {
+		log.Err(err).Msgf("error getting environment %s", envVar)
+		return
+	}
+	// Get context data
+	ctx := r.Context().Value(sessions.ContextKey(sessions.CtxSession)).(sessions.ContextValue)
+	// Check permissions for query
+	if !h.Users.CheckPermissions(ctx[sessions.CtxUser], users.QueryLevel, env.UUID) {
+		adminErrorResponse(w, fmt.Sprintf("%s has insufficient permissions", ctx[sessions.CtxUser]), http.StatusForbidden, nil)
+		return
+	}
+	// Parse request JSON body
+	log.Debug().Msg("Decoding POST body")
+	var q RecurringQueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+		adminErrorResponse(w, "error parsing POST body", http.StatusInternalServerError, err)
+		return
+	}
+	// Check CSRF Token
+	if !sessions.CheckCSRFToken(ctx[sessions.CtxCSRF], q.CSRFToken) {
+		adminErrorResponse(w, "invalid CSRF token", http.StatusInternalServerError, nil)
+		return
+	}
+	// Query can not be empty
+	if q.Query == "" {
+		adminErrorResponse(w, "query can not be empty", http.StatusInternalServerError, nil)
+		return
+	}
+	if err := handlers.ValidateSelector(q.Selector); err != nil {
+		adminErrorResponse(w, "error with selector", http.StatusBadRequest, err)
+		return
+	}
+	if q.Name == "" {
+		q.Name = queries.GenRecurringQueryName()
+	}
+	newRecurring := queries.RecurringQuery{
+		Name:          q.Name,
+		Creator:       ctx[sessions.CtxUser],
+		Query:         q.Query,
+		EnvironmentID: env.ID,
+		Cron:          q.Cron,
+		Interval:      q.Interval,
+		ExpHours:      q.ExpHours,
+	}
+	// Recurring queries from the UI always target the current environment
+	if err := newRecurring.SetTargets(queries.RecurringQueryTargets{
+		Environments: []string{env.UUID},
+		Platforms:    q.Platforms,
+		UUIDs:        q.UUIDs,
+		Hosts:        q.Hosts,
+		Tags:         q.Tags,
+		Selector:     q.Selector,
+	}); err != nil {
+		adminErrorResponse(w, "error with targets", http.StatusInternalServerError, err)
+		return
+	}
+	if err := h.Queries.CreateRecurring(&newRecurring); err != nil {
+		adminErrorResponse(w, "error creating recurring query", http.StatusInternalServerError, err)
+		return
+	}
+	h.AuditLog.QueryAction(ctx[sessions.CtxUser], "create recurring query "+newRecurring.Name, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	adminOKResponse(w, "recurring query created successfully")
+}
+
 // CarvesActionsPOSTHandler - Handler for POST requests to carves
 func (h *HandlersAdmin) CarvesActionsPOSTHandler(w http.ResponseWriter, r *http.Request) {
 	if h.DebugHTTPConfig.Enabled {
@@ -785,6 +1020,57 @@ func (h *HandlersAdmin) NodeActionsPOSTHandler(w http.ResponseWriter, r *http.Re
 			return
 		}
 	}
+	if m.Action == "approve" {
+		okCount := 0
+		errCount := 0
+		for _, u := range m.UUIDs {
+			node, err := h.Nodes.GetByUUID(u)
+			if err != nil {
+				errCount++
+				log.Err(err).Msgf("error getting node %s", u)
+				continue
+			}
+			if err := h.Nodes.Approve(node); err != nil {
+				errCount++
+				log.Err(err).Msgf("error approving node %s", u)
+			} else {
+				okCount++
+				h.AuditLog.NodeAction(ctx[sessions.CtxUser], "approved node "+node.UUID, strings.Split(r.RemoteAddr, ":")[0], node.EnvironmentID)
+			}
+		}
+		if errCount == 0 {
+			adminOKResponse(w, fmt.Sprintf("%d Node(s) have been approved successfully", okCount))
+		} else {
+			adminErrorResponse(w, fmt.Sprintf("Error approving %d node(s)", errCount), http.StatusInternalServerError, nil)
+			return
+		}
+	}
+	if m.Action == "revoke_cert" {
+		okCount := 0
+		errCount := 0
+		manager := handlers.Managers{Envs: h.Envs, Nodes: h.Nodes}
+		for _, u := range m.UUIDs {
+			node, err := h.Nodes.GetByUUID(u)
+			if err != nil {
+				errCount++
+				log.Err(err).Msgf("error getting node %s", u)
+				continue
+			}
+			if _, err := handlers.RevokeNodeCert(manager, node, ctx[sessions.CtxUser]); err != nil {
+				errCount++
+				log.Err(err).Msgf("error revoking certificates of node %s", u)
+			} else {
+				okCount++
+				h.AuditLog.NodeAction(ctx[sessions.CtxUser], "revoked certificates of node "+node.UUID, strings.Split(r.RemoteAddr, ":")[0], node.EnvironmentID)
+			}
+		}
+		if errCount == 0 {
+			adminOKResponse(w, fmt.Sprintf("Certificates of %d Node(s) have been revoked successfully", okCount))
+		} else {
+			adminErrorResponse(w, fmt.Sprintf("Error revoking certificates of %d node(s)", errCount), http.StatusInternalServerError, nil)
+			return
+		}
+	}
 	h.AuditLog.NodeAction(ctx[sessions.CtxUser], m.Action, strings.Split(r.RemoteAddr, ":")[0], auditlog.NoEnvironment)
 }
 
@@ -996,6 +1282,10 @@ func (h *HandlersAdmin) UsersPOSTHandler(w http.ResponseWriter, r *http.Request)
 		// Prepare user to create
 		newUser, err := h.Users.New(u.Username, u.NewPassword, u.Email, u.Fullname, u.Admin, u.Service)
 		if err != nil {
+			if errors.Is(err, users.ErrPasswordPolicy) {
+				adminErrorResponse(w, err.Error(), http.StatusBadRequest, nil)
+				return
+			}
 			adminErrorResponse(w, "error with new user", http.StatusInternalServerError, err)
 			return
 		}
@@ -1046,6 +1336,10 @@ func (h *HandlersAdmin) UsersPOSTHandler(w http.ResponseWriter, r *http.Request)
 		}
 		if u.NewPassword != "" {
 			if err := h.Users.ChangePassword(u.Username, u.NewPassword); err != nil {
+				if errors.Is(err, users.ErrPasswordPolicy) {
+					adminErrorResponse(w, err.Error(), http.StatusBadRequest, nil)
+					return
+				}
 				adminErrorResponse(w, "error changing password", http.StatusInternalServerError, err)
 				return
 			}
@@ -1458,6 +1752,10 @@ func (h *HandlersAdmin) EditProfilePOSTHandler(w http.ResponseWriter, r *http.Re
 			// Update password with the new one
 			if access && u.NewPassword != "" {
 				if err := h.Users.ChangePassword(user.Username, u.NewPassword); err != nil {
+					if errors.Is(err, users.ErrPasswordPolicy) {
+						adminErrorResponse(w, err.Error(), http.StatusBadRequest, nil)
+						return
+					}
 					adminErrorResponse(w, "error changing password", http.StatusInternalServerError, err)
 					return
 				}
@@ -1484,6 +1782,50 @@ func (h *HandlersAdmin) EditProfilePOSTHandler(w http.ResponseWriter, r *http.Re
 			}
 		}
 		adminOKResponse(w, "profiled updated successfully")
+	case "mfa_enroll":
+		secret, err := h.Users.NewMFASecret(u.Username)
+		if err != nil {
+			adminErrorResponse(w, "error enrolling second factor", http.StatusInternalServerError, err)
+			return
+		}
+		utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, MFAResponse{
+			Message: "scan the QR code and confirm with a code",
+			MFA:     mfaEnroll,
+			Secret:  secret,
+			URI:     users.MFAURI(h.mfaIssuer(), u.Username, secret),
+		})
+	case "mfa_confirm":
+		codes, err := h.Users.EnableMFA(u.Username, u.OTP)
+		if err != nil {
+			adminErrorResponse(w, "error confirming second factor", http.StatusForbidden, err)
+			return
+		}
+		utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, MFAResponse{Message: "second factor enabled successfully", RecoveryCodes: codes})
+	case "mfa_recovery":
+		if !h.Users.CheckMFA(u.Username, u.OTP) {
+			adminErrorResponse(w, users.ErrMFAInvalid.Error(), http.StatusForbidden, nil)
+			return
+		}
+		codes, err := h.Users.NewRecoveryCodes(u.Username)
+		if err != nil {
+			adminErrorResponse(w, "error generating recovery codes", http.StatusInternalServerError, err)
+			return
+		}
+		utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, MFAResponse{Message: "recovery codes generated successfully", RecoveryCodes: codes})
+	case "mfa_disable":
+		if h.Settings.RequireMFA() {
+			adminErrorResponse(w, "second factor is required for all users", http.StatusForbidden, nil)
+			return
+		}
+		if !h.Users.CheckMFA(u.Username, u.OTP) {
+			adminErrorResponse(w, users.ErrMFAInvalid.Error(), http.StatusForbidden, nil)
+			return
+		}
+		if err := h.Users.DisableMFA(u.Username); err != nil {
+			adminErrorResponse(w, "error disabling second factor", http.StatusInternalServerError, err)
+			return
+		}
+		adminOKResponse(w, "second factor disabled successfully")
 	}
 	h.AuditLog.UserAction(ctx[sessions.CtxUser], fmt.Sprintf("%s - %s", u.Action, u.Username), strings.Split(r.RemoteAddr, ":")[0])
 }
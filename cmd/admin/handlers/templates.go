@@ -13,6 +13,7 @@ import (
 	"github.com/jmpsec/osctrl/pkg/carves"
 	"github.com/jmpsec/osctrl/pkg/environments"
 	"github.com/jmpsec/osctrl/pkg/nodes"
+	"github.com/jmpsec/osctrl/pkg/queries"
 	"github.com/jmpsec/osctrl/pkg/settings"
 	"github.com/jmpsec/osctrl/pkg/tags"
 	"github.com/jmpsec/osctrl/pkg/types"
@@ -394,6 +395,71 @@ func (h *HandlersAdmin) SavedQueriesGETHandler(w http.ResponseWriter, r *http.Re
 	h.AuditLog.Visit(ctx[sessions.CtxUser], r.URL.Path, strings.Split(r.RemoteAddr, ":")[0], env.ID)
 }
 
+// RecurringQueriesGETHandler for GET requests to recurring queries
+func (h *HandlersAdmin) RecurringQueriesGETHandler(w http.ResponseWriter, r *http.Request) {
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		log.Info().Msg("error getting environment")
+		return
+	}
+	// Get environment
+	env, err := h.Envs.Get(envVar)
+	if err != nil {
+		log.Err(err).Msg("error getting environment")
+		return
+	}
+	// Get context data
+	ctx := r.Context().Value(sessions.ContextKey(sessions.CtxSession)).(sessions.ContextValue)
+	// Check permissions
+	if !h.Users.CheckPermissions(ctx[sessions.CtxUser], users.QueryLevel, env.UUID) {
+		log.Info().Msgf("%s has insufficient permissions", ctx[sessions.CtxUser])
+		return
+	}
+	// Prepare template
+	tempateFiles := h.NewTemplateFiles(h.TemplatesFolder, "queries-recurring.html").filepaths
+	t, err := template.ParseFiles(tempateFiles...)
+	if err != nil {
+		log.Err(err).Msg("error getting table template")
+		return
+	}
+	// Get all environments
+	envAll, err := h.Envs.All()
+	if err != nil {
+		log.Err(err).Msg("error getting environments")
+		return
+	}
+	// Get if the user is admin
+	user, err := h.Users.Get(ctx[sessions.CtxUser])
+	if err != nil {
+		log.Err(err).Msg("error getting user")
+		return
+	}
+	// Left metadata
+	leftMetadata := AsideLeftMetadata{
+		EnvUUID:       env.UUID,
+		EnvName:       env.Name,
+		OsqueryValues: h.OsqueryValues,
+	}
+	// Prepare template data
+	templateData := RecurringQueriesTemplateData{
+		Title:        "Recurring queries in " + env.Name,
+		Metadata:     h.TemplateMetadata(ctx, h.ServiceMetadata, user.Admin),
+		LeftMetadata: leftMetadata,
+		Environments: h.allowedEnvironments(ctx[sessions.CtxUser], envAll),
+		Target:       queries.TargetRecurring,
+	}
+	if err := t.Execute(w, templateData); err != nil {
+		log.Err(err).Msg("template error")
+		return
+	}
+	// Audit log visit
+	h.AuditLog.Visit(ctx[sessions.CtxUser], r.URL.Path, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+}
+
 // CarvesRunGETHandler for GET requests to run file carves
 func (h *HandlersAdmin) CarvesRunGETHandler(w http.ResponseWriter, r *http.Request) {
 	if h.DebugHTTPConfig.Enabled {
@@ -1460,12 +1526,18 @@ func (h *HandlersAdmin) EditProfileGETHandler(w http.ResponseWriter, r *http.Req
 	if err != nil {
 		log.Err(err).Msg("error getting permissions")
 	}
+	// Get named tokens
+	tokens, err := h.Users.GetUserTokens(user.Username)
+	if err != nil {
+		log.Err(err).Msg("error getting tokens")
+	}
 	// Prepare template data
 	templateData := ProfileTemplateData{
 		Title:        "Edit " + user.Username + " profile",
 		Metadata:     h.TemplateMetadata(ctx, h.ServiceMetadata, user.Admin),
 		Environments: h.allowedEnvironments(ctx[sessions.CtxUser], envAll),
 		Permissions:  permissions,
+		Tokens:       tokens,
 		CurrentUser:  user,
 	}
 	if err := t.Execute(w, templateData); err != nil {
@@ -114,3 +114,96 @@ func (h *HandlersAdmin) TokensPOSTHandler(w http.ResponseWriter, r *http.Request
 	// Serialize and serve JSON
 	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, response)
 }
+
+// UserTokenCreatePOSTHandler for POST request for /tokens/{username}/create
+func (h *HandlersAdmin) UserTokenCreatePOSTHandler(w http.ResponseWriter, r *http.Request) {
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract username and verify
+	username := r.PathValue("username")
+	if username == "" || !h.Users.Exists(username) {
+		adminErrorResponse(w, "error getting username", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get context data
+	ctx := r.Context().Value(sessions.ContextKey(sessions.CtxSession)).(sessions.ContextValue)
+	// Check permissions
+	if !h.Users.CheckPermissions(ctx[sessions.CtxUser], users.AdminLevel, users.NoEnvironment) && ctx[sessions.CtxUser] != username {
+		adminErrorResponse(w, "insufficient permissions", http.StatusForbidden, nil)
+		return
+	}
+	// Parse request JSON body
+	log.Debug().Msg("Decoding POST body")
+	var t TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		adminErrorResponse(w, "error parsing POST body", http.StatusInternalServerError, err)
+		return
+	}
+	// Check CSRF Token
+	if !checkCSRFToken(ctx[sessions.CtxCSRF], t.CSRFToken) {
+		adminErrorResponse(w, "invalid CSRF token", http.StatusInternalServerError, nil)
+		return
+	}
+	// Tokens restricted to one environment need access to it
+	if t.Environment != users.TokenAllEnvironments && !h.Users.CheckPermissions(username, users.UserLevel, t.Environment) {
+		adminErrorResponse(w, "no access to environment", http.StatusForbidden, nil)
+		return
+	}
+	log.Debug().Msg("Creating named token")
+	token, userToken, err := h.Users.CreateUserToken(username, t.Name, h.AdminConfig.Host, t.Environment, t.Scope, t.ExpHours)
+	if err != nil {
+		adminErrorResponse(w, "error creating token", http.StatusInternalServerError, err)
+		return
+	}
+	response := TokenResponse{
+		Token:        token,
+		TokenID:      userToken.TokenID,
+		ExpirationTS: utils.TimeTimestamp(userToken.ExpiresAt),
+		Expiration:   utils.PastFutureTimes(userToken.ExpiresAt),
+	}
+	// Audit log token creation
+	h.AuditLog.UserAction(ctx[sessions.CtxUser], "create token "+t.Name+" for user "+username, strings.Split(r.RemoteAddr, ":")[0])
+	// Serialize and serve JSON
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, response)
+}
+
+// UserTokenRevokePOSTHandler for POST request for /tokens/{username}/revoke
+func (h *HandlersAdmin) UserTokenRevokePOSTHandler(w http.ResponseWriter, r *http.Request) {
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract username and verify
+	username := r.PathValue("username")
+	if username == "" || !h.Users.Exists(username) {
+		adminErrorResponse(w, "error getting username", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get context data
+	ctx := r.Context().Value(sessions.ContextKey(sessions.CtxSession)).(sessions.ContextValue)
+	// Check permissions
+	if !h.Users.CheckPermissions(ctx[sessions.CtxUser], users.AdminLevel, users.NoEnvironment) && ctx[sessions.CtxUser] != username {
+		adminErrorResponse(w, "insufficient permissions", http.StatusForbidden, nil)
+		return
+	}
+	// Parse request JSON body
+	log.Debug().Msg("Decoding POST body")
+	var t TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		adminErrorResponse(w, "error parsing POST body", http.StatusInternalServerError, err)
+		return
+	}
+	// Check CSRF Token
+	if !checkCSRFToken(ctx[sessions.CtxCSRF], t.CSRFToken) {
+		adminErrorResponse(w, "invalid CSRF token", http.StatusInternalServerError, nil)
+		return
+	}
+	if err := h.Users.RevokeUserToken(username, t.TokenID, ctx[sessions.CtxUser]); err != nil {
+		adminErrorResponse(w, "error revoking token", http.StatusInternalServerError, err)
+		return
+	}
+	// Audit log token revocation
+	h.AuditLog.UserAction(ctx[sessions.CtxUser], "revoke token "+t.TokenID+" for user "+username, strings.Split(r.RemoteAddr, ":")[0])
+	// Serialize and serve JSON
+	adminOKResponse(w, "token revoked successfully")
+}
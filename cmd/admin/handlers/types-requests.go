@@ -4,6 +4,7 @@ package handlers
 type LoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	OTP      string `json:"otp"`
 }
 
 // LogoutRequest to receive logout requests
@@ -19,6 +20,7 @@ type DistributedQueryRequest struct {
 	UUIDs        []string `json:"uuid_list"`
 	Hosts        []string `json:"host_list"`
 	Tags         []string `json:"tag_list"`
+	Selector     string   `json:"selector"`
 	Save         bool     `json:"save"`
 	Name         string   `json:"name"`
 	Query        string   `json:"query"`
@@ -26,6 +28,21 @@ type DistributedQueryRequest struct {
 	ExpHours     int      `json:"exp_hours"`
 }
 
+// RecurringQueryRequest to receive recurring query requests
+type RecurringQueryRequest struct {
+	CSRFToken string   `json:"csrftoken"`
+	Platforms []string `json:"platform_list"`
+	UUIDs     []string `json:"uuid_list"`
+	Hosts     []string `json:"host_list"`
+	Tags      []string `json:"tag_list"`
+	Selector  string   `json:"selector"`
+	Name      string   `json:"name"`
+	Query     string   `json:"query"`
+	Cron      string   `json:"cron"`
+	Interval  int      `json:"interval"`
+	ExpHours  int      `json:"exp_hours"`
+}
+
 // DistributedQueryActionRequest to receive query requests
 type DistributedQueryActionRequest struct {
 	CSRFToken string   `json:"csrftoken"`
@@ -116,6 +133,7 @@ type UsersRequest struct {
 	Admin        bool     `json:"admin"`
 	Service      bool     `json:"service"`
 	Environments []string `json:"environments"`
+	OTP          string   `json:"otp"`
 }
 
 // TagsRequest to receive tag action requests
@@ -154,16 +172,30 @@ type AdminResponse struct {
 	Message string `json:"message"`
 }
 
+// MFAResponse to be returned to login and profile requests related to the second factor
+type MFAResponse struct {
+	Message       string   `json:"message"`
+	MFA           string   `json:"mfa,omitempty"`
+	Secret        string   `json:"secret,omitempty"`
+	URI           string   `json:"uri,omitempty"`
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
 // TokenRequest to receive API token related requests
 type TokenRequest struct {
-	CSRFToken string `json:"csrftoken"`
-	Username  string `json:"username"`
-	ExpHours  int    `json:"exp_hours"`
+	CSRFToken   string `json:"csrftoken"`
+	Username    string `json:"username"`
+	ExpHours    int    `json:"exp_hours"`
+	Name        string `json:"name"`
+	Scope       string `json:"scope"`
+	Environment string `json:"environment"`
+	TokenID     string `json:"token_id"`
 }
 
 // TokenResponse to be returned to API token requests
 type TokenResponse struct {
 	Token        string `json:"token"`
+	TokenID      string `json:"token_id,omitempty"`
 	Expiration   string `json:"expiration"`
 	ExpirationTS string `json:"exp_ts"`
 }
@@ -126,6 +126,9 @@ type QueryTableTemplateData GenericTableTemplateData
 // SavedQueriesTemplateData for passing data to the saved queries
 type SavedQueriesTemplateData GenericTableTemplateData
 
+// RecurringQueriesTemplateData for passing data to the recurring queries
+type RecurringQueriesTemplateData GenericTableTemplateData
+
 // CarvesTableTemplateData for passing data to the carves template
 type CarvesTableTemplateData GenericTableTemplateData
 
@@ -202,6 +205,7 @@ type ProfileTemplateData struct {
 	Environments []environments.TLSEnvironment
 	CurrentUser  users.AdminUser
 	Permissions  users.UserAccess
+	Tokens       []users.UserToken
 	Metadata     TemplateMetadata
 	LeftMetadata AsideLeftMetadata
 }
@@ -26,6 +26,20 @@ const (
 	ResultsLink string = "#result-logs"
 )
 
+// Second factor states returned in login responses
+const (
+	mfaRequired string = "required"
+	mfaEnroll   string = "enroll"
+)
+
+// Helper to get the issuer for the second factor of users
+func (h *HandlersAdmin) mfaIssuer() string {
+	if h.AdminConfig != nil && h.AdminConfig.Host != "" {
+		return users.DefaultTokenIssuer + " " + h.AdminConfig.Host
+	}
+	return users.DefaultTokenIssuer
+}
+
 // Helper to handle admin error responses
 func adminErrorResponse(w http.ResponseWriter, msg string, code int, err error) {
 	log.Err(err).Msgf("%d:%s", code, msg)
@@ -237,3 +251,16 @@ func parseCarveTarget(target string) []CarveTarget {
 	}
 	return targets
 }
+
+// Helper to register a failed login, auditing it and locking the user if there were too many
+func (h *HandlersAdmin) loginFailed(username, reason, ip string) {
+	h.AuditLog.LoginFailed(username, reason, ip)
+	locked, err := h.Users.LoginFailed(username)
+	if err != nil {
+		log.Debug().Err(err).Msgf("error registering failed login for %s", username)
+		return
+	}
+	if locked {
+		h.AuditLog.Lockout(username, ip)
+	}
+}
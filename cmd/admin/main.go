@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/rsa"
 	"crypto/tls"
 	"fmt"
@@ -20,7 +21,9 @@ import (
 	"github.com/jmpsec/osctrl/pkg/carves"
 	"github.com/jmpsec/osctrl/pkg/config"
 	"github.com/jmpsec/osctrl/pkg/environments"
+	osctrlhandlers "github.com/jmpsec/osctrl/pkg/handlers"
 	"github.com/jmpsec/osctrl/pkg/logging"
+	"github.com/jmpsec/osctrl/pkg/migrations"
 	"github.com/jmpsec/osctrl/pkg/nodes"
 	"github.com/jmpsec/osctrl/pkg/queries"
 	"github.com/jmpsec/osctrl/pkg/settings"
@@ -68,6 +71,10 @@ const (
 	defaultRefresh int = 300
 	// Default interval in seconds to expire queries/carves
 	defaultExpiration int = 900
+	// Default interval to check for recurring queries to run
+	defaultRecurring = time.Minute
+	// Default interval to apply the node lifecycle policies
+	defaultLifecycle = time.Hour
 	// Default hours to classify nodes as inactive
 	defaultInactive int = 72
 )
@@ -81,21 +88,23 @@ var (
 
 // Global general variables
 var (
-	err         error
-	db          *backend.DBManager
-	redis       *cache.RedisManager
-	settingsmgr *settings.Settings
-	nodesmgr    *nodes.NodeManager
-	queriesmgr  *queries.Queries
-	carvesmgr   *carves.Carves
-	sessionsmgr *sessions.SessionManager
-	envs        *environments.EnvManager
-	adminUsers  *users.UserManager
-	tagsmgr     *tags.TagManager
-	carvers3    *carves.CarverS3
-	app         *cli.App
-	flags       []cli.Flag
-	flagParams  config.ServiceFlagParams
+	err           error
+	db            *backend.DBManager
+	redis         *cache.RedisManager
+	invalidations cache.InvalidationBus
+	settingsmgr   *settings.Settings
+	nodesmgr      *nodes.NodeManager
+	queriesmgr    *queries.Queries
+	carvesmgr     *carves.Carves
+	sessionsmgr   *sessions.SessionManager
+	envs          *environments.EnvManager
+	adminUsers    *users.UserManager
+	tagsmgr       *tags.TagManager
+	carvers3      *carves.CarverS3
+	carverlocal   *carves.CarverLocal
+	app           *cli.App
+	flags         []cli.Flag
+	flagParams    config.ServiceFlagParams
 	// FIXME this is nasty and should not be a global but here we are
 	osqueryTables []types.OsqueryTable
 	handlersAdmin *handlers.HandlersAdmin
@@ -109,11 +118,18 @@ var (
 	samlData       samlThings
 )
 
+// OIDC variables
+var (
+	oidcConfig JSONConfigurationOIDC
+	oidcMgr    *oidcAuth
+)
+
 // Valid values for auth in configuration
 var validAuth = map[string]bool{
 	config.AuthDB:   true,
 	config.AuthSAML: true,
 	config.AuthJSON: true,
+	config.AuthOIDC: true,
 }
 
 // Valid values for carver in configuration
@@ -189,6 +205,11 @@ func osctrlAdminService() {
 		log.Debug().Msgf("Backend NOT ready! Retrying in %d seconds...\n", flagParams.DBConfigValues.ConnRetry)
 		time.Sleep(time.Duration(flagParams.DBConfigValues.ConnRetry) * time.Second)
 	}
+	// ////////////////////////////// Schema
+	log.Info().Msg("Checking database schema...")
+	if err := migrations.Startup(db.Conn, flagParams.DBSchemaCheck); err != nil {
+		log.Fatal().Msgf("Error with database schema - %v", err)
+	}
 	// ////////////////////////////// Cache
 	log.Info().Msg("Initializing cache...")
 	for {
@@ -219,13 +240,31 @@ func osctrlAdminService() {
 	log.Info().Msg("Initialize queries")
 	queriesmgr = queries.CreateQueries(db.Conn)
 	log.Info().Msg("Initialize carves")
-	carvesmgr = carves.CreateFileCarves(db.Conn, flagParams.ConfigValues.Carver, carvers3)
+	carvesmgr = carves.CreateFileCarves(db.Conn, flagParams.ConfigValues.Carver, carvers3, carverlocal)
+	log.Info().Msg("Initialize cache invalidation")
+	invalidations, err = cache.NewRedisBus(redis)
+	if err != nil {
+		log.Fatal().Msgf("Error initializing cache invalidation - %v", err)
+	}
+	envs.Bus = invalidations
+	settingsmgr.Bus = invalidations
+	nodesmgr.SetInvalidationBus(invalidations)
 	log.Info().Msg("Initialize sessions")
 	sessionsmgr = sessions.CreateSessionManager(db.Conn, authCookieName, flagParams.ConfigValues.SessionKey)
 	log.Info().Msg("Loading service settings")
 	if err := loadingSettings(settingsmgr, flagParams.ConfigValues); err != nil {
 		log.Fatal().Msgf("Error loading settings - %v", err)
 	}
+	// Password policy, lockout and login throttling from settings
+	adminUsers.PasswordPolicy = users.PasswordPolicy{
+		MinLength:  int(settingsmgr.PasswordMinLength()),
+		MinClasses: int(settingsmgr.PasswordMinClasses()),
+	}
+	adminUsers.LockoutPolicy = users.LockoutPolicy{
+		MaxAttempts: int(settingsmgr.LockoutAttempts()),
+		Duration:    time.Duration(settingsmgr.LockoutMinutes()) * time.Minute,
+	}
+	loginThrottle := users.NewLoginThrottle(int(settingsmgr.ThrottleAttempts()), time.Duration(settingsmgr.ThrottleMinutes())*time.Minute)
 	// Start SAML Middleware if we are using SAML
 	if flagParams.ConfigValues.Auth == config.AuthSAML {
 		log.Debug().Msg("SAML enabled for authentication")
@@ -246,6 +285,16 @@ func osctrlAdminService() {
 			log.Fatal().Msgf("Can not initialize SAML Middleware %s", err)
 		}
 	}
+	// Initialize audit log manager
+	if flagParams.AuditLog {
+		log.Info().Msg("Initialize audit log (enabled)")
+	} else {
+		log.Info().Msg("Initialize audit log (disabled)")
+	}
+	auditLog, err = auditlog.CreateAuditLogManager(db.Conn, serviceName, flagParams.AuditLog)
+	if err != nil {
+		log.Fatal().Msgf("Error initializing audit log manager - %v", err)
+	}
 	// FIXME Redis cache - Ticker to cleanup sessions
 	// FIXME splay this?
 	log.Info().Msg("Initialize cleanup sessions")
@@ -267,6 +316,11 @@ func osctrlAdminService() {
 		if _t == 0 {
 			_t = int64(defaultExpiration)
 		}
+		manager := osctrlhandlers.Managers{
+			Nodes: nodesmgr,
+			Envs:  envs,
+			Tags:  tagsmgr,
+		}
 		for {
 			log.Debug().Msg("Cleaning up expired queries/carves")
 			allEnvs, err := envs.All()
@@ -286,10 +340,78 @@ func osctrlAdminService() {
 				if err := queriesmgr.CleanupExpiredCarves(e.ID); err != nil {
 					log.Err(err).Msg("Error cleaning up expired carves")
 				}
+				// Purge carves older than the retention of the environment
+				if e.CarveRetention > 0 {
+					purged, err := carvesmgr.PurgeExpired(e.ID, e.CarveRetention)
+					if err != nil {
+						log.Err(err).Msgf("Error purging carves for %s", e.Name)
+					}
+					for _, c := range purged {
+						auditLog.CarvePurge(c.SessionID, fmt.Sprintf("older than %d days", e.CarveRetention), e.ID)
+					}
+				}
+				// Carves without new blocks are marked as stale and re-issued if enabled
+				if idle := settingsmgr.StaleCarves(); idle > 0 {
+					stale, err := osctrlhandlers.ProcessStaleCarves(carvesmgr, queriesmgr, manager, e.ID, time.Duration(idle)*time.Second, settingsmgr.ReissueCarves(), settingsmgr.InactiveHours(settings.NoEnvironmentID), time.Now())
+					if err != nil {
+						log.Err(err).Msgf("Error processing stale carves for %s", e.Name)
+					}
+					for _, c := range stale {
+						reason := c.StatusReason
+						if c.ReissueQuery != "" {
+							reason += ", re-issued as " + c.ReissueQuery
+						}
+						auditLog.CarveStale(c.SessionID, reason, e.ID)
+					}
+				}
 			}
 			time.Sleep(time.Duration(_t) * time.Second)
 		}
 	}()
+	// Goroutine to create runs for recurring queries
+	log.Info().Msg("Initialize recurring queries")
+	go func() {
+		manager := osctrlhandlers.Managers{
+			Nodes: nodesmgr,
+			Envs:  envs,
+			Tags:  tagsmgr,
+		}
+		for {
+			log.Debug().Msg("Running due recurring queries")
+			inactive := settingsmgr.InactiveHours(settings.NoEnvironmentID)
+			if err := osctrlhandlers.RunDueRecurringQueries(queriesmgr, manager, inactive, time.Now()); err != nil {
+				log.Err(err).Msg("Error running recurring queries")
+			}
+			time.Sleep(defaultRecurring)
+		}
+	}()
+	// Goroutine to archive and purge nodes with the lifecycle policies of each environment
+	log.Info().Msg("Initialize node lifecycle")
+	go func() {
+		for {
+			log.Debug().Msg("Applying node lifecycle policies")
+			allEnvs, err := envs.All()
+			if err != nil {
+				log.Err(err).Msg("Error getting all environments")
+			}
+			for _, e := range allEnvs {
+				if e.NodeArchiveDays == 0 && e.NodePurgeDays == 0 {
+					continue
+				}
+				res, err := nodesmgr.ApplyLifecycle(e.ID, e.NodeArchiveDays, e.NodePurgeDays, false)
+				if err != nil {
+					log.Err(err).Msgf("Error applying node lifecycle for %s", e.Name)
+				}
+				for _, n := range res.Archived {
+					auditLog.NodeAction(serviceName, fmt.Sprintf("archive node %s not seen for %d days", n.UUID, e.NodeArchiveDays), "", e.ID)
+				}
+				for _, n := range res.Purged {
+					auditLog.NodeAction(serviceName, fmt.Sprintf("purge node %s archived for more than %d days", n.UUID, e.NodePurgeDays), "", e.ID)
+				}
+			}
+			time.Sleep(defaultLifecycle)
+		}
+	}()
 	var loggerDBConfig *backend.JSONConfigurationDB
 	// Set the logger configuration file if we have a DB logger
 	if flagParams.ConfigValues.Logger == config.LoggingDB {
@@ -298,15 +420,13 @@ func osctrlAdminService() {
 			loggerDBConfig = &flagParams.DBConfigValues
 		}
 	}
-	// Initialize audit log manager
-	if flagParams.AuditLog {
-		log.Info().Msg("Initialize audit log (enabled)")
-	} else {
-		log.Info().Msg("Initialize audit log (disabled)")
-	}
-	auditLog, err = auditlog.CreateAuditLogManager(db.Conn, serviceName, flagParams.AuditLog)
-	if err != nil {
-		log.Fatal().Msgf("Error initializing audit log manager - %v", err)
+	// Initialize OIDC provider if we are using OIDC
+	if flagParams.ConfigValues.Auth == config.AuthOIDC {
+		log.Debug().Msg("OIDC enabled for authentication")
+		oidcMgr, err = newOIDCAuth(context.Background(), oidcConfig, adminUsers, envs, sessionsmgr, auditLog)
+		if err != nil {
+			log.Fatal().Msgf("Can not initialize OIDC provider %s", err)
+		}
 	}
 	// Initialize Admin handlers before router
 	log.Info().Msg("Initializing handlers")
@@ -333,6 +453,7 @@ func osctrlAdminService() {
 		handlers.WithCarvesFolder(flagParams.CarvedDir),
 		handlers.WithAdminConfig(&flagParams.ConfigValues),
 		handlers.WithAuditLog(auditLog),
+		handlers.WithLoginThrottle(loginThrottle),
 		handlers.WithDBLogger(flagParams.LoggerFile, loggerDBConfig),
 		handlers.WithDebugHTTP(&flagParams.DebugHTTPValues),
 	)
@@ -342,7 +463,7 @@ func osctrlAdminService() {
 	adminMux := http.NewServeMux()
 	// ///////////////////////// UNAUTHENTICATED CONTENT
 	// Admin: login only if local auth is enabled
-	if flagParams.ConfigValues.Auth != config.AuthNone && flagParams.ConfigValues.Auth != config.AuthSAML {
+	if flagParams.ConfigValues.Auth != config.AuthNone && flagParams.ConfigValues.Auth != config.AuthSAML && flagParams.ConfigValues.Auth != config.AuthOIDC {
 		// login
 		adminMux.HandleFunc("GET "+loginPath, handlersAdmin.LoginHandler)
 		adminMux.HandleFunc("POST "+loginPath, handlersAdmin.LoginPOSTHandler)
@@ -431,6 +552,9 @@ func osctrlAdminService() {
 	adminMux.Handle(
 		"POST /query/{env}/run",
 		handlerAuthCheck(http.HandlerFunc(handlersAdmin.QueryRunPOSTHandler), flagParams.ConfigValues.Auth))
+	adminMux.Handle(
+		"POST /query/{env}/preview",
+		handlerAuthCheck(http.HandlerFunc(handlersAdmin.QueryPreviewPOSTHandler), flagParams.ConfigValues.Auth))
 	// Admin: list queries
 	adminMux.Handle(
 		"GET /query/{env}/list",
@@ -439,6 +563,13 @@ func osctrlAdminService() {
 	adminMux.Handle(
 		"GET /query/{env}/saved",
 		handlerAuthCheck(http.HandlerFunc(handlersAdmin.SavedQueriesGETHandler), flagParams.ConfigValues.Auth))
+	// Admin: recurring queries
+	adminMux.Handle(
+		"GET /query/{env}/recurring",
+		handlerAuthCheck(http.HandlerFunc(handlersAdmin.RecurringQueriesGETHandler), flagParams.ConfigValues.Auth))
+	adminMux.Handle(
+		"POST /query/{env}/recurring",
+		handlerAuthCheck(http.HandlerFunc(handlersAdmin.RecurringQueryPOSTHandler), flagParams.ConfigValues.Auth))
 	// Admin: query actions
 	adminMux.Handle(
 		"POST /query/{env}/actions",
@@ -478,6 +609,13 @@ func osctrlAdminService() {
 	adminMux.Handle(
 		"GET /carves/{env}/download/{sessionid}",
 		handlerAuthCheck(http.HandlerFunc(handlersAdmin.CarvesDownloadHandler), flagParams.ConfigValues.Auth))
+	// Admin: carves contents
+	adminMux.Handle(
+		"GET /carves/{env}/files/{sessionid}",
+		handlerAuthCheck(http.HandlerFunc(handlersAdmin.JSONCarveFilesHandler), flagParams.ConfigValues.Auth))
+	adminMux.Handle(
+		"GET /carves/{env}/extract/{sessionid}",
+		handlerAuthCheck(http.HandlerFunc(handlersAdmin.CarvesExtractHandler), flagParams.ConfigValues.Auth))
 	// Admin: nodes configuration
 	adminMux.Handle(
 		"GET /conf/{env}",
@@ -545,6 +683,12 @@ func osctrlAdminService() {
 	adminMux.Handle(
 		"POST /tokens/{username}/refresh",
 		handlerAuthCheck(http.HandlerFunc(handlersAdmin.TokensPOSTHandler), flagParams.ConfigValues.Auth))
+	adminMux.Handle(
+		"POST /tokens/{username}/create",
+		handlerAuthCheck(http.HandlerFunc(handlersAdmin.UserTokenCreatePOSTHandler), flagParams.ConfigValues.Auth))
+	adminMux.Handle(
+		"POST /tokens/{username}/revoke",
+		handlerAuthCheck(http.HandlerFunc(handlersAdmin.UserTokenRevokePOSTHandler), flagParams.ConfigValues.Auth))
 	// Admin: edit profile
 	adminMux.Handle(
 		"GET /profile",
@@ -579,6 +723,14 @@ func osctrlAdminService() {
 			http.Redirect(w, r, samlConfig.LogoutURL, http.StatusFound)
 		})
 	}
+	// OIDC login and callback
+	if flagParams.ConfigValues.Auth == config.AuthOIDC {
+		adminMux.HandleFunc("GET "+loginPath, oidcMgr.LoginHandler)
+		adminMux.HandleFunc("GET "+oidcMgr.CallbackPath, oidcMgr.CallbackHandler)
+		adminMux.HandleFunc("GET "+logoutPath, func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, loginPath, http.StatusFound)
+		})
+	}
 	// Launch HTTP server for admin
 	serviceListener := flagParams.ConfigValues.Listener + ":" + flagParams.ConfigValues.Port
 	if flagParams.TLSServer {
@@ -643,6 +795,13 @@ func cliAction(c *cli.Context) error {
 			return fmt.Errorf("failed to load SAML configuration - %w", err)
 		}
 	}
+	// Load OIDC configuration if this authentication is used in the service config
+	if flagParams.ConfigValues.Auth == config.AuthOIDC {
+		oidcConfig, err = loadOIDC(flagParams.OIDCConfigFile)
+		if err != nil {
+			return fmt.Errorf("failed to load OIDC configuration - %w", err)
+		}
+	}
 	// Load JWT configuration if external JWT JSON config file is used
 	if flagParams.JWTFlag {
 		flagParams.JWTConfigValues, err = loadJWTConfiguration(flagParams.JWTConfigFile)
@@ -666,6 +825,16 @@ func cliAction(c *cli.Context) error {
 			return fmt.Errorf("failed to initiate s3 carver - %w", err)
 		}
 	}
+	if flagParams.ConfigValues.Carver == config.CarverLocal {
+		if flagParams.LocalCarverConfig.Dir != "" {
+			carverlocal, err = carves.CreateCarverLocal(flagParams.LocalCarverConfig)
+		} else {
+			carverlocal, err = carves.CreateCarverLocalFile(flagParams.CarverConfigFile)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to initiate local carver - %w", err)
+		}
+	}
 	return nil
 }
 
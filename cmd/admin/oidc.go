@@ -1,13 +1,365 @@
 package main
 
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gorilla/securecookie"
+	"github.com/jmpsec/osctrl/cmd/admin/sessions"
+	"github.com/jmpsec/osctrl/pkg/auditlog"
+	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/jmpsec/osctrl/pkg/environments"
+	"github.com/jmpsec/osctrl/pkg/users"
+	"github.com/jmpsec/osctrl/pkg/utils"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// Cookie to keep state, nonce and PKCE verifier during the OIDC flow
+	oidcCookieName = "osctrl-admin-oidc"
+	// Maximum time for the user to complete the OIDC flow
+	oidcCookieMaxAge = 600
+	// Default path for the OIDC callback
+	oidcCallbackPath = "/oidc/callback"
+	// Default claims to map OIDC users
+	defOIDCUsernameClaim = "email"
+	defOIDCEmailClaim    = "email"
+	defOIDCNameClaim     = "name"
+	defOIDCGroupsClaim   = "groups"
+)
+
 // JSONConfigurationOIDC to keep all OIDC details for auth
 type JSONConfigurationOIDC struct {
-	IssuerURL         string   `json:"issuerurl"`
-	ClientID          string   `json:"clientid"`
-	ClientSecret      string   `json:"clientsecret"`
-	RedirectURL       string   `json:"redirecturl"`
-	Scope             []string `json:"scope"`
-	Nonce             string   `json:"nonce"`
-	ResponseType      string   `json:"responsetype"`
-	AuthorizationCode string   `json:"authorizationcode"`
+	IssuerURL     string               `json:"issuerurl"`
+	ClientID      string               `json:"clientid"`
+	ClientSecret  string               `json:"clientsecret"`
+	RedirectURL   string               `json:"redirecturl"`
+	Scope         []string             `json:"scope"`
+	UsernameClaim string               `json:"usernameclaim"`
+	EmailClaim    string               `json:"emailclaim"`
+	NameClaim     string               `json:"nameclaim"`
+	GroupsClaim   string               `json:"groupsclaim"`
+	AdminGroups   []string             `json:"admingroups"`
+	JITProvision  bool                 `json:"jitprovision"`
+	GroupMappings []users.GroupMapping `json:"groupmappings"`
+}
+
+// OIDCIdentity to hold the user values mapped from the ID token claims
+type OIDCIdentity struct {
+	Username string
+	Email    string
+	Fullname string
+	Groups   []string
+}
+
+// Values kept in the OIDC cookie between the login and the callback
+type oidcFlowValues struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+// oidcAuth to handle the OIDC authorization code flow with PKCE
+type oidcAuth struct {
+	Config       JSONConfigurationOIDC
+	CallbackPath string
+	Provider     *oidc.Provider
+	Verifier     *oidc.IDTokenVerifier
+	OAuth2       oauth2.Config
+	Users        *users.UserManager
+	Envs         *environments.EnvManager
+	Sessions     *sessions.SessionManager
+	AuditLog     *auditlog.AuditLogManager
+}
+
+// Function to load the configuration file
+func loadOIDC(file string) (JSONConfigurationOIDC, error) {
+	var cfg JSONConfigurationOIDC
+	log.Info().Msgf("Loading %s", file)
+	// Load file and read config
+	viper.SetConfigFile(file)
+	if err := viper.ReadInConfig(); err != nil {
+		return cfg, err
+	}
+	// OIDC values
+	oidcRaw := viper.Sub(config.AuthOIDC)
+	if oidcRaw == nil {
+		return cfg, fmt.Errorf("JSON key %s not found in %s", config.AuthOIDC, file)
+	}
+	if err := oidcRaw.Unmarshal(&cfg); err != nil {
+		return cfg, err
+	}
+	// Verify OIDC configuration
+	if err := verifyOIDC(cfg); err != nil {
+		return cfg, err
+	}
+	// No errors!
+	return cfg, nil
+}
+
+// Function to verify OIDC configuration
+func verifyOIDC(cfg JSONConfigurationOIDC) error {
+	if cfg.IssuerURL == "" {
+		return fmt.Errorf("missing IssuerURL")
+	}
+	if cfg.ClientID == "" {
+		return fmt.Errorf("missing ClientID")
+	}
+	if cfg.RedirectURL == "" {
+		return fmt.Errorf("missing RedirectURL")
+	}
+	if _, err := url.Parse(cfg.RedirectURL); err != nil {
+		return fmt.Errorf("invalid RedirectURL - %w", err)
+	}
+	return nil
+}
+
+// Function to initialize the OIDC provider using discovery from the issuer
+func newOIDCAuth(ctx context.Context, cfg JSONConfigurationOIDC, usersmgr *users.UserManager, envsmgr *environments.EnvManager, sessionsmgr *sessions.SessionManager, auditlog *auditlog.AuditLogManager) (*oidcAuth, error) {
+	provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("error discovering OIDC provider - %w", err)
+	}
+	scopes := []string{oidc.ScopeOpenID}
+	for _, s := range cfg.Scope {
+		if s != oidc.ScopeOpenID {
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 1 {
+		scopes = append(scopes, "profile", "email")
+	}
+	callbackPath := oidcCallbackPath
+	if u, err := url.Parse(cfg.RedirectURL); err == nil && u.Path != "" {
+		callbackPath = u.Path
+	}
+	if cfg.UsernameClaim == "" {
+		cfg.UsernameClaim = defOIDCUsernameClaim
+	}
+	if cfg.EmailClaim == "" {
+		cfg.EmailClaim = defOIDCEmailClaim
+	}
+	if cfg.NameClaim == "" {
+		cfg.NameClaim = defOIDCNameClaim
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = defOIDCGroupsClaim
+	}
+	return &oidcAuth{
+		Config:       cfg,
+		CallbackPath: callbackPath,
+		Provider:     provider,
+		Verifier:     provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		OAuth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       scopes,
+		},
+		Users:    usersmgr,
+		Envs:     envsmgr,
+		Sessions: sessionsmgr,
+		AuditLog: auditlog,
+	}, nil
+}
+
+// LoginHandler - Handler to start the authorization code flow, redirecting to the provider
+func (o *oidcAuth) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	values := oidcFlowValues{
+		State:    utils.GenRandomString(32),
+		Nonce:    utils.GenRandomString(32),
+		Verifier: oauth2.GenerateVerifier(),
+	}
+	encoded, err := securecookie.EncodeMulti(oidcCookieName, values, o.Sessions.Codecs...)
+	if err != nil {
+		log.Err(err).Msg("error encoding OIDC cookie")
+		http.Redirect(w, r, errorPath, http.StatusFound)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookieName,
+		Value:    encoded,
+		Path:     o.CallbackPath,
+		MaxAge:   oidcCookieMaxAge,
+		Secure:   o.Sessions.Options.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	authURL := o.OAuth2.AuthCodeURL(values.State, oidc.Nonce(values.Nonce), oauth2.S256ChallengeOption(values.Verifier))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// CallbackHandler - Handler for the provider redirect, to exchange the code and create the session
+func (o *oidcAuth) CallbackHandler(w http.ResponseWriter, r *http.Request) {
+	// Values from the login are only valid once
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookieName,
+		Path:     o.CallbackPath,
+		MaxAge:   -1,
+		Secure:   o.Sessions.Options.Secure,
+		HttpOnly: true,
+	})
+	if errMsg := r.URL.Query().Get("error"); errMsg != "" {
+		log.Error().Msgf("OIDC provider error: %s - %s", errMsg, r.URL.Query().Get("error_description"))
+		http.Redirect(w, r, forbiddenPath, http.StatusFound)
+		return
+	}
+	cookie, err := r.Cookie(oidcCookieName)
+	if err != nil {
+		log.Err(err).Msg("missing OIDC cookie")
+		http.Redirect(w, r, loginPath, http.StatusFound)
+		return
+	}
+	var values oidcFlowValues
+	if err := securecookie.DecodeMulti(oidcCookieName, cookie.Value, &values, o.Sessions.Codecs...); err != nil {
+		log.Err(err).Msg("error decoding OIDC cookie")
+		http.Redirect(w, r, loginPath, http.StatusFound)
+		return
+	}
+	if r.URL.Query().Get("state") != values.State {
+		log.Error().Msg("OIDC state does not match")
+		http.Redirect(w, r, forbiddenPath, http.StatusFound)
+		return
+	}
+	identity, err := o.exchange(r.Context(), r.URL.Query().Get("code"), values)
+	if err != nil {
+		log.Err(err).Msg("error authenticating with OIDC")
+		http.Redirect(w, r, forbiddenPath, http.StatusFound)
+		return
+	}
+	u, err := o.provision(identity)
+	if err != nil {
+		log.Err(err).Msgf("error provisioning OIDC user %s", identity.Username)
+		http.Redirect(w, r, forbiddenPath, http.StatusFound)
+		return
+	}
+	// Permissions are evaluated on every login, so revoked groups lose access
+	if err := syncGroupAccess(o.Users, o.Envs, o.AuditLog, o.Config.GroupMappings, u.Username, utils.GetIP(r), identity.Groups); err != nil {
+		log.Err(err).Msgf("error mapping groups for user %s", u.Username)
+		http.Redirect(w, r, forbiddenPath, http.StatusFound)
+		return
+	}
+	if _, err := o.Sessions.Save(r, w, u); err != nil {
+		log.Err(err).Msg("session error")
+		http.Redirect(w, r, forbiddenPath, http.StatusFound)
+		return
+	}
+	if o.AuditLog != nil {
+		o.AuditLog.NewLogin(u.Username, utils.GetIP(r))
+	}
+	http.Redirect(w, r, "/dashboard", http.StatusFound)
+}
+
+// Helper to exchange the authorization code and map the verified ID token claims
+func (o *oidcAuth) exchange(ctx context.Context, code string, values oidcFlowValues) (OIDCIdentity, error) {
+	var identity OIDCIdentity
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	token, err := o.OAuth2.Exchange(ctx, code, oauth2.VerifierOption(values.Verifier))
+	if err != nil {
+		return identity, fmt.Errorf("error exchanging code - %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return identity, fmt.Errorf("missing id_token")
+	}
+	idToken, err := o.Verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return identity, fmt.Errorf("error verifying id_token - %w", err)
+	}
+	if idToken.Nonce != values.Nonce {
+		return identity, fmt.Errorf("id_token nonce does not match")
+	}
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return identity, fmt.Errorf("error parsing claims - %w", err)
+	}
+	return o.mapClaims(claims)
+}
+
+// Helper to map the ID token claims to the user values
+func (o *oidcAuth) mapClaims(claims map[string]interface{}) (OIDCIdentity, error) {
+	identity := OIDCIdentity{
+		Username: claimString(claims, o.Config.UsernameClaim),
+		Email:    claimString(claims, o.Config.EmailClaim),
+		Fullname: claimString(claims, o.Config.NameClaim),
+		Groups:   claimStrings(claims, o.Config.GroupsClaim),
+	}
+	if identity.Username == "" {
+		return identity, fmt.Errorf("missing claim %s", o.Config.UsernameClaim)
+	}
+	// Email is not trusted as identity when the provider says it is not verified
+	if verified, ok := claims["email_verified"].(bool); ok && !verified && o.Config.UsernameClaim == "email" {
+		return identity, fmt.Errorf("email %s is not verified", identity.Username)
+	}
+	return identity, nil
+}
+
+// Helper to get or create the user for an identity, keeping its values up to date
+func (o *oidcAuth) provision(identity OIDCIdentity) (users.AdminUser, error) {
+	admin := false
+	for _, g := range identity.Groups {
+		if slices.Contains(o.Config.AdminGroups, g) {
+			admin = true
+			break
+		}
+	}
+	if !o.Users.Exists(identity.Username) {
+		if !o.Config.JITProvision {
+			return users.AdminUser{}, fmt.Errorf("user not found: %s", identity.Username)
+		}
+		u, err := o.Users.NewSSO(identity.Username, identity.Email, identity.Fullname, admin)
+		if err != nil {
+			return u, err
+		}
+		if err := o.Users.Create(u); err != nil {
+			return u, err
+		}
+		return u, nil
+	}
+	if err := o.Users.ChangeEmail(identity.Username, identity.Email); err != nil {
+		return users.AdminUser{}, err
+	}
+	if err := o.Users.ChangeFullname(identity.Username, identity.Fullname); err != nil {
+		return users.AdminUser{}, err
+	}
+	// Admin groups are only authoritative when they are configured
+	if len(o.Config.AdminGroups) > 0 {
+		if err := o.Users.ChangeAdmin(identity.Username, admin); err != nil {
+			return users.AdminUser{}, err
+		}
+	}
+	return o.Users.Get(identity.Username)
+}
+
+// Helper to get a string claim
+func claimString(claims map[string]interface{}, name string) string {
+	if v, ok := claims[name].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// Helper to get a list of strings claim, accepting a single string too
+func claimStrings(claims map[string]interface{}, name string) []string {
+	var res []string
+	switch v := claims[name].(type) {
+	case string:
+		res = append(res, v)
+	case []interface{}:
+		for _, i := range v {
+			if s, ok := i.(string); ok {
+				res = append(res, s)
+			}
+		}
+	}
+	return res
 }
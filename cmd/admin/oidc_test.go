@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jmpsec/osctrl/cmd/admin/sessions"
+	"github.com/jmpsec/osctrl/pkg/auditlog"
+	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/jmpsec/osctrl/pkg/environments"
+	"github.com/jmpsec/osctrl/pkg/migrations"
+	"github.com/jmpsec/osctrl/pkg/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	testOIDCClientID = "osctrl-admin"
+	testOIDCCode     = "test-code"
+)
+
+// mockOIDCProvider is a minimal OIDC provider issuing ID tokens for one authorization code
+type mockOIDCProvider struct {
+	Server    *httptest.Server
+	Key       *rsa.PrivateKey
+	Claims    jwt.MapClaims
+	Nonce     string
+	Challenge string
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	m := &mockOIDCProvider{Key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                m.Server.URL,
+			"authorization_endpoint":                m.Server.URL + "/auth",
+			"token_endpoint":                        m.Server.URL + "/token",
+			"jwks_uri":                              m.Server.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "test",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("code") != testOIDCCode {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != m.Challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		claims := jwt.MapClaims{
+			"iss":   m.Server.URL,
+			"aud":   testOIDCClientID,
+			"sub":   "1234",
+			"nonce": m.Nonce,
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range m.Claims {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     signed,
+		})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Server.Close)
+	return m
+}
+
+func testOIDCAuth(t *testing.T, provider *mockOIDCProvider, cfg JSONConfigurationOIDC) *oidcAuth {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, migrations.Startup(db, false))
+	usersmgr := users.CreateUserManager(db, &config.JSONConfigurationJWT{JWTSecret: "test"})
+	envsmgr := environments.CreateEnvironment(db)
+	for _, name := range []string{"dev", "prod"} {
+		env := envsmgr.Empty(name, "osctrl.example.com")
+		require.NoError(t, envsmgr.Create(&env))
+	}
+	sessionsmgr := sessions.CreateSessionManager(db, authCookieName, "")
+	auditlogmgr, err := auditlog.CreateAuditLogManager(db, "test", true)
+	require.NoError(t, err)
+	cfg.IssuerURL = provider.Server.URL
+	cfg.ClientID = testOIDCClientID
+	cfg.RedirectURL = "https://admin.example.com" + oidcCallbackPath
+	o, err := newOIDCAuth(context.Background(), cfg, usersmgr, envsmgr, sessionsmgr, auditlogmgr)
+	require.NoError(t, err)
+	return o
+}
+
+// Helper to run the full login flow and return the callback response
+func runOIDCLogin(t *testing.T, provider *mockOIDCProvider, o *oidcAuth) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	o.LoginHandler(w, httptest.NewRequest(http.MethodGet, loginPath, nil))
+	require.Equal(t, http.StatusFound, w.Code)
+	authURL, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "S256", authURL.Query().Get("code_challenge_method"))
+	provider.Nonce = authURL.Query().Get("nonce")
+	provider.Challenge = authURL.Query().Get("code_challenge")
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	q := url.Values{}
+	q.Set("code", testOIDCCode)
+	q.Set("state", authURL.Query().Get("state"))
+	r := httptest.NewRequest(http.MethodGet, oidcCallbackPath+"?"+q.Encode(), nil)
+	r.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	o.CallbackHandler(w, r)
+	return w
+}
+
+func TestOIDCLoginJITProvision(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	provider.Claims = jwt.MapClaims{
+		"email":  "alice@example.com",
+		"name":   "Alice",
+		"groups": []string{"osctrl-admins"},
+	}
+	o := testOIDCAuth(t, provider, JSONConfigurationOIDC{
+		AdminGroups:  []string{"osctrl-admins"},
+		JITProvision: true,
+	})
+	w := runOIDCLogin(t, provider, o)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/dashboard", w.Header().Get("Location"))
+
+	u, err := o.Users.Get("alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", u.Fullname)
+	assert.True(t, u.Admin)
+
+	var session *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == authCookieName {
+			session = c
+		}
+	}
+	require.NotNil(t, session)
+	s, err := o.Sessions.Get(session.Value)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", s.Username)
+}
+
+func TestOIDCLoginNoJITProvision(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	provider.Claims = jwt.MapClaims{"email": "bob@example.com"}
+	o := testOIDCAuth(t, provider, JSONConfigurationOIDC{})
+	w := runOIDCLogin(t, provider, o)
+	assert.Equal(t, forbiddenPath, w.Header().Get("Location"))
+	assert.False(t, o.Users.Exists("bob@example.com"))
+}
+
+func TestOIDCLoginGroupMappings(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	provider.Claims = jwt.MapClaims{
+		"email":  "dave@example.com",
+		"groups": []string{"readers", "responders"},
+	}
+	o := testOIDCAuth(t, provider, JSONConfigurationOIDC{
+		JITProvision: true,
+		GroupMappings: []users.GroupMapping{
+			{Group: "readers", Environments: []string{users.AllEnvironments}, Access: users.EnvAccess{User: true}},
+			{Group: "responders", Environments: []string{"prod"}, Access: users.EnvAccess{Query: true}},
+		},
+	})
+	dev, err := o.Envs.Get("dev")
+	require.NoError(t, err)
+	prod, err := o.Envs.Get("prod")
+	require.NoError(t, err)
+
+	runOIDCLogin(t, provider, o)
+	access, err := o.Users.GetAccess("dave@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, users.EnvAccess{User: true}, access[dev.UUID])
+	assert.Equal(t, users.EnvAccess{User: true, Query: true}, access[prod.UUID])
+
+	// Removed groups lose access on the next login
+	provider.Claims["groups"] = []string{"responders"}
+	runOIDCLogin(t, provider, o)
+	access, err = o.Users.GetAccess("dave@example.com")
+	require.NoError(t, err)
+	_, ok := access[dev.UUID]
+	assert.False(t, ok)
+	assert.Equal(t, users.EnvAccess{Query: true}, access[prod.UUID])
+
+	logs, err := o.AuditLog.GetByEnv(dev.ID)
+	require.NoError(t, err)
+	assert.Len(t, logs, 2)
+}
+
+func TestOIDCCallbackInvalidState(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	o := testOIDCAuth(t, provider, JSONConfigurationOIDC{JITProvision: true})
+	w := httptest.NewRecorder()
+	o.LoginHandler(w, httptest.NewRequest(http.MethodGet, loginPath, nil))
+	r := httptest.NewRequest(http.MethodGet, oidcCallbackPath+"?code="+testOIDCCode+"&state=wrong", nil)
+	r.AddCookie(w.Result().Cookies()[0])
+	w = httptest.NewRecorder()
+	o.CallbackHandler(w, r)
+	assert.Equal(t, forbiddenPath, w.Header().Get("Location"))
+}
+
+func TestOIDCMapClaims(t *testing.T) {
+	o := &oidcAuth{Config: JSONConfigurationOIDC{
+		UsernameClaim: "preferred_username",
+		EmailClaim:    "email",
+		NameClaim:     "name",
+		GroupsClaim:   "roles",
+	}}
+	identity, err := o.mapClaims(map[string]interface{}{
+		"preferred_username": "carol",
+		"email":              "carol@example.com",
+		"roles":              "readers",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "carol", identity.Username)
+	assert.Equal(t, []string{"readers"}, identity.Groups)
+
+	_, err = o.mapClaims(map[string]interface{}{"email": "carol@example.com"})
+	assert.Error(t, err)
+}
@@ -11,20 +11,23 @@ import (
 	"github.com/crewjam/saml"
 	"github.com/crewjam/saml/samlsp"
 	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/jmpsec/osctrl/pkg/users"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 )
 
 // JSONConfigurationSAML to keep all SAML details for auth
 type JSONConfigurationSAML struct {
-	CertPath     string `json:"certpath"`
-	KeyPath      string `json:"keypath"`
-	MetaDataURL  string `json:"metadataurl"`
-	RootURL      string `json:"rooturl"`
-	LoginURL     string `json:"loginurl"`
-	LogoutURL    string `json:"logouturl"`
-	JITProvision bool   `json:"jitprovision"`
-	SPInitiated  bool   `json:"spinitiated"`
+	CertPath        string               `json:"certpath"`
+	KeyPath         string               `json:"keypath"`
+	MetaDataURL     string               `json:"metadataurl"`
+	RootURL         string               `json:"rooturl"`
+	LoginURL        string               `json:"loginurl"`
+	LogoutURL       string               `json:"logouturl"`
+	JITProvision    bool                 `json:"jitprovision"`
+	SPInitiated     bool                 `json:"spinitiated"`
+	GroupsAttribute string               `json:"groupsattribute"`
+	GroupMappings   []users.GroupMapping `json:"groupmappings"`
 }
 
 // Structure to keep all SAML related data
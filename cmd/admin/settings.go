@@ -30,6 +30,66 @@ func loadingSettings(mgr *settings.Settings, cfg config.JSONConfigurationService
 			return fmt.Errorf("failed to add %s to configuration: %w", settings.InactiveHours, err)
 		}
 	}
+	// Check if service settings for urgent queries acceleration window is ready
+	if !mgr.IsValue(config.ServiceAdmin, settings.AcceleratedWindow, settings.NoEnvironmentID) {
+		if err := mgr.NewIntegerValue(config.ServiceAdmin, settings.AcceleratedWindow, settings.DefaultAcceleratedWindow, settings.NoEnvironmentID); err != nil {
+			return fmt.Errorf("failed to add %s to configuration: %w", settings.AcceleratedWindow, err)
+		}
+	}
+	// Check if service settings for stale carves is ready
+	if !mgr.IsValue(config.ServiceAdmin, settings.StaleCarves, settings.NoEnvironmentID) {
+		if err := mgr.NewIntegerValue(config.ServiceAdmin, settings.StaleCarves, settings.DefaultStaleCarves, settings.NoEnvironmentID); err != nil {
+			return fmt.Errorf("failed to add %s to configuration: %w", settings.StaleCarves, err)
+		}
+	}
+	// Check if service settings for re-issuing stale carves is ready
+	if !mgr.IsValue(config.ServiceAdmin, settings.ReissueCarves, settings.NoEnvironmentID) {
+		if err := mgr.NewIntegerValue(config.ServiceAdmin, settings.ReissueCarves, 0, settings.NoEnvironmentID); err != nil {
+			return fmt.Errorf("failed to add %s to configuration: %w", settings.ReissueCarves, err)
+		}
+	}
+	// Check if service settings for enforcing MFA is ready
+	if !mgr.IsValue(config.ServiceAdmin, settings.RequireMFA, settings.NoEnvironmentID) {
+		if err := mgr.NewBooleanValue(config.ServiceAdmin, settings.RequireMFA, false, settings.NoEnvironmentID); err != nil {
+			return fmt.Errorf("failed to add %s to settings: %w", settings.RequireMFA, err)
+		}
+	}
+	// Check if service settings for minimum length of passwords is ready
+	if !mgr.IsValue(config.ServiceAdmin, settings.PasswordMinLength, settings.NoEnvironmentID) {
+		if err := mgr.NewIntegerValue(config.ServiceAdmin, settings.PasswordMinLength, settings.DefaultPasswordMinLength, settings.NoEnvironmentID); err != nil {
+			return fmt.Errorf("failed to add %s to configuration: %w", settings.PasswordMinLength, err)
+		}
+	}
+	// Check if service settings for character classes of passwords is ready
+	if !mgr.IsValue(config.ServiceAdmin, settings.PasswordMinClasses, settings.NoEnvironmentID) {
+		if err := mgr.NewIntegerValue(config.ServiceAdmin, settings.PasswordMinClasses, settings.DefaultPasswordMinClasses, settings.NoEnvironmentID); err != nil {
+			return fmt.Errorf("failed to add %s to configuration: %w", settings.PasswordMinClasses, err)
+		}
+	}
+	// Check if service settings for failed logins before lockout is ready
+	if !mgr.IsValue(config.ServiceAdmin, settings.LockoutAttempts, settings.NoEnvironmentID) {
+		if err := mgr.NewIntegerValue(config.ServiceAdmin, settings.LockoutAttempts, settings.DefaultLockoutAttempts, settings.NoEnvironmentID); err != nil {
+			return fmt.Errorf("failed to add %s to configuration: %w", settings.LockoutAttempts, err)
+		}
+	}
+	// Check if service settings for lockout duration is ready
+	if !mgr.IsValue(config.ServiceAdmin, settings.LockoutMinutes, settings.NoEnvironmentID) {
+		if err := mgr.NewIntegerValue(config.ServiceAdmin, settings.LockoutMinutes, settings.DefaultLockoutMinutes, settings.NoEnvironmentID); err != nil {
+			return fmt.Errorf("failed to add %s to configuration: %w", settings.LockoutMinutes, err)
+		}
+	}
+	// Check if service settings for login attempts per IP is ready
+	if !mgr.IsValue(config.ServiceAdmin, settings.ThrottleAttempts, settings.NoEnvironmentID) {
+		if err := mgr.NewIntegerValue(config.ServiceAdmin, settings.ThrottleAttempts, settings.DefaultThrottleAttempts, settings.NoEnvironmentID); err != nil {
+			return fmt.Errorf("failed to add %s to configuration: %w", settings.ThrottleAttempts, err)
+		}
+	}
+	// Check if service settings for login throttling window is ready
+	if !mgr.IsValue(config.ServiceAdmin, settings.ThrottleMinutes, settings.NoEnvironmentID) {
+		if err := mgr.NewIntegerValue(config.ServiceAdmin, settings.ThrottleMinutes, settings.DefaultThrottleMinutes, settings.NoEnvironmentID); err != nil {
+			return fmt.Errorf("failed to add %s to configuration: %w", settings.ThrottleMinutes, err)
+		}
+	}
 	// Check if service settings for display dashboard is ready
 	if !mgr.IsValue(config.ServiceAdmin, settings.NodeDashboard, settings.NoEnvironmentID) {
 		if err := mgr.NewBooleanValue(config.ServiceAdmin, settings.NodeDashboard, false, settings.NoEnvironmentID); err != nil {
@@ -5,12 +5,13 @@ function sendCarve(_url, _redir) {
   var _uuid_list = $("#target_uuids").val();
   var _host_list = $("#target_hosts").val();
   var _tag_list = $("#target_tags").val();
+  var _selector = $("#target_selector").val().trim();
   var _exp_hours = parseInt($("#expiration_hours").val());
   var _repeat = $("#target_repeat").prop("checked") ? 1 : 0;
   var _path = $("#carve").val();
 
   // Making sure targets are specified
-  if (_env_list.length === 0 && _platform_list.length === 0 && _uuid_list.length === 0 && _host_list.length === 0 && _tag_list.length === 0) {
+  if (_env_list.length === 0 && _platform_list.length === 0 && _uuid_list.length === 0 && _host_list.length === 0 && _tag_list.length === 0 && _selector === "") {
     $("#warningModalMessage").text("No targets have been specified");
     $("#warningModal").modal();
     return;
@@ -38,6 +39,7 @@ function sendCarve(_url, _redir) {
     uuid_list: _uuid_list,
     host_list: _host_list,
     tag_list: _tag_list,
+    selector: _selector,
     path: _path,
     exp_hours: _exp_hours,
     repeat: _repeat,
@@ -98,6 +100,41 @@ function downloadCarve(_downloadUrl) {
   location.href = _downloadUrl;
 }
 
+function loadCarveFiles(_filesUrl, _extractUrl, _target) {
+  var _tbody = $("#" + _target + " tbody");
+  $.getJSON(_filesUrl)
+    .done(function (data) {
+      _tbody.empty();
+      $.each(data.data, function (i, entry) {
+        var _row = $("<tr>");
+        _row.append($("<td>").css("font-family", "monospace").text(entry.path));
+        _row.append($("<td>").text(entry.size));
+        _row.append($("<td>").css("font-family", "monospace").text(entry.mode));
+        _row.append($("<td>").text(entry.mtime));
+        _row.append($("<td>").css("font-family", "monospace").text(entry.sha256));
+        var _cell = $("<td>");
+        if (entry.sha256 !== "") {
+          var _link = $("<a>")
+            .attr("href", _extractUrl + "?path=" + encodeURIComponent(entry.path))
+            .attr("title", "Download file")
+            .append($("<i>").addClass("fas fa-download"));
+          _cell.append(_link);
+        }
+        _row.append(_cell);
+        _tbody.append(_row);
+      });
+      $("#" + _target).show();
+    })
+    .fail(function (xhr) {
+      var _message = "Error listing carve";
+      if (xhr.responseJSON && xhr.responseJSON.message) {
+        _message = xhr.responseJSON.message;
+      }
+      $("#warningModalMessage").text(_message);
+      $("#warningModal").modal();
+    });
+}
+
 function refreshCarveDetails() {
   location.reload();
 }
@@ -60,3 +60,48 @@ function sendPostRequest(req_data, req_url, _redir, _modal, _callback) {
     }
   });
 }
+
+function showMFAQRCode(_element, _uri) {
+  $("#" + _element).empty();
+  new QRCode(document.getElementById(_element), {
+    text: _uri,
+    width: 200,
+    height: 200
+  });
+}
+
+function previewTargets(_url) {
+  var _platform_list = $("#target_platform").val();
+  // Check if all platforms have been selected
+  if (_platform_list.includes("all_platforms_99")) {
+    _platform_list = [];
+    $("#target_platform option").each(function () {
+      if ($(this).val() !== "" && $(this).val() !== "all_platforms_99") {
+        _platform_list.push($(this).val());
+      }
+    });
+  }
+  var data = {
+    csrftoken: $("#csrftoken").val(),
+    environment_list: $("#target_env").val(),
+    platform_list: _platform_list,
+    uuid_list: $("#target_uuids").val(),
+    host_list: $("#target_hosts").val(),
+    tag_list: $("#target_tags").val(),
+    selector: $("#target_selector").val().trim(),
+  };
+  sendPostRequest(data, _url, "", false, function (data) {
+    var _preview = $("#target_preview");
+    _preview.empty();
+    _preview.append($("<strong>").text(data.count + " target node(s)"));
+    if (data.count > 0) {
+      var _list = $("<ul>").addClass("list-unstyled small mb-0");
+      $.each(data.nodes, function (i, node) {
+        _list.append(
+          $("<li>").text(node.hostname + " (" + node.uuid + ") - " + node.platform + " " + node.platform_version + " - osquery " + node.osquery_version)
+        );
+      });
+      _preview.append(_list);
+    }
+  });
+}
@@ -1,13 +1,38 @@
 function sendLogin() {
   var _user = $("#login_user").val();
   var _password = $("#login_password").val();
+  var _otp = $("#login_otp").val();
 
   var _url = '/login';
   var data = {
       username: _user,
-      password: _password
+      password: _password,
+      otp: _otp
   };
   sendPostRequest(data, _url, '', false, function(_data){
+    // Second factor is needed, the login is sent again with the code
+    if (_data.mfa) {
+      if (_data.mfa === 'enroll') {
+        showMFAQRCode('login_mfa_qrcode', _data.uri);
+        $("#login_mfa_secret").text(_data.secret);
+        $("#login_mfa_enroll").show();
+      }
+      $("#login_mfa").show();
+      $("#login_otp").focus();
+      return;
+    }
+    // Recovery codes are displayed once after enrolling
+    if (_data.recovery_codes) {
+      $("#login_mfa_enroll").hide();
+      $("#login_mfa").hide();
+      $("#login_button").hide();
+      $("#login_mfa_recovery_codes").text(_data.recovery_codes.join('\n'));
+      $("#login_mfa_recovery").show();
+      $("#login_continue").click(function() {
+        window.location.replace(_data.message);
+      });
+      return;
+    }
     window.location.replace(_data.message);
   });
 }
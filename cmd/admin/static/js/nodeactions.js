@@ -23,6 +23,56 @@ function removeNodes(_uuids) {
   sendPostRequest(data, _url, '/', true);
 }
 
+function confirmApproveNodes(_uuids) {
+  var modal_message = 'Are you sure you want to approve ' + _uuids.length + ' node(s)?';
+  if (_uuids.length === 1) {
+    modal_message = 'Are you sure you want to approve this node?';
+  }
+  $("#confirmModalMessage").text(modal_message);
+  $('#confirm_action').click(function () {
+    $('#confirmModal').modal('hide');
+    approveNodes(_uuids);
+  });
+  $("#confirmModal").modal();
+}
+
+function approveNodes(_uuids) {
+  var _csrftoken = $("#csrftoken").val();
+
+  var _url = '/node/actions';
+  var data = {
+    csrftoken: _csrftoken,
+    uuids: _uuids,
+    action: 'approve'
+  };
+  sendPostRequest(data, _url, window.location, true);
+}
+
+function confirmRevokeCertNodes(_uuids) {
+  var modal_message = 'Are you sure you want to revoke the client certificates of ' + _uuids.length + ' node(s)?';
+  if (_uuids.length === 1) {
+    modal_message = 'Are you sure you want to revoke the client certificates of this node?';
+  }
+  $("#confirmModalMessage").text(modal_message);
+  $('#confirm_action').click(function () {
+    $('#confirmModal').modal('hide');
+    revokeCertNodes(_uuids);
+  });
+  $("#confirmModal").modal();
+}
+
+function revokeCertNodes(_uuids) {
+  var _csrftoken = $("#csrftoken").val();
+
+  var _url = '/node/actions';
+  var data = {
+    csrftoken: _csrftoken,
+    uuids: _uuids,
+    action: 'revoke_cert'
+  };
+  sendPostRequest(data, _url, window.location, true);
+}
+
 function nodesView(environment) {
   window.location.href = '/environment/' + environment + '/active';
 }
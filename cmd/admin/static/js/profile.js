@@ -86,3 +86,104 @@ function refreshUserToken() {
     }
   );
 }
+
+function newNamedToken() {
+  $("#named_token_name").val('');
+  $("#named_token_value").val('');
+  $("#named_token_result").hide();
+  $("#createNamedTokenButton").prop("disabled", false);
+  $("#namedTokenModal").modal();
+}
+
+function createNamedToken() {
+  $("#createNamedTokenButton").prop("disabled", true);
+  var _csrftoken = $("#csrftoken").val();
+  var _username = $("#profile_token_username").val();
+  var data = {
+    csrftoken: _csrftoken,
+    username: _username,
+    name: $("#named_token_name").val(),
+    scope: $("#named_token_scope").val(),
+    environment: $("#named_token_environment").val(),
+    exp_hours: parseInt($("#named_token_hours").val()),
+  };
+  sendPostRequest(
+    data,
+    "/tokens/" + _username + "/create",
+    "",
+    false,
+    function (data) {
+      $("#named_token_value").val(data.token);
+      $("#named_token_result").show();
+    }
+  );
+}
+
+function revokeNamedToken(_tokenid, _name) {
+  var modal_message = 'Are you sure you want to revoke the token ' + _name + '?';
+  $("#confirmModalMessage").text(modal_message);
+  $('#confirm_action').click(function () {
+    $('#confirmModal').modal('hide');
+    var _csrftoken = $("#csrftoken").val();
+    var _username = $("#profile_token_username").val();
+    var data = {
+      csrftoken: _csrftoken,
+      username: _username,
+      token_id: _tokenid,
+    };
+    sendPostRequest(data, "/tokens/" + _username + "/revoke", window.location.pathname, false);
+  });
+  $("#confirmModal").modal();
+}
+
+function mfaEnroll() {
+  var _csrftoken = $("#csrftoken").val();
+  var _username = $("#profile_username").val();
+  var data = {
+    csrftoken: _csrftoken,
+    action: 'mfa_enroll',
+    username: _username,
+  };
+  sendPostRequest(data, window.location.pathname, '', false, function (data) {
+    showMFAQRCode('mfa_qrcode', data.uri);
+    $("#mfa_secret").text(data.secret);
+    $("#mfa_enroll").show();
+    mfaAction('mfa_confirm');
+  });
+}
+
+function mfaAction(_action) {
+  if (_action !== 'mfa_confirm') {
+    $("#mfa_enroll").hide();
+  }
+  $("#mfa_action").val(_action);
+  $("#mfa_otp").val('');
+  $("#mfa_code").show();
+  $("#mfa_recovery").hide();
+  $("#mfaConfirmButton").show();
+  $("#mfaModal").modal();
+}
+
+function mfaConfirm() {
+  var _csrftoken = $("#csrftoken").val();
+  var _username = $("#profile_username").val();
+  var _action = $("#mfa_action").val();
+  var data = {
+    csrftoken: _csrftoken,
+    action: _action,
+    username: _username,
+    otp: $("#mfa_otp").val(),
+  };
+  sendPostRequest(data, window.location.pathname, '', false, function (data) {
+    $("#mfa_action").val('done');
+    if (data.recovery_codes) {
+      $("#mfa_enroll").hide();
+      $("#mfa_code").hide();
+      $("#mfaConfirmButton").hide();
+      $("#mfa_recovery_codes").text(data.recovery_codes.join('\n'));
+      $("#mfa_recovery").show();
+      return;
+    }
+    $("#mfaModal").modal('hide');
+  });
+}
@@ -5,6 +5,7 @@ function sendQuery(_queryUrl, _redir) {
   var _uuid_list = $("#target_uuids").val();
   var _host_list = $("#target_hosts").val();
   var _tag_list = $("#target_tags").val();
+  var _selector = $("#target_selector").val().trim();
   var _exp_hours = parseInt($("#expiration_hours").val());
   var _query_name = $("#save_query_name").val();
   var _query_save = $("#save_query_check").is(":checked") ? true : false;
@@ -12,7 +13,7 @@ function sendQuery(_queryUrl, _redir) {
   var _query = editor.getValue();
 
   // Making sure targets are specified
-  if (_env_list.length === 0 && _platform_list.length === 0 && _uuid_list.length === 0 && _host_list.length === 0 && _tag_list.length === 0) {
+  if (_env_list.length === 0 && _platform_list.length === 0 && _uuid_list.length === 0 && _host_list.length === 0 && _tag_list.length === 0 && _selector === "") {
     $("#warningModalMessage").text("No targets have been specified");
     $("#warningModal").modal();
     return;
@@ -50,6 +51,7 @@ function sendQuery(_queryUrl, _redir) {
     uuid_list: _uuid_list,
     host_list: _host_list,
     tag_list: _tag_list,
+    selector: _selector,
     save: _query_save,
     name: _query_name,
     query: _query,
@@ -117,6 +119,53 @@ function confirmDeleteSavedQueries(_names, _url) {
   $("#confirmModal").modal();
 }
 
+function pauseRecurringQueries(_names, _url) {
+  actionQueries("recurring_pause", _names, _url, window.location.pathname);
+}
+
+function resumeRecurringQueries(_names, _url) {
+  actionQueries("recurring_resume", _names, _url, window.location.pathname);
+}
+
+function confirmDeleteRecurringQueries(_names, _url) {
+  var modal_message = "Are you sure you want to delete " + _names.length + " recurring query(s)?";
+  $("#confirmModalMessage").text(modal_message);
+  $("#confirm_action").click(function () {
+    $("#confirmModal").modal("hide");
+    actionQueries("recurring_delete", _names, _url, window.location.pathname);
+  });
+  $("#confirmModal").modal();
+}
+
+function splitRecurringValues(_id) {
+  return $(_id)
+    .val()
+    .split(",")
+    .map(function (v) {
+      return v.trim();
+    })
+    .filter(function (v) {
+      return v !== "";
+    });
+}
+
+function createRecurringQuery(_url) {
+  var data = {
+    csrftoken: $("#csrftoken").val(),
+    name: $("#recurring_name").val(),
+    query: $("#recurring_query").val(),
+    cron: $("#recurring_cron").val(),
+    interval: parseInt($("#recurring_interval").val()) || 0,
+    exp_hours: parseInt($("#recurring_expiration").val()) || 0,
+    platform_list: splitRecurringValues("#recurring_platforms"),
+    uuid_list: splitRecurringValues("#recurring_uuids"),
+    host_list: splitRecurringValues("#recurring_hosts"),
+    tag_list: splitRecurringValues("#recurring_tags"),
+    selector: $("#recurring_selector").val().trim(),
+  };
+  sendPostRequest(data, _url, window.location.pathname, false);
+}
+
 function queryResultLink(link, query, url) {
   var external_link = '<a href="' + link + '" _target="_blank" rel="noopener noreferrer"><i class="fas fa-external-link-alt"></i></a>';
   return '<span class="query-link"><a href="' + url + '">' + query + "</a> - " + external_link + "</span> ";
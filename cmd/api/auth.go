@@ -7,6 +7,7 @@ import (
 
 	"github.com/jmpsec/osctrl/cmd/api/handlers"
 	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/jmpsec/osctrl/pkg/users"
 	"github.com/jmpsec/osctrl/pkg/utils"
 	"github.com/rs/zerolog/log"
 )
@@ -26,6 +27,47 @@ func extractHeaderToken(r *http.Request) string {
 	return strings.TrimSpace(splitToken[1])
 }
 
+// Helper to get the token scope needed for a request. Reads only need read-only tokens, except
+// downloads of carved files, while changes need the scope of the type of resource, queries and
+// carves, or admin for everything else
+func requestScope(r *http.Request) string {
+	if isCarveDownload(r) {
+		return users.TokenScopeCarve
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return users.TokenScopeRead
+	}
+	switch {
+	case strings.HasPrefix(r.URL.Path, _apiPath(apiQueriesPath)+"/"):
+		return users.TokenScopeQuery
+	case strings.HasPrefix(r.URL.Path, _apiPath(apiCarvesPath)+"/"):
+		return users.TokenScopeCarve
+	}
+	return users.TokenScopeAdmin
+}
+
+// Helper to check if a request downloads carved files, /carves/{env}/extract/... or /carves/{env}/files/...
+func isCarveDownload(r *http.Request) bool {
+	if !strings.HasPrefix(r.URL.Path, _apiPath(apiCarvesPath)+"/") {
+		return false
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, _apiPath(apiCarvesPath)+"/"), "/")
+	return len(parts) > 2 && (parts[1] == "extract" || parts[1] == "files")
+}
+
+// Helper to get the UUID of the environment of a request, if any
+func requestEnvironment(r *http.Request) string {
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		return users.TokenAllEnvironments
+	}
+	env, err := envs.Get(envVar)
+	if err != nil {
+		return envVar
+	}
+	return env.UUID
+}
+
 // Handler to check access to a resource based on the authentication enabled
 func handlerAuthCheck(h http.Handler, auth, jwtSecret string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -49,13 +91,31 @@ func handlerAuthCheck(h http.Handler, auth, jwtSecret string) http.Handler {
 				http.Redirect(w, r, forbiddenPath, http.StatusForbidden)
 				return
 			}
-			// Update metadata for the user
+			// Check that the token has not been revoked and it is allowed for this request
+			userToken, err := apiUsers.ValidateToken(claims, token)
+			if err != nil {
+				log.Info().Msgf("token not valid: %v", err)
+				http.Redirect(w, r, forbiddenPath, http.StatusForbidden)
+				return
+			}
+			if !userToken.Allows(requestScope(r), requestEnvironment(r)) {
+				log.Info().Msgf("token %s from %s not allowed for %s %s", userToken.Name, claims.Username, r.Method, r.URL.Path)
+				http.Redirect(w, r, forbiddenPath, http.StatusForbidden)
+				return
+			}
+			// Update metadata for the user and the token
 			if err := apiUsers.UpdateTokenIPAddress(utils.GetIP(r), claims.Username); err != nil {
 				log.Err(err).Msgf("error updating token for user %s", claims.Username)
 			}
+			if userToken.TokenID != "" {
+				if err := apiUsers.UpdateUserTokenUse(userToken.TokenID, utils.GetIP(r)); err != nil {
+					log.Err(err).Msgf("error updating token %s", userToken.TokenID)
+				}
+			}
 			// Set middleware values
 			s := make(handlers.ContextValue)
 			s["user"] = claims.Username
+			s["token"] = userToken.TokenID
 			ctx := context.WithValue(r.Context(), handlers.ContextKey(contextAPI), s)
 			// Access granted
 			h.ServeHTTP(w, r.WithContext(ctx))
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/jmpsec/osctrl/pkg/environments"
+	"github.com/jmpsec/osctrl/pkg/migrations"
+	"github.com/jmpsec/osctrl/pkg/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestHandlerAuthCheckCarveDownloads(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, migrations.Startup(db, false))
+	envs = environments.CreateEnvironment(db)
+	apiUsers = users.CreateUserManager(db, &config.JSONConfigurationJWT{JWTSecret: "test", HoursToExpire: 1})
+	user, err := apiUsers.New("tester", "password", "", "", false, false)
+	require.NoError(t, err)
+	require.NoError(t, apiUsers.Create(user))
+	readToken, _, err := apiUsers.CreateUserToken("tester", "read", "osctrl", users.TokenAllEnvironments, users.TokenScopeRead, 0)
+	require.NoError(t, err)
+	carveToken, _, err := apiUsers.CreateUserToken("tester", "carve", "osctrl", users.TokenAllEnvironments, users.TokenScopeCarve, 0)
+	require.NoError(t, err)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	mux := http.NewServeMux()
+	for _, path := range []string{"/{env}", "/{env}/files/{sessionid}", "/{env}/extract/{sessionid}"} {
+		mux.Handle("GET "+_apiPath(apiCarvesPath)+path, handlerAuthCheck(ok, config.AuthJWT, "test"))
+	}
+	request := func(path, token string) int {
+		req := httptest.NewRequest(http.MethodGet, _apiPath(apiCarvesPath)+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Read-only tokens can list carves but not download carved files
+	assert.Equal(t, http.StatusOK, request("/dev", readToken))
+	assert.Equal(t, http.StatusForbidden, request("/dev/files/session", readToken))
+	assert.Equal(t, http.StatusForbidden, request("/dev/extract/session", readToken))
+	// Carve tokens can download them
+	assert.Equal(t, http.StatusOK, request("/dev/files/session", carveToken))
+	assert.Equal(t, http.StatusOK, request("/dev/extract/session", carveToken))
+}
@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"path"
 	"strings"
 	"time"
 
@@ -189,6 +192,10 @@ func (h *HandlersApi) CarvesRunHandler(w http.ResponseWriter, r *http.Request) {
 		apiErrorResponse(w, "path can not be empty", http.StatusInternalServerError, nil)
 		return
 	}
+	if err := handlers.ValidateSelector(c.Selector); err != nil {
+		apiErrorResponse(w, "error with selector", http.StatusBadRequest, err)
+		return
+	}
 	expTime := queries.QueryExpiration(c.ExpHours)
 	if c.ExpHours == 0 {
 		expTime = time.Time{}
@@ -215,6 +222,7 @@ func (h *HandlersApi) CarvesRunHandler(w http.ResponseWriter, r *http.Request) {
 		UUIDs:         c.UUIDs,
 		Hosts:         c.Hosts,
 		Tags:          c.Tags,
+		Selector:      c.Selector,
 		EnvID:         env.ID,
 		InactiveHours: h.Settings.InactiveHours(settings.NoEnvironmentID),
 	}
@@ -314,3 +322,169 @@ func (h *HandlersApi) CarvesActionHandler(w http.ResponseWriter, r *http.Request
 	h.AuditLog.CarveAction(ctx[ctxUser], actionVar+" carve "+nameVar, strings.Split(r.RemoteAddr, ":")[0], env.ID)
 	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, types.ApiGenericResponse{Message: msgReturn})
 }
+
+// CarveFilesHandler - GET Handler to return the files inside a carve in JSON
+func (h *HandlersApi) CarveFilesHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		apiErrorResponse(w, "error with environment", http.StatusBadRequest, nil)
+		return
+	}
+	// Get environment
+	env, err := h.Envs.GetByUUID(envVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting environment", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.Users.CheckPermissions(ctx[ctxUser], users.CarveLevel, env.UUID) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	// Extract session
+	sessionVar := r.PathValue("sessionid")
+	if sessionVar == "" {
+		apiErrorResponse(w, "error getting session", http.StatusBadRequest, nil)
+		return
+	}
+	carve, err := h.Carves.GetBySession(sessionVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting carve", http.StatusInternalServerError, err)
+		return
+	}
+	if carve.ID == 0 || carve.EnvironmentID != env.ID {
+		apiErrorResponse(w, "carve not found", http.StatusNotFound, nil)
+		return
+	}
+	// List files in carve
+	entries, err := h.Carves.ListEntries(carve)
+	if err != nil {
+		apiErrorResponse(w, "error listing carve", http.StatusInternalServerError, err)
+		return
+	}
+	// Serialize and serve JSON
+	log.Debug().Msgf("Returned %d files in carve %s", len(entries), sessionVar)
+	h.AuditLog.Visit(ctx[ctxUser], r.URL.Path, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, entries)
+}
+
+// CarveExtractHandler - GET Handler to download one file from inside a carve
+func (h *HandlersApi) CarveExtractHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		apiErrorResponse(w, "error with environment", http.StatusBadRequest, nil)
+		return
+	}
+	// Get environment
+	env, err := h.Envs.GetByUUID(envVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting environment", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.Users.CheckPermissions(ctx[ctxUser], users.CarveLevel, env.UUID) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	// Extract session
+	sessionVar := r.PathValue("sessionid")
+	if sessionVar == "" {
+		apiErrorResponse(w, "error getting session", http.StatusBadRequest, nil)
+		return
+	}
+	// Extract path of the file inside the carve
+	pathVar := r.URL.Query().Get("path")
+	if pathVar == "" {
+		apiErrorResponse(w, "path can not be empty", http.StatusBadRequest, nil)
+		return
+	}
+	carve, err := h.Carves.GetBySession(sessionVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting carve", http.StatusInternalServerError, err)
+		return
+	}
+	if carve.ID == 0 || carve.EnvironmentID != env.ID {
+		apiErrorResponse(w, "carve not found", http.StatusNotFound, nil)
+		return
+	}
+	entry, err := h.Carves.OpenEntry(carve, pathVar)
+	if err != nil {
+		if errors.Is(err, carves.ErrEntryNotFound) {
+			apiErrorResponse(w, "file not found in carve", http.StatusNotFound, err)
+		} else {
+			apiErrorResponse(w, "error reading carve", http.StatusInternalServerError, err)
+		}
+		return
+	}
+	defer entry.Close()
+	// Stream file
+	log.Debug().Msgf("Extracting %s from carve %s", pathVar, sessionVar)
+	h.AuditLog.Visit(ctx[ctxUser], r.URL.Path+"?path="+pathVar, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	utils.HTTPDownload(w, "File Carve Extract", path.Base(entry.Header.Name), entry.Header.Size)
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, entry); err != nil {
+		log.Err(err).Msgf("error extracting %s from carve %s", pathVar, sessionVar)
+	}
+}
+
+// CarveIntegrityHandler - GET Handler to return the hashes and verification status of a carve in JSON
+func (h *HandlersApi) CarveIntegrityHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		apiErrorResponse(w, "error with environment", http.StatusBadRequest, nil)
+		return
+	}
+	// Get environment
+	env, err := h.Envs.GetByUUID(envVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting environment", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.Users.CheckPermissions(ctx[ctxUser], users.CarveLevel, env.UUID) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	// Extract session
+	sessionVar := r.PathValue("sessionid")
+	if sessionVar == "" {
+		apiErrorResponse(w, "error getting session", http.StatusBadRequest, nil)
+		return
+	}
+	carve, err := h.Carves.GetBySession(sessionVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting carve", http.StatusInternalServerError, err)
+		return
+	}
+	if carve.ID == 0 || carve.EnvironmentID != env.ID {
+		apiErrorResponse(w, "carve not found", http.StatusNotFound, nil)
+		return
+	}
+	integrity, err := h.Carves.GetIntegrity(carve)
+	if err != nil {
+		apiErrorResponse(w, "error getting carve integrity", http.StatusInternalServerError, err)
+		return
+	}
+	// Serialize and serve JSON
+	log.Debug().Msgf("Returned integrity of carve %s", sessionVar)
+	h.AuditLog.Visit(ctx[ctxUser], r.URL.Path, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, integrity)
+}
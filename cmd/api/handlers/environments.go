@@ -422,3 +422,109 @@ func (h *HandlersApi) EnvRemoveActionsHandler(w http.ResponseWriter, r *http.Req
 	h.AuditLog.EnvAction(ctx[ctxUser], actionVar+" removal for environment "+env.Name, strings.Split(r.RemoteAddr, ":")[0], env.ID)
 	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, types.ApiGenericResponse{Message: msgReturn})
 }
+
+// Helper to get the environment from the request path and check admin access to it
+func (h *HandlersApi) envAdminAccess(w http.ResponseWriter, r *http.Request) (environments.TLSEnvironment, string, bool) {
+	var env environments.TLSEnvironment
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		apiErrorResponse(w, "error getting environment", http.StatusBadRequest, nil)
+		return env, "", false
+	}
+	// Get environment by UUID
+	env, err := h.Envs.GetByUUID(envVar)
+	if err != nil {
+		if err.Error() == "record not found" {
+			apiErrorResponse(w, "environment not found", http.StatusNotFound, err)
+		} else {
+			apiErrorResponse(w, "error getting environment", http.StatusInternalServerError, err)
+		}
+		return env, "", false
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.Users.CheckPermissions(ctx[ctxUser], users.AdminLevel, env.UUID) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return env, "", false
+	}
+	return env, ctx[ctxUser], true
+}
+
+// EnvEnrollTokensHandler - GET Handler to list the enrollment tokens of an environment
+func (h *HandlersApi) EnvEnrollTokensHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	env, user, ok := h.envAdminAccess(w, r)
+	if !ok {
+		return
+	}
+	tokens, err := h.Envs.GetEnrollTokens(env.ID)
+	if err != nil {
+		apiErrorResponse(w, "error getting enrollment tokens", http.StatusInternalServerError, err)
+		return
+	}
+	// Serialize and serve JSON
+	log.Debug().Msgf("Returned %d enrollment tokens for environment %s", len(tokens), env.Name)
+	h.AuditLog.Visit(user, r.URL.Path, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, tokens)
+}
+
+// EnvEnrollTokenCreateHandler - POST Handler to create an enrollment token for an environment
+func (h *HandlersApi) EnvEnrollTokenCreateHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	env, user, ok := h.envAdminAccess(w, r)
+	if !ok {
+		return
+	}
+	var t types.ApiEnrollTokenRequest
+	// Parse request JSON body
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		apiErrorResponse(w, "error parsing POST body", http.StatusInternalServerError, err)
+		return
+	}
+	token, enrollToken, err := h.Envs.CreateEnrollToken(env.ID, t.Hostname, t.Serial, user, t.MaxUses, t.ExpHours)
+	if err != nil {
+		apiErrorResponse(w, "error creating enrollment token", http.StatusBadRequest, err)
+		return
+	}
+	// Serialize and serve JSON
+	log.Debug().Msgf("Created enrollment token %s for environment %s", enrollToken.TokenID, env.Name)
+	h.AuditLog.EnvAction(user, "create enrollment token "+enrollToken.TokenID+" for environment "+env.Name, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, types.ApiEnrollTokenResponse{
+		Token:     token,
+		TokenID:   enrollToken.TokenID,
+		ExpiresAt: enrollToken.ExpiresAt,
+	})
+}
+
+// EnvEnrollTokenRevokeHandler - POST Handler to revoke an enrollment token of an environment
+func (h *HandlersApi) EnvEnrollTokenRevokeHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract token ID
+	tokenVar := r.PathValue("tokenid")
+	if tokenVar == "" {
+		apiErrorResponse(w, "error with token", http.StatusBadRequest, nil)
+		return
+	}
+	env, user, ok := h.envAdminAccess(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Envs.RevokeEnrollToken(env.ID, tokenVar); err != nil {
+		apiErrorResponse(w, "error revoking enrollment token", http.StatusBadRequest, err)
+		return
+	}
+	// Serialize and serve JSON
+	log.Debug().Msgf("Revoked enrollment token %s for environment %s", tokenVar, env.Name)
+	h.AuditLog.EnvAction(user, "revoke enrollment token "+tokenVar+" for environment "+env.Name, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, types.ApiGenericResponse{Message: "enrollment token revoked"})
+}
@@ -33,6 +33,7 @@ type HandlersApi struct {
 	ServiceVersion  string
 	ServiceName     string
 	AuditLog        *auditlog.AuditLogManager
+	LoginThrottle   *users.LoginThrottle
 	ApiConfig       *config.JSONConfigurationService
 	DebugHTTP       *zerolog.Logger
 	DebugHTTPConfig *config.DebugHTTPConfiguration
@@ -112,6 +113,12 @@ func WithAuditLog(auditLog *auditlog.AuditLogManager) HandlersOption {
 	}
 }
 
+func WithLoginThrottle(throttle *users.LoginThrottle) HandlersOption {
+	return func(h *HandlersApi) {
+		h.LoginThrottle = throttle
+	}
+}
+
 func WithDebugHTTP(cfg *config.DebugHTTPConfiguration) HandlersOption {
 	return func(h *HandlersApi) {
 		h.DebugHTTPConfig = cfg
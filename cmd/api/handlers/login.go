@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/jmpsec/osctrl/pkg/types"
 	"github.com/jmpsec/osctrl/pkg/users"
 	"github.com/jmpsec/osctrl/pkg/utils"
+	"github.com/rs/zerolog/log"
 )
 
 // LoginHandler - POST Handler for API login request
@@ -34,12 +36,41 @@ func (h *HandlersApi) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		apiErrorResponse(w, "error parsing POST body", http.StatusInternalServerError, err)
 		return
 	}
+	ip := strings.Split(r.RemoteAddr, ":")[0]
+	// Check login attempts from this IP and lockout of the user
+	if !h.LoginThrottle.Allow(ip) {
+		h.AuditLog.LoginFailed(l.Username, "too many login attempts from "+ip, ip)
+		apiErrorResponse(w, "too many login attempts", http.StatusTooManyRequests, nil)
+		return
+	}
+	if h.Users.IsLocked(l.Username) {
+		h.AuditLog.LoginFailed(l.Username, "user is locked", ip)
+		apiErrorResponse(w, "user is temporarily locked", http.StatusForbidden, nil)
+		return
+	}
 	// Check credentials
 	access, user := h.Users.CheckLoginCredentials(l.Username, l.Password)
 	if !access {
+		h.loginFailed(l.Username, "invalid credentials", ip)
 		apiErrorResponse(w, "invalid credentials", http.StatusForbidden, err)
 		return
 	}
+	// Check second factor, enrollment is only possible in the admin
+	if users.MFANeeded(user, h.Settings.RequireMFA()) {
+		if !user.MFAEnabled {
+			apiErrorResponse(w, users.ErrMFAEnrollment.Error(), http.StatusForbidden, fmt.Errorf("user %s has not enrolled", l.Username))
+			return
+		}
+		if l.OTP == "" {
+			apiErrorResponse(w, users.ErrMFARequired.Error(), http.StatusUnauthorized, nil)
+			return
+		}
+		if !h.Users.CheckMFA(l.Username, l.OTP) {
+			h.loginFailed(l.Username, users.ErrMFAInvalid.Error(), ip)
+			apiErrorResponse(w, users.ErrMFAInvalid.Error(), http.StatusForbidden, fmt.Errorf("invalid second factor for user %s", l.Username))
+			return
+		}
+	}
 	// Check if user has access to this environment
 	if !h.Users.CheckPermissions(l.Username, users.AdminLevel, env.UUID) {
 		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use %s by user %s", h.ServiceName, l.Username))
@@ -58,7 +89,10 @@ func (h *HandlersApi) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		user.APIToken = token
 	}
-	h.AuditLog.NewLogin(l.Username, r.RemoteAddr)
+	if err := h.Users.LoginSucceeded(l.Username); err != nil {
+		log.Err(err).Msgf("error resetting failed logins for %s", l.Username)
+	}
+	h.AuditLog.NewLogin(l.Username, ip)
 	// Serialize and serve JSON
 	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, types.ApiLoginResponse{Token: user.APIToken})
 }
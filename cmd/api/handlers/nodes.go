@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/jmpsec/osctrl/pkg/handlers"
 	"github.com/jmpsec/osctrl/pkg/nodes"
 	"github.com/jmpsec/osctrl/pkg/types"
 	"github.com/jmpsec/osctrl/pkg/users"
@@ -274,6 +275,288 @@ func (h *HandlersApi) TagNodeHandler(w http.ResponseWriter, r *http.Request) {
 	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, types.ApiGenericResponse{Message: "node tagged"})
 }
 
+// DuplicateNodesHandler - GET Handler to list suspected duplicated nodes
+func (h *HandlersApi) DuplicateNodesHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		apiErrorResponse(w, "error with environment", http.StatusBadRequest, nil)
+		return
+	}
+	// Get environment
+	env, err := h.Envs.GetByUUID(envVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting environment", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.Users.CheckPermissions(ctx[ctxUser], users.AdminLevel, env.UUID) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	groups, err := h.Nodes.GetDuplicates(env.ID)
+	if err != nil {
+		apiErrorResponse(w, "error getting duplicated nodes", http.StatusInternalServerError, err)
+		return
+	}
+	log.Debug().Msgf("Returned %d groups of duplicated nodes", len(groups))
+	h.AuditLog.Visit(ctx[ctxUser], r.URL.Path, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	// Serialize and serve JSON
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, groups)
+}
+
+// MergeNodesHandler - POST Handler to merge duplicated nodes into one node
+func (h *HandlersApi) MergeNodesHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		apiErrorResponse(w, "error with environment", http.StatusBadRequest, nil)
+		return
+	}
+	// Get environment
+	env, err := h.Envs.GetByUUID(envVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting environment", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.Users.CheckPermissions(ctx[ctxUser], users.AdminLevel, env.UUID) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	var m types.ApiNodeMergeRequest
+	// Parse request JSON body
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		apiErrorResponse(w, "error parsing POST body", http.StatusInternalServerError, err)
+		return
+	}
+	if m.Target == 0 || len(m.Duplicates) == 0 {
+		apiErrorResponse(w, "target and duplicates are required", http.StatusBadRequest, nil)
+		return
+	}
+	nds, err := h.Nodes.GetByIDs(append([]uint{m.Target}, m.Duplicates...))
+	if err != nil {
+		apiErrorResponse(w, "error getting nodes", http.StatusInternalServerError, err)
+		return
+	}
+	var target nodes.OsqueryNode
+	var duplicates []nodes.OsqueryNode
+	for _, n := range nds {
+		if n.EnvironmentID != env.ID {
+			apiErrorResponse(w, "node not found", http.StatusNotFound, fmt.Errorf("node %d not in environment %s", n.ID, env.Name))
+			return
+		}
+		if n.ID == m.Target {
+			target = n
+		} else {
+			duplicates = append(duplicates, n)
+		}
+	}
+	if target.ID == 0 || len(duplicates) != len(m.Duplicates) {
+		apiErrorResponse(w, "node not found", http.StatusNotFound, nil)
+		return
+	}
+	manager := handlers.Managers{Envs: h.Envs, Nodes: h.Nodes, Tags: h.Tags}
+	if err := handlers.MergeNodes(manager, h.Queries, h.Carves, target, duplicates); err != nil {
+		apiErrorResponse(w, "error merging nodes", http.StatusInternalServerError, err)
+		return
+	}
+	for _, d := range duplicates {
+		log.Debug().Msgf("Merged node %s into %s", d.UUID, target.UUID)
+		h.AuditLog.NodeAction(ctx[ctxUser], "merged node "+d.UUID+" into "+target.UUID, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	}
+	// Serialize and serve JSON
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, types.ApiGenericResponse{Message: "nodes merged"})
+}
+
+// PendingNodesHandler - GET Handler to list the nodes waiting for approval
+func (h *HandlersApi) PendingNodesHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		apiErrorResponse(w, "error with environment", http.StatusBadRequest, nil)
+		return
+	}
+	// Get environment
+	env, err := h.Envs.GetByUUID(envVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting environment", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.Users.CheckPermissions(ctx[ctxUser], users.AdminLevel, env.UUID) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	pending, err := h.Nodes.GetPending(env.ID)
+	if err != nil {
+		apiErrorResponse(w, "error getting pending nodes", http.StatusInternalServerError, err)
+		return
+	}
+	log.Debug().Msgf("Returned %d nodes pending approval", len(pending))
+	h.AuditLog.Visit(ctx[ctxUser], r.URL.Path, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	// Serialize and serve JSON
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, pending)
+}
+
+// ApproveNodesHandler - POST Handler to approve nodes waiting for approval
+func (h *HandlersApi) ApproveNodesHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		apiErrorResponse(w, "error with environment", http.StatusBadRequest, nil)
+		return
+	}
+	// Get environment
+	env, err := h.Envs.GetByUUID(envVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting environment", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.Users.CheckPermissions(ctx[ctxUser], users.AdminLevel, env.UUID) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	var a types.ApiNodeApproveRequest
+	// Parse request JSON body
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+		apiErrorResponse(w, "error parsing POST body", http.StatusInternalServerError, err)
+		return
+	}
+	if len(a.UUIDs) == 0 {
+		apiErrorResponse(w, "uuids are required", http.StatusBadRequest, nil)
+		return
+	}
+	for _, uuid := range a.UUIDs {
+		node, err := h.Nodes.GetByUUIDEnv(uuid, env.ID)
+		if err != nil {
+			apiErrorResponse(w, "node not found", http.StatusNotFound, err)
+			return
+		}
+		if err := h.Nodes.Approve(node); err != nil {
+			apiErrorResponse(w, "error approving node", http.StatusBadRequest, err)
+			return
+		}
+		log.Debug().Msgf("Approved node %s", node.UUID)
+		h.AuditLog.NodeAction(ctx[ctxUser], "approved node "+node.UUID, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	}
+	// Serialize and serve JSON
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, types.ApiGenericResponse{Message: "nodes approved"})
+}
+
+// RevokeNodeCertHandler - POST Handler to revoke the client certificates of a node
+func (h *HandlersApi) RevokeNodeCertHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		apiErrorResponse(w, "error with environment", http.StatusBadRequest, nil)
+		return
+	}
+	// Get environment
+	env, err := h.Envs.GetByUUID(envVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting environment", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.Users.CheckPermissions(ctx[ctxUser], users.AdminLevel, env.UUID) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	var n types.ApiNodeGenericRequest
+	// Parse request JSON body
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		apiErrorResponse(w, "error parsing POST body", http.StatusInternalServerError, err)
+		return
+	}
+	node, err := h.Nodes.GetByUUIDEnv(n.UUID, env.ID)
+	if err != nil {
+		apiErrorResponse(w, "node not found", http.StatusNotFound, err)
+		return
+	}
+	manager := handlers.Managers{Envs: h.Envs, Nodes: h.Nodes}
+	revoked, err := handlers.RevokeNodeCert(manager, node, ctx[ctxUser])
+	if err != nil {
+		apiErrorResponse(w, "error revoking certificates", http.StatusInternalServerError, err)
+		return
+	}
+	log.Debug().Msgf("Revoked %d certificates of node %s", revoked, node.UUID)
+	h.AuditLog.NodeAction(ctx[ctxUser], "revoked certificates of node "+node.UUID, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	// Serialize and serve JSON
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, types.ApiGenericResponse{Message: fmt.Sprintf("%d certificates revoked", revoked)})
+}
+
+// ClearNodeCertHandler - POST Handler to clear the revoked client certificates of a node, so it can get new ones
+func (h *HandlersApi) ClearNodeCertHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		apiErrorResponse(w, "error with environment", http.StatusBadRequest, nil)
+		return
+	}
+	// Get environment
+	env, err := h.Envs.GetByUUID(envVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting environment", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.Users.CheckPermissions(ctx[ctxUser], users.AdminLevel, env.UUID) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	var n types.ApiNodeGenericRequest
+	// Parse request JSON body
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		apiErrorResponse(w, "error parsing POST body", http.StatusInternalServerError, err)
+		return
+	}
+	if n.UUID == "" {
+		apiErrorResponse(w, "uuid is required", http.StatusBadRequest, nil)
+		return
+	}
+	cleared, err := h.Envs.ClearRevokedClientCerts(env.ID, n.UUID)
+	if err != nil {
+		apiErrorResponse(w, "error clearing certificates", http.StatusInternalServerError, err)
+		return
+	}
+	log.Debug().Msgf("Cleared %d revoked certificates of node %s", cleared, n.UUID)
+	h.AuditLog.NodeAction(ctx[ctxUser], "cleared revoked certificates of node "+n.UUID, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	// Serialize and serve JSON
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, types.ApiGenericResponse{Message: fmt.Sprintf("%d certificates cleared", cleared)})
+}
+
 // LookupNodeHandler - POST Handler to lookup a node by identifier
 func (h *HandlersApi) LookupNodeHandler(w http.ResponseWriter, r *http.Request) {
 	// Debug HTTP if enabled
@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/jmpsec/osctrl/pkg/handlers"
+	"github.com/jmpsec/osctrl/pkg/logging"
 	"github.com/jmpsec/osctrl/pkg/queries"
 	"github.com/jmpsec/osctrl/pkg/settings"
 	"github.com/jmpsec/osctrl/pkg/types"
@@ -111,6 +113,10 @@ func (h *HandlersApi) QueriesRunHandler(w http.ResponseWriter, r *http.Request)
 		apiErrorResponse(w, "query can not be empty", http.StatusBadRequest, nil)
 		return
 	}
+	if err := handlers.ValidateSelector(q.Selector); err != nil {
+		apiErrorResponse(w, "error with selector", http.StatusBadRequest, err)
+		return
+	}
 	expTime := queries.QueryExpiration(q.ExpHours)
 	if q.ExpHours == 0 {
 		expTime = time.Time{}
@@ -126,6 +132,10 @@ func (h *HandlersApi) QueriesRunHandler(w http.ResponseWriter, r *http.Request)
 		Type:          queries.StandardQueryType,
 		EnvironmentID: env.ID,
 	}
+	// Urgent queries make target nodes poll faster for a while
+	if q.Urgent {
+		newQuery.SetUrgent(time.Duration(h.Settings.AcceleratedWindow(settings.NoEnvironmentID)) * time.Second)
+	}
 	if err := h.Queries.Create(&newQuery); err != nil {
 		apiErrorResponse(w, "error creating query", http.StatusInternalServerError, err)
 		return
@@ -137,6 +147,7 @@ func (h *HandlersApi) QueriesRunHandler(w http.ResponseWriter, r *http.Request)
 		UUIDs:         q.UUIDs,
 		Hosts:         q.Hosts,
 		Tags:          q.Tags,
+		Selector:      q.Selector,
 		EnvID:         env.ID,
 		InactiveHours: h.Settings.InactiveHours(settings.NoEnvironmentID),
 	}
@@ -169,6 +180,67 @@ func (h *HandlersApi) QueriesRunHandler(w http.ResponseWriter, r *http.Request)
 	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, types.ApiQueriesResponse{Name: newQuery.Name})
 }
 
+// QueryPreviewHandler - POST Handler to return the nodes that would be targeted by a query or carve
+func (h *HandlersApi) QueryPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		apiErrorResponse(w, "error with environment", http.StatusBadRequest, nil)
+		return
+	}
+	// Get environment
+	env, err := h.Envs.GetByUUID(envVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting environment", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.Users.CheckPermissions(ctx[ctxUser], users.QueryLevel, env.UUID) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	var q types.ApiDistributedQueryRequest
+	// Parse request JSON body
+	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+		apiErrorResponse(w, "error parsing POST body", http.StatusInternalServerError, err)
+		return
+	}
+	if err := handlers.ValidateSelector(q.Selector); err != nil {
+		apiErrorResponse(w, "error with selector", http.StatusBadRequest, err)
+		return
+	}
+	// Prepare data for the handler code
+	data := handlers.ProcessingQuery{
+		Envs:          q.Environments,
+		Platforms:     q.Platforms,
+		UUIDs:         q.UUIDs,
+		Hosts:         q.Hosts,
+		Tags:          q.Tags,
+		Selector:      q.Selector,
+		EnvID:         env.ID,
+		InactiveHours: h.Settings.InactiveHours(settings.NoEnvironmentID),
+	}
+	manager := handlers.Managers{
+		Nodes: h.Nodes,
+		Envs:  h.Envs,
+		Tags:  h.Tags,
+	}
+	preview, err := handlers.PreviewQueryCarve(data, manager)
+	if err != nil {
+		apiErrorResponse(w, "error getting target nodes", http.StatusInternalServerError, err)
+		return
+	}
+	// Serialize and serve JSON
+	log.Debug().Msgf("Returned preview with %d target nodes", preview.Count)
+	h.AuditLog.Visit(ctx[ctxUser], r.URL.Path, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, preview)
+}
+
 // QueriesActionHandler - POST Handler to delete/expire a query
 func (h *HandlersApi) QueriesActionHandler(w http.ResponseWriter, r *http.Request) {
 	// Debug HTTP if enabled
@@ -359,19 +431,173 @@ func (h *HandlersApi) QueryResultsHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 	// Get query by name
-	// TODO this is a temporary solution, we need to refactor this and take into consideration the
-	// logger for TLS and whether if the results are stored in the DB or a different DB
-	queryLogs, err := postgresQueryLogs(h.DB, name)
+	query, err := h.Queries.Get(name, env.ID)
 	if err != nil {
-		if err.Error() == "record not found" {
-			apiErrorResponse(w, "query not found", http.StatusNotFound, err)
-		} else {
-			apiErrorResponse(w, "error getting query", http.StatusInternalServerError, err)
+		apiErrorResponse(w, "error getting query", http.StatusInternalServerError, err)
+		return
+	}
+	if query.ID == 0 {
+		apiErrorResponse(w, "query not found", http.StatusNotFound, nil)
+		return
+	}
+	// Results by node UUID, unless a page of results by target node is requested
+	if paged, _ := strconv.ParseBool(r.URL.Query().Get("page")); !paged {
+		// TODO this is a temporary solution, we need to refactor this and take into consideration the
+		// logger for TLS and whether if the results are stored in the DB or a different DB
+		queryLogs, err := postgresQueryLogs(h.DB, name)
+		if err != nil {
+			apiErrorResponse(w, "error getting query results", http.StatusInternalServerError, err)
+			return
 		}
+		log.Debug().Msgf("Returned query results for %s", name)
+		h.AuditLog.Visit(ctx[ctxUser], r.URL.Path, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+		utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, queryLogs)
+		return
+	}
+	// Extract pagination and filters
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	filter := queries.NodeQueryFilter{
+		UUID:     r.URL.Query().Get("uuid"),
+		Hostname: r.URL.Query().Get("hostname"),
+		Status:   r.URL.Query().Get("status"),
+	}
+	if filter.Status != "" && !queries.NodeQueryStatuses[filter.Status] {
+		apiErrorResponse(w, "invalid status", http.StatusBadRequest, nil)
+		return
+	}
+	results, err := logging.QueryResultsPage(h.DB, h.Queries, query, filter, offset, limit)
+	if err != nil {
+		apiErrorResponse(w, "error getting query results", http.StatusInternalServerError, err)
 		return
 	}
 	// Serialize and serve JSON
 	log.Debug().Msgf("Returned query results for %s", name)
 	h.AuditLog.Visit(ctx[ctxUser], r.URL.Path, strings.Split(r.RemoteAddr, ":")[0], env.ID)
-	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, queryLogs)
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, results)
+}
+
+// QueryStatusHandler - GET Handler to return the status of a single query in each target node in JSON
+func (h *HandlersApi) QueryStatusHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract name
+	name := r.PathValue("name")
+	if name == "" {
+		apiErrorResponse(w, "error getting name", http.StatusBadRequest, nil)
+		return
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		apiErrorResponse(w, "error with environment", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get environment
+	env, err := h.Envs.GetByUUID(envVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting environment", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.Users.CheckPermissions(ctx[ctxUser], users.QueryLevel, env.UUID) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	// Get query by name
+	query, err := h.Queries.Get(name, env.ID)
+	if err != nil {
+		apiErrorResponse(w, "error getting query", http.StatusInternalServerError, err)
+		return
+	}
+	if query.ID == 0 {
+		apiErrorResponse(w, "query not found", http.StatusNotFound, nil)
+		return
+	}
+	// Extract pagination and filters
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	filter := queries.NodeQueryFilter{
+		UUID:     r.URL.Query().Get("uuid"),
+		Hostname: r.URL.Query().Get("hostname"),
+		Status:   r.URL.Query().Get("status"),
+	}
+	if filter.Status != "" && !queries.NodeQueryStatuses[filter.Status] {
+		apiErrorResponse(w, "invalid status", http.StatusBadRequest, nil)
+		return
+	}
+	status, err := h.Queries.GetNodeQueryStatus(query, filter, offset, limit)
+	if err != nil {
+		apiErrorResponse(w, "error getting query status", http.StatusInternalServerError, err)
+		return
+	}
+	// Serialize and serve JSON
+	log.Debug().Msgf("Returned query status for %s", name)
+	h.AuditLog.Visit(ctx[ctxUser], r.URL.Path, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, status)
+}
+
+// QueryExportHandler - GET Handler to stream the results of a single query as CSV, NDJSON or Parquet
+func (h *HandlersApi) QueryExportHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract name
+	name := r.PathValue("name")
+	if name == "" {
+		apiErrorResponse(w, "error getting name", http.StatusBadRequest, nil)
+		return
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		apiErrorResponse(w, "error with environment", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get environment
+	env, err := h.Envs.GetByUUID(envVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting environment", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.Users.CheckPermissions(ctx[ctxUser], users.QueryLevel, env.UUID) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	// Extract format, CSV by default
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = logging.ExportCSV
+	}
+	contentType, ok := logging.ExportContentTypes[format]
+	if !ok {
+		apiErrorResponse(w, "invalid format", http.StatusBadRequest, nil)
+		return
+	}
+	// Get query by name
+	query, err := h.Queries.Get(name, env.ID)
+	if err != nil {
+		apiErrorResponse(w, "error getting query", http.StatusInternalServerError, err)
+		return
+	}
+	if query.ID == 0 {
+		apiErrorResponse(w, "query not found", http.StatusNotFound, nil)
+		return
+	}
+	h.AuditLog.Visit(ctx[ctxUser], r.URL.Path, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	// Stream results, once started errors can not change the response code
+	w.Header().Set(utils.ContentType, contentType)
+	w.Header().Set(utils.ContentDisposition, "attachment; filename="+query.Name+"."+format)
+	w.WriteHeader(http.StatusOK)
+	if err := logging.ExportQueryResults(w, format, h.DB, h.Nodes, query.Name); err != nil {
+		log.Err(err).Msgf("error exporting results for %s", query.Name)
+		return
+	}
+	log.Debug().Msgf("Exported query results for %s as %s", query.Name, format)
 }
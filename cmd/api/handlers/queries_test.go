@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jmpsec/osctrl/pkg/auditlog"
+	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/jmpsec/osctrl/pkg/environments"
+	"github.com/jmpsec/osctrl/pkg/migrations"
+	"github.com/jmpsec/osctrl/pkg/nodes"
+	"github.com/jmpsec/osctrl/pkg/queries"
+	"github.com/jmpsec/osctrl/pkg/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestQueryStatusHandler(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, migrations.Startup(db, false))
+	auditLog, err := auditlog.CreateAuditLogManager(db, "osctrl-api", false)
+	require.NoError(t, err)
+	h := CreateHandlersApi(
+		WithDB(db),
+		WithEnvs(environments.CreateEnvironment(db)),
+		WithUsers(users.CreateUserManager(db, &config.JSONConfigurationJWT{JWTSecret: "test", HoursToExpire: 1})),
+		WithQueries(queries.CreateQueries(db)),
+		WithAuditLog(auditLog),
+		WithDebugHTTP(&config.DebugHTTPConfiguration{Enabled: false}),
+	)
+	env := h.Envs.Empty("dev", "localhost")
+	require.NoError(t, h.Envs.Create(&env))
+	user, err := h.Users.New("tester", "password", "", "", true, false)
+	require.NoError(t, err)
+	require.NoError(t, h.Users.Create(user))
+	for _, n := range []nodes.OsqueryNode{
+		{UUID: "AAAA-1111", Hostname: "alpha", EnvironmentID: env.ID},
+		{UUID: "BBBB-2222", Hostname: "bravo", EnvironmentID: env.ID},
+	} {
+		require.NoError(t, db.Create(&n).Error)
+	}
+	query := queries.DistributedQuery{
+		Name:          "test_query",
+		Query:         "SELECT * FROM osquery_info;",
+		Expected:      2,
+		EnvironmentID: env.ID,
+		Expiration:    time.Now().Add(time.Hour),
+	}
+	require.NoError(t, h.Queries.Create(&query))
+	require.NoError(t, h.Queries.CreateNodeQueries([]uint{1, 2}, query.ID))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/queries/{env}/status/{name}", h.QueryStatusHandler)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/queries/"+env.UUID+"/status/test_query", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ContextKey(contextAPI), ContextValue{ctxUser: "tester"}))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// Without query parameters the node query status is returned
+	var status queries.NodeQueryStatusList
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &status))
+	assert.Equal(t, "test_query", status.Name)
+	assert.Equal(t, 2, status.Expected)
+	assert.Equal(t, int64(2), status.Total)
+	require.Len(t, status.Nodes, 2)
+	assert.Equal(t, "alpha", status.Nodes[0].Hostname)
+	assert.Equal(t, queries.DistributedQueryStatusPending, status.Nodes[0].Status)
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jmpsec/osctrl/pkg/handlers"
+	"github.com/jmpsec/osctrl/pkg/queries"
+	"github.com/jmpsec/osctrl/pkg/settings"
+	"github.com/jmpsec/osctrl/pkg/types"
+	"github.com/jmpsec/osctrl/pkg/users"
+	"github.com/jmpsec/osctrl/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// RecurringQueriesListHandler - GET Handler to return all recurring queries in JSON by environment
+func (h *HandlersApi) RecurringQueriesListHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		apiErrorResponse(w, "error with environment", http.StatusBadRequest, nil)
+		return
+	}
+	// Get environment
+	env, err := h.Envs.GetByUUID(envVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting environment", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.Users.CheckPermissions(ctx[ctxUser], users.QueryLevel, env.UUID) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	// Get recurring queries
+	rqs, err := h.Queries.GetAllRecurring(env.ID)
+	if err != nil {
+		apiErrorResponse(w, "error getting recurring queries", http.StatusInternalServerError, err)
+		return
+	}
+	// Serialize and serve JSON
+	log.Debug().Msgf("Returned %d recurring queries", len(rqs))
+	h.AuditLog.Visit(ctx[ctxUser], r.URL.Path, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, rqs)
+}
+
+// RecurringQueryShowHandler - GET Handler to return a single recurring query with the history of runs in JSON
+func (h *HandlersApi) RecurringQueryShowHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract name
+	name := r.PathValue("name")
+	if name == "" {
+		apiErrorResponse(w, "error getting name", http.StatusBadRequest, nil)
+		return
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		apiErrorResponse(w, "error with environment", http.StatusBadRequest, nil)
+		return
+	}
+	// Get environment
+	env, err := h.Envs.GetByUUID(envVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting environment", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.Users.CheckPermissions(ctx[ctxUser], users.QueryLevel, env.UUID) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	// Get recurring query by name
+	rq, err := h.Queries.GetRecurring(name, env.ID)
+	if err != nil {
+		if err.Error() == "record not found" {
+			apiErrorResponse(w, "recurring query not found", http.StatusNotFound, err)
+		} else {
+			apiErrorResponse(w, "error getting recurring query", http.StatusInternalServerError, err)
+		}
+		return
+	}
+	runs, err := h.Queries.GetRecurringRuns(rq.ID)
+	if err != nil {
+		apiErrorResponse(w, "error getting recurring query runs", http.StatusInternalServerError, err)
+		return
+	}
+	// Serialize and serve JSON
+	log.Debug().Msgf("Returned recurring query %s", name)
+	h.AuditLog.Visit(ctx[ctxUser], r.URL.Path, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, queries.RecurringQueryDetails{Query: rq, Runs: runs})
+}
+
+// RecurringQueryCreateHandler - POST Handler to create a recurring query
+func (h *HandlersApi) RecurringQueryCreateHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		apiErrorResponse(w, "error with environment", http.StatusBadRequest, nil)
+		return
+	}
+	// Get environment
+	env, err := h.Envs.GetByUUID(envVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting environment", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.Users.CheckPermissions(ctx[ctxUser], users.QueryLevel, env.UUID) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	var q types.ApiRecurringQueryRequest
+	// Parse request JSON body
+	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+		apiErrorResponse(w, "error parsing POST body", http.StatusInternalServerError, err)
+		return
+	}
+	// Query can not be empty
+	if q.Query == "" {
+		apiErrorResponse(w, "query can not be empty", http.StatusBadRequest, nil)
+		return
+	}
+	if err := handlers.ValidateSelector(q.Selector); err != nil {
+		apiErrorResponse(w, "error with selector", http.StatusBadRequest, err)
+		return
+	}
+	if q.Name == "" {
+		q.Name = queries.GenRecurringQueryName()
+	}
+	newRecurring := queries.RecurringQuery{
+		Name:          q.Name,
+		Creator:       ctx[ctxUser],
+		Query:         q.Query,
+		EnvironmentID: env.ID,
+		Cron:          q.Cron,
+		Interval:      q.Interval,
+		ExpHours:      q.ExpHours,
+		Hidden:        q.Hidden,
+	}
+	if err := newRecurring.SetTargets(queries.RecurringQueryTargets{
+		Environments: q.Environments,
+		Platforms:    q.Platforms,
+		UUIDs:        q.UUIDs,
+		Hosts:        q.Hosts,
+		Tags:         q.Tags,
+		Selector:     q.Selector,
+	}); err != nil {
+		apiErrorResponse(w, "error with targets", http.StatusBadRequest, err)
+		return
+	}
+	if err := h.Queries.CreateRecurring(&newRecurring); err != nil {
+		apiErrorResponse(w, "error creating recurring query", http.StatusBadRequest, err)
+		return
+	}
+	// Return query name as serialized response
+	log.Debug().Msgf("Created recurring query %s with id %d", newRecurring.Name, newRecurring.ID)
+	h.AuditLog.QueryAction(ctx[ctxUser], "create recurring query "+newRecurring.Name, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, types.ApiQueriesResponse{Name: newRecurring.Name})
+}
+
+// RecurringQueryActionHandler - POST Handler to pause/resume/delete a recurring query
+func (h *HandlersApi) RecurringQueryActionHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract environment
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		apiErrorResponse(w, "error with environment", http.StatusBadRequest, nil)
+		return
+	}
+	// Get environment
+	env, err := h.Envs.GetByUUID(envVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting environment", http.StatusInternalServerError, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.Users.CheckPermissions(ctx[ctxUser], users.AdminLevel, env.UUID) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	// Extract action
+	actionVar := r.PathValue("action")
+	if actionVar == "" {
+		apiErrorResponse(w, "error getting action", http.StatusBadRequest, nil)
+		return
+	}
+	// Name can not be empty
+	nameVar := r.PathValue("name")
+	if nameVar == "" {
+		apiErrorResponse(w, "name can not be empty", http.StatusBadRequest, nil)
+		return
+	}
+	// Check if recurring query exists
+	if !h.Queries.ExistsRecurring(nameVar, env.ID) {
+		apiErrorResponse(w, "recurring query not found", http.StatusNotFound, nil)
+		return
+	}
+	var msgReturn string
+	switch actionVar {
+	case settings.QueryPause:
+		if err := h.Queries.PauseRecurring(nameVar, env.ID, true); err != nil {
+			apiErrorResponse(w, "error pausing recurring query", http.StatusInternalServerError, err)
+			return
+		}
+		msgReturn = fmt.Sprintf("recurring query %s paused successfully", nameVar)
+	case settings.QueryResume:
+		if err := h.Queries.PauseRecurring(nameVar, env.ID, false); err != nil {
+			apiErrorResponse(w, "error resuming recurring query", http.StatusInternalServerError, err)
+			return
+		}
+		msgReturn = fmt.Sprintf("recurring query %s resumed successfully", nameVar)
+	case settings.QueryDelete:
+		if err := h.Queries.DeleteRecurring(nameVar, env.ID); err != nil {
+			apiErrorResponse(w, "error deleting recurring query", http.StatusInternalServerError, err)
+			return
+		}
+		msgReturn = fmt.Sprintf("recurring query %s deleted successfully", nameVar)
+	default:
+		apiErrorResponse(w, "invalid action", http.StatusBadRequest, nil)
+		return
+	}
+	// Return message as serialized response
+	log.Debug().Msgf("Returned message %s", msgReturn)
+	h.AuditLog.QueryAction(ctx[ctxUser], actionVar+" recurring query "+nameVar, strings.Split(r.RemoteAddr, ":")[0], env.ID)
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, types.ApiGenericResponse{Message: msgReturn})
+}
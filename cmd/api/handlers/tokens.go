@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jmpsec/osctrl/pkg/auditlog"
+	"github.com/jmpsec/osctrl/pkg/types"
+	"github.com/jmpsec/osctrl/pkg/users"
+	"github.com/jmpsec/osctrl/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// Helper to check if the user in the context can manage the tokens of a user
+func (h *HandlersApi) canManageTokens(ctxUsername, username string) bool {
+	if ctxUsername == username {
+		return h.Users.CheckPermissions(ctxUsername, users.UserLevel, users.NoEnvironment)
+	}
+	return h.Users.CheckPermissions(ctxUsername, users.AdminLevel, users.NoEnvironment)
+}
+
+// UserTokensHandler - GET Handler to list the named tokens of a user
+func (h *HandlersApi) UserTokensHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract username
+	usernameVar := r.PathValue("username")
+	if usernameVar == "" {
+		apiErrorResponse(w, "error with username", http.StatusBadRequest, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.canManageTokens(ctx[ctxUser], usernameVar) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	// Get tokens
+	tokens, err := h.Users.GetUserTokens(usernameVar)
+	if err != nil {
+		apiErrorResponse(w, "error getting tokens", http.StatusInternalServerError, err)
+		return
+	}
+	// Serialize and serve JSON
+	log.Debug().Msgf("Returned %d tokens for %s", len(tokens), usernameVar)
+	h.AuditLog.Visit(ctx[ctxUser], r.URL.Path, strings.Split(r.RemoteAddr, ":")[0], auditlog.NoEnvironment)
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, tokens)
+}
+
+// UserTokenCreateHandler - POST Handler to create a named token for a user
+func (h *HandlersApi) UserTokenCreateHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract username
+	usernameVar := r.PathValue("username")
+	if usernameVar == "" {
+		apiErrorResponse(w, "error with username", http.StatusBadRequest, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.canManageTokens(ctx[ctxUser], usernameVar) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	if !h.Users.Exists(usernameVar) {
+		apiErrorResponse(w, "user does not exist", http.StatusNotFound, nil)
+		return
+	}
+	var t types.ApiUserTokenRequest
+	// Parse request JSON body
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		apiErrorResponse(w, "error parsing POST body", http.StatusInternalServerError, err)
+		return
+	}
+	if t.Scope == "" {
+		t.Scope = users.TokenScopeRead
+	}
+	// Tokens restricted to one environment need access to it
+	envUUID := users.TokenAllEnvironments
+	if t.Environment != "" {
+		env, err := h.Envs.Get(t.Environment)
+		if err != nil {
+			apiErrorResponse(w, "error getting environment", http.StatusBadRequest, err)
+			return
+		}
+		if !h.Users.CheckPermissions(usernameVar, users.UserLevel, env.UUID) {
+			apiErrorResponse(w, "no access to environment", http.StatusForbidden, fmt.Errorf("user %s has no access to %s", usernameVar, env.Name))
+			return
+		}
+		envUUID = env.UUID
+	}
+	token, userToken, err := h.Users.CreateUserToken(usernameVar, t.Name, h.ServiceName, envUUID, t.Scope, t.ExpHours)
+	if err != nil {
+		apiErrorResponse(w, "error creating token", http.StatusBadRequest, err)
+		return
+	}
+	// Serialize and serve JSON
+	log.Debug().Msgf("Created token %s for %s", userToken.TokenID, usernameVar)
+	h.AuditLog.UserAction(ctx[ctxUser], "create token "+t.Name+" for user "+usernameVar, strings.Split(r.RemoteAddr, ":")[0])
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, types.ApiUserTokenResponse{
+		Token:     token,
+		TokenID:   userToken.TokenID,
+		ExpiresAt: userToken.ExpiresAt,
+	})
+}
+
+// UserTokenRevokeHandler - POST Handler to revoke a named token of a user
+func (h *HandlersApi) UserTokenRevokeHandler(w http.ResponseWriter, r *http.Request) {
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Extract username
+	usernameVar := r.PathValue("username")
+	if usernameVar == "" {
+		apiErrorResponse(w, "error with username", http.StatusBadRequest, nil)
+		return
+	}
+	// Extract token ID
+	tokenVar := r.PathValue("tokenid")
+	if tokenVar == "" {
+		apiErrorResponse(w, "error with token", http.StatusBadRequest, nil)
+		return
+	}
+	// Get context data and check access
+	ctx := r.Context().Value(ContextKey(contextAPI)).(ContextValue)
+	if !h.canManageTokens(ctx[ctxUser], usernameVar) {
+		apiErrorResponse(w, "no access", http.StatusForbidden, fmt.Errorf("attempt to use API by user %s", ctx[ctxUser]))
+		return
+	}
+	if err := h.Users.RevokeUserToken(usernameVar, tokenVar, ctx[ctxUser]); err != nil {
+		apiErrorResponse(w, "error revoking token", http.StatusBadRequest, err)
+		return
+	}
+	// Serialize and serve JSON
+	log.Debug().Msgf("Revoked token %s for %s", tokenVar, usernameVar)
+	h.AuditLog.UserAction(ctx[ctxUser], "revoke token "+tokenVar+" for user "+usernameVar, strings.Split(r.RemoteAddr, ":")[0])
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, types.ApiDataResponse{Data: "token revoked successfully"})
+}
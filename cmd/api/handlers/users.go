@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -116,6 +117,10 @@ func (h *HandlersApi) UserActionHandler(w http.ResponseWriter, r *http.Request)
 		// Prepare user to create
 		newUser, err := h.Users.New(u.Username, u.Password, u.Email, u.Fullname, u.Admin, u.Service)
 		if err != nil {
+			if errors.Is(err, users.ErrPasswordPolicy) {
+				apiErrorResponse(w, err.Error(), http.StatusBadRequest, nil)
+				return
+			}
 			apiErrorResponse(w, "error with new user", http.StatusInternalServerError, err)
 			return
 		}
@@ -149,6 +154,10 @@ func (h *HandlersApi) UserActionHandler(w http.ResponseWriter, r *http.Request)
 		}
 		if u.Password != "" {
 			if err := h.Users.ChangePassword(u.Username, u.Password); err != nil {
+				if errors.Is(err, users.ErrPasswordPolicy) {
+					apiErrorResponse(w, err.Error(), http.StatusBadRequest, nil)
+					return
+				}
 				apiErrorResponse(w, "error changing password", http.StatusInternalServerError, err)
 				return
 			}
@@ -187,6 +196,18 @@ func (h *HandlersApi) UserActionHandler(w http.ResponseWriter, r *http.Request)
 				return
 			}
 		}
+		if u.ResetMFA && user.MFAEnabled {
+			if err := h.Users.DisableMFA(u.Username); err != nil {
+				apiErrorResponse(w, "error resetting second factor", http.StatusInternalServerError, err)
+				return
+			}
+		}
+		if u.Unlock {
+			if err := h.Users.Unlock(u.Username); err != nil {
+				apiErrorResponse(w, "error unlocking user", http.StatusInternalServerError, err)
+				return
+			}
+		}
 		// TODO: If user is admin, give access to all environments
 		returnData = "user updated successfully"
 	case users.ActionRemove:
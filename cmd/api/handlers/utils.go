@@ -43,3 +43,16 @@ func apiErrorResponse(w http.ResponseWriter, msg string, code int, err error) {
 	log.Debug().Msgf("apiErrorResponse %s: %v", msg, err)
 	utils.HTTPResponse(w, utils.JSONApplicationUTF8, code, types.ApiErrorResponse{Error: msg})
 }
+
+// Helper to register a failed login, auditing it and locking the user if there were too many
+func (h *HandlersApi) loginFailed(username, reason, ip string) {
+	h.AuditLog.LoginFailed(username, reason, ip)
+	locked, err := h.Users.LoginFailed(username)
+	if err != nil {
+		log.Debug().Err(err).Msgf("error registering failed login for %s", username)
+		return
+	}
+	if locked {
+		h.AuditLog.Lockout(username, ip)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/jmpsec/osctrl/pkg/config"
 	"github.com/jmpsec/osctrl/pkg/environments"
 	"github.com/jmpsec/osctrl/pkg/logging"
+	"github.com/jmpsec/osctrl/pkg/migrations"
 	"github.com/jmpsec/osctrl/pkg/nodes"
 	"github.com/jmpsec/osctrl/pkg/queries"
 	"github.com/jmpsec/osctrl/pkg/settings"
@@ -91,21 +92,24 @@ const (
 
 // Global variables
 var (
-	err         error
-	db          *backend.DBManager
-	redis       *cache.RedisManager
-	apiUsers    *users.UserManager
-	tagsmgr     *tags.TagManager
-	settingsmgr *settings.Settings
-	envs        *environments.EnvManager
-	nodesmgr    *nodes.NodeManager
-	queriesmgr  *queries.Queries
-	filecarves  *carves.Carves
-	handlersApi *handlers.HandlersApi
-	app         *cli.App
-	flags       []cli.Flag
-	flagParams  config.ServiceFlagParams
-	auditLog    *auditlog.AuditLogManager
+	err           error
+	db            *backend.DBManager
+	redis         *cache.RedisManager
+	invalidations cache.InvalidationBus
+	apiUsers      *users.UserManager
+	tagsmgr       *tags.TagManager
+	settingsmgr   *settings.Settings
+	envs          *environments.EnvManager
+	nodesmgr      *nodes.NodeManager
+	queriesmgr    *queries.Queries
+	filecarves    *carves.Carves
+	carvers3      *carves.CarverS3
+	carverlocal   *carves.CarverLocal
+	handlersApi   *handlers.HandlersApi
+	app           *cli.App
+	flags         []cli.Flag
+	flagParams    config.ServiceFlagParams
+	auditLog      *auditlog.AuditLogManager
 )
 
 // Valid values for auth and logging in configuration
@@ -177,6 +181,11 @@ func osctrlAPIService() {
 		log.Info().Msgf("Backend NOT ready! Retrying in %d seconds...\n", flagParams.DBConfigValues.ConnRetry)
 		time.Sleep(time.Duration(flagParams.DBConfigValues.ConnRetry) * time.Second)
 	}
+	// ////////////////////////////// Schema
+	log.Info().Msg("Checking database schema...")
+	if err := migrations.Startup(db.Conn, flagParams.DBSchemaCheck); err != nil {
+		log.Fatal().Msgf("Error with database schema - %v", err)
+	}
 	// ////////////////////////////// Cache
 	log.Info().Msg("Initializing cache...")
 	for {
@@ -208,11 +217,29 @@ func osctrlAPIService() {
 	log.Info().Msg("Initialize queries")
 	queriesmgr = queries.CreateQueries(db.Conn)
 	log.Info().Msg("Initialize carves")
-	filecarves = carves.CreateFileCarves(db.Conn, flagParams.ConfigValues.Carver, nil)
+	filecarves = carves.CreateFileCarves(db.Conn, flagParams.ConfigValues.Carver, carvers3, carverlocal)
+	log.Info().Msg("Initialize cache invalidation")
+	invalidations, err = cache.NewRedisBus(redis)
+	if err != nil {
+		log.Fatal().Msgf("Error initializing cache invalidation - %v", err)
+	}
+	envs.Bus = invalidations
+	settingsmgr.Bus = invalidations
+	nodesmgr.SetInvalidationBus(invalidations)
 	log.Info().Msg("Loading service settings")
 	if err := loadingSettings(settingsmgr, flagParams.ConfigValues); err != nil {
 		log.Fatal().Msgf("Error loading settings - %v", err)
 	}
+	// Password policy, lockout and login throttling from settings
+	apiUsers.PasswordPolicy = users.PasswordPolicy{
+		MinLength:  int(settingsmgr.PasswordMinLength()),
+		MinClasses: int(settingsmgr.PasswordMinClasses()),
+	}
+	apiUsers.LockoutPolicy = users.LockoutPolicy{
+		MaxAttempts: int(settingsmgr.LockoutAttempts()),
+		Duration:    time.Duration(settingsmgr.LockoutMinutes()) * time.Minute,
+	}
+	loginThrottle := users.NewLoginThrottle(int(settingsmgr.ThrottleAttempts()), time.Duration(settingsmgr.ThrottleMinutes())*time.Minute)
 	// Initialize audit log manager
 	if flagParams.AuditLog {
 		log.Info().Msg("Initialize audit log")
@@ -236,6 +263,7 @@ func osctrlAPIService() {
 		handlers.WithVersion(buildVersion),
 		handlers.WithName(serviceName),
 		handlers.WithAuditLog(auditLog),
+		handlers.WithLoginThrottle(loginThrottle),
 		handlers.WithDebugHTTP(&flagParams.DebugHTTPValues),
 	)
 
@@ -280,6 +308,24 @@ func osctrlAPIService() {
 	muxAPI.Handle(
 		"POST "+_apiPath(apiNodesPath)+"/{env}/tag",
 		handlerAuthCheck(http.HandlerFunc(handlersApi.TagNodeHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+	muxAPI.Handle(
+		"GET "+_apiPath(apiNodesPath)+"/{env}/duplicates",
+		handlerAuthCheck(http.HandlerFunc(handlersApi.DuplicateNodesHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+	muxAPI.Handle(
+		"POST "+_apiPath(apiNodesPath)+"/{env}/merge",
+		handlerAuthCheck(http.HandlerFunc(handlersApi.MergeNodesHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+	muxAPI.Handle(
+		"GET "+_apiPath(apiNodesPath)+"/{env}/pending",
+		handlerAuthCheck(http.HandlerFunc(handlersApi.PendingNodesHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+	muxAPI.Handle(
+		"POST "+_apiPath(apiNodesPath)+"/{env}/approve",
+		handlerAuthCheck(http.HandlerFunc(handlersApi.ApproveNodesHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+	muxAPI.Handle(
+		"POST "+_apiPath(apiNodesPath)+"/{env}/revoke-cert",
+		handlerAuthCheck(http.HandlerFunc(handlersApi.RevokeNodeCertHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+	muxAPI.Handle(
+		"POST "+_apiPath(apiNodesPath)+"/{env}/clear-cert",
+		handlerAuthCheck(http.HandlerFunc(handlersApi.ClearNodeCertHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
 	muxAPI.Handle(
 		"POST "+_apiPath(apiNodesPath)+"/lookup",
 		handlerAuthCheck(http.HandlerFunc(handlersApi.LookupNodeHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
@@ -294,18 +340,40 @@ func osctrlAPIService() {
 		muxAPI.Handle(
 			"POST "+_apiPath(apiQueriesPath)+"/{env}",
 			handlerAuthCheck(http.HandlerFunc(handlersApi.QueriesRunHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+		muxAPI.Handle(
+			"POST "+_apiPath(apiQueriesPath)+"/{env}/preview",
+			handlerAuthCheck(http.HandlerFunc(handlersApi.QueryPreviewHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
 		muxAPI.Handle(
 			"GET "+_apiPath(apiQueriesPath)+"/{env}/{name}",
 			handlerAuthCheck(http.HandlerFunc(handlersApi.QueryShowHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
 		muxAPI.Handle(
 			"GET "+_apiPath(apiQueriesPath)+"/{env}/results/{name}",
 			handlerAuthCheck(http.HandlerFunc(handlersApi.QueryResultsHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+		muxAPI.Handle(
+			"GET "+_apiPath(apiQueriesPath)+"/{env}/export/{name}",
+			handlerAuthCheck(http.HandlerFunc(handlersApi.QueryExportHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+		muxAPI.Handle(
+			"GET "+_apiPath(apiQueriesPath)+"/{env}/status/{name}",
+			handlerAuthCheck(http.HandlerFunc(handlersApi.QueryStatusHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
 		muxAPI.Handle(
 			"GET "+_apiPath(apiAllQueriesPath+"/{env}"),
 			handlerAuthCheck(http.HandlerFunc(handlersApi.AllQueriesShowHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
 		muxAPI.Handle(
 			"POST "+_apiPath(apiQueriesPath)+"/{env}/{action}/{name}",
 			handlerAuthCheck(http.HandlerFunc(handlersApi.QueriesActionHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+		// API: recurring queries by environment
+		muxAPI.Handle(
+			"GET "+_apiPath(apiQueriesPath)+"/{env}/recurring",
+			handlerAuthCheck(http.HandlerFunc(handlersApi.RecurringQueriesListHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+		muxAPI.Handle(
+			"POST "+_apiPath(apiQueriesPath)+"/{env}/recurring",
+			handlerAuthCheck(http.HandlerFunc(handlersApi.RecurringQueryCreateHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+		muxAPI.Handle(
+			"GET "+_apiPath(apiQueriesPath)+"/{env}/recurring/{name}",
+			handlerAuthCheck(http.HandlerFunc(handlersApi.RecurringQueryShowHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+		muxAPI.Handle(
+			"POST "+_apiPath(apiQueriesPath)+"/{env}/recurring/{name}/{action}",
+			handlerAuthCheck(http.HandlerFunc(handlersApi.RecurringQueryActionHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
 	}
 	// API: carves by environment
 	if flagParams.OsqueryConfigValues.Carve {
@@ -315,6 +383,15 @@ func osctrlAPIService() {
 		muxAPI.Handle(
 			"GET "+_apiPath(apiCarvesPath)+"/{env}/queries/{target}",
 			handlerAuthCheck(http.HandlerFunc(handlersApi.CarveQueriesHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+		muxAPI.Handle(
+			"GET "+_apiPath(apiCarvesPath)+"/{env}/files/{sessionid}",
+			handlerAuthCheck(http.HandlerFunc(handlersApi.CarveFilesHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+		muxAPI.Handle(
+			"GET "+_apiPath(apiCarvesPath)+"/{env}/integrity/{sessionid}",
+			handlerAuthCheck(http.HandlerFunc(handlersApi.CarveIntegrityHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+		muxAPI.Handle(
+			"GET "+_apiPath(apiCarvesPath)+"/{env}/extract/{sessionid}",
+			handlerAuthCheck(http.HandlerFunc(handlersApi.CarveExtractHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
 		muxAPI.Handle(
 			"GET "+_apiPath(apiCarvesPath)+"/{env}/list",
 			handlerAuthCheck(http.HandlerFunc(handlersApi.CarveListHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
@@ -338,6 +415,15 @@ func osctrlAPIService() {
 	muxAPI.Handle(
 		"POST "+_apiPath(apiUsersPath)+"/{username}/{action}",
 		handlerAuthCheck(http.HandlerFunc(handlersApi.UserActionHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+	muxAPI.Handle(
+		"GET "+_apiPath(apiUsersPath)+"/{username}/tokens",
+		handlerAuthCheck(http.HandlerFunc(handlersApi.UserTokensHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+	muxAPI.Handle(
+		"POST "+_apiPath(apiUsersPath)+"/{username}/tokens",
+		handlerAuthCheck(http.HandlerFunc(handlersApi.UserTokenCreateHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+	muxAPI.Handle(
+		"POST "+_apiPath(apiUsersPath)+"/{username}/tokens/{tokenid}/revoke",
+		handlerAuthCheck(http.HandlerFunc(handlersApi.UserTokenRevokeHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
 	// API: platforms
 	muxAPI.Handle(
 		"GET "+_apiPath(apiPlatformsPath)+"/{env}",
@@ -364,6 +450,15 @@ func osctrlAPIService() {
 	muxAPI.Handle(
 		"POST "+_apiPath(apiEnvironmentsPath)+"/{env}/remove/{action}",
 		handlerAuthCheck(http.HandlerFunc(handlersApi.EnvRemoveActionsHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+	muxAPI.Handle(
+		"GET "+_apiPath(apiEnvironmentsPath)+"/{env}/tokens/all",
+		handlerAuthCheck(http.HandlerFunc(handlersApi.EnvEnrollTokensHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+	muxAPI.Handle(
+		"POST "+_apiPath(apiEnvironmentsPath)+"/{env}/tokens/create",
+		handlerAuthCheck(http.HandlerFunc(handlersApi.EnvEnrollTokenCreateHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
+	muxAPI.Handle(
+		"POST "+_apiPath(apiEnvironmentsPath)+"/{env}/tokens/{tokenid}/revoke",
+		handlerAuthCheck(http.HandlerFunc(handlersApi.EnvEnrollTokenRevokeHandler), flagParams.ConfigValues.Auth, flagParams.JWTConfigValues.JWTSecret))
 	// API: tags by environment
 	muxAPI.Handle(
 		"GET "+_apiPath(apiTagsPath),
@@ -463,6 +558,27 @@ func cliAction(c *cli.Context) error {
 			return fmt.Errorf("failed to load JWT configuration - %s", err.Error())
 		}
 	}
+	// Load carver configuration to read the content of carves
+	if flagParams.ConfigValues.Carver == config.CarverS3 {
+		if flagParams.S3CarverConfig.Bucket != "" {
+			carvers3, err = carves.CreateCarverS3(flagParams.S3CarverConfig)
+		} else {
+			carvers3, err = carves.CreateCarverS3File(flagParams.CarverConfigFile)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to initiate s3 carver - %w", err)
+		}
+	}
+	if flagParams.ConfigValues.Carver == config.CarverLocal {
+		if flagParams.LocalCarverConfig.Dir != "" {
+			carverlocal, err = carves.CreateCarverLocal(flagParams.LocalCarverConfig)
+		} else {
+			carverlocal, err = carves.CreateCarverLocalFile(flagParams.CarverConfigFile)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to initiate local carver - %w", err)
+		}
+	}
 	return nil
 }
 
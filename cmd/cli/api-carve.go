@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/url"
 	"path"
 
 	"github.com/jmpsec/osctrl/pkg/carves"
@@ -98,12 +100,13 @@ func (api *OsctrlAPI) CompleteCarve(env, name string) (types.ApiGenericResponse,
 }
 
 // RunCarve to initiate a carve in osctrl
-func (api *OsctrlAPI) RunCarve(env, fPath string, uuids, hosts, platforms, tags []string, hidden bool, exp int) (types.ApiQueriesResponse, error) {
+func (api *OsctrlAPI) RunCarve(env, fPath string, uuids, hosts, platforms, tags []string, selector string, hidden bool, exp int) (types.ApiQueriesResponse, error) {
 	c := types.ApiDistributedQueryRequest{
 		UUIDs:     uuids,
 		Hosts:     hosts,
 		Platforms: platforms,
 		Tags:      tags,
+		Selector:  selector,
 		Path:      fPath,
 		Hidden:    hidden,
 		ExpHours:  exp,
@@ -124,3 +127,26 @@ func (api *OsctrlAPI) RunCarve(env, fPath string, uuids, hosts, platforms, tags
 	}
 	return r, nil
 }
+
+// GetCarveFiles to retrieve the files inside a carve from osctrl
+func (api *OsctrlAPI) GetCarveFiles(env, sessionid string) ([]carves.CarveEntry, error) {
+	var es []carves.CarveEntry
+	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APICarves, env, "files", sessionid))
+	rawEs, err := api.GetGeneric(reqURL, nil)
+	if err != nil {
+		return es, fmt.Errorf("error api request - %w - %s", err, string(rawEs))
+	}
+	if err := json.Unmarshal(rawEs, &es); err != nil {
+		return es, fmt.Errorf("can not parse body - %w", err)
+	}
+	return es, nil
+}
+
+// ExtractCarveFile to stream one file from inside a carve in osctrl
+func (api *OsctrlAPI) ExtractCarveFile(env, sessionid, fPath string, w io.Writer) error {
+	reqURL := fmt.Sprintf("%s%s?path=%s", api.Configuration.URL, path.Join(APIPath, APICarves, env, "extract", sessionid), url.QueryEscape(fPath))
+	if err := api.DownloadGeneric(reqURL, w); err != nil {
+		return fmt.Errorf("error api request - %w", err)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -106,3 +107,55 @@ func (api *OsctrlAPI) ActionEnrollmentRemove(identifier, action, target string,
 	}
 	return res.Message, nil
 }
+
+// GetEnrollTokens to retrieve the enrollment tokens of an environment
+func (api *OsctrlAPI) GetEnrollTokens(identifier string) ([]environments.EnrollToken, error) {
+	var tokens []environments.EnrollToken
+	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APIEnvironments, identifier, "tokens", "all"))
+	rawT, err := api.GetGeneric(reqURL, nil)
+	if err != nil {
+		return tokens, fmt.Errorf("error api request - %w - %s", err, string(rawT))
+	}
+	if err := json.Unmarshal(rawT, &tokens); err != nil {
+		return tokens, fmt.Errorf("can not parse body - %w", err)
+	}
+	return tokens, nil
+}
+
+// CreateEnrollToken to create a new enrollment token for an environment
+func (api *OsctrlAPI) CreateEnrollToken(identifier, hostname, serial string, maxUses, expHours int) (types.ApiEnrollTokenResponse, error) {
+	var res types.ApiEnrollTokenResponse
+	t := types.ApiEnrollTokenRequest{
+		Hostname: hostname,
+		Serial:   serial,
+		MaxUses:  maxUses,
+		ExpHours: expHours,
+	}
+	jsonMessage, err := json.Marshal(t)
+	if err != nil {
+		return res, fmt.Errorf("error marshaling data - %w", err)
+	}
+	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APIEnvironments, identifier, "tokens", "create"))
+	rawT, err := api.PostGeneric(reqURL, bytes.NewReader(jsonMessage))
+	if err != nil {
+		return res, fmt.Errorf("error api request - %w - %s", err, string(rawT))
+	}
+	if err := json.Unmarshal(rawT, &res); err != nil {
+		return res, fmt.Errorf("can not parse body - %w", err)
+	}
+	return res, nil
+}
+
+// RevokeEnrollToken to revoke an enrollment token of an environment
+func (api *OsctrlAPI) RevokeEnrollToken(identifier, tokenID string) error {
+	var res types.ApiGenericResponse
+	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APIEnvironments, identifier, "tokens", tokenID, "revoke"))
+	rawT, err := api.PostGeneric(reqURL, nil)
+	if err != nil {
+		return fmt.Errorf("error api request - %w - %s", err, string(rawT))
+	}
+	if err := json.Unmarshal(rawT, &res); err != nil {
+		return fmt.Errorf("can not parse body - %w", err)
+	}
+	return nil
+}
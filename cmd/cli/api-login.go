@@ -10,12 +10,13 @@ import (
 )
 
 // PostLogin to login into API to retrieve a token
-func (api *OsctrlAPI) PostLogin(env, username, password string, expHours int) (types.ApiLoginResponse, error) {
+func (api *OsctrlAPI) PostLogin(env, username, password, otp string, expHours int) (types.ApiLoginResponse, error) {
 	var res types.ApiLoginResponse
 	l := types.ApiLoginRequest{
 		Username: username,
 		Password: password,
 		ExpHours: expHours,
+		OTP:      otp,
 	}
 	jsonMessage, err := json.Marshal(l)
 	if err != nil {
@@ -106,3 +106,120 @@ func (api *OsctrlAPI) LookupNode(identifier string) (nodes.OsqueryNode, error) {
 	}
 	return node, nil
 }
+
+// GetNodeDuplicates to retrieve suspected duplicated nodes from osctrl
+func (api *OsctrlAPI) GetNodeDuplicates(env string) ([]nodes.DuplicateGroup, error) {
+	var groups []nodes.DuplicateGroup
+	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APINodes, env, "duplicates"))
+	rawGroups, err := api.GetGeneric(reqURL, nil)
+	if err != nil {
+		return groups, fmt.Errorf("error api request - %w - %s", err, string(rawGroups))
+	}
+	if err := json.Unmarshal(rawGroups, &groups); err != nil {
+		return groups, fmt.Errorf("can not parse body - %w", err)
+	}
+	return groups, nil
+}
+
+// MergeNodes to merge duplicated nodes in osctrl
+func (api *OsctrlAPI) MergeNodes(env string, target uint, duplicates []uint) error {
+	m := types.ApiNodeMergeRequest{
+		Target:     target,
+		Duplicates: duplicates,
+	}
+	var r types.ApiGenericResponse
+	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APINodes, env, "merge"))
+	jsonMessage, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("error marshaling data - %w", err)
+	}
+	jsonParam := bytes.NewReader(jsonMessage)
+	rawN, err := api.PostGeneric(reqURL, jsonParam)
+	if err != nil {
+		return fmt.Errorf("error api request - %w - %s", err, string(rawN))
+	}
+	if err := json.Unmarshal(rawN, &r); err != nil {
+		return fmt.Errorf("can not parse body - %w", err)
+	}
+	return nil
+}
+
+// GetPendingNodes to retrieve the nodes pending approval from osctrl
+func (api *OsctrlAPI) GetPendingNodes(env string) ([]nodes.OsqueryNode, error) {
+	var nds []nodes.OsqueryNode
+	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APINodes, env, "pending"))
+	rawNodes, err := api.GetGeneric(reqURL, nil)
+	if err != nil {
+		return nds, fmt.Errorf("error api request - %w - %s", err, string(rawNodes))
+	}
+	if err := json.Unmarshal(rawNodes, &nds); err != nil {
+		return nds, fmt.Errorf("can not parse body - %w", err)
+	}
+	return nds, nil
+}
+
+// ApproveNodes to approve nodes pending approval in osctrl
+func (api *OsctrlAPI) ApproveNodes(env string, uuids []string) error {
+	a := types.ApiNodeApproveRequest{
+		UUIDs: uuids,
+	}
+	var r types.ApiGenericResponse
+	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APINodes, env, "approve"))
+	jsonMessage, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("error marshaling data - %w", err)
+	}
+	jsonParam := bytes.NewReader(jsonMessage)
+	rawN, err := api.PostGeneric(reqURL, jsonParam)
+	if err != nil {
+		return fmt.Errorf("error api request - %w - %s", err, string(rawN))
+	}
+	if err := json.Unmarshal(rawN, &r); err != nil {
+		return fmt.Errorf("can not parse body - %w", err)
+	}
+	return nil
+}
+
+// RevokeNodeCert to revoke the client certificates of a node in osctrl
+func (api *OsctrlAPI) RevokeNodeCert(env, identifier string) error {
+	n := types.ApiNodeGenericRequest{
+		UUID: identifier,
+	}
+	var r types.ApiGenericResponse
+	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APINodes, env, "revoke-cert"))
+	jsonMessage, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("error marshaling data - %w", err)
+	}
+	jsonParam := bytes.NewReader(jsonMessage)
+	rawN, err := api.PostGeneric(reqURL, jsonParam)
+	if err != nil {
+		return fmt.Errorf("error api request - %w - %s", err, string(rawN))
+	}
+	if err := json.Unmarshal(rawN, &r); err != nil {
+		return fmt.Errorf("can not parse body - %w", err)
+	}
+	return nil
+}
+
+// ClearNodeCert to clear the revoked client certificates of a node in osctrl
+func (api *OsctrlAPI) ClearNodeCert(env, identifier string) error {
+	n := types.ApiNodeGenericRequest{
+		UUID: identifier,
+	}
+	var r types.ApiGenericResponse
+	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APINodes, env, "clear-cert"))
+	jsonMessage, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("error marshaling data - %w", err)
+	}
+	jsonParam := bytes.NewReader(jsonMessage)
+	rawN, err := api.PostGeneric(reqURL, jsonParam)
+	if err != nil {
+		return fmt.Errorf("error api request - %w - %s", err, string(rawN))
+	}
+	if err := json.Unmarshal(rawN, &r); err != nil {
+		return fmt.Errorf("can not parse body - %w", err)
+	}
+	return nil
+}
@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/url"
 	"path"
+	"strconv"
 
+	"github.com/jmpsec/osctrl/pkg/handlers"
 	"github.com/jmpsec/osctrl/pkg/queries"
 	"github.com/jmpsec/osctrl/pkg/settings"
 	"github.com/jmpsec/osctrl/pkg/types"
@@ -82,14 +86,16 @@ func (api *OsctrlAPI) CompleteQuery(env, name string) (types.ApiGenericResponse,
 }
 
 // RunQuery to initiate a query in osctrl
-func (api *OsctrlAPI) RunQuery(env, query string, uuids, hosts, platforms, tags []string, hidden bool, exp int) (types.ApiQueriesResponse, error) {
+func (api *OsctrlAPI) RunQuery(env, query string, uuids, hosts, platforms, tags []string, selector string, hidden, urgent bool, exp int) (types.ApiQueriesResponse, error) {
 	q := types.ApiDistributedQueryRequest{
 		UUIDs:    uuids,
 		Hosts:    hosts,
 		Platforms: platforms,
 		Tags:     tags,
+		Selector:  selector,
 		Query:    query,
 		Hidden:   hidden,
+		Urgent:   urgent,
 		ExpHours: exp,
 	}
 	var r types.ApiQueriesResponse
@@ -109,3 +115,55 @@ func (api *OsctrlAPI) RunQuery(env, query string, uuids, hosts, platforms, tags
 	}
 	return r, nil
 }
+
+// ExportQuery to stream the results of a query from osctrl in the given format
+func (api *OsctrlAPI) ExportQuery(env, name, format string, w io.Writer) error {
+	reqURL := fmt.Sprintf("%s%s?format=%s", api.Configuration.URL, path.Join(APIPath, APIQueries, env, "export", name), url.QueryEscape(format))
+	if err := api.DownloadGeneric(reqURL, w); err != nil {
+		return fmt.Errorf("error api request - %w", err)
+	}
+	return nil
+}
+
+// GetQueryStatus to retrieve the status of a query in a page of target nodes from osctrl
+func (api *OsctrlAPI) GetQueryStatus(env, name, status string, offset, limit int) (queries.NodeQueryStatusList, error) {
+	var s queries.NodeQueryStatusList
+	params := url.Values{}
+	params.Set("status", status)
+	params.Set("offset", strconv.Itoa(offset))
+	params.Set("limit", strconv.Itoa(limit))
+	reqURL := fmt.Sprintf("%s%s?%s", api.Configuration.URL, path.Join(APIPath, APIQueries, env, "status", name), params.Encode())
+	rawS, err := api.GetGeneric(reqURL, nil)
+	if err != nil {
+		return s, fmt.Errorf("error api request - %w - %s", err, string(rawS))
+	}
+	if err := json.Unmarshal(rawS, &s); err != nil {
+		return s, fmt.Errorf("can not parse body - %w", err)
+	}
+	return s, nil
+}
+
+// PreviewTargets to retrieve the nodes that would be targeted by a query or carve in osctrl
+func (api *OsctrlAPI) PreviewTargets(env string, uuids, hosts, platforms, tags []string, selector string) (handlers.TargetPreview, error) {
+	q := types.ApiDistributedQueryRequest{
+		UUIDs:     uuids,
+		Hosts:     hosts,
+		Platforms: platforms,
+		Tags:      tags,
+		Selector:  selector,
+	}
+	var p handlers.TargetPreview
+	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APIQueries, env, "preview"))
+	jsonMessage, err := json.Marshal(q)
+	if err != nil {
+		return p, fmt.Errorf("error marshaling data - %w", err)
+	}
+	rawP, err := api.PostGeneric(reqURL, bytes.NewReader(jsonMessage))
+	if err != nil {
+		return p, fmt.Errorf("error api request - %w - %s", err, string(rawP))
+	}
+	if err := json.Unmarshal(rawP, &p); err != nil {
+		return p, fmt.Errorf("can not parse body - %w", err)
+	}
+	return p, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/jmpsec/osctrl/pkg/queries"
+	"github.com/jmpsec/osctrl/pkg/types"
+)
+
+const (
+	// APIRecurring for the recurring queries path
+	APIRecurring = "recurring"
+)
+
+// GetRecurringQueries to retrieve recurring queries from osctrl
+func (api *OsctrlAPI) GetRecurringQueries(env string) ([]queries.RecurringQuery, error) {
+	var rqs []queries.RecurringQuery
+	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APIQueries, env, APIRecurring))
+	rawRqs, err := api.GetGeneric(reqURL, nil)
+	if err != nil {
+		return rqs, fmt.Errorf("error api request - %w - %s", err, string(rawRqs))
+	}
+	if err := json.Unmarshal(rawRqs, &rqs); err != nil {
+		return rqs, fmt.Errorf("can not parse body - %w", err)
+	}
+	return rqs, nil
+}
+
+// GetRecurringQuery to retrieve one recurring query with the history of runs from osctrl
+func (api *OsctrlAPI) GetRecurringQuery(env, name string) (queries.RecurringQueryDetails, error) {
+	var rq queries.RecurringQueryDetails
+	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APIQueries, env, APIRecurring, name))
+	rawRq, err := api.GetGeneric(reqURL, nil)
+	if err != nil {
+		return rq, fmt.Errorf("error api request - %w - %s", err, string(rawRq))
+	}
+	if err := json.Unmarshal(rawRq, &rq); err != nil {
+		return rq, fmt.Errorf("can not parse body - %w", err)
+	}
+	return rq, nil
+}
+
+// CreateRecurringQuery to create a recurring query in osctrl
+func (api *OsctrlAPI) CreateRecurringQuery(env string, q types.ApiRecurringQueryRequest) (types.ApiQueriesResponse, error) {
+	var r types.ApiQueriesResponse
+	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APIQueries, env, APIRecurring))
+	jsonMessage, err := json.Marshal(q)
+	if err != nil {
+		return r, fmt.Errorf("error marshaling data - %w", err)
+	}
+	jsonParam := bytes.NewReader(jsonMessage)
+	rawQ, err := api.PostGeneric(reqURL, jsonParam)
+	if err != nil {
+		return r, fmt.Errorf("error api request - %w - %s", err, string(rawQ))
+	}
+	if err := json.Unmarshal(rawQ, &r); err != nil {
+		return r, fmt.Errorf("can not parse body - %w", err)
+	}
+	return r, nil
+}
+
+// ActionRecurringQuery to pause/resume/delete a recurring query in osctrl
+func (api *OsctrlAPI) ActionRecurringQuery(env, name, action string) (types.ApiGenericResponse, error) {
+	var r types.ApiGenericResponse
+	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APIQueries, env, APIRecurring, name, action))
+	rawQ, err := api.PostGeneric(reqURL, nil)
+	if err != nil {
+		return r, fmt.Errorf("error api request - %w - %s", err, string(rawQ))
+	}
+	if err := json.Unmarshal(rawQ, &r); err != nil {
+		return r, fmt.Errorf("can not parse body - %w", err)
+	}
+	return r, nil
+}
@@ -120,3 +120,50 @@ func (api *OsctrlAPI) EditUser(username, password, email, fullname, environment
 	}
 	return api.EditUserReq(u)
 }
+
+// GetUserTokens to retrieve the named tokens of a user from osctrl
+func (api *OsctrlAPI) GetUserTokens(username string) ([]users.UserToken, error) {
+	var ts []users.UserToken
+	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APIUSers, username, "tokens"))
+	rawTs, err := api.GetGeneric(reqURL, nil)
+	if err != nil {
+		return ts, fmt.Errorf("error api request - %w - %s", err, string(rawTs))
+	}
+	if err := json.Unmarshal(rawTs, &ts); err != nil {
+		return ts, fmt.Errorf("can not parse body - %w", err)
+	}
+	return ts, nil
+}
+
+// CreateUserToken to create a named token for a user in osctrl
+func (api *OsctrlAPI) CreateUserToken(username string, t types.ApiUserTokenRequest) (types.ApiUserTokenResponse, error) {
+	var r types.ApiUserTokenResponse
+	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APIUSers, username, "tokens"))
+	jsonMessage, err := json.Marshal(t)
+	if err != nil {
+		return r, fmt.Errorf("error marshaling data - %w", err)
+	}
+	jsonParam := bytes.NewReader(jsonMessage)
+	rawT, err := api.PostGeneric(reqURL, jsonParam)
+	if err != nil {
+		return r, fmt.Errorf("error api request - %w - %s", err, string(rawT))
+	}
+	if err := json.Unmarshal(rawT, &r); err != nil {
+		return r, fmt.Errorf("can not parse body - %w", err)
+	}
+	return r, nil
+}
+
+// RevokeUserToken to revoke a named token of a user in osctrl
+func (api *OsctrlAPI) RevokeUserToken(username, tokenID string) error {
+	var r types.ApiDataResponse
+	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APIUSers, username, "tokens", tokenID, "revoke"))
+	rawT, err := api.PostGeneric(reqURL, bytes.NewReader([]byte("{}")))
+	if err != nil {
+		return fmt.Errorf("error api request - %w - %s", err, string(rawT))
+	}
+	if err := json.Unmarshal(rawT, &r); err != nil {
+		return fmt.Errorf("can not parse body - %w", err)
+	}
+	return nil
+}
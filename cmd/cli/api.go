@@ -176,7 +176,36 @@ func (api *OsctrlAPI) ReqGeneric(reqType string, url string, body io.Reader) ([]
 	return bodyBytes, nil
 }
 
-// CheckApiAuth to check if API authentication is working
+// DownloadGeneric - Function to send a GET request and stream the response body to a writer
+func (api *OsctrlAPI) DownloadGeneric(url string, w io.Writer) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("NewRequest - %w", err)
+	}
+	// Set custom User-Agent
+	req.Header.Set(UserAgent, osctrlUserAgent)
+	// Prepare headers
+	for key, value := range api.Headers {
+		req.Header.Add(key, value)
+	}
+	// Send request
+	resp, err := api.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Client.Do - %w", err)
+	}
+	defer resp.Body.Close()
+	// Check response code, errors come as JSON in the body
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("HTTP Code %d - %s", resp.StatusCode, string(bodyBytes))
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("can not read response - %w", err)
+	}
+	return nil
+}
+
+// CheckAPI to check if the API is reachable and authentication is working
 func (api *OsctrlAPI) CheckAPI() error {
 	log.Debug().Msg("Preparing request to check unauthenticated API")
 	reqURL := fmt.Sprintf("%s%s", api.Configuration.URL, path.Join(APIPath, APIChecksNoAuth))
@@ -4,6 +4,7 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
@@ -41,6 +42,7 @@ func carveToData(c carves.CarvedFile, header []string) [][]string {
 		strconv.Itoa(c.CarveSize) + " / " + strconv.Itoa(c.BlockSize),
 		strconv.Itoa(c.CompletedBlocks) + " / " + strconv.Itoa(c.TotalBlocks),
 		c.Status,
+		c.StatusReason,
 		c.Carver,
 		stringifyBool(c.Archived),
 		c.ArchivePath,
@@ -80,6 +82,7 @@ func listCarves(c *cli.Context) error {
 		"Block/Total Size",
 		"Completed/Total Blocks",
 		"Status",
+		"Reason",
 		"Carver",
 		"Archived",
 		"ArchivePath",
@@ -313,9 +316,14 @@ func runCarve(c *cli.Context) error {
 		fmt.Println("❌ environment is required")
 		os.Exit(1)
 	}
+	selector := c.String("selector")
+	if err := handlers.ValidateSelector(selector); err != nil {
+		fmt.Printf("❌ %s\n", err)
+		os.Exit(1)
+	}
 	uuidStr := c.String("uuid")
-	if uuidStr == "" {
-		fmt.Println("❌ UUID is required")
+	if uuidStr == "" && selector == "" {
+		fmt.Println("❌ UUID or selector is required")
 		os.Exit(1)
 	}
 	uuidList := []string{uuidStr}
@@ -370,6 +378,7 @@ func runCarve(c *cli.Context) error {
 			UUIDs:         uuidList,
 			Hosts:         hostList,
 			Tags:          tagList,
+			Selector:      selector,
 			EnvID:         e.ID,
 			InactiveHours: settingsmgr.InactiveHours(settings.NoEnvironmentID),
 		}
@@ -394,7 +403,7 @@ func runCarve(c *cli.Context) error {
 		// Audit log
 		auditlogsmgr.NewCarve(getShellUsername(), path, "CLI", e.ID)
 	} else if apiFlag {
-		c, err := osctrlAPI.RunCarve(env, path, uuidList, hostList, platformList, tagList, hidden, expHours)
+		c, err := osctrlAPI.RunCarve(env, path, uuidList, hostList, platformList, tagList, selector, hidden, expHours)
 		if err != nil {
 			return fmt.Errorf("❌ error running carve - %w", err)
 		}
@@ -405,3 +414,148 @@ func runCarve(c *cli.Context) error {
 	}
 	return nil
 }
+
+// Helper function to convert the files inside a carve into the data expected for output
+func carveEntriesToData(es []carves.CarveEntry, header []string) [][]string {
+	var data [][]string
+	if header != nil {
+		data = append(data, header)
+	}
+	for _, e := range es {
+		_e := []string{
+			e.Path,
+			strconv.FormatInt(e.Size, 10),
+			e.Mode,
+			e.ModTime.String(),
+			e.SHA256,
+		}
+		data = append(data, _e)
+	}
+	return data
+}
+
+func listCarveFiles(c *cli.Context) error {
+	// Get values from flags
+	session := c.String("session")
+	if session == "" {
+		fmt.Println("❌ carve session is required")
+		os.Exit(1)
+	}
+	env := c.String("env")
+	if env == "" {
+		fmt.Println("❌ environment is required")
+		os.Exit(1)
+	}
+	// Retrieve data
+	var es []carves.CarveEntry
+	if dbFlag {
+		e, err := envs.Get(env)
+		if err != nil {
+			return fmt.Errorf("❌ error env get - %w", err)
+		}
+		carve, err := filecarves.GetBySession(session)
+		if err != nil {
+			return fmt.Errorf("❌ error getting carve - %w", err)
+		}
+		if carve.ID == 0 || carve.EnvironmentID != e.ID {
+			return fmt.Errorf("❌ carve %s not found", session)
+		}
+		es, err = filecarves.ListEntries(carve)
+		if err != nil {
+			return fmt.Errorf("❌ error listing carve - %w", err)
+		}
+	} else if apiFlag {
+		es, err = osctrlAPI.GetCarveFiles(env, session)
+		if err != nil {
+			return fmt.Errorf("❌ error listing carve - %w", err)
+		}
+	}
+	header := []string{
+		"Path",
+		"Size",
+		"Mode",
+		"ModTime",
+		"SHA256",
+	}
+	// Prepare output
+	switch formatFlag {
+	case jsonFormat:
+		jsonRaw, err := json.Marshal(es)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(jsonRaw))
+	case csvFormat:
+		data := carveEntriesToData(es, header)
+		w := csv.NewWriter(os.Stdout)
+		if err := w.WriteAll(data); err != nil {
+			return err
+		}
+	case prettyFormat:
+		table := tablewriter.NewWriter(os.Stdout)
+		table.Header(stringSliceToAnySlice(header)...)
+		if len(es) > 0 {
+			fmt.Printf("Files in carve (%d):\n", len(es))
+			data := carveEntriesToData(es, nil)
+			table.Bulk(data)
+		} else {
+			fmt.Println("No files")
+		}
+		table.Render()
+	}
+	return nil
+}
+
+func extractCarveFile(c *cli.Context) error {
+	// Get values from flags
+	session := c.String("session")
+	if session == "" {
+		fmt.Println("❌ carve session is required")
+		os.Exit(1)
+	}
+	env := c.String("env")
+	if env == "" {
+		fmt.Println("❌ environment is required")
+		os.Exit(1)
+	}
+	fPath := c.String("path")
+	if fPath == "" {
+		fmt.Println("❌ path is required")
+		os.Exit(1)
+	}
+	var out io.Writer = os.Stdout
+	if output := c.String("output"); output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("❌ error creating file - %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if dbFlag {
+		e, err := envs.Get(env)
+		if err != nil {
+			return fmt.Errorf("❌ error env get - %w", err)
+		}
+		carve, err := filecarves.GetBySession(session)
+		if err != nil {
+			return fmt.Errorf("❌ error getting carve - %w", err)
+		}
+		if carve.ID == 0 || carve.EnvironmentID != e.ID {
+			return fmt.Errorf("❌ carve %s not found", session)
+		}
+		entry, err := filecarves.OpenEntry(carve, fPath)
+		if err != nil {
+			return fmt.Errorf("❌ error extracting file - %w", err)
+		}
+		defer entry.Close()
+		if _, err := io.Copy(out, entry); err != nil {
+			return fmt.Errorf("❌ error extracting file - %w", err)
+		}
+	} else if apiFlag {
+		if err := osctrlAPI.ExtractCarveFile(env, session, fPath, out); err != nil {
+			return fmt.Errorf("❌ error extracting file - %w", err)
+		}
+	}
+	return nil
+}
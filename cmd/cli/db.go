@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/jmpsec/osctrl/pkg/migrations"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+)
+
+// Helper function to convert the status of migrations into the data expected for output
+func migrationsToData(status []migrations.MigrationStatus, header []string) [][]string {
+	var data [][]string
+	if header != nil {
+		data = append(data, header)
+	}
+	for _, s := range status {
+		applied := ""
+		if s.Applied {
+			applied = s.AppliedAt.String()
+		}
+		_s := []string{
+			strconv.FormatUint(uint64(s.Version), 10),
+			s.Name,
+			stringifyBool(s.Applied),
+			applied,
+		}
+		data = append(data, _s)
+	}
+	return data
+}
+
+func migrateDB(c *cli.Context) error {
+	m, err := migrations.CreateMigrator(db.Conn)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	done, err := m.Migrate(c.Uint("to"))
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	if !silentFlag {
+		for _, mig := range done {
+			fmt.Printf("✅ applied migration %d (%s)\n", mig.Version, mig.Name)
+		}
+		current, err := m.Current()
+		if err != nil {
+			return fmt.Errorf("❌ %w", err)
+		}
+		fmt.Printf("✅ schema is at version %d, latest is %d\n", current, m.Latest())
+	}
+	return nil
+}
+
+func rollbackDB(c *cli.Context) error {
+	steps := c.Int("steps")
+	if steps <= 0 {
+		fmt.Println("❌ steps must be greater than zero")
+		os.Exit(1)
+	}
+	m, err := migrations.CreateMigrator(db.Conn)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	done, err := m.Rollback(steps, c.Bool("force"))
+	if !silentFlag {
+		for _, mig := range done {
+			fmt.Printf("✅ rolled back migration %d (%s)\n", mig.Version, mig.Name)
+		}
+	}
+	if errors.Is(err, migrations.ErrBaselineRollback) {
+		fmt.Println("❌ rolling back the baseline drops every table, including users. Use --force to continue")
+		os.Exit(1)
+	}
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	if !silentFlag {
+		current, err := m.Current()
+		if err != nil {
+			return fmt.Errorf("❌ %w", err)
+		}
+		fmt.Printf("✅ schema is at version %d, latest is %d\n", current, m.Latest())
+	}
+	return nil
+}
+
+func statusDB(c *cli.Context) error {
+	m, err := migrations.CreateMigrator(db.Conn)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	status, err := m.Status()
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	header := []string{
+		"Version",
+		"Name",
+		"Applied",
+		"AppliedAt",
+	}
+	// Prepare output
+	switch formatFlag {
+	case jsonFormat:
+		jsonRaw, err := json.Marshal(status)
+		if err != nil {
+			return fmt.Errorf("❌ error marshaling - %w", err)
+		}
+		fmt.Println(string(jsonRaw))
+	case csvFormat:
+		data := migrationsToData(status, header)
+		w := csv.NewWriter(os.Stdout)
+		if err := w.WriteAll(data); err != nil {
+			return fmt.Errorf("❌ error writing csv - %w", err)
+		}
+	case prettyFormat:
+		current, err := m.Current()
+		if err != nil {
+			return fmt.Errorf("❌ %w", err)
+		}
+		fmt.Printf("Schema version %d, latest is %d\n", current, m.Latest())
+		table := tablewriter.NewWriter(os.Stdout)
+		table.Header(stringSliceToAnySlice(header)...)
+		table.Bulk(migrationsToData(status, nil))
+		table.Render()
+		if err := m.Check(); err != nil {
+			fmt.Printf("❌ %s\n", err)
+		}
+	}
+	return nil
+}
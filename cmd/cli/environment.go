@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/jmpsec/osctrl/pkg/config"
 	"github.com/jmpsec/osctrl/pkg/environments"
+	"github.com/jmpsec/osctrl/pkg/nodes"
 	"github.com/jmpsec/osctrl/pkg/tags"
 	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli/v2"
@@ -184,6 +186,335 @@ func updateEnvironment(c *cli.Context) error {
 	return nil
 }
 
+func carveLimitsEnvironment(c *cli.Context) error {
+	// Get environment name
+	envName := c.String("name")
+	if envName == "" {
+		fmt.Println("❌ environment name is required")
+		os.Exit(1)
+	}
+	if dbFlag {
+		env, err := envs.Get(envName)
+		if err != nil {
+			return err
+		}
+		// Values not provided are kept as they are
+		maxSize := env.CarveMaxSize
+		if c.IsSet("max-size") {
+			maxSize = c.Int64("max-size")
+		}
+		quota := env.CarveQuota
+		if c.IsSet("quota") {
+			quota = c.Int64("quota")
+		}
+		retention := env.CarveRetention
+		if c.IsSet("retention") {
+			retention = c.Int("retention")
+		}
+		if maxSize < 0 || quota < 0 || retention < 0 {
+			fmt.Println("❌ carve limits can not be negative")
+			os.Exit(1)
+		}
+		if err := envs.UpdateCarveLimits(envName, maxSize, quota, retention); err != nil {
+			return err
+		}
+		// Audit log
+		auditlogsmgr.EnvAction(getShellUsername(), "update carve limits for environment "+envName, "CLI", env.ID)
+		if !silentFlag {
+			fmt.Printf("✅ carve limits for %s updated: max size %d, quota %d, retention %d days\n", envName, maxSize, quota, retention)
+		}
+	} else if apiFlag {
+		fmt.Println("❌ API not supported yet for this operation")
+		os.Exit(1)
+	}
+	return nil
+}
+
+func nodeLifecycleEnvironment(c *cli.Context) error {
+	// Get environment name
+	envName := c.String("name")
+	if envName == "" {
+		fmt.Println("❌ environment name is required")
+		os.Exit(1)
+	}
+	if dbFlag {
+		env, err := envs.Get(envName)
+		if err != nil {
+			return err
+		}
+		// Values not provided are kept as they are
+		archiveDays := env.NodeArchiveDays
+		if c.IsSet("archive-days") {
+			archiveDays = c.Int("archive-days")
+		}
+		purgeDays := env.NodePurgeDays
+		if c.IsSet("purge-days") {
+			purgeDays = c.Int("purge-days")
+		}
+		if archiveDays < 0 || purgeDays < 0 {
+			fmt.Println("❌ days can not be negative")
+			os.Exit(1)
+		}
+		if err := envs.UpdateNodeLifecycle(envName, archiveDays, purgeDays); err != nil {
+			return err
+		}
+		// Audit log
+		auditlogsmgr.EnvAction(getShellUsername(), "update node lifecycle for environment "+envName, "CLI", env.ID)
+		fmt.Printf("✅ node lifecycle for %s updated: archive after %d days, purge after %d days\n", envName, archiveDays, purgeDays)
+	} else if apiFlag {
+		fmt.Println("❌ API not supported yet for this operation")
+		os.Exit(1)
+	}
+	return nil
+}
+
+func duplicatePolicyEnvironment(c *cli.Context) error {
+	// Get environment name
+	envName := c.String("name")
+	if envName == "" {
+		fmt.Println("❌ environment name is required")
+		os.Exit(1)
+	}
+	policy := c.String("policy")
+	if !nodes.ValidDuplicatePolicy(policy) {
+		fmt.Printf("❌ invalid policy %s\n", policy)
+		os.Exit(1)
+	}
+	if dbFlag {
+		env, err := envs.Get(envName)
+		if err != nil {
+			return err
+		}
+		if err := envs.UpdateDuplicatePolicy(envName, policy); err != nil {
+			return err
+		}
+		// Audit log
+		auditlogsmgr.EnvAction(getShellUsername(), "update duplicate policy for environment "+envName, "CLI", env.ID)
+		fmt.Printf("✅ duplicate policy for %s updated to %q\n", envName, policy)
+	} else if apiFlag {
+		fmt.Println("❌ API not supported yet for this operation")
+		os.Exit(1)
+	}
+	return nil
+}
+
+func enrollPolicyEnvironment(c *cli.Context) error {
+	// Get environment name
+	envName := c.String("name")
+	if envName == "" {
+		fmt.Println("❌ environment name is required")
+		os.Exit(1)
+	}
+	requireTokens := c.Bool("require-tokens")
+	approval := c.Bool("approval")
+	if dbFlag {
+		env, err := envs.Get(envName)
+		if err != nil {
+			return err
+		}
+		if err := envs.UpdateEnrollPolicy(envName, requireTokens, approval); err != nil {
+			return err
+		}
+		// Audit log
+		auditlogsmgr.EnvAction(getShellUsername(), "update enroll policy for environment "+envName, "CLI", env.ID)
+		fmt.Printf("✅ enroll policy for %s updated (tokens required: %v, approval: %v)\n", envName, requireTokens, approval)
+	} else if apiFlag {
+		fmt.Println("❌ API not supported yet for this operation")
+		os.Exit(1)
+	}
+	return nil
+}
+
+func mtlsEnvironment(c *cli.Context) error {
+	// Get environment name
+	envName := c.String("name")
+	if envName == "" {
+		fmt.Println("❌ environment name is required")
+		os.Exit(1)
+	}
+	enabled := c.Bool("enable")
+	if dbFlag {
+		env, err := envs.Get(envName)
+		if err != nil {
+			return err
+		}
+		if err := envs.UpdateClientCerts(envName, enabled); err != nil {
+			return err
+		}
+		// Audit log
+		auditlogsmgr.EnvAction(getShellUsername(), "update client certificates for environment "+envName, "CLI", env.ID)
+		fmt.Printf("✅ client certificates for %s updated (enabled: %v)\n", envName, enabled)
+	} else if apiFlag {
+		fmt.Println("❌ API not supported yet for this operation")
+		os.Exit(1)
+	}
+	return nil
+}
+
+// Helper function to convert enrollment tokens into the data expected for output
+func enrollTokensToData(tokens []environments.EnrollToken, header []string) [][]string {
+	var data [][]string
+	if header != nil {
+		data = append(data, header)
+	}
+	for _, t := range tokens {
+		expires := "never"
+		if !t.ExpiresAt.IsZero() {
+			expires = t.ExpiresAt.Format(time.RFC3339)
+		}
+		maxUses := "unlimited"
+		if t.MaxUses > 0 {
+			maxUses = strconv.Itoa(t.MaxUses)
+		}
+		data = append(data, []string{
+			t.TokenID,
+			t.Hostname,
+			t.Serial,
+			strconv.Itoa(t.Uses),
+			maxUses,
+			expires,
+			t.LastUsedBy,
+			t.CreatedBy,
+			stringifyBool(t.Revoked),
+		})
+	}
+	return data
+}
+
+func createEnrollToken(c *cli.Context) error {
+	// Get values from flags
+	envName := c.String("name")
+	if envName == "" {
+		fmt.Println("❌ environment name is required")
+		os.Exit(1)
+	}
+	hostname := c.String("hostname")
+	serial := c.String("serial")
+	maxUses := c.Int("max-uses")
+	expHours := c.Int("expire-hours")
+	var token string
+	if dbFlag {
+		env, err := envs.Get(envName)
+		if err != nil {
+			return err
+		}
+		token, _, err = envs.CreateEnrollToken(env.ID, hostname, serial, getShellUsername(), maxUses, expHours)
+		if err != nil {
+			return fmt.Errorf("error creating token - %w", err)
+		}
+		// Audit log
+		auditlogsmgr.EnvAction(getShellUsername(), "create enroll token for environment "+envName, "CLI", env.ID)
+	} else if apiFlag {
+		res, err := osctrlAPI.CreateEnrollToken(envName, hostname, serial, maxUses, expHours)
+		if err != nil {
+			return fmt.Errorf("error creating token - %w", err)
+		}
+		token = res.Token
+	}
+	if !silentFlag {
+		fmt.Println("✅ enroll token created, it will not be shown again")
+	}
+	fmt.Println(token)
+	return nil
+}
+
+func listEnrollTokens(c *cli.Context) error {
+	// Get values from flags
+	envName := c.String("name")
+	if envName == "" {
+		fmt.Println("❌ environment name is required")
+		os.Exit(1)
+	}
+	// Retrieve data
+	var tokens []environments.EnrollToken
+	if dbFlag {
+		env, err := envs.Get(envName)
+		if err != nil {
+			return err
+		}
+		tokens, err = envs.GetEnrollTokens(env.ID)
+		if err != nil {
+			return fmt.Errorf("error getting tokens - %w", err)
+		}
+	} else if apiFlag {
+		tokens, err = osctrlAPI.GetEnrollTokens(envName)
+		if err != nil {
+			return fmt.Errorf("error getting tokens - %w", err)
+		}
+	}
+	header := []string{
+		"ID",
+		"Hostname",
+		"Serial",
+		"Uses",
+		"Max Uses",
+		"Expires",
+		"Last Used By",
+		"Created By",
+		"Revoked?",
+	}
+	// Prepare output
+	switch formatFlag {
+	case jsonFormat:
+		jsonRaw, err := json.Marshal(tokens)
+		if err != nil {
+			return fmt.Errorf("error serializing - %w", err)
+		}
+		fmt.Println(string(jsonRaw))
+	case csvFormat:
+		data := enrollTokensToData(tokens, header)
+		w := csv.NewWriter(os.Stdout)
+		if err := w.WriteAll(data); err != nil {
+			return fmt.Errorf("error WriteAll - %w", err)
+		}
+	case prettyFormat:
+		table := tablewriter.NewWriter(os.Stdout)
+		table.Header(stringSliceToAnySlice(header)...)
+		if len(tokens) > 0 {
+			fmt.Printf("Existing enroll tokens for %s (%d):\n", envName, len(tokens))
+			data := enrollTokensToData(tokens, nil)
+			table.Bulk(data)
+		} else {
+			fmt.Printf("No enroll tokens for %s\n", envName)
+		}
+		table.Render()
+	}
+	return nil
+}
+
+func revokeEnrollToken(c *cli.Context) error {
+	// Get values from flags
+	envName := c.String("name")
+	if envName == "" {
+		fmt.Println("❌ environment name is required")
+		os.Exit(1)
+	}
+	tokenID := c.String("token")
+	if tokenID == "" {
+		fmt.Println("❌ token is required")
+		os.Exit(1)
+	}
+	if dbFlag {
+		env, err := envs.Get(envName)
+		if err != nil {
+			return err
+		}
+		if err := envs.RevokeEnrollToken(env.ID, tokenID); err != nil {
+			return fmt.Errorf("error revoking token - %w", err)
+		}
+		// Audit log
+		auditlogsmgr.EnvAction(getShellUsername(), "revoke enroll token "+tokenID+" for environment "+envName, "CLI", env.ID)
+	} else if apiFlag {
+		if err := osctrlAPI.RevokeEnrollToken(envName, tokenID); err != nil {
+			return fmt.Errorf("error revoking token - %w", err)
+		}
+	}
+	if !silentFlag {
+		fmt.Println("✅ enroll token was revoked successfully")
+	}
+	return nil
+}
+
 func deleteEnvironment(c *cli.Context) error {
 	// Get environment name
 	envName := c.String("name")
@@ -247,6 +578,15 @@ func showEnvironment(c *cli.Context) error {
 	fmt.Printf(" Query Interval: %d seconds\n", env.QueryInterval)
 	fmt.Printf(" Carve Init Path: /%s/%s\n", env.UUID, env.CarverInitPath)
 	fmt.Printf(" Carve Block Path: /%s/%s\n", env.UUID, env.CarverBlockPath)
+	fmt.Printf(" Carve Max Size: %d bytes\n", env.CarveMaxSize)
+	fmt.Printf(" Carve Quota: %d bytes\n", env.CarveQuota)
+	fmt.Printf(" Carve Retention: %d days\n", env.CarveRetention)
+	fmt.Printf(" Node Archive: %d days\n", env.NodeArchiveDays)
+	fmt.Printf(" Node Purge: %d days\n", env.NodePurgeDays)
+	fmt.Printf(" Duplicate Policy: %s\n", env.DuplicatePolicy)
+	fmt.Printf(" Enroll Tokens Required? %v\n", env.EnrollTokens)
+	fmt.Printf(" Enroll Approval? %v\n", env.EnrollApproval)
+	fmt.Printf(" Client Certificates (mTLS)? %v\n", env.ClientCerts)
 	fmt.Println(" Flags: ")
 	fmt.Printf("%s\n", env.Flags)
 	fmt.Println(" Options: ")
@@ -641,6 +981,8 @@ func genFlagsEnvironment(c *cli.Context) error {
 	}
 	secret := c.String("secret")
 	cert := c.String("certificate")
+	clientCert := c.String("client-cert")
+	clientKey := c.String("client-key")
 	// Get osquery values to generate flags
 	osqueryValues := config.OsqueryConfiguration{
 		Config: c.Bool("config"),
@@ -660,7 +1002,7 @@ func genFlagsEnvironment(c *cli.Context) error {
 			return err
 		}
 	}
-	flags, err := envs.GenerateFlags(env, secret, cert, osqueryValues)
+	flags, err := envs.GenerateFlagsClient(env, secret, cert, clientCert, clientKey, osqueryValues)
 	if err != nil {
 		return err
 	}
@@ -5,9 +5,11 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/jmpsec/osctrl/pkg/auditlog"
 	"github.com/jmpsec/osctrl/pkg/backend"
+	"github.com/jmpsec/osctrl/pkg/cache"
 	"github.com/jmpsec/osctrl/pkg/carves"
 	"github.com/jmpsec/osctrl/pkg/config"
 	"github.com/jmpsec/osctrl/pkg/environments"
@@ -59,6 +61,7 @@ var (
 	err         error
 	app         *cli.App
 	dbConfig    backend.JSONConfigurationDB
+	redisConfig cache.JSONConfigurationRedis
 	apiConfig   JSONConfigurationAPI
 	flags       []cli.Flag
 	commands    []*cli.Command
@@ -85,7 +88,16 @@ var (
 	verboseFlag      bool
 	writeApiFileFlag bool
 	dbConfigFile     string
+	redisConfigFile  string
 	apiConfigFile    string
+	carverType       string
+	carverConfigFile string
+)
+
+// Carver configuration values
+var (
+	s3CarverConfig    config.S3Configuration
+	localCarverConfig config.LocalCarverConfiguration
 )
 
 // Initialization code
@@ -199,6 +211,97 @@ func init() {
 			EnvVars:     []string{"DB_CONN_MAX_LIFETIME"},
 			Destination: &dbConfig.ConnMaxLifetime,
 		},
+		&cli.StringFlag{
+			Name:        "redis-file",
+			Value:       "",
+			Usage:       "Load redis JSON configuration from `FILE`, to evict cached data in osctrl services",
+			EnvVars:     []string{"REDIS_CONFIG_FILE"},
+			Destination: &redisConfigFile,
+		},
+		&cli.StringFlag{
+			Name:        "redis-connection-string",
+			Value:       "",
+			Usage:       "Redis connection string, must include schema (<redis|rediss|unix>://<user>:<pass>@<host>:<port>/<db>?<options>",
+			EnvVars:     []string{"REDIS_CONNECTION_STRING"},
+			Destination: &redisConfig.ConnectionString,
+		},
+		&cli.StringFlag{
+			Name:        "redis-host",
+			Value:       "",
+			Usage:       "Redis host to be connected to, to evict cached data in osctrl services",
+			EnvVars:     []string{"REDIS_HOST"},
+			Destination: &redisConfig.Host,
+		},
+		&cli.StringFlag{
+			Name:        "redis-port",
+			Value:       "6379",
+			Usage:       "Redis port to be connected to",
+			EnvVars:     []string{"REDIS_PORT"},
+			Destination: &redisConfig.Port,
+		},
+		&cli.StringFlag{
+			Name:        "redis-pass",
+			Value:       "",
+			Usage:       "Password to be used for redis",
+			EnvVars:     []string{"REDIS_PASS"},
+			Destination: &redisConfig.Password,
+		},
+		&cli.IntFlag{
+			Name:        "redis-db",
+			Value:       0,
+			Usage:       "Redis database to be selected after connecting",
+			EnvVars:     []string{"REDIS_DB"},
+			Destination: &redisConfig.DB,
+		},
+		&cli.StringFlag{
+			Name:        "carver-type",
+			Value:       config.CarverDB,
+			Usage:       "Carver used by osctrl services, to read the content of carves",
+			EnvVars:     []string{"CARVER_TYPE"},
+			Destination: &carverType,
+		},
+		&cli.StringFlag{
+			Name:        "carver-file",
+			Value:       "",
+			Usage:       "Load carver JSON configuration from `FILE`",
+			EnvVars:     []string{"CARVER_FILE"},
+			Destination: &carverConfigFile,
+		},
+		&cli.StringFlag{
+			Name:        "carver-s3-bucket",
+			Value:       "",
+			Usage:       "S3 bucket to be used as configuration for carves",
+			EnvVars:     []string{"CARVER_S3_BUCKET"},
+			Destination: &s3CarverConfig.Bucket,
+		},
+		&cli.StringFlag{
+			Name:        "carver-s3-region",
+			Value:       "",
+			Usage:       "S3 region to be used as configuration for carves",
+			EnvVars:     []string{"CARVER_S3_REGION"},
+			Destination: &s3CarverConfig.Region,
+		},
+		&cli.StringFlag{
+			Name:        "carver-s3-key-id",
+			Value:       "",
+			Usage:       "S3 access key id to be used as configuration for carves",
+			EnvVars:     []string{"CARVER_S3_KEY_ID"},
+			Destination: &s3CarverConfig.AccessKey,
+		},
+		&cli.StringFlag{
+			Name:        "carver-s3-secret",
+			Value:       "",
+			Usage:       "S3 access key secret to be used as configuration for carves",
+			EnvVars:     []string{"CARVER_S3_SECRET"},
+			Destination: &s3CarverConfig.SecretAccessKey,
+		},
+		&cli.StringFlag{
+			Name:        "carver-local-dir",
+			Value:       "",
+			Usage:       "Directory to be used as configuration for the local carver",
+			EnvVars:     []string{"CARVER_LOCAL_DIR"},
+			Destination: &localCarverConfig.Dir,
+		},
 		&cli.BoolFlag{
 			Name:        "insecure",
 			Aliases:     []string{"i"},
@@ -335,6 +438,18 @@ func init() {
 							Aliases: []string{"env"},
 							Usage:   "Grant read access to this environment",
 						},
+						&cli.BoolFlag{
+							Name:    "reset-mfa",
+							Aliases: []string{"M"},
+							Hidden:  false,
+							Usage:   "Remove the second factor of this user, so it must enroll again",
+						},
+						&cli.BoolFlag{
+							Name:    "unlock",
+							Aliases: []string{"U"},
+							Hidden:  false,
+							Usage:   "Remove the lockout of this user after too many failed logins",
+						},
 					},
 					Action: cliWrapper(editUser),
 				},
@@ -485,6 +600,78 @@ func init() {
 					Usage:   "List all existing users",
 					Action:  cliWrapper(listUsers),
 				},
+				{
+					Name:    "token",
+					Aliases: []string{"t"},
+					Usage:   "Commands for named API tokens of users",
+					Subcommands: []*cli.Command{
+						{
+							Name:    "create",
+							Aliases: []string{"c"},
+							Usage:   "Create a new named API token for a user",
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "username",
+									Aliases: []string{"u"},
+									Usage:   "User to create the token for",
+								},
+								&cli.StringFlag{
+									Name:    "name",
+									Aliases: []string{"n"},
+									Usage:   "Name of the token",
+								},
+								&cli.StringFlag{
+									Name:    "scope",
+									Aliases: []string{"s"},
+									Value:   users.TokenScopeRead,
+									Usage:   "Scope of the token (read-only, query, carve, admin)",
+								},
+								&cli.StringFlag{
+									Name:    "environment",
+									Aliases: []string{"e"},
+									Usage:   "Environment to restrict the token to, all environments if empty",
+								},
+								&cli.IntFlag{
+									Name:    "expire-hours",
+									Aliases: []string{"E"},
+									Usage:   "Hours for the token to expire, default JWT expiration if empty",
+								},
+							},
+							Action: cliWrapper(createUserToken),
+						},
+						{
+							Name:    "list",
+							Aliases: []string{"l"},
+							Usage:   "List all named API tokens of a user",
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "username",
+									Aliases: []string{"u"},
+									Usage:   "User to list tokens for",
+								},
+							},
+							Action: cliWrapper(listUserTokens),
+						},
+						{
+							Name:    "revoke",
+							Aliases: []string{"r"},
+							Usage:   "Revoke a named API token of a user",
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "username",
+									Aliases: []string{"u"},
+									Usage:   "User owning the token",
+								},
+								&cli.StringFlag{
+									Name:    "token",
+									Aliases: []string{"t"},
+									Usage:   "ID of the token to revoke",
+								},
+							},
+							Action: cliWrapper(revokeUserToken),
+						},
+					},
+				},
 			},
 		},
 		{
@@ -632,6 +819,182 @@ func init() {
 					},
 					Action: cliWrapper(updateEnvironment),
 				},
+				{
+					Name:  "carve-limits",
+					Usage: "Update the carve limits of an existing TLS environment",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "name",
+							Aliases: []string{"n"},
+							Usage:   "Environment name to be updated",
+						},
+						&cli.Int64Flag{
+							Name:    "max-size",
+							Aliases: []string{"m"},
+							Usage:   "Maximum size in bytes of a single carve, 0 for no limit",
+						},
+						&cli.Int64Flag{
+							Name:    "quota",
+							Aliases: []string{"q"},
+							Usage:   "Total bytes of carves that can be stored, 0 for no limit",
+						},
+						&cli.IntFlag{
+							Name:    "retention",
+							Aliases: []string{"r"},
+							Usage:   "Days to keep carves before they are purged, 0 to keep them forever",
+						},
+					},
+					Action: cliWrapper(carveLimitsEnvironment),
+				},
+				{
+					Name:  "node-lifecycle",
+					Usage: "Update the node lifecycle policy of an existing TLS environment",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "name",
+							Aliases: []string{"n"},
+							Usage:   "Environment name to be updated",
+						},
+						&cli.IntFlag{
+							Name:    "archive-days",
+							Aliases: []string{"a"},
+							Usage:   "Days without being seen to archive nodes, 0 to disable",
+						},
+						&cli.IntFlag{
+							Name:    "purge-days",
+							Aliases: []string{"p"},
+							Usage:   "Days after being archived to purge nodes, 0 to disable",
+						},
+					},
+					Action: cliWrapper(nodeLifecycleEnvironment),
+				},
+				{
+					Name:  "duplicate-policy",
+					Usage: "Update how duplicated nodes are handled when enrolling in an existing TLS environment",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "name",
+							Aliases: []string{"n"},
+							Usage:   "Environment name to be updated",
+						},
+						&cli.StringFlag{
+							Name:    "policy",
+							Aliases: []string{"p"},
+							Usage:   "Policy for duplicated nodes: merge, keep or reject, empty to update nodes with the same UUID",
+						},
+					},
+					Action: cliWrapper(duplicatePolicyEnvironment),
+				},
+				{
+					Name:  "enroll-policy",
+					Usage: "Update if an existing TLS environment requires enroll tokens and approval of new nodes",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "name",
+							Aliases: []string{"n"},
+							Usage:   "Environment name to be updated",
+						},
+						&cli.BoolFlag{
+							Name:    "require-tokens",
+							Aliases: []string{"t"},
+							Usage:   "Only accept enroll tokens, rejecting the shared enroll secret",
+						},
+						&cli.BoolFlag{
+							Name:    "approval",
+							Aliases: []string{"a"},
+							Usage:   "New nodes need approval before receiving configuration and queries",
+						},
+					},
+					Action: cliWrapper(enrollPolicyEnvironment),
+				},
+				{
+					Name:  "mtls",
+					Usage: "Update if nodes of an existing TLS environment use client certificates (mTLS)",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "name",
+							Aliases: []string{"n"},
+							Usage:   "Environment name to be updated",
+						},
+						&cli.BoolFlag{
+							Name:    "enable",
+							Aliases: []string{"E"},
+							Usage:   "Issue client certificates to nodes and reject requests without them",
+						},
+					},
+					Action: cliWrapper(mtlsEnvironment),
+				},
+				{
+					Name:  "enroll-token",
+					Usage: "Commands for enroll tokens of an environment",
+					Subcommands: []*cli.Command{
+						{
+							Name:    "create",
+							Aliases: []string{"c"},
+							Usage:   "Create a new enroll token, optionally bound to a host",
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "name",
+									Aliases: []string{"n"},
+									Usage:   "Environment name for the token",
+								},
+								&cli.StringFlag{
+									Name:    "hostname",
+									Aliases: []string{"H"},
+									Usage:   "Hostname allowed to use the token, any host if empty",
+								},
+								&cli.StringFlag{
+									Name:    "serial",
+									Aliases: []string{"s"},
+									Usage:   "Hardware serial allowed to use the token, any serial if empty",
+								},
+								&cli.IntFlag{
+									Name:    "max-uses",
+									Aliases: []string{"m"},
+									Value:   1,
+									Usage:   "Times the token can be used, 0 for unlimited",
+								},
+								&cli.IntFlag{
+									Name:    "expire-hours",
+									Aliases: []string{"E"},
+									Usage:   "Hours for the token to expire, 0 for no expiration",
+								},
+							},
+							Action: cliWrapper(createEnrollToken),
+						},
+						{
+							Name:    "list",
+							Aliases: []string{"l"},
+							Usage:   "List all enroll tokens of an environment",
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "name",
+									Aliases: []string{"n"},
+									Usage:   "Environment name to list tokens for",
+								},
+							},
+							Action: cliWrapper(listEnrollTokens),
+						},
+						{
+							Name:    "revoke",
+							Aliases: []string{"r"},
+							Usage:   "Revoke an enroll token of an environment",
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "name",
+									Aliases: []string{"n"},
+									Usage:   "Environment name of the token",
+								},
+								&cli.StringFlag{
+									Name:    "token",
+									Aliases: []string{"t"},
+									Usage:   "ID of the token to revoke",
+								},
+							},
+							Action: cliWrapper(revokeEnrollToken),
+						},
+					},
+				},
 				{
 					Name:  "add-scheduled-query",
 					Usage: "Add a new query to the osquery schedule for an environment",
@@ -955,6 +1318,14 @@ func init() {
 									Aliases: []string{"crt"},
 									Usage:   "Certificate file path to be used",
 								},
+								&cli.StringFlag{
+									Name:  "client-cert",
+									Usage: "Client certificate file path to be used, for environments using mTLS",
+								},
+								&cli.StringFlag{
+									Name:  "client-key",
+									Usage: "Client key file path to be used, for environments using mTLS",
+								},
 								&cli.StringFlag{
 									Name:    "secret",
 									Aliases: []string{"s"},
@@ -1366,76 +1737,100 @@ func init() {
 					},
 					Action: cliWrapper(lookupNode),
 				},
-			},
-		},
-		{
-			Name:  "query",
-			Usage: "Commands for queries",
-			Subcommands: []*cli.Command{
 				{
-					Name:    "complete",
-					Aliases: []string{"c"},
-					Usage:   "Mark an on-demand query as completed",
+					Name:  "lifecycle",
+					Usage: "Archive unseen nodes and purge archived nodes using the lifecycle policy of an environment",
 					Flags: []cli.Flag{
 						&cli.StringFlag{
-							Name:    "name",
+							Name:    "env",
+							Aliases: []string{"e"},
+							Usage:   "Environment to be used",
+						},
+						&cli.IntFlag{
+							Name:    "archive-days",
+							Aliases: []string{"a"},
+							Usage:   "Days without being seen to archive nodes, overrides the environment policy",
+						},
+						&cli.IntFlag{
+							Name:    "purge-days",
+							Aliases: []string{"p"},
+							Usage:   "Days after being archived to purge nodes, overrides the environment policy",
+						},
+						&cli.BoolFlag{
+							Name:    "dry-run",
 							Aliases: []string{"n"},
-							Usage:   "Query name to be completed",
+							Usage:   "Report the nodes to be archived and purged without changing anything",
 						},
+					},
+					Action: cliWrapper(lifecycleNodes),
+				},
+				{
+					Name:  "duplicates",
+					Usage: "List suspected duplicated nodes by UUID, hardware serial or hostname",
+					Flags: []cli.Flag{
 						&cli.StringFlag{
 							Name:    "env",
 							Aliases: []string{"e"},
 							Usage:   "Environment to be used",
 						},
 					},
-					Action: cliWrapper(completeQuery),
+					Action: cliWrapper(duplicateNodes),
 				},
 				{
-					Name:    "delete",
-					Aliases: []string{"d"},
-					Usage:   "Mark an on-demand query as deleted",
+					Name:  "merge",
+					Usage: "Merge duplicated nodes into one node, keeping tags and query history",
 					Flags: []cli.Flag{
-						&cli.StringFlag{
-							Name:    "name",
-							Aliases: []string{"n"},
-							Usage:   "Query name to be deleted",
-						},
 						&cli.StringFlag{
 							Name:    "env",
 							Aliases: []string{"e"},
 							Usage:   "Environment to be used",
 						},
+						&cli.UintFlag{
+							Name:    "target",
+							Aliases: []string{"t"},
+							Usage:   "ID of the node to keep",
+						},
+						&cli.UintSliceFlag{
+							Name:    "duplicate",
+							Aliases: []string{"d"},
+							Usage:   "ID of the node to be merged, can be repeated",
+						},
 					},
-					Action: cliWrapper(deleteQuery),
+					Action: cliWrapper(mergeNodes),
 				},
 				{
-					Name:    "expire",
-					Aliases: []string{"e"},
-					Usage:   "Mark an on-demand query as expired",
+					Name:  "pending",
+					Usage: "List nodes waiting for approval before receiving configuration and queries",
 					Flags: []cli.Flag{
-						&cli.StringFlag{
-							Name:    "name",
-							Aliases: []string{"n"},
-							Usage:   "Query name to be expired",
-						},
 						&cli.StringFlag{
 							Name:    "env",
 							Aliases: []string{"e"},
 							Usage:   "Environment to be used",
 						},
 					},
-					Action: cliWrapper(expireQuery),
+					Action: cliWrapper(pendingNodes),
 				},
 				{
-					Name:    "run",
-					Aliases: []string{"r"},
-					Usage:   "Start a new on-demand query",
+					Name:  "approve",
+					Usage: "Approve nodes waiting for approval",
 					Flags: []cli.Flag{
 						&cli.StringFlag{
-							Name:    "query",
-							Aliases: []string{"q"},
-							Usage:   "Query to be issued",
+							Name:    "env",
+							Aliases: []string{"e"},
+							Usage:   "Environment to be used",
 						},
+						&cli.StringSliceFlag{
+							Name:    "uuid",
+							Aliases: []string{"u"},
+							Usage:   "UUID of the node to be approved, can be repeated",
+						},
+					},
+					Action: cliWrapper(approveNodes),
+				},
+				{
+					Name:  "revoke-cert",
+					Usage: "Revoke the client certificates of a node, for environments using mTLS",
+					Flags: []cli.Flag{
 						&cli.StringFlag{
 							Name:    "env",
 							Aliases: []string{"e"},
@@ -1444,7 +1839,197 @@ func init() {
 						&cli.StringFlag{
 							Name:    "uuid",
 							Aliases: []string{"u"},
-							Usage:   "Node UUID(s) to be used. Comma separated for multiple values",
+							Usage:   "UUID of the node",
+						},
+					},
+					Action: cliWrapper(revokeCertNode),
+				},
+				{
+					Name:  "clear-cert",
+					Usage: "Clear the revoked client certificates of a node, so it can get new certificates",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "env",
+							Aliases: []string{"e"},
+							Usage:   "Environment to be used",
+						},
+						&cli.StringFlag{
+							Name:    "uuid",
+							Aliases: []string{"u"},
+							Usage:   "UUID of the node",
+						},
+					},
+					Action: cliWrapper(clearCertNode),
+				},
+			},
+		},
+		{
+			Name:  "query",
+			Usage: "Commands for queries",
+			Subcommands: []*cli.Command{
+				{
+					Name:    "complete",
+					Aliases: []string{"c"},
+					Usage:   "Mark an on-demand query as completed",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "name",
+							Aliases: []string{"n"},
+							Usage:   "Query name to be completed",
+						},
+						&cli.StringFlag{
+							Name:    "env",
+							Aliases: []string{"e"},
+							Usage:   "Environment to be used",
+						},
+					},
+					Action: cliWrapper(completeQuery),
+				},
+				{
+					Name:    "delete",
+					Aliases: []string{"d"},
+					Usage:   "Mark an on-demand query as deleted",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "name",
+							Aliases: []string{"n"},
+							Usage:   "Query name to be deleted",
+						},
+						&cli.StringFlag{
+							Name:    "env",
+							Aliases: []string{"e"},
+							Usage:   "Environment to be used",
+						},
+					},
+					Action: cliWrapper(deleteQuery),
+				},
+				{
+					Name:    "expire",
+					Aliases: []string{"e"},
+					Usage:   "Mark an on-demand query as expired",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "name",
+							Aliases: []string{"n"},
+							Usage:   "Query name to be expired",
+						},
+						&cli.StringFlag{
+							Name:    "env",
+							Aliases: []string{"e"},
+							Usage:   "Environment to be used",
+						},
+					},
+					Action: cliWrapper(expireQuery),
+				},
+				{
+					Name:    "export",
+					Aliases: []string{"x"},
+					Usage:   "Export the results of an on-demand query as CSV, NDJSON or Parquet",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "name",
+							Aliases: []string{"n"},
+							Usage:   "Query name to be exported",
+						},
+						&cli.StringFlag{
+							Name:    "env",
+							Aliases: []string{"e"},
+							Usage:   "Environment to be used",
+						},
+						&cli.StringFlag{
+							Name:    "format",
+							Aliases: []string{"f"},
+							Value:   "csv",
+							Usage:   "Export format: csv, ndjson or parquet",
+						},
+						&cli.StringFlag{
+							Name:    "output",
+							Aliases: []string{"o"},
+							Usage:   "File to write the export, stdout if empty",
+						},
+					},
+					Action: cliWrapper(exportQuery),
+				},
+				{
+					Name:    "status",
+					Aliases: []string{"S"},
+					Usage:   "Show the status of an on-demand query in each target node",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "name",
+							Aliases: []string{"n"},
+							Usage:   "Query name to be displayed",
+						},
+						&cli.StringFlag{
+							Name:    "env",
+							Aliases: []string{"e"},
+							Usage:   "Environment to be used",
+						},
+						&cli.StringFlag{
+							Name:    "status",
+							Aliases: []string{"s"},
+							Usage:   "Only show target nodes with this status: pending, completed, error or expired",
+						},
+					},
+					Action: cliWrapper(statusQuery),
+				},
+				{
+					Name:    "preview",
+					Aliases: []string{"P"},
+					Usage:   "Preview the nodes targeted by a query or carve before launching it",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "env",
+							Aliases: []string{"e"},
+							Usage:   "Environment to be used",
+						},
+						&cli.StringFlag{
+							Name:    "uuid",
+							Aliases: []string{"u"},
+							Usage:   "Node UUID(s) to be used. Comma separated for multiple values",
+						},
+						&cli.StringFlag{
+							Name:    "host",
+							Aliases: []string{"hostname", "H"},
+							Usage:   "Node hostname(s) to be used. Comma separated for multiple values",
+						},
+						&cli.StringFlag{
+							Name:    "platform",
+							Aliases: []string{"p"},
+							Usage:   "Node platform(s) to be used. Comma separated for multiple values",
+						},
+						&cli.StringFlag{
+							Name:    "tag",
+							Aliases: []string{"t"},
+							Usage:   "Tag(s) to be used. Comma separated for multiple values",
+						},
+						&cli.StringFlag{
+							Name:    "selector",
+							Aliases: []string{"S"},
+							Usage:   "Selector expression to target nodes, ex. \"platform=darwin AND tag=prod AND osquery_version>=5.10\"",
+						},
+					},
+					Action: cliWrapper(previewQuery),
+				},
+				{
+					Name:    "run",
+					Aliases: []string{"r"},
+					Usage:   "Start a new on-demand query",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "query",
+							Aliases: []string{"q"},
+							Usage:   "Query to be issued",
+						},
+						&cli.StringFlag{
+							Name:    "env",
+							Aliases: []string{"e"},
+							Usage:   "Environment to be used",
+						},
+						&cli.StringFlag{
+							Name:    "uuid",
+							Aliases: []string{"u"},
+							Usage:   "Node UUID(s) to be used. Comma separated for multiple values",
 						},
 						&cli.StringFlag{
 							Name:    "host",
@@ -1461,12 +2046,22 @@ func init() {
 							Aliases: []string{"t"},
 							Usage:   "Tag(s) to be used. Comma separated for multiple values",
 						},
+						&cli.StringFlag{
+							Name:    "selector",
+							Aliases: []string{"S"},
+							Usage:   "Selector expression to target nodes, ex. \"platform=darwin AND tag=prod AND osquery_version>=5.10\"",
+						},
 						&cli.BoolFlag{
 							Name:    "hidden",
 							Aliases: []string{"x"},
 							Hidden:  false,
 							Usage:   "Mark query as hidden",
 						},
+						&cli.BoolFlag{
+							Name:    "urgent",
+							Aliases: []string{"U"},
+							Usage:   "Mark query as urgent, so target nodes poll for queries faster for a while",
+						},
 						&cli.IntFlag{
 							Name:    "expiration",
 							Aliases: []string{"E"},
@@ -1525,6 +2120,168 @@ func init() {
 					},
 					Action: cliWrapper(listQueries),
 				},
+				{
+					Name:    "recurring",
+					Aliases: []string{"R"},
+					Usage:   "Commands for recurring queries",
+					Subcommands: []*cli.Command{
+						{
+							Name:    "create",
+							Aliases: []string{"c"},
+							Usage:   "Create a recurring query, running on a cron schedule (UTC) or interval",
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "name",
+									Aliases: []string{"n"},
+									Usage:   "Recurring query name, generated if empty",
+								},
+								&cli.StringFlag{
+									Name:    "query",
+									Aliases: []string{"q"},
+									Usage:   "Query to be issued in every run",
+								},
+								&cli.StringFlag{
+									Name:    "env",
+									Aliases: []string{"e"},
+									Usage:   "Environment to be used",
+								},
+								&cli.StringFlag{
+									Name:    "cron",
+									Aliases: []string{"C"},
+									Usage:   "Cron expression for the runs, such as \"0 */4 * * *\"",
+								},
+								&cli.DurationFlag{
+									Name:    "interval",
+									Aliases: []string{"i"},
+									Usage:   "Interval between runs, such as 4h",
+								},
+								&cli.StringFlag{
+									Name:    "uuid",
+									Aliases: []string{"u"},
+									Usage:   "Node UUID(s) to be used. Comma separated for multiple values",
+								},
+								&cli.StringFlag{
+									Name:    "host",
+									Aliases: []string{"hostname", "H"},
+									Usage:   "Node hostname(s) to be used. Comma separated for multiple values",
+								},
+								&cli.StringFlag{
+									Name:    "platform",
+									Aliases: []string{"p"},
+									Usage:   "Node platform(s) to be used. Comma separated for multiple values",
+								},
+								&cli.StringFlag{
+									Name:    "tag",
+									Aliases: []string{"t"},
+									Usage:   "Tag(s) to be used. Comma separated for multiple values",
+								},
+								&cli.StringFlag{
+									Name:    "selector",
+									Aliases: []string{"S"},
+									Usage:   "Selector expression to target nodes, ex. \"platform=darwin AND tag=prod AND osquery_version>=5.10\"",
+								},
+								&cli.BoolFlag{
+									Name:    "hidden",
+									Aliases: []string{"x"},
+									Hidden:  false,
+									Usage:   "Mark runs as hidden",
+								},
+								&cli.IntFlag{
+									Name:    "expiration",
+									Aliases: []string{"E"},
+									Value:   6,
+									Usage:   "Expiration in hours for each run (0 for no expiration)",
+								},
+							},
+							Action: cliWrapper(createRecurringQuery),
+						},
+						{
+							Name:    "list",
+							Aliases: []string{"l"},
+							Usage:   "List recurring queries",
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "env",
+									Aliases: []string{"e"},
+									Usage:   "Environment to be used",
+								},
+							},
+							Action: cliWrapper(listRecurringQueries),
+						},
+						{
+							Name:    "show",
+							Aliases: []string{"s"},
+							Usage:   "Show a recurring query and its history of runs",
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "name",
+									Aliases: []string{"n"},
+									Usage:   "Recurring query name to be shown",
+								},
+								&cli.StringFlag{
+									Name:    "env",
+									Aliases: []string{"e"},
+									Usage:   "Environment to be used",
+								},
+							},
+							Action: cliWrapper(showRecurringQuery),
+						},
+						{
+							Name:    "pause",
+							Aliases: []string{"p"},
+							Usage:   "Pause a recurring query",
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "name",
+									Aliases: []string{"n"},
+									Usage:   "Recurring query name to be paused",
+								},
+								&cli.StringFlag{
+									Name:    "env",
+									Aliases: []string{"e"},
+									Usage:   "Environment to be used",
+								},
+							},
+							Action: cliWrapper(pauseRecurringQuery),
+						},
+						{
+							Name:    "resume",
+							Aliases: []string{"r"},
+							Usage:   "Resume a paused recurring query",
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "name",
+									Aliases: []string{"n"},
+									Usage:   "Recurring query name to be resumed",
+								},
+								&cli.StringFlag{
+									Name:    "env",
+									Aliases: []string{"e"},
+									Usage:   "Environment to be used",
+								},
+							},
+							Action: cliWrapper(resumeRecurringQuery),
+						},
+						{
+							Name:    "delete",
+							Aliases: []string{"d"},
+							Usage:   "Delete a recurring query",
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "name",
+									Aliases: []string{"n"},
+									Usage:   "Recurring query name to be deleted",
+								},
+								&cli.StringFlag{
+									Name:    "env",
+									Aliases: []string{"e"},
+									Usage:   "Environment to be used",
+								},
+							},
+							Action: cliWrapper(deleteRecurringQuery),
+						},
+					},
+				},
 			},
 		},
 		{
@@ -1620,6 +2377,11 @@ func init() {
 							Aliases: []string{"t"},
 							Usage:   "Tag(s) to be used. Comma separated for multiple values",
 						},
+						&cli.StringFlag{
+							Name:    "selector",
+							Aliases: []string{"S"},
+							Usage:   "Selector expression to target nodes, ex. \"platform=darwin AND tag=prod AND osquery_version>=5.10\"",
+						},
 						&cli.IntFlag{
 							Name:    "expiration",
 							Aliases: []string{"E"},
@@ -1685,6 +2447,52 @@ func init() {
 					},
 					Action: cliWrapper(listCarveQueries),
 				},
+				{
+					Name:    "files",
+					Aliases: []string{"f"},
+					Usage:   "List the files inside a completed file carve",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "session",
+							Aliases: []string{"s"},
+							Usage:   "Carve session ID to be inspected",
+						},
+						&cli.StringFlag{
+							Name:    "env",
+							Aliases: []string{"e"},
+							Usage:   "Environment to be used",
+						},
+					},
+					Action: cliWrapper(listCarveFiles),
+				},
+				{
+					Name:    "extract",
+					Aliases: []string{"x"},
+					Usage:   "Extract one file from inside a completed file carve",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "session",
+							Aliases: []string{"s"},
+							Usage:   "Carve session ID to extract from",
+						},
+						&cli.StringFlag{
+							Name:    "path",
+							Aliases: []string{"p"},
+							Usage:   "Path of the file inside the carve",
+						},
+						&cli.StringFlag{
+							Name:    "env",
+							Aliases: []string{"e"},
+							Usage:   "Environment to be used",
+						},
+						&cli.StringFlag{
+							Name:    "output",
+							Aliases: []string{"o"},
+							Usage:   "File to write the extracted file, stdout if empty",
+						},
+					},
+					Action: cliWrapper(extractCarveFile),
+				},
 			},
 		},
 		{
@@ -1836,6 +2644,51 @@ func init() {
 				},
 			},
 		},
+		{
+			Name:  "db",
+			Usage: "Database schema migrations, only with DB access",
+			Subcommands: []*cli.Command{
+				{
+					Name:    "migrate",
+					Aliases: []string{"m"},
+					Usage:   "Apply pending schema migrations",
+					Flags: []cli.Flag{
+						&cli.UintFlag{
+							Name:    "to",
+							Aliases: []string{"t"},
+							Value:   0,
+							Usage:   "Target version to migrate to (0 for latest)",
+						},
+					},
+					Action: dbWrapper(migrateDB),
+				},
+				{
+					Name:    "status",
+					Aliases: []string{"s"},
+					Usage:   "Show the status of all schema migrations",
+					Action:  dbWrapper(statusDB),
+				},
+				{
+					Name:    "rollback",
+					Aliases: []string{"r"},
+					Usage:   "Rollback the last applied schema migrations",
+					Flags: []cli.Flag{
+						&cli.IntFlag{
+							Name:    "steps",
+							Aliases: []string{"n"},
+							Value:   1,
+							Usage:   "Number of migrations to rollback",
+						},
+						&cli.BoolFlag{
+							Name:  "force",
+							Value: false,
+							Usage: "Allow the rollback of the baseline, dropping all tables",
+						},
+					},
+					Action: dbWrapper(rollbackDB),
+				},
+			},
+		},
 		{
 			Name:   "check-db",
 			Usage:  "Checks DB connection",
@@ -1871,6 +2724,11 @@ func init() {
 					Value:   6,
 					Usage:   "Expiration in hours (0 for server default)",
 				},
+				&cli.StringFlag{
+					Name:    "otp",
+					Aliases: []string{"o"},
+					Usage:   "Second factor code, it will be requested if needed and not provided",
+				},
 				&cli.BoolFlag{
 					Name:        "write-api-file",
 					Aliases:     []string{"w"},
@@ -1973,8 +2831,20 @@ func loginAPI(c *cli.Context) error {
 		return fmt.Errorf("error reading password %w", err)
 	}
 	fmt.Println()
-	apiResponse, err := osctrlAPI.PostLogin(env, username, string(passwordByte), expHours)
-	if err != nil {
+	otp := c.String("otp")
+	apiResponse, err := osctrlAPI.PostLogin(env, username, string(passwordByte), otp, expHours)
+	if err != nil && otp == "" && strings.Contains(err.Error(), users.ErrMFARequired.Error()) {
+		fmt.Printf("\n ->  Please introduce your second factor code: ")
+		otpByte, err := term.ReadPassword(int(os.Stdin.Fd()))
+		if err != nil {
+			return fmt.Errorf("error reading code %w", err)
+		}
+		fmt.Println()
+		apiResponse, err = osctrlAPI.PostLogin(env, username, string(passwordByte), string(otpByte), expHours)
+		if err != nil {
+			return fmt.Errorf("error in login %w", err)
+		}
+	} else if err != nil {
 		return fmt.Errorf("error in login %w", err)
 	}
 	apiConfig.Token = apiResponse.Token
@@ -2068,6 +2938,10 @@ func cliWrapper(action func(*cli.Context) error) func(*cli.Context) error {
 			// Initialize settings
 			log.Debug().Msg("Creating settings manager")
 			settingsmgr = settings.NewSettings(db.Conn)
+			adminUsers.PasswordPolicy = users.PasswordPolicy{
+				MinLength:  int(settingsmgr.PasswordMinLength()),
+				MinClasses: int(settingsmgr.PasswordMinClasses()),
+			}
 			// Initialize nodes
 			log.Debug().Msg("Creating nodes manager")
 			nodesmgr = nodes.CreateNodes(db.Conn)
@@ -2076,7 +2950,10 @@ func cliWrapper(action func(*cli.Context) error) func(*cli.Context) error {
 			queriesmgr = queries.CreateQueries(db.Conn)
 			// Initialize carves
 			log.Debug().Msg("Creating file carves manager")
-			filecarves = carves.CreateFileCarves(db.Conn, config.CarverDB, nil)
+			filecarves, err = createFileCarves()
+			if err != nil {
+				return fmt.Errorf("error creating file carves manager - %w", err)
+			}
 			// Initialize tags
 			log.Debug().Msg("Creating tags manager")
 			tagsmgr = tags.CreateTagManager(db.Conn)
@@ -2086,6 +2963,18 @@ func cliWrapper(action func(*cli.Context) error) func(*cli.Context) error {
 			if err != nil {
 				return fmt.Errorf("error creating audit log manager - %w", err)
 			}
+			// Initialize cache invalidation, so services evict the data changed by the CLI
+			if redisConfigFile != "" || redisConfig.ConnectionString != "" || redisConfig.Host != "" {
+				log.Debug().Msg("Creating cache invalidation")
+				invalidations, err := createInvalidationBus()
+				if err != nil {
+					return fmt.Errorf("error creating cache invalidation - %w", err)
+				}
+				defer invalidations.Close()
+				envs.Bus = invalidations
+				settingsmgr.Bus = invalidations
+				nodesmgr.SetInvalidationBus(invalidations)
+			}
 			// Execute action
 			return action(c)
 		}
@@ -2108,6 +2997,81 @@ func cliWrapper(action func(*cli.Context) error) func(*cli.Context) error {
 	}
 }
 
+// Helper to connect to redis and create the bus to publish cache invalidations
+func createInvalidationBus() (*cache.RedisBus, error) {
+	var redis *cache.RedisManager
+	var err error
+	if redisConfigFile != "" {
+		redis, err = cache.CreateRedisManagerFile(redisConfigFile)
+	} else {
+		redis, err = cache.CreateRedisManager(redisConfig)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("in CreateRedisManager - %w", err)
+	}
+	return cache.NewRedisBus(redis)
+}
+
+// Helper to create the file carves manager with the carver configured in osctrl services
+func createFileCarves() (*carves.Carves, error) {
+	var carvers3 *carves.CarverS3
+	var carverlocal *carves.CarverLocal
+	var err error
+	switch carverType {
+	case config.CarverS3:
+		if s3CarverConfig.Bucket != "" {
+			carvers3, err = carves.CreateCarverS3(s3CarverConfig)
+		} else {
+			carvers3, err = carves.CreateCarverS3File(carverConfigFile)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to initiate s3 carver - %w", err)
+		}
+	case config.CarverLocal:
+		if localCarverConfig.Dir != "" {
+			carverlocal, err = carves.CreateCarverLocal(localCarverConfig)
+		} else {
+			carverlocal, err = carves.CreateCarverLocalFile(carverConfigFile)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to initiate local carver - %w", err)
+		}
+	}
+	return carves.CreateFileCarves(db.Conn, carverType, carvers3, carverlocal), nil
+}
+
+// Function to wrap actions that only need the DB connection, without initializing managers
+// that expect the schema to be up to date
+func dbWrapper(action func(*cli.Context) error) func(*cli.Context) error {
+	return func(c *cli.Context) error {
+		if verboseFlag {
+			showFlags(c)
+			zerolog.SetGlobalLevel(zerolog.DebugLevel)
+		}
+		// Verify if format is correct
+		if !formats[formatFlag] {
+			return fmt.Errorf("invalid format %s", formatFlag)
+		}
+		if !dbFlag {
+			return fmt.Errorf("❌ DB access is required, use --db")
+		}
+		if dbConfigFile != "" {
+			log.Debug().Msg("Initializing DB from file")
+			db, err = backend.CreateDBManagerFile(dbConfigFile)
+			if err != nil {
+				return fmt.Errorf("in CreateDBManagerFile - %w", err)
+			}
+		} else {
+			log.Debug().Msg("Creating DB manager from config")
+			db, err = backend.CreateDBManager(dbConfig)
+			if err != nil {
+				return fmt.Errorf("in CreateDBManager - %w", err)
+			}
+		}
+		return action(c)
+	}
+}
+
 // Action to run when no flags are provided
 func cliAction(c *cli.Context) error {
 	if c.NumFlags() == 0 {
@@ -5,10 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/jmpsec/osctrl/pkg/handlers"
 	"github.com/jmpsec/osctrl/pkg/nodes"
 	"github.com/jmpsec/osctrl/pkg/settings"
 	"github.com/jmpsec/osctrl/pkg/tags"
+	"github.com/jmpsec/osctrl/pkg/utils"
 	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli/v2"
 )
@@ -279,3 +283,433 @@ func lookupNode(c *cli.Context) error {
 	}
 	return _showNode(node)
 }
+
+// Helper function to convert the result of a node lifecycle into the data expected for output
+func lifecycleToData(res nodes.LifecycleResult, header []string) [][]string {
+	var data [][]string
+	if header != nil {
+		data = append(data, header)
+	}
+	for _, n := range res.Archived {
+		data = append(data, []string{"archive", n.Hostname, n.UUID, n.Environment, nodeLastSeen(n)})
+	}
+	for _, n := range res.Purged {
+		data = append(data, []string{"purge", n.Hostname, n.UUID, n.Environment, utils.PastFutureTimes(n.LastSeen)})
+	}
+	return data
+}
+
+func lifecycleNodes(c *cli.Context) error {
+	// Get values from flags
+	envName := c.String("env")
+	if envName == "" {
+		fmt.Println("❌ environment is required")
+		os.Exit(1)
+	}
+	dryRun := c.Bool("dry-run")
+	var res nodes.LifecycleResult
+	if dbFlag {
+		env, err := envs.Get(envName)
+		if err != nil {
+			return fmt.Errorf("error getting environment - %w", err)
+		}
+		archiveDays := env.NodeArchiveDays
+		if c.IsSet("archive-days") {
+			archiveDays = c.Int("archive-days")
+		}
+		purgeDays := env.NodePurgeDays
+		if c.IsSet("purge-days") {
+			purgeDays = c.Int("purge-days")
+		}
+		res, err = nodesmgr.ApplyLifecycle(env.ID, archiveDays, purgeDays, dryRun)
+		if err != nil {
+			return fmt.Errorf("error applying node lifecycle - %w", err)
+		}
+		// Audit log
+		if !dryRun {
+			for _, n := range res.Archived {
+				auditlogsmgr.NodeAction(getShellUsername(), fmt.Sprintf("archive node %s not seen for %d days", n.UUID, archiveDays), "CLI", env.ID)
+			}
+			for _, n := range res.Purged {
+				auditlogsmgr.NodeAction(getShellUsername(), fmt.Sprintf("purge node %s archived for more than %d days", n.UUID, purgeDays), "CLI", env.ID)
+			}
+		}
+	} else if apiFlag {
+		fmt.Println("❌ API not supported yet for this operation")
+		os.Exit(1)
+	}
+	header := []string{
+		"Action",
+		"Hostname",
+		"UUID",
+		"Environment",
+		"Last Seen",
+	}
+	// Prepare output
+	switch formatFlag {
+	case jsonFormat:
+		jsonRaw, err := json.Marshal(res)
+		if err != nil {
+			return fmt.Errorf("error marshaling - %w", err)
+		}
+		fmt.Println(string(jsonRaw))
+	case csvFormat:
+		data := lifecycleToData(res, header)
+		w := csv.NewWriter(os.Stdout)
+		if err := w.WriteAll(data); err != nil {
+			return fmt.Errorf("error writing csv - %w", err)
+		}
+	case prettyFormat:
+		table := tablewriter.NewWriter(os.Stdout)
+		table.Header(stringSliceToAnySlice(header)...)
+		verb := "Archived"
+		if dryRun {
+			verb = "To be archived"
+		}
+		fmt.Printf("%s nodes: %d\n", verb, len(res.Archived))
+		verb = "Purged"
+		if dryRun {
+			verb = "To be purged"
+		}
+		fmt.Printf("%s nodes: %d\n", verb, len(res.Purged))
+		if len(res.Archived)+len(res.Purged) > 0 {
+			table.Bulk(lifecycleToData(res, nil))
+			table.Render()
+		}
+	}
+	return nil
+}
+
+// Helper function to convert groups of duplicated nodes into the data expected for output
+func duplicatesToData(groups []nodes.DuplicateGroup, header []string) [][]string {
+	var data [][]string
+	if header != nil {
+		data = append(data, header)
+	}
+	for _, g := range groups {
+		for _, n := range g.Nodes {
+			data = append(data, []string{
+				g.Field,
+				g.Value,
+				strconv.FormatUint(uint64(n.ID), 10),
+				n.Hostname,
+				n.UUID,
+				n.HardwareSerial,
+				nodeLastSeen(n),
+			})
+		}
+	}
+	return data
+}
+
+func duplicateNodes(c *cli.Context) error {
+	// Get values from flags
+	env := c.String("env")
+	if env == "" {
+		fmt.Println("❌ environment is required")
+		os.Exit(1)
+	}
+	// Retrieve data
+	var groups []nodes.DuplicateGroup
+	if dbFlag {
+		e, err := envs.Get(env)
+		if err != nil {
+			return fmt.Errorf("error getting environment - %w", err)
+		}
+		groups, err = nodesmgr.GetDuplicates(e.ID)
+		if err != nil {
+			return fmt.Errorf("error getting duplicated nodes - %w", err)
+		}
+	} else if apiFlag {
+		groups, err = osctrlAPI.GetNodeDuplicates(env)
+		if err != nil {
+			return fmt.Errorf("error getting duplicated nodes - %w", err)
+		}
+	}
+	header := []string{
+		"Field",
+		"Value",
+		"ID",
+		"Hostname",
+		"UUID",
+		"HardwareSerial",
+		"Last Seen",
+	}
+	// Prepare output
+	switch formatFlag {
+	case jsonFormat:
+		jsonRaw, err := json.Marshal(groups)
+		if err != nil {
+			return fmt.Errorf("error marshaling - %w", err)
+		}
+		fmt.Println(string(jsonRaw))
+	case csvFormat:
+		data := duplicatesToData(groups, header)
+		w := csv.NewWriter(os.Stdout)
+		if err := w.WriteAll(data); err != nil {
+			return fmt.Errorf("error writing csv - %w", err)
+		}
+	case prettyFormat:
+		table := tablewriter.NewWriter(os.Stdout)
+		table.Header(stringSliceToAnySlice(header)...)
+		if len(groups) > 0 {
+			fmt.Printf("Duplicated nodes (%d groups):\n", len(groups))
+			data := duplicatesToData(groups, nil)
+			table.Bulk(data)
+		} else {
+			fmt.Println("No duplicated nodes")
+		}
+		table.Render()
+	}
+	return nil
+}
+
+func mergeNodes(c *cli.Context) error {
+	// Get values from flags
+	env := c.String("env")
+	if env == "" {
+		fmt.Println("❌ environment is required")
+		os.Exit(1)
+	}
+	target := c.Uint("target")
+	if target == 0 {
+		fmt.Println("❌ target is required")
+		os.Exit(1)
+	}
+	duplicates := c.UintSlice("duplicate")
+	if len(duplicates) == 0 {
+		fmt.Println("❌ duplicate is required")
+		os.Exit(1)
+	}
+	if dbFlag {
+		e, err := envs.Get(env)
+		if err != nil {
+			return fmt.Errorf("error getting environment - %w", err)
+		}
+		nds, err := nodesmgr.GetByIDs(append([]uint{target}, duplicates...))
+		if err != nil {
+			return fmt.Errorf("error getting nodes - %w", err)
+		}
+		var targetNode nodes.OsqueryNode
+		var duplicateNodes []nodes.OsqueryNode
+		for _, n := range nds {
+			if n.EnvironmentID != e.ID {
+				return fmt.Errorf("node %d is not in environment %s", n.ID, env)
+			}
+			if n.ID == target {
+				targetNode = n
+			} else {
+				duplicateNodes = append(duplicateNodes, n)
+			}
+		}
+		if targetNode.ID == 0 || len(duplicateNodes) != len(duplicates) {
+			return fmt.Errorf("nodes not found")
+		}
+		manager := handlers.Managers{Envs: envs, Nodes: nodesmgr, Tags: tagsmgr}
+		if err := handlers.MergeNodes(manager, queriesmgr, filecarves, targetNode, duplicateNodes); err != nil {
+			return fmt.Errorf("error merging nodes - %w", err)
+		}
+		// Audit log
+		for _, d := range duplicateNodes {
+			auditlogsmgr.NodeAction(getShellUsername(), "merge node "+d.UUID+" into "+targetNode.UUID, "CLI", e.ID)
+		}
+	} else if apiFlag {
+		if err := osctrlAPI.MergeNodes(env, target, duplicates); err != nil {
+			return fmt.Errorf("error merging nodes - %w", err)
+		}
+	}
+	if !silentFlag {
+		fmt.Println("✅ nodes were merged successfully")
+	}
+	return nil
+}
+
+// Helper function to convert pending nodes into the data expected for output
+func pendingToData(nds []nodes.OsqueryNode, header []string) [][]string {
+	var data [][]string
+	if header != nil {
+		data = append(data, header)
+	}
+	for _, n := range nds {
+		data = append(data, []string{
+			strconv.FormatUint(uint64(n.ID), 10),
+			n.Hostname,
+			n.UUID,
+			n.HardwareSerial,
+			n.IPAddress,
+			n.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return data
+}
+
+func pendingNodes(c *cli.Context) error {
+	// Get values from flags
+	env := c.String("env")
+	if env == "" {
+		fmt.Println("❌ environment is required")
+		os.Exit(1)
+	}
+	// Retrieve data
+	var nds []nodes.OsqueryNode
+	if dbFlag {
+		e, err := envs.Get(env)
+		if err != nil {
+			return fmt.Errorf("error getting environment - %w", err)
+		}
+		nds, err = nodesmgr.GetPending(e.ID)
+		if err != nil {
+			return fmt.Errorf("error getting pending nodes - %w", err)
+		}
+	} else if apiFlag {
+		nds, err = osctrlAPI.GetPendingNodes(env)
+		if err != nil {
+			return fmt.Errorf("error getting pending nodes - %w", err)
+		}
+	}
+	header := []string{
+		"ID",
+		"Hostname",
+		"UUID",
+		"HardwareSerial",
+		"IPAddress",
+		"Enrolled",
+	}
+	// Prepare output
+	switch formatFlag {
+	case jsonFormat:
+		jsonRaw, err := json.Marshal(nds)
+		if err != nil {
+			return fmt.Errorf("error marshaling - %w", err)
+		}
+		fmt.Println(string(jsonRaw))
+	case csvFormat:
+		data := pendingToData(nds, header)
+		w := csv.NewWriter(os.Stdout)
+		if err := w.WriteAll(data); err != nil {
+			return fmt.Errorf("error writing csv - %w", err)
+		}
+	case prettyFormat:
+		table := tablewriter.NewWriter(os.Stdout)
+		table.Header(stringSliceToAnySlice(header)...)
+		if len(nds) > 0 {
+			fmt.Printf("Nodes pending approval (%d):\n", len(nds))
+			data := pendingToData(nds, nil)
+			table.Bulk(data)
+		} else {
+			fmt.Println("No nodes pending approval")
+		}
+		table.Render()
+	}
+	return nil
+}
+
+func approveNodes(c *cli.Context) error {
+	// Get values from flags
+	env := c.String("env")
+	if env == "" {
+		fmt.Println("❌ environment is required")
+		os.Exit(1)
+	}
+	uuids := c.StringSlice("uuid")
+	if len(uuids) == 0 {
+		fmt.Println("❌ uuid is required")
+		os.Exit(1)
+	}
+	if dbFlag {
+		e, err := envs.Get(env)
+		if err != nil {
+			return fmt.Errorf("error getting environment - %w", err)
+		}
+		for _, u := range uuids {
+			n, err := nodesmgr.GetByUUIDEnv(u, e.ID)
+			if err != nil {
+				return fmt.Errorf("error getting node %s - %w", u, err)
+			}
+			if err := nodesmgr.Approve(n); err != nil {
+				return fmt.Errorf("error approving node - %w", err)
+			}
+			// Audit log
+			auditlogsmgr.NodeAction(getShellUsername(), "approved node "+n.UUID, "CLI", e.ID)
+		}
+	} else if apiFlag {
+		if err := osctrlAPI.ApproveNodes(env, uuids); err != nil {
+			return fmt.Errorf("error approving nodes - %w", err)
+		}
+	}
+	if !silentFlag {
+		fmt.Println("✅ nodes were approved successfully")
+	}
+	return nil
+}
+
+func revokeCertNode(c *cli.Context) error {
+	// Get values from flags
+	env := c.String("env")
+	if env == "" {
+		fmt.Println("❌ environment is required")
+		os.Exit(1)
+	}
+	uuid := c.String("uuid")
+	if uuid == "" {
+		fmt.Println("❌ uuid is required")
+		os.Exit(1)
+	}
+	if dbFlag {
+		e, err := envs.Get(env)
+		if err != nil {
+			return fmt.Errorf("error getting environment - %w", err)
+		}
+		n, err := nodesmgr.GetByUUIDEnv(uuid, e.ID)
+		if err != nil {
+			return fmt.Errorf("error getting node %s - %w", uuid, err)
+		}
+		manager := handlers.Managers{Envs: envs, Nodes: nodesmgr}
+		if _, err := handlers.RevokeNodeCert(manager, n, getShellUsername()); err != nil {
+			return fmt.Errorf("error revoking certificates - %w", err)
+		}
+		// Audit log
+		auditlogsmgr.NodeAction(getShellUsername(), "revoked certificates of node "+n.UUID, "CLI", e.ID)
+	} else if apiFlag {
+		if err := osctrlAPI.RevokeNodeCert(env, uuid); err != nil {
+			return fmt.Errorf("error revoking certificates - %w", err)
+		}
+	}
+	if !silentFlag {
+		fmt.Println("✅ node certificates were revoked successfully")
+	}
+	return nil
+}
+
+func clearCertNode(c *cli.Context) error {
+	// Get values from flags
+	env := c.String("env")
+	if env == "" {
+		fmt.Println("❌ environment is required")
+		os.Exit(1)
+	}
+	uuid := c.String("uuid")
+	if uuid == "" {
+		fmt.Println("❌ uuid is required")
+		os.Exit(1)
+	}
+	if dbFlag {
+		e, err := envs.Get(env)
+		if err != nil {
+			return fmt.Errorf("error getting environment - %w", err)
+		}
+		if _, err := envs.ClearRevokedClientCerts(e.ID, uuid); err != nil {
+			return fmt.Errorf("error clearing certificates - %w", err)
+		}
+		// Audit log
+		auditlogsmgr.NodeAction(getShellUsername(), "cleared revoked certificates of node "+uuid, "CLI", e.ID)
+	} else if apiFlag {
+		if err := osctrlAPI.ClearNodeCert(env, uuid); err != nil {
+			return fmt.Errorf("error clearing certificates - %w", err)
+		}
+	}
+	if !silentFlag {
+		fmt.Println("✅ revoked node certificates were cleared successfully")
+	}
+	return nil
+}
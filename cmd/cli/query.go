@@ -4,14 +4,17 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/jmpsec/osctrl/pkg/handlers"
+	"github.com/jmpsec/osctrl/pkg/logging"
 	"github.com/jmpsec/osctrl/pkg/queries"
 	"github.com/jmpsec/osctrl/pkg/settings"
+	"github.com/jmpsec/osctrl/pkg/utils"
 	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli/v2"
 )
@@ -239,6 +242,270 @@ func expireQuery(c *cli.Context) error {
 	return nil
 }
 
+func exportQuery(c *cli.Context) error {
+	// Get values from flags
+	name := c.String("name")
+	if name == "" {
+		fmt.Println("❌ query name is required")
+		os.Exit(1)
+	}
+	env := c.String("env")
+	if env == "" {
+		fmt.Println("❌ environment is required")
+		os.Exit(1)
+	}
+	format := c.String("format")
+	if _, ok := logging.ExportContentTypes[format]; !ok {
+		fmt.Printf("❌ invalid format %s\n", format)
+		os.Exit(1)
+	}
+	var out io.Writer = os.Stdout
+	if output := c.String("output"); output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("❌ error creating file - %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if dbFlag {
+		e, err := envs.Get(env)
+		if err != nil {
+			return fmt.Errorf("❌ error env get - %w", err)
+		}
+		if !queriesmgr.Exists(name, e.ID) {
+			return fmt.Errorf("❌ query %s not found", name)
+		}
+		if err := logging.ExportQueryResults(out, format, db.Conn, nodesmgr, name); err != nil {
+			return fmt.Errorf("❌ error exporting query - %w", err)
+		}
+	} else if apiFlag {
+		if err := osctrlAPI.ExportQuery(env, name, format, out); err != nil {
+			return fmt.Errorf("❌ error exporting query - %w", err)
+		}
+	}
+	return nil
+}
+
+// Helper function to convert the target nodes of a query into the data expected for output
+func nodeQueriesToData(targets []queries.NodeQueryTarget, header []string) [][]string {
+	var data [][]string
+	if header != nil {
+		data = append(data, header)
+	}
+	for _, t := range targets {
+		data = append(data, []string{
+			t.UUID,
+			t.Hostname,
+			t.Status,
+			t.Message,
+			utils.PastFutureTimes(t.LastSeen),
+			utils.PastFutureTimes(t.UpdatedAt),
+		})
+	}
+	return data
+}
+
+func statusQuery(c *cli.Context) error {
+	// Get values from flags
+	name := c.String("name")
+	if name == "" {
+		fmt.Println("❌ query name is required")
+		os.Exit(1)
+	}
+	env := c.String("env")
+	if env == "" {
+		fmt.Println("❌ environment is required")
+		os.Exit(1)
+	}
+	status := c.String("status")
+	if status != "" && !queries.NodeQueryStatuses[status] {
+		fmt.Printf("❌ invalid status %s\n", status)
+		os.Exit(1)
+	}
+	var query queries.DistributedQuery
+	if dbFlag {
+		e, err := envs.Get(env)
+		if err != nil {
+			return fmt.Errorf("❌ error env get - %w", err)
+		}
+		query, err = queriesmgr.Get(name, e.ID)
+		if err != nil {
+			return fmt.Errorf("❌ error query get - %w", err)
+		}
+	}
+	// Retrieve all target nodes, one page at a time
+	var qs queries.NodeQueryStatusList
+	var targets []queries.NodeQueryTarget
+	for offset := 0; ; offset += queries.MaxNodeQueriesLimit {
+		var err error
+		if dbFlag {
+			qs, err = queriesmgr.GetNodeQueryStatus(query, queries.NodeQueryFilter{Status: status}, offset, queries.MaxNodeQueriesLimit)
+			if err != nil {
+				return fmt.Errorf("❌ error query status - %w", err)
+			}
+		} else if apiFlag {
+			qs, err = osctrlAPI.GetQueryStatus(env, name, status, offset, queries.MaxNodeQueriesLimit)
+			if err != nil {
+				return fmt.Errorf("❌ error query status - %w", err)
+			}
+		}
+		targets = append(targets, qs.Nodes...)
+		if len(qs.Nodes) < queries.MaxNodeQueriesLimit {
+			break
+		}
+	}
+	qs.Nodes = targets
+	header := []string{
+		"UUID",
+		"Hostname",
+		"Status",
+		"Message",
+		"Last Seen",
+		"Updated",
+	}
+	// Prepare output
+	switch {
+	case formatFlag == jsonFormat:
+		jsonRaw, err := json.Marshal(qs)
+		if err != nil {
+			return fmt.Errorf("❌ error json marshal - %w", err)
+		}
+		fmt.Println(string(jsonRaw))
+	case formatFlag == csvFormat:
+		data := nodeQueriesToData(qs.Nodes, header)
+		w := csv.NewWriter(os.Stdout)
+		if err := w.WriteAll(data); err != nil {
+			return fmt.Errorf("❌ error csv writeall - %w", err)
+		}
+	case formatFlag == prettyFormat:
+		fmt.Printf("Query %s targeted %d nodes: %d pending, %d completed, %d error, %d expired\n",
+			name,
+			qs.Total,
+			qs.Statuses[queries.DistributedQueryStatusPending],
+			qs.Statuses[queries.DistributedQueryStatusCompleted],
+			qs.Statuses[queries.DistributedQueryStatusError],
+			qs.Statuses[queries.DistributedQueryStatusExpired])
+		table := tablewriter.NewWriter(os.Stdout)
+		table.Header(stringSliceToAnySlice(header)...)
+		if len(qs.Nodes) > 0 {
+			data := nodeQueriesToData(qs.Nodes, nil)
+			table.Bulk(data)
+		} else {
+			fmt.Println("No target nodes")
+		}
+		table.Render()
+	}
+	return nil
+}
+
+func previewQuery(c *cli.Context) error {
+	// Get values from flags
+	env := c.String("env")
+	if env == "" {
+		fmt.Println("❌ environment is required")
+		os.Exit(1)
+	}
+	selector := c.String("selector")
+	if err := handlers.ValidateSelector(selector); err != nil {
+		fmt.Printf("❌ %s\n", err)
+		os.Exit(1)
+	}
+	uuidList := splitList(c.String("uuid"))
+	platformList := splitList(c.String("platform"))
+	hostList := splitList(c.String("host"))
+	tagList := splitList(c.String("tag"))
+	if len(uuidList) == 0 && len(platformList) == 0 && len(hostList) == 0 && len(tagList) == 0 && selector == "" {
+		fmt.Println("❌ at least one target is required")
+		os.Exit(1)
+	}
+	var preview handlers.TargetPreview
+	if dbFlag {
+		e, err := envs.Get(env)
+		if err != nil {
+			return fmt.Errorf("❌ error env get - %w", err)
+		}
+		data := handlers.ProcessingQuery{
+			Envs:          []string{},
+			Platforms:     platformList,
+			UUIDs:         uuidList,
+			Hosts:         hostList,
+			Tags:          tagList,
+			Selector:      selector,
+			EnvID:         e.ID,
+			InactiveHours: settingsmgr.InactiveHours(settings.NoEnvironmentID),
+		}
+		manager := handlers.Managers{
+			Nodes: nodesmgr,
+			Envs:  envs,
+			Tags:  tagsmgr,
+		}
+		preview, err = handlers.PreviewQueryCarve(data, manager)
+		if err != nil {
+			return fmt.Errorf("❌ error preview targets - %w", err)
+		}
+	} else if apiFlag {
+		preview, err = osctrlAPI.PreviewTargets(env, uuidList, hostList, platformList, tagList, selector)
+		if err != nil {
+			return fmt.Errorf("❌ error preview targets - %w", err)
+		}
+	}
+	header := []string{
+		"UUID",
+		"Hostname",
+		"Platform",
+		"Platform Version",
+		"osquery Version",
+		"Last Seen",
+	}
+	// Prepare output
+	switch {
+	case formatFlag == jsonFormat:
+		jsonRaw, err := json.Marshal(preview)
+		if err != nil {
+			return fmt.Errorf("❌ error json marshal - %w", err)
+		}
+		fmt.Println(string(jsonRaw))
+	case formatFlag == csvFormat:
+		data := previewToData(preview.Nodes, header)
+		w := csv.NewWriter(os.Stdout)
+		if err := w.WriteAll(data); err != nil {
+			return fmt.Errorf("❌ error csv writeall - %w", err)
+		}
+	case formatFlag == prettyFormat:
+		table := tablewriter.NewWriter(os.Stdout)
+		table.Header(stringSliceToAnySlice(header)...)
+		if preview.Count > 0 {
+			fmt.Printf("Target nodes (%d):\n", preview.Count)
+			data := previewToData(preview.Nodes, nil)
+			table.Bulk(data)
+		} else {
+			fmt.Println("No target nodes")
+		}
+		table.Render()
+	}
+	return nil
+}
+
+// Helper function to convert the nodes of a preview into the data expected for output
+func previewToData(targets []handlers.TargetPreviewNode, header []string) [][]string {
+	var data [][]string
+	if header != nil {
+		data = append(data, header)
+	}
+	for _, n := range targets {
+		data = append(data, []string{
+			n.UUID,
+			n.Hostname,
+			n.Platform,
+			n.PlatformVersion,
+			n.OsqueryVersion,
+			utils.PastFutureTimes(n.LastSeen),
+		})
+	}
+	return data
+}
+
 func runQuery(c *cli.Context) error {
 	// Get values from flags
 	query := c.String("query")
@@ -251,9 +518,14 @@ func runQuery(c *cli.Context) error {
 		fmt.Println("❌ environment is required")
 		os.Exit(1)
 	}
+	selector := c.String("selector")
+	if err := handlers.ValidateSelector(selector); err != nil {
+		fmt.Printf("❌ %s\n", err)
+		os.Exit(1)
+	}
 	uuidStr := c.String("uuid")
-	if uuidStr == "" {
-		fmt.Println("❌ UUID is required")
+	if uuidStr == "" && selector == "" {
+		fmt.Println("❌ UUID or selector is required")
 		os.Exit(1)
 	}
 	uuidList := []string{uuidStr}
@@ -277,6 +549,7 @@ func runQuery(c *cli.Context) error {
 	}
 	expHours := c.Int("expiration")
 	hidden := c.Bool("hidden")
+	urgent := c.Bool("urgent")
 	queryName := queries.GenQueryName()
 	if dbFlag {
 		e, err := envs.Get(env)
@@ -297,6 +570,10 @@ func runQuery(c *cli.Context) error {
 			Type:          queries.StandardQueryType,
 			EnvironmentID: e.ID,
 		}
+		// Urgent queries make target nodes poll faster for a while
+		if urgent {
+			newQuery.SetUrgent(time.Duration(settingsmgr.AcceleratedWindow(settings.NoEnvironmentID)) * time.Second)
+		}
 		if err := queriesmgr.Create(&newQuery); err != nil {
 			return fmt.Errorf("❌ error query create - %w", err)
 		}
@@ -307,6 +584,7 @@ func runQuery(c *cli.Context) error {
 			UUIDs:         uuidList,
 			Hosts:         hostList,
 			Tags:          tagList,
+			Selector:      selector,
 			EnvID:         e.ID,
 			InactiveHours: settingsmgr.InactiveHours(settings.NoEnvironmentID),
 		}
@@ -331,14 +609,18 @@ func runQuery(c *cli.Context) error {
 		// Audit log
 		auditlogsmgr.NewQuery(getShellUsername(), query, "CLI", e.ID)
 	} else if apiFlag {
-		q, err := osctrlAPI.RunQuery(env, query, uuidList, hostList, platformList, tagList, hidden, expHours)
+		q, err := osctrlAPI.RunQuery(env, query, uuidList, hostList, platformList, tagList, selector, hidden, urgent, expHours)
 		if err != nil {
 			return fmt.Errorf("❌ error run query - %w", err)
 		}
 		queryName = q.Name
 	}
 	if !silentFlag {
-		fmt.Printf("✅ query %s created successfully\n", queryName)
+		if urgent {
+			fmt.Printf("✅ urgent query %s created successfully\n", queryName)
+		} else {
+			fmt.Printf("✅ query %s created successfully\n", queryName)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/jmpsec/osctrl/pkg/handlers"
+	"github.com/jmpsec/osctrl/pkg/queries"
+	"github.com/jmpsec/osctrl/pkg/settings"
+	"github.com/jmpsec/osctrl/pkg/types"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+)
+
+// Helper function to convert a slice of recurring queries into the data expected for output
+func recurringToData(rqs []queries.RecurringQuery, header []string) [][]string {
+	var data [][]string
+	if header != nil {
+		data = append(data, header)
+	}
+	for _, rq := range rqs {
+		schedule := rq.Cron
+		if schedule == "" {
+			schedule = fmt.Sprintf("every %d minutes", rq.Interval)
+		}
+		_rq := []string{
+			rq.Name,
+			rq.Creator,
+			rq.Query,
+			schedule,
+			stringifyBool(rq.Paused),
+			strconv.Itoa(rq.Runs),
+			rq.LastRun.String(),
+			rq.NextRun.String(),
+		}
+		data = append(data, _rq)
+	}
+	return data
+}
+
+// Helper function to convert the history of runs into the data expected for output
+func recurringRunsToData(runs []queries.RecurringQueryRun, header []string) [][]string {
+	var data [][]string
+	if header != nil {
+		data = append(data, header)
+	}
+	for _, r := range runs {
+		_r := []string{
+			r.QueryName,
+			r.RunAt.String(),
+			strconv.Itoa(r.Expected),
+			r.Error,
+		}
+		data = append(data, _r)
+	}
+	return data
+}
+
+func listRecurringQueries(c *cli.Context) error {
+	env := c.String("env")
+	if env == "" {
+		fmt.Println("❌ environment is required")
+		os.Exit(1)
+	}
+	// Retrieve data
+	var rqs []queries.RecurringQuery
+	if dbFlag {
+		e, err := envs.Get(env)
+		if err != nil {
+			return fmt.Errorf("❌ error env get - %w", err)
+		}
+		rqs, err = queriesmgr.GetAllRecurring(e.ID)
+		if err != nil {
+			return fmt.Errorf("❌ error get recurring queries - %w", err)
+		}
+	} else if apiFlag {
+		rqs, err = osctrlAPI.GetRecurringQueries(env)
+		if err != nil {
+			return fmt.Errorf("❌ error get recurring queries - %w", err)
+		}
+	}
+	header := []string{
+		"Name",
+		"Creator",
+		"Query",
+		"Schedule",
+		"Paused",
+		"Runs",
+		"Last Run",
+		"Next Run",
+	}
+	// Prepare output
+	switch {
+	case formatFlag == jsonFormat:
+		jsonRaw, err := json.Marshal(rqs)
+		if err != nil {
+			return fmt.Errorf("❌ error json marshal - %w", err)
+		}
+		fmt.Println(string(jsonRaw))
+	case formatFlag == csvFormat:
+		data := recurringToData(rqs, header)
+		w := csv.NewWriter(os.Stdout)
+		if err := w.WriteAll(data); err != nil {
+			return fmt.Errorf("❌ error csv writeall - %w", err)
+		}
+	case formatFlag == prettyFormat:
+		table := tablewriter.NewWriter(os.Stdout)
+		table.Header(stringSliceToAnySlice(header)...)
+		if len(rqs) > 0 {
+			fmt.Printf("Existing recurring queries (%d):\n", len(rqs))
+			data := recurringToData(rqs, nil)
+			table.Bulk(data)
+		} else {
+			fmt.Printf("No recurring queries\n")
+		}
+		table.Render()
+	}
+	return nil
+}
+
+func showRecurringQuery(c *cli.Context) error {
+	// Get values from flags
+	name := c.String("name")
+	if name == "" {
+		fmt.Println("❌ recurring query name is required")
+		os.Exit(1)
+	}
+	env := c.String("env")
+	if env == "" {
+		fmt.Println("❌ environment is required")
+		os.Exit(1)
+	}
+	// Retrieve data
+	var details queries.RecurringQueryDetails
+	if dbFlag {
+		e, err := envs.Get(env)
+		if err != nil {
+			return fmt.Errorf("❌ error env get - %w", err)
+		}
+		details.Query, err = queriesmgr.GetRecurring(name, e.ID)
+		if err != nil {
+			return fmt.Errorf("❌ error get recurring query - %w", err)
+		}
+		details.Runs, err = queriesmgr.GetRecurringRuns(details.Query.ID)
+		if err != nil {
+			return fmt.Errorf("❌ error get recurring query runs - %w", err)
+		}
+	} else if apiFlag {
+		details, err = osctrlAPI.GetRecurringQuery(env, name)
+		if err != nil {
+			return fmt.Errorf("❌ error get recurring query - %w", err)
+		}
+	}
+	header := []string{
+		"Query Name",
+		"Run At",
+		"Expected",
+		"Error",
+	}
+	// Prepare output
+	switch {
+	case formatFlag == jsonFormat:
+		jsonRaw, err := json.Marshal(details)
+		if err != nil {
+			return fmt.Errorf("❌ error json marshal - %w", err)
+		}
+		fmt.Println(string(jsonRaw))
+	case formatFlag == csvFormat:
+		data := recurringRunsToData(details.Runs, header)
+		w := csv.NewWriter(os.Stdout)
+		if err := w.WriteAll(data); err != nil {
+			return fmt.Errorf("❌ error csv writeall - %w", err)
+		}
+	case formatFlag == prettyFormat:
+		fmt.Printf("Recurring query %s: %s\n", details.Query.Name, details.Query.Query)
+		table := tablewriter.NewWriter(os.Stdout)
+		table.Header(stringSliceToAnySlice(header)...)
+		if len(details.Runs) > 0 {
+			fmt.Printf("Runs (%d):\n", len(details.Runs))
+			data := recurringRunsToData(details.Runs, nil)
+			table.Bulk(data)
+		} else {
+			fmt.Printf("No runs\n")
+		}
+		table.Render()
+	}
+	return nil
+}
+
+func createRecurringQuery(c *cli.Context) error {
+	// Get values from flags
+	query := c.String("query")
+	if query == "" {
+		fmt.Println("❌ query is required")
+		os.Exit(1)
+	}
+	env := c.String("env")
+	if env == "" {
+		fmt.Println("❌ environment is required")
+		os.Exit(1)
+	}
+	cron := c.String("cron")
+	interval := int(c.Duration("interval").Minutes())
+	if cron == "" && interval <= 0 {
+		fmt.Println("❌ cron or interval is required")
+		os.Exit(1)
+	}
+	name := c.String("name")
+	if name == "" {
+		name = queries.GenRecurringQueryName()
+	}
+	request := types.ApiRecurringQueryRequest{
+		Name:      name,
+		Query:     query,
+		Cron:      cron,
+		Interval:  interval,
+		Hidden:    c.Bool("hidden"),
+		ExpHours:  c.Int("expiration"),
+		UUIDs:     splitList(c.String("uuid")),
+		Hosts:     splitList(c.String("host")),
+		Platforms: splitList(c.String("platform")),
+		Tags:      splitList(c.String("tag")),
+		Selector:  c.String("selector"),
+	}
+	if err := handlers.ValidateSelector(request.Selector); err != nil {
+		fmt.Printf("❌ %s\n", err)
+		os.Exit(1)
+	}
+	if dbFlag {
+		e, err := envs.Get(env)
+		if err != nil {
+			return fmt.Errorf("❌ error env get - %w", err)
+		}
+		newRecurring := queries.RecurringQuery{
+			Name:          request.Name,
+			Creator:       appName,
+			Query:         request.Query,
+			EnvironmentID: e.ID,
+			Cron:          request.Cron,
+			Interval:      request.Interval,
+			ExpHours:      request.ExpHours,
+			Hidden:        request.Hidden,
+		}
+		if err := newRecurring.SetTargets(queries.RecurringQueryTargets{
+			Platforms: request.Platforms,
+			UUIDs:     request.UUIDs,
+			Hosts:     request.Hosts,
+			Tags:      request.Tags,
+			Selector:  request.Selector,
+		}); err != nil {
+			return fmt.Errorf("❌ error with targets - %w", err)
+		}
+		if err := queriesmgr.CreateRecurring(&newRecurring); err != nil {
+			return fmt.Errorf("❌ error creating recurring query - %w", err)
+		}
+		// Audit log
+		auditlogsmgr.QueryAction(getShellUsername(), "create recurring query "+name, "CLI", e.ID)
+	} else if apiFlag {
+		r, err := osctrlAPI.CreateRecurringQuery(env, request)
+		if err != nil {
+			return fmt.Errorf("❌ error creating recurring query - %w", err)
+		}
+		name = r.Name
+	}
+	if !silentFlag {
+		fmt.Printf("✅ recurring query %s created successfully\n", name)
+	}
+	return nil
+}
+
+// Helper to run an action for a recurring query
+func actionRecurringQuery(c *cli.Context, action string) error {
+	// Get values from flags
+	name := c.String("name")
+	if name == "" {
+		fmt.Println("❌ recurring query name is required")
+		os.Exit(1)
+	}
+	env := c.String("env")
+	if env == "" {
+		fmt.Println("❌ environment is required")
+		os.Exit(1)
+	}
+	if dbFlag {
+		e, err := envs.Get(env)
+		if err != nil {
+			return fmt.Errorf("❌ error env get - %w", err)
+		}
+		switch action {
+		case settings.QueryPause:
+			err = queriesmgr.PauseRecurring(name, e.ID, true)
+		case settings.QueryResume:
+			err = queriesmgr.PauseRecurring(name, e.ID, false)
+		case settings.QueryDelete:
+			err = queriesmgr.DeleteRecurring(name, e.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("❌ error %s recurring query - %w", action, err)
+		}
+		// Audit log
+		auditlogsmgr.QueryAction(getShellUsername(), action+" recurring query "+name, "CLI", e.ID)
+	} else if apiFlag {
+		if _, err := osctrlAPI.ActionRecurringQuery(env, name, action); err != nil {
+			return fmt.Errorf("❌ error %s recurring query - %w", action, err)
+		}
+	}
+	if !silentFlag {
+		fmt.Printf("✅ recurring query %s %s successfully\n", name, action+"d")
+	}
+	return nil
+}
+
+func pauseRecurringQuery(c *cli.Context) error {
+	return actionRecurringQuery(c, settings.QueryPause)
+}
+
+func resumeRecurringQuery(c *cli.Context) error {
+	return actionRecurringQuery(c, settings.QueryResume)
+}
+
+func deleteRecurringQuery(c *cli.Context) error {
+	return actionRecurringQuery(c, settings.QueryDelete)
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/jmpsec/osctrl/pkg/types"
 	"github.com/jmpsec/osctrl/pkg/users"
+	"github.com/jmpsec/osctrl/pkg/utils"
 	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli/v2"
 )
@@ -108,6 +109,8 @@ func editUser(c *cli.Context) error {
 	notAdmin := c.Bool("non-admin")
 	service := c.Bool("service")
 	nonService := c.Bool("non-service")
+	resetMFA := c.Bool("reset-mfa")
+	unlock := c.Bool("unlock")
 	if password != "" {
 		if dbFlag {
 			if err := adminUsers.ChangePassword(username, password); err != nil {
@@ -157,6 +160,20 @@ func editUser(c *cli.Context) error {
 			}
 		}
 	}
+	if resetMFA {
+		if dbFlag {
+			if err := adminUsers.DisableMFA(username); err != nil {
+				return fmt.Errorf("error resetting second factor - %w", err)
+			}
+		}
+	}
+	if unlock {
+		if dbFlag {
+			if err := adminUsers.Unlock(username); err != nil {
+				return fmt.Errorf("error unlocking user - %w", err)
+			}
+		}
+	}
 	if apiFlag {
 		u := types.ApiUserRequest{
 			Username:   username,
@@ -167,6 +184,8 @@ func editUser(c *cli.Context) error {
 			NotAdmin:   notAdmin,
 			Service:    service,
 			NotService: nonService,
+			ResetMFA:   resetMFA,
+			Unlock:     unlock,
 		}
 		if err := osctrlAPI.EditUserReq(u); err != nil {
 			return fmt.Errorf("error editing user - %w", err)
@@ -311,3 +330,167 @@ func showUser(c *cli.Context) error {
 	}
 	return nil
 }
+
+// Helper function to convert a slice of user tokens into the data expected for output
+func userTokensToData(tokens []users.UserToken, header []string) [][]string {
+	var data [][]string
+	if header != nil {
+		data = append(data, header)
+	}
+	for _, t := range tokens {
+		env := t.Environment
+		if env == users.TokenAllEnvironments {
+			env = "all"
+		}
+		_t := []string{
+			t.TokenID,
+			t.Name,
+			t.Scope,
+			env,
+			utils.PastFutureTimes(t.ExpiresAt),
+			utils.PastFutureTimes(t.LastUsed),
+			t.LastIPAddress,
+			stringifyBool(t.Revoked),
+		}
+		data = append(data, _t)
+	}
+	return data
+}
+
+func createUserToken(c *cli.Context) error {
+	// Get values from flags
+	username := c.String("username")
+	if username == "" {
+		fmt.Println("❌ username is required")
+		os.Exit(1)
+	}
+	name := c.String("name")
+	if name == "" {
+		fmt.Println("❌ token name is required")
+		os.Exit(1)
+	}
+	scope := c.String("scope")
+	if !users.ValidTokenScope(scope) {
+		fmt.Printf("❌ invalid scope %s\n", scope)
+		os.Exit(1)
+	}
+	environment := c.String("environment")
+	expHours := c.Int("expire-hours")
+	var res types.ApiUserTokenResponse
+	if dbFlag {
+		// Tokens are signed with the JWT secret, that is only available to the API
+		fmt.Println("❌ DB not supported for this operation, use the API")
+		os.Exit(1)
+	} else if apiFlag {
+		res, err = osctrlAPI.CreateUserToken(username, types.ApiUserTokenRequest{
+			Name:        name,
+			Environment: environment,
+			Scope:       scope,
+			ExpHours:    expHours,
+		})
+		if err != nil {
+			return fmt.Errorf("error creating token - %w", err)
+		}
+	}
+	if formatFlag == jsonFormat {
+		jsonRaw, err := json.Marshal(res)
+		if err != nil {
+			return fmt.Errorf("error serializing - %w", err)
+		}
+		fmt.Println(string(jsonRaw))
+		return nil
+	}
+	if !silentFlag {
+		fmt.Printf("✅ token %s (%s) created successfully, it expires %s\n", name, res.TokenID, utils.PastFutureTimes(res.ExpiresAt))
+	}
+	fmt.Println(res.Token)
+	return nil
+}
+
+func listUserTokens(c *cli.Context) error {
+	// Get values from flags
+	username := c.String("username")
+	if username == "" {
+		fmt.Println("❌ username is required")
+		os.Exit(1)
+	}
+	// Retrieve data
+	var tokens []users.UserToken
+	if dbFlag {
+		tokens, err = adminUsers.GetUserTokens(username)
+		if err != nil {
+			return fmt.Errorf("error getting tokens - %w", err)
+		}
+	} else if apiFlag {
+		tokens, err = osctrlAPI.GetUserTokens(username)
+		if err != nil {
+			return fmt.Errorf("error getting tokens - %w", err)
+		}
+	}
+	header := []string{
+		"ID",
+		"Name",
+		"Scope",
+		"Environment",
+		"Expires",
+		"Last Used",
+		"Last IPAddress",
+		"Revoked?",
+	}
+	// Prepare output
+	switch formatFlag {
+	case jsonFormat:
+		jsonRaw, err := json.Marshal(tokens)
+		if err != nil {
+			return fmt.Errorf("error serializing - %w", err)
+		}
+		fmt.Println(string(jsonRaw))
+	case csvFormat:
+		data := userTokensToData(tokens, header)
+		w := csv.NewWriter(os.Stdout)
+		if err := w.WriteAll(data); err != nil {
+			return fmt.Errorf("error WriteAll - %w", err)
+		}
+	case prettyFormat:
+		table := tablewriter.NewWriter(os.Stdout)
+		table.Header(stringSliceToAnySlice(header)...)
+		if len(tokens) > 0 {
+			fmt.Printf("Existing tokens for %s (%d):\n", username, len(tokens))
+			data := userTokensToData(tokens, nil)
+			table.Bulk(data)
+		} else {
+			fmt.Printf("No tokens for %s\n", username)
+		}
+		table.Render()
+	}
+	return nil
+}
+
+func revokeUserToken(c *cli.Context) error {
+	// Get values from flags
+	username := c.String("username")
+	if username == "" {
+		fmt.Println("❌ username is required")
+		os.Exit(1)
+	}
+	tokenID := c.String("token")
+	if tokenID == "" {
+		fmt.Println("❌ token is required")
+		os.Exit(1)
+	}
+	if dbFlag {
+		if err := adminUsers.RevokeUserToken(username, tokenID, getShellUsername()); err != nil {
+			return fmt.Errorf("error revoking token - %w", err)
+		}
+		// Audit log
+		auditlogsmgr.UserAction(getShellUsername(), "revoke token "+tokenID+" for user "+username, "CLI")
+	} else if apiFlag {
+		if err := osctrlAPI.RevokeUserToken(username, tokenID); err != nil {
+			return fmt.Errorf("error revoking token - %w", err)
+		}
+	}
+	if !silentFlag {
+		fmt.Println("✅ token was revoked successfully")
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"strings"
 
 	"github.com/jmpsec/osctrl/pkg/nodes"
 	"github.com/jmpsec/osctrl/pkg/utils"
@@ -42,3 +43,11 @@ func getShellUsername() string {
 	}
 	return user
 }
+
+// Helper to split comma separated values, returning an empty list for an empty string
+func splitList(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+	return strings.Split(value, ",")
+}
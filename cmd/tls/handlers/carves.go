@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"fmt"
+
 	"github.com/jmpsec/osctrl/pkg/carves"
 	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/jmpsec/osctrl/pkg/environments"
 	"github.com/jmpsec/osctrl/pkg/types"
 	"github.com/rs/zerolog/log"
 )
@@ -53,14 +56,43 @@ func (h *HandlersTLS) ProcessCarveInit(req types.CarveInitRequest, sessionid, en
 	return nil
 }
 
+// Helper to check a new carve against the size limits of the environment, it returns the
+// reason to reject the carve or empty if the carve is allowed
+func (h *HandlersTLS) checkCarveLimits(env environments.TLSEnvironment, size int) string {
+	if env.CarveMaxSize > 0 && int64(size) > env.CarveMaxSize {
+		return fmt.Sprintf("size %d exceeds maximum of %d bytes", size, env.CarveMaxSize)
+	}
+	if env.CarveQuota > 0 {
+		used, err := h.Carves.UsedBytes(env.ID)
+		if err != nil {
+			log.Err(err).Msg("error getting carve usage")
+			return "unable to check quota"
+		}
+		if used+int64(size) > env.CarveQuota {
+			return fmt.Sprintf("quota of %d bytes exceeded, %d bytes in use", env.CarveQuota, used)
+		}
+	}
+	return ""
+}
+
 // ProcessCarveBlock - Function to process one block from a file carve
 // FIXME it can be more efficient on db access
 func (h *HandlersTLS) ProcessCarveBlock(req types.CarveBlockRequest, environment, uuid string, envid uint) {
+	// Blocks retried by the node are only stored once
+	if h.Carves.BlockExists(req.SessionID, req.BlockID) {
+		log.Warn().Msgf("duplicated block %d for carve %s", req.BlockID, req.SessionID)
+		return
+	}
 	// Initiate carve block
 	block := h.Carves.InitateBlock(environment, uuid, req.RequestID, req.SessionID, req.Data, req.BlockID, envid)
-	// Create Block
+	// Create Block, a concurrent retry of the same block fails with the unique index
 	if err := h.Carves.CreateBlock(block, uuid, req.Data); err != nil {
+		if h.Carves.BlockExists(req.SessionID, req.BlockID) {
+			log.Warn().Msgf("duplicated block %d for carve %s", req.BlockID, req.SessionID)
+			return
+		}
 		log.Err(err).Msg("error creating CarvedBlock")
+		return
 	}
 	// Bump block completion
 	if err := h.Carves.CompleteBlock(req.SessionID); err != nil {
@@ -68,27 +100,72 @@ func (h *HandlersTLS) ProcessCarveBlock(req types.CarveBlockRequest, environment
 	}
 	// If it is completed, set status
 	if h.Carves.Completed(req.SessionID) {
-		// Archive carve if the carver is s3
-		if h.Carves.Carver == config.CarverS3 {
-			archived, err := h.Carves.Archive(req.SessionID, "")
-			if err != nil {
-				log.Err(err).Msg("error archiving results")
-				return
-			}
-			if archived == nil {
-				log.Error().Msg("empty archive")
-				return
-			}
-			if err := h.Carves.ArchiveCarve(req.SessionID, archived.File); err != nil {
-				log.Err(err).Msg("error archiving carve")
-			}
-		}
-		if err := h.Carves.ChangeStatus(carves.StatusCompleted, req.SessionID); err != nil {
-			log.Err(err).Msg("error completing carve")
-		}
+		h.finalizeCarve(req.SessionID, envid)
 	} else {
 		if err := h.Carves.ChangeStatus(carves.StatusInProgress, req.SessionID); err != nil {
 			log.Err(err).Msg("error progressing carve")
 		}
 	}
 }
+
+// Helper to verify, archive and hash a carve once all blocks have been received
+func (h *HandlersTLS) finalizeCarve(sessionid string, envid uint) {
+	carve, err := h.Carves.GetBySession(sessionid)
+	if err != nil {
+		log.Err(err).Msg("error getting carve")
+		return
+	}
+	// Verify blocks before marking the carve as completed
+	if err := h.Carves.VerifyBlocks(carve); err != nil {
+		log.Err(err).Msgf("error verifying carve %s", sessionid)
+		if err := h.Carves.SetIntegrity(sessionid, carves.IntegrityFailed, err.Error(), ""); err != nil {
+			log.Err(err).Msg("error setting carve integrity")
+		}
+		h.failCarve(sessionid)
+		if h.AuditLog != nil {
+			h.AuditLog.CarveIntegrity(sessionid, err.Error(), false, envid)
+		}
+		return
+	}
+	// Archive carve if the carver is s3 or local, so blocks are not kept around
+	if h.Carves.Carver == config.CarverS3 || h.Carves.Carver == config.CarverLocal {
+		archived, err := h.Carves.Archive(sessionid, "")
+		if err != nil {
+			log.Err(err).Msg("error archiving results")
+			h.failCarve(sessionid)
+			return
+		}
+		if archived == nil {
+			log.Error().Msg("empty archive")
+			h.failCarve(sessionid)
+			return
+		}
+		if err := h.Carves.ArchiveCarve(sessionid, archived.File); err != nil {
+			log.Err(err).Msg("error archiving carve")
+		}
+		carve.Archived = true
+		carve.ArchivePath = archived.File
+	}
+	// Hash the reassembled carve
+	hash, err := h.Carves.HashArchive(carve)
+	if err != nil {
+		log.Err(err).Msgf("error hashing carve %s", sessionid)
+	} else {
+		if err := h.Carves.SetIntegrity(sessionid, carves.IntegrityVerified, "", hash); err != nil {
+			log.Err(err).Msg("error setting carve integrity")
+		}
+		if h.AuditLog != nil {
+			h.AuditLog.CarveIntegrity(sessionid, hash, true, envid)
+		}
+	}
+	if err := h.Carves.ChangeStatus(carves.StatusCompleted, sessionid); err != nil {
+		log.Err(err).Msg("error completing carve")
+	}
+}
+
+// Helper to mark a carve as failed when it can not be finalized
+func (h *HandlersTLS) failCarve(sessionid string) {
+	if err := h.Carves.ChangeStatus(carves.StatusFailed, sessionid); err != nil {
+		log.Err(err).Msg("error failing carve")
+	}
+}
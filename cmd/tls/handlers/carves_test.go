@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"os"
+	"testing"
+
+	"github.com/jmpsec/osctrl/pkg/carves"
+	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/jmpsec/osctrl/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestProcessCarveBlockArchiveFailure(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&carves.CarvedFile{}, &carves.CarvedBlock{}))
+	local, err := carves.CreateCarverLocal(config.LocalCarverConfiguration{Dir: t.TempDir()})
+	require.NoError(t, err)
+	h := &HandlersTLS{Carves: carves.CreateFileCarves(db, config.CarverLocal, nil, local)}
+	require.NoError(t, h.Carves.CreateCarve(carves.CarvedFile{
+		CarveID:     "carve-id",
+		SessionID:   "session-id",
+		UUID:        "NODE-UUID",
+		Environment: "dev",
+		Carver:      config.CarverLocal,
+		Status:      carves.StatusInProgress,
+		TotalBlocks: 2,
+	}))
+	block := func(id int) types.CarveBlockRequest {
+		return types.CarveBlockRequest{
+			BlockID:   id,
+			SessionID: "session-id",
+			RequestID: "request-id",
+			Data:      base64.StdEncoding.EncodeToString([]byte("block")),
+		}
+	}
+
+	// Retried blocks are only stored and counted once
+	h.ProcessCarveBlock(block(0), "dev", "NODE-UUID", 1)
+	h.ProcessCarveBlock(block(0), "dev", "NODE-UUID", 1)
+	blocks, err := h.Carves.GetBlocks("session-id")
+	require.NoError(t, err)
+	assert.Len(t, blocks, 1)
+
+	// Carve fails when it can not be archived
+	require.NoError(t, os.Remove(local.BlockPath("dev", "session-id", 0)))
+	h.ProcessCarveBlock(block(1), "dev", "NODE-UUID", 1)
+	carve, err := h.Carves.GetBySession("session-id")
+	require.NoError(t, err)
+	assert.Equal(t, 2, carve.CompletedBlocks)
+	assert.Equal(t, carves.StatusFailed, carve.Status)
+	assert.False(t, carve.Archived)
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/jmpsec/osctrl/pkg/auditlog"
 	"github.com/jmpsec/osctrl/pkg/carves"
 	"github.com/jmpsec/osctrl/pkg/config"
 	"github.com/jmpsec/osctrl/pkg/environments"
@@ -23,6 +24,8 @@ const (
 	defOsqueryVersion = version.OsqueryVersion
 	// path for enroll packages
 	enrollPackagesPath = "packages"
+	// configuration for nodes pending approval
+	pendingConfiguration = "{}"
 )
 
 // Valid values for actions in handlers
@@ -55,10 +58,14 @@ type HandlersTLS struct {
 	Tags            *tags.TagManager
 	Queries         *queries.Queries
 	Carves          *carves.Carves
+	AuditLog        *auditlog.AuditLogManager
 	Settings        *settings.Settings
 	SettingsMap     *settings.MapSettings
 	Logs            *logging.LoggerTLS
 	WriteHandler    *batchWriter
+	LogQueue        *ingestQueue
+	QueryQueue      *ingestQueue
+	RetryAfter      time.Duration
 	OsqueryValues   *config.OsqueryConfiguration
 	DebugHTTP       *zerolog.Logger
 	DebugHTTPConfig *config.DebugHTTPConfiguration
@@ -128,6 +135,13 @@ func WithCarves(carves *carves.Carves) Option {
 	}
 }
 
+// WithAuditLog to pass value as option
+func WithAuditLog(auditLog *auditlog.AuditLogManager) Option {
+	return func(h *HandlersTLS) {
+		h.AuditLog = auditLog
+	}
+}
+
 // WithLogs to pass value as option
 func WithLogs(logs *logging.LoggerTLS) Option {
 	return func(h *HandlersTLS) {
@@ -142,6 +156,15 @@ func WithWriteHandler(writeHandler *batchWriter) Option {
 	}
 }
 
+// WithIngestQueues to create the ingestion queues for logs and query results
+func WithIngestQueues(cfg config.JSONConfigurationIngest) Option {
+	return func(h *HandlersTLS) {
+		h.LogQueue = NewLogQueue(cfg.Workers, cfg.QueueSize, cfg.RejectWhenFull)
+		h.QueryQueue = NewQueryQueue(cfg.Workers, cfg.QueueSize, cfg.RejectWhenFull)
+		h.RetryAfter = cfg.RetryAfter
+	}
+}
+
 // WithOsqueryValues to pass osquery configuration values
 func WithOsqueryValues(values *config.OsqueryConfiguration) Option {
 	return func(h *HandlersTLS) {
@@ -178,6 +201,9 @@ func CreateHandlersTLS(opts ...Option) *HandlersTLS {
 	}
 	if h.Envs != nil {
 		h.EnvCache = environments.NewEnvCache(*h.Envs)
+		if h.Envs.Bus != nil {
+			h.EnvCache.Subscribe(h.Envs.Bus)
+		}
 	}
 	return h
 }
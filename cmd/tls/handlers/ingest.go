@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Queue for status and result logs
+	logQueueName = "log"
+	// Queue for distributed query results
+	queryQueueName = "query"
+)
+
+// ingestQueue is a bounded worker pool processing data ingested from nodes.
+type ingestQueue struct {
+	name           string
+	jobs           chan func()
+	rejectWhenFull bool
+}
+
+// NewIngestQueue creates and starts a new ingest queue with its workers.
+func NewIngestQueue(name string, workers, queueSize int, rejectWhenFull bool) *ingestQueue {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	q := &ingestQueue{
+		name:           name,
+		jobs:           make(chan func(), queueSize),
+		rejectWhenFull: rejectWhenFull,
+	}
+	for i := 0; i < workers; i++ {
+		go q.run()
+	}
+	return q
+}
+
+// NewLogQueue creates the ingest queue for status and result logs.
+func NewLogQueue(workers, queueSize int, rejectWhenFull bool) *ingestQueue {
+	return NewIngestQueue(logQueueName, workers, queueSize, rejectWhenFull)
+}
+
+// NewQueryQueue creates the ingest queue for distributed query results.
+func NewQueryQueue(workers, queueSize int, rejectWhenFull bool) *ingestQueue {
+	return NewIngestQueue(queryQueueName, workers, queueSize, rejectWhenFull)
+}
+
+// submit enqueues a job, returning false if it was dropped because the queue is full.
+// Without a queue, the job runs in its own goroutine.
+func (q *ingestQueue) submit(ctx context.Context, job func()) bool {
+	if q == nil {
+		go job()
+		return true
+	}
+	select {
+	case q.jobs <- job:
+		q.updateLength()
+		return true
+	default:
+	}
+	if q.rejectWhenFull {
+		q.drop()
+		return false
+	}
+	// Wait until there is room in the queue or the request is gone
+	select {
+	case q.jobs <- job:
+		q.updateLength()
+		return true
+	case <-ctx.Done():
+		q.drop()
+		return false
+	}
+}
+
+// run is the background worker that processes jobs from the queue.
+func (q *ingestQueue) run() {
+	for job := range q.jobs {
+		q.updateLength()
+		job()
+	}
+}
+
+// updateLength records the current number of jobs waiting in the queue.
+func (q *ingestQueue) updateLength() {
+	ingestQueueLength.WithLabelValues(q.name).Set(float64(len(q.jobs)))
+}
+
+// drop records a job rejected because the queue is full.
+func (q *ingestQueue) drop() {
+	ingestQueueDropped.WithLabelValues(q.name).Inc()
+	log.Warn().Msgf("%s ingest queue is full, dropping request", q.name)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIngestQueueProcess(t *testing.T) {
+	q := NewIngestQueue("test", 2, 10, false)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	processed := 0
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		assert.True(t, q.submit(context.Background(), func() {
+			defer wg.Done()
+			mu.Lock()
+			processed++
+			mu.Unlock()
+		}))
+	}
+	wg.Wait()
+	assert.Equal(t, 5, processed)
+}
+
+func TestIngestQueueRejectWhenFull(t *testing.T) {
+	q := NewIngestQueue("test", 1, 1, true)
+	block := make(chan struct{})
+	started := make(chan struct{})
+	// Worker busy with the first job
+	assert.True(t, q.submit(context.Background(), func() {
+		close(started)
+		<-block
+	}))
+	<-started
+	// Queue holds the second job
+	assert.True(t, q.submit(context.Background(), func() {}))
+	// Queue is full
+	assert.False(t, q.submit(context.Background(), func() {}))
+	close(block)
+}
+
+func TestIngestQueueWaitCancelled(t *testing.T) {
+	q := NewIngestQueue("test", 1, 0, false)
+	block := make(chan struct{})
+	defer close(block)
+	started := make(chan struct{})
+	assert.True(t, q.submit(context.Background(), func() {
+		close(started)
+		<-block
+	}))
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.False(t, q.submit(ctx, func() {}))
+}
+
+func TestIngestQueueNil(t *testing.T) {
+	var q *ingestQueue
+	done := make(chan struct{})
+	assert.True(t, q.submit(context.Background(), func() { close(done) }))
+	<-done
+}
+
+func TestRetryLater(t *testing.T) {
+	h := &HandlersTLS{RetryAfter: 30 * time.Second}
+	w := httptest.NewRecorder()
+	h.retryLater(w)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+}
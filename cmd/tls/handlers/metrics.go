@@ -9,6 +9,7 @@ const (
 	Environment   = "osctrl_env"
 	RequestType   = "type"
 	LogType       = "log_type"
+	QueueName     = "queue"
 )
 
 var (
@@ -37,6 +38,14 @@ var (
 		Help:    "The duration of batch data flushing to backend",
 		Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 2, 5},
 	}, []string{"operation"})
+	ingestQueueLength = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "osctrl_tls_ingest_queue_length",
+		Help: "The number of logs and query results waiting to be processed",
+	}, []string{QueueName})
+	ingestQueueDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "osctrl_tls_ingest_queue_dropped_total",
+		Help: "The number of logs and query results rejected because the queue was full",
+	}, []string{QueueName})
 )
 
 func RegisterMetrics(reg prometheus.Registerer) {
@@ -45,4 +54,6 @@ func RegisterMetrics(reg prometheus.Registerer) {
 	reg.MustRegister(logProcessDuration)
 	reg.MustRegister(distributedQueryProcessingDuration)
 	reg.MustRegister(batchFlushDuration)
+	reg.MustRegister(ingestQueueLength)
+	reg.MustRegister(ingestQueueDropped)
 }
@@ -4,6 +4,7 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/jmpsec/osctrl/pkg/carves"
 	"github.com/jmpsec/osctrl/pkg/environments"
 	"github.com/jmpsec/osctrl/pkg/nodes"
 	"github.com/jmpsec/osctrl/pkg/queries"
@@ -72,18 +74,56 @@ func (h *HandlersTLS) EnrollHandler(w http.ResponseWriter, r *http.Request) {
 		Str("platform", t.PlatformType).
 		Msg("Processing enrollment request")
 
+	// Environments using mTLS only accept nodes presenting a valid client certificate
+	certFingerprint, err := h.enrollClientCert(r, env, t.HostIdentifier)
+	if err != nil {
+		log.Warn().Err(err).
+			Str("env_name", env.Name).
+			Str("host_identifier", t.HostIdentifier).
+			Msg("Invalid client certificate provided")
+		utils.HTTPResponse(w, "", http.StatusForbidden, []byte(""))
+		return
+	}
 	// Check if received secret is valid
 	var nodeKey string
 	var newNode nodes.OsqueryNode
 	nodeInvalid := true
-	if h.checkValidSecret(t.EnrollSecret, env) {
+	if h.checkEnrollSecret(t, env) {
 		// Generate node_key using UUID as entropy
 		nodeKey = generateNodeKey(t.HostIdentifier, time.Now())
 		newNode = nodeFromEnroll(t, env, utils.GetIP(r), nodeKey, len(body))
+		// Nodes wait for approval before receiving configuration and queries
+		newNode.Pending = env.EnrollApproval
+		// Bind the client certificate to the node
+		newNode.CertFingerprint = certFingerprint
 
+		// Check for other nodes with the same UUID, hardware serial or hostname
+		duplicates, err := h.Nodes.FindDuplicates(newNode)
+		if err != nil {
+			log.Err(err).
+				Str("env_name", env.Name).
+				Str("host_identifier", t.HostIdentifier).
+				Msg("error checking duplicated nodes")
+		}
+		if len(duplicates) > 0 {
+			log.Warn().
+				Str("env_name", env.Name).
+				Str("host_identifier", t.HostIdentifier).
+				Int("duplicates", len(duplicates)).
+				Str("policy", env.DuplicatePolicy).
+				Msg("Enrolling node is duplicated")
+		}
 		// Check if UUID exists already, if so archive node and enroll new node
 		existingNode := h.Nodes.CheckByUUIDEnv(t.HostIdentifier, env.Name)
-		if existingNode {
+		if env.DuplicatePolicy == nodes.DuplicateReject && len(rejectingDuplicates(newNode, duplicates)) > 0 {
+			nodeKey = ""
+			log.Warn().
+				Str("env_name", env.Name).
+				Str("host_identifier", t.HostIdentifier).
+				Msg("Enrollment rejected for duplicated node")
+		} else if !h.useEnrollToken(t, env) {
+			nodeKey = ""
+		} else if existingNode && env.DuplicatePolicy != nodes.DuplicateKeep {
 			log.Info().
 				Str("env_name", env.Name).
 				Str("host_identifier", t.HostIdentifier).
@@ -148,6 +188,14 @@ func (h *HandlersTLS) EnrollHandler(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 		}
+		// Enrollment tokens are only consumed by enrolled nodes
+		if nodeInvalid && nodeKey != "" {
+			h.releaseEnrollToken(t, env)
+		}
+		// Merge the history of duplicated nodes into the enrolled node
+		if !nodeInvalid && len(duplicates) > 0 && env.DuplicatePolicy == nodes.DuplicateMerge {
+			h.mergeDuplicates(newNode, duplicates)
+		}
 	} else {
 		log.Err(err).
 			Str("env_name", env.Name).
@@ -167,6 +215,7 @@ func (h *HandlersTLS) EnrollHandler(w http.ResponseWriter, r *http.Request) {
 		Str("env_name", env.Name).
 		Str("host_identifier", t.HostIdentifier).
 		Bool("node_invalid", nodeInvalid).
+		Bool("pending", newNode.Pending).
 		Msg("Enrollment process completed")
 
 	// Serialize and send response
@@ -212,7 +261,7 @@ func (h *HandlersTLS) ConfigHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// We need to update the node info in another go routine
-	if node, err := h.Nodes.GetByKey(t.NodeKey); err == nil {
+	if node, err := h.nodeByKey(r, env, t.NodeKey); err == nil {
 		ip := utils.GetIP(r)
 		if ip == node.IPAddress {
 			ip = ""
@@ -224,6 +273,10 @@ func (h *HandlersTLS) ConfigHandler(w http.ResponseWriter, r *http.Request) {
 		requestSize.WithLabelValues(string(env.UUID), "ConfigHandler").Observe(float64(len(body)))
 		log.Debug().Msgf("node UUID: %s in %s environment ingested %d bytes for ConfigHandler endpoint", node.UUID, env.Name, len(body))
 		response = []byte(env.Configuration)
+		// Nodes pending approval do not receive any configuration
+		if node.Pending {
+			response = []byte(pendingConfiguration)
+		}
 	} else {
 		response = types.ConfigResponse{NodeInvalid: true}
 	}
@@ -301,19 +354,29 @@ func (h *HandlersTLS) LogHandler(w http.ResponseWriter, r *http.Request) {
 	}()
 	var nodeInvalid bool
 	// Check if provided node_key is valid and if so, update node
-	node, err := h.Nodes.GetByKey(t.NodeKey)
-	if err == nil {
+	node, err := h.nodeByKey(r, env, t.NodeKey)
+	if err == nil && node.Pending {
+		// Logs from nodes pending approval are discarded
+		nodeInvalid = false
+		log.Debug().Msgf("node UUID: %s in %s environment is pending approval, discarding logs", node.UUID, env.Name)
+	} else if err == nil {
 		nodeInvalid = false
 		// Record ingested data
 		requestSize.WithLabelValues(string(env.UUID), "LogHandler").Observe(float64(len(body)))
 		log.Debug().Msgf("node UUID: %s in %s environment ingested %d bytes for LogHandler endpoint", node.UUID, env.Name, len(body))
 		// Process logs and update metadata
-		go func() {
+		ip := utils.GetIP(r)
+		debug := (*h.EnvsMap)[env.Name].DebugHTTP
+		queued := h.LogQueue.submit(r.Context(), func() {
 			start := time.Now()
-			h.Logs.ProcessLogs(t.Data, t.LogType, env.Name, utils.GetIP(r), len(body), (*h.EnvsMap)[env.Name].DebugHTTP)
+			h.Logs.ProcessLogs(t.Data, t.LogType, env.Name, ip, len(body), debug)
 			duration := time.Since(start).Seconds()
 			logProcessDuration.WithLabelValues(string(env.UUID), t.LogType).Observe(duration)
-		}()
+		})
+		if !queued {
+			h.retryLater(w)
+			return
+		}
 	} else {
 		nodeInvalid = true
 	}
@@ -367,15 +430,18 @@ func (h *HandlersTLS) QueryReadHandler(w http.ResponseWriter, r *http.Request) {
 	var nodeInvalid, accelerate bool
 	qs := make(queries.QueryReadQueries)
 	// Check if provided node_key is valid and if so, update node
-	if node, err := h.Nodes.GetByKey(t.NodeKey); err == nil {
+	if node, err := h.nodeByKey(r, env, t.NodeKey); err == nil {
 		// Record ingested data
 		requestSize.WithLabelValues(string(env.UUID), "QueryRead").Observe(float64(len(body)))
 		log.Debug().Msgf("node UUID: %s in %s environment ingested %d bytes for QueryReadHandler endpoint", node.UUID, env.Name, len(body))
 
 		nodeInvalid = false
-		qs, accelerate, err = h.Queries.NodeQueries(node)
-		if err != nil {
-			log.Err(err).Msg("error getting queries from db")
+		// Nodes pending approval do not receive any queries
+		if !node.Pending {
+			qs, accelerate, err = h.Queries.NodeQueries(node)
+			if err != nil {
+				log.Err(err).Msg("error getting queries from db")
+			}
 		}
 		// Refresh node last seen
 		ip := utils.GetIP(r)
@@ -444,12 +510,28 @@ func (h *HandlersTLS) QueryWriteHandler(w http.ResponseWriter, r *http.Request)
 	}
 	var nodeInvalid bool
 	// Check if provided node_key is valid and if so, update node
-	if node, err := h.Nodes.GetByKey(t.NodeKey); err == nil {
+	if node, err := h.nodeByKey(r, env, t.NodeKey); err == nil && node.Pending {
+		// Results from nodes pending approval are discarded
+		nodeInvalid = false
+		log.Debug().Msgf("node UUID: %s in %s environment is pending approval, discarding results", node.UUID, env.Name)
+	} else if err == nil {
 		// Record ingested data
 		requestSize.WithLabelValues(string(env.UUID), "QueryWrite").Observe(float64(len(body)))
 		log.Debug().Msgf("node UUID: %s in %s environment ingested %d bytes for QueryWriteHandler endpoint", node.UUID, env.Name, len(body))
 
 		nodeInvalid = false
+		// Process submitted results and mark query as processed
+		debug := (*h.EnvsMap)[env.Name].DebugHTTP
+		queued := h.QueryQueue.submit(r.Context(), func() {
+			start := time.Now()
+			h.Logs.ProcessLogQueryResult(t, env.ID, debug)
+			duration := time.Since(start).Seconds()
+			distributedQueryProcessingDuration.WithLabelValues(string(env.UUID)).Observe(duration)
+		})
+		if !queued {
+			h.retryLater(w)
+			return
+		}
 		for name, c := range t.Queries {
 			var carves []types.QueryCarveScheduled
 			if err := json.Unmarshal(c, &carves); err == nil {
@@ -468,13 +550,6 @@ func (h *HandlersTLS) QueryWriteHandler(w http.ResponseWriter, r *http.Request)
 			ip = ""
 		}
 		h.WriteHandler.addEvent(lastSeenUpdate{NodeID: node.ID, IP: ip})
-		// Process submitted results and mark query as processed
-		go func() {
-			start := time.Now()
-			h.Logs.ProcessLogQueryResult(t, env.ID, (*h.EnvsMap)[env.Name].DebugHTTP)
-			duration := time.Since(start).Seconds()
-			distributedQueryProcessingDuration.WithLabelValues(string(env.UUID)).Observe(duration)
-		}()
 	} else {
 		nodeInvalid = true
 	}
@@ -676,17 +751,25 @@ func (h *HandlersTLS) CarveInitHandler(w http.ResponseWriter, r *http.Request) {
 	initCarve := false
 	var carveSessionID string
 	// Check if provided node_key is valid and if so, update node
-	if node, err := h.Nodes.GetByKey(t.NodeKey); err == nil {
+	if node, err := h.nodeByKey(r, env, t.NodeKey); err == nil {
 		// Record ingested data
 		requestSize.WithLabelValues(string(env.UUID), "CarveInit").Observe(float64(len(body)))
 		log.Debug().Msgf("node UUID: %s in %s environment ingested %d bytes for CarveInitHandler endpoint", node.UUID, env.Name, len(body))
 
-		initCarve = true
-		carveSessionID = generateCarveSessionID()
-		// Process carve init
-		if err := h.ProcessCarveInit(t, carveSessionID, env.Name); err != nil {
-			log.Err(err).Msg("error procesing carve init")
-			initCarve = false
+		// Check carve limits for the environment
+		if reason := h.checkCarveLimits(env, t.CarveSize); reason != "" {
+			log.Warn().Msgf("carve %s from node %s rejected: %s", t.RequestID, node.UUID, reason)
+			if err := h.Carves.Reject(t.RequestID, node.UUID, t.CarveSize); err != nil {
+				log.Err(err).Msg("error rejecting carve")
+			}
+		} else {
+			initCarve = true
+			carveSessionID = generateCarveSessionID()
+			// Process carve init
+			if err := h.ProcessCarveInit(t, carveSessionID, env.Name); err != nil {
+				log.Err(err).Msg("error procesing carve init")
+				initCarve = false
+			}
 		}
 		// Refresh last seen
 		ip := utils.GetIP(r)
@@ -745,6 +828,18 @@ func (h *HandlersTLS) CarveBlockHandler(w http.ResponseWriter, r *http.Request)
 	blockCarve := false
 	// Check if provided session_id matches with the request_id (carve query name)
 	if carve, err := h.Carves.GetCheckCarve(t.SessionID, t.RequestID); err == nil {
+		// Stale carves do not accept more blocks, partial data has been removed already
+		if carve.Status == carves.StatusStale {
+			log.Warn().Msgf("block %d for stale carve %s", t.BlockID, t.SessionID)
+			utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, types.CarveBlockResponse{Success: false})
+			return
+		}
+		// Reject blocks that do not belong to the carve
+		if t.BlockID < 0 || t.BlockID >= carve.TotalBlocks {
+			log.Warn().Msgf("out of range block %d for carve %s", t.BlockID, t.SessionID)
+			utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, types.CarveBlockResponse{Success: false})
+			return
+		}
 		// Record ingested data
 		requestSize.WithLabelValues(string(env.UUID), "CarveBlock").Observe(float64(len(body)))
 		log.Info().Msgf("node %d in %s environment ingested %d bytes for CarveBlockHandler endpoint", carve.NodeID, env.Name, len(body))
@@ -804,7 +899,7 @@ func (h *HandlersTLS) FlagsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	// Check if provided secret is valid and if so, prepare flags
 	if h.checkValidSecret(t.Secret, env) {
-		flagsStr, err := h.Envs.GenerateFlags(env, t.SecrefFile, t.CertFile, *h.OsqueryValues)
+		flagsStr, err := h.Envs.GenerateFlagsClient(env, t.SecrefFile, t.CertFile, t.ClientCertFile, t.ClientKeyFile, *h.OsqueryValues)
 		if err != nil {
 			log.Err(err).Msg("error generating flags")
 			utils.HTTPResponse(w, "", http.StatusInternalServerError, []byte(""))
@@ -861,7 +956,7 @@ func (h *HandlersTLS) CertHandler(w http.ResponseWriter, r *http.Request) {
 		utils.HTTPResponse(w, "", http.StatusInternalServerError, []byte(""))
 		return
 	}
-	// Check if provided secret is valid and if so, prepare flags
+	// Check if provided secret is valid and if so, prepare certificate
 	if h.checkValidSecret(t.Secret, env) {
 		response = []byte(env.Certificate)
 	} else {
@@ -876,6 +971,66 @@ func (h *HandlersTLS) CertHandler(w http.ResponseWriter, r *http.Request) {
 	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, response)
 }
 
+// ClientCertHandler - Function to issue client certificates to nodes of environments using mTLS, from osctrld
+func (h *HandlersTLS) ClientCertHandler(w http.ResponseWriter, r *http.Request) {
+	// Retrieve environment variable
+	envVar := r.PathValue("env")
+	if envVar == "" {
+		utils.HTTPResponse(w, "", http.StatusBadRequest, []byte(""))
+		return
+	}
+	// To prevent abuse, check if the received UUID is valid
+	if !utils.CheckUUID(envVar) {
+		utils.HTTPResponse(w, "", http.StatusBadRequest, []byte(""))
+		return
+	}
+	// Get environment
+	env, err := h.Envs.GetByUUID(envVar)
+	if err != nil {
+		log.Err(err).Msg("error getting environment")
+		utils.HTTPResponse(w, "", http.StatusInternalServerError, []byte(""))
+		return
+	}
+	if !env.ClientCerts {
+		utils.HTTPResponse(w, "", http.StatusNotFound, []byte(""))
+		return
+	}
+	// Debug HTTP if enabled
+	if h.DebugHTTPConfig.Enabled {
+		utils.DebugHTTPDump(h.DebugHTTP, r, h.DebugHTTPConfig.ShowBody)
+	}
+	// Decode read POST body
+	var t types.ClientCertRequest
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Err(err).Msg("error reading POST body")
+		utils.HTTPResponse(w, "", http.StatusInternalServerError, []byte(""))
+		return
+	}
+	if err := json.Unmarshal(body, &t); err != nil {
+		log.Err(err).Msg("error parsing POST body")
+		utils.HTTPResponse(w, "", http.StatusInternalServerError, []byte(""))
+		return
+	}
+	if t.UUID == "" || !h.checkClientCertSecret(t, env) {
+		utils.HTTPResponse(w, "", http.StatusForbidden, []byte(""))
+		return
+	}
+	clientCert, clientKey, err := h.Envs.IssueClientCert(env, t.UUID)
+	if errors.Is(err, environments.ErrRevokedClientCert) {
+		log.Warn().Err(err).Str("env_name", env.Name).Str("uuid", t.UUID).Msg("Client certificate refused")
+		utils.HTTPResponse(w, "", http.StatusForbidden, []byte(""))
+		return
+	}
+	if err != nil {
+		log.Err(err).Msg("error issuing client certificate")
+		utils.HTTPResponse(w, "", http.StatusInternalServerError, []byte(""))
+		return
+	}
+	log.Info().Str("env_name", env.Name).Str("uuid", t.UUID).Msg("Client certificate issued")
+	utils.HTTPResponse(w, utils.JSONApplicationUTF8, http.StatusOK, types.ClientCertResponse{Certificate: clientCert, Key: clientKey})
+}
+
 // VerifyHandler - Function to verify status of enrolled osquery nodes, from osctrld
 func (h *HandlersTLS) VerifyHandler(w http.ResponseWriter, r *http.Request) {
 	var response types.VerifyResponse
@@ -916,7 +1071,7 @@ func (h *HandlersTLS) VerifyHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	// Check if provided secret is valid and if so, prepare flags
 	if h.checkValidSecret(t.Secret, env) {
-		flagsStr, err := h.Envs.GenerateFlags(env, t.SecrefFile, t.CertFile, *h.OsqueryValues)
+		flagsStr, err := h.Envs.GenerateFlagsClient(env, t.SecrefFile, t.CertFile, t.ClientCertFile, t.ClientKeyFile, *h.OsqueryValues)
 		if err != nil {
 			log.Err(err).Msg("error generating flags")
 			utils.HTTPResponse(w, "", http.StatusInternalServerError, []byte(""))
@@ -3,15 +3,19 @@ package handlers
 import (
 	"bytes"
 	"crypto/sha1"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/jmpsec/osctrl/pkg/environments"
+	"github.com/jmpsec/osctrl/pkg/handlers"
 	"github.com/jmpsec/osctrl/pkg/nodes"
 	"github.com/jmpsec/osctrl/pkg/types"
+	"github.com/jmpsec/osctrl/pkg/utils"
 	"github.com/rs/zerolog/log"
 	"github.com/segmentio/ksuid"
 )
@@ -37,6 +41,100 @@ func (h *HandlersTLS) checkValidSecret(secret string, env environments.TLSEnviro
 	return (strings.TrimSpace(secret) == env.Secret)
 }
 
+// Helper to check if the enroll secret of a request is valid for this environment, being the shared
+// secret or an enrollment token for the host. Environments can require enrollment tokens only.
+// Enrollment tokens are only checked here, the use is registered once the node is accepted and it is
+// reverted if the node can not be enrolled
+func (h *HandlersTLS) checkEnrollSecret(req types.EnrollRequest, env environments.TLSEnvironment) bool {
+	if environments.IsEnrollToken(req.EnrollSecret) {
+		info := req.HostDetails.EnrollSystemInfo
+		if _, err := h.Envs.CheckEnrollToken(env.ID, req.EnrollSecret, info.Hostname, info.HardwareSerial); err != nil {
+			log.Warn().Err(err).Str("env_name", env.Name).Str("host_identifier", req.HostIdentifier).Msg("Invalid enrollment token provided")
+			return false
+		}
+		return true
+	}
+	if env.EnrollTokens {
+		return false
+	}
+	return h.checkValidSecret(req.EnrollSecret, env)
+}
+
+// Helper to register the use of the enrollment token of an accepted node, if one was used. Tokens
+// without uses left because of concurrent enrollments make the enrollment fail
+func (h *HandlersTLS) useEnrollToken(req types.EnrollRequest, env environments.TLSEnvironment) bool {
+	if !environments.IsEnrollToken(req.EnrollSecret) {
+		return true
+	}
+	info := req.HostDetails.EnrollSystemInfo
+	token, err := h.Envs.UseEnrollToken(env.ID, req.EnrollSecret, info.Hostname, info.HardwareSerial)
+	if err != nil {
+		log.Warn().Err(err).Str("env_name", env.Name).Str("host_identifier", req.HostIdentifier).Msg("Enrollment token can not be used")
+		return false
+	}
+	log.Info().Str("env_name", env.Name).Str("host_identifier", req.HostIdentifier).Str("token_id", token.TokenID).Int("uses", token.Uses).Msg("Enrollment token used")
+	return true
+}
+
+// Helper to revert the use of the enrollment token of a node that could not be enrolled, if one was used
+func (h *HandlersTLS) releaseEnrollToken(req types.EnrollRequest, env environments.TLSEnvironment) {
+	if !environments.IsEnrollToken(req.EnrollSecret) {
+		return
+	}
+	if err := h.Envs.ReleaseEnrollToken(env.ID, req.EnrollSecret); err != nil {
+		log.Err(err).Str("env_name", env.Name).Str("host_identifier", req.HostIdentifier).Msg("error releasing enrollment token")
+	}
+}
+
+// Helper to check if the secret of a request for a client certificate is valid for this environment.
+// Certificates are issued with an enrollment token for the host, or with the enroll secret only if
+// new nodes need approval, so the secret alone does not give access to the environment
+func (h *HandlersTLS) checkClientCertSecret(req types.ClientCertRequest, env environments.TLSEnvironment) bool {
+	if environments.IsEnrollToken(req.Secret) {
+		if _, err := h.Envs.CheckEnrollToken(env.ID, req.Secret, req.Hostname, req.HardwareSerial); err != nil {
+			log.Warn().Err(err).Str("env_name", env.Name).Str("uuid", req.UUID).Msg("Invalid enrollment token provided")
+			return false
+		}
+		return true
+	}
+	if env.EnrollTokens || !env.EnrollApproval {
+		return false
+	}
+	return h.checkValidSecret(req.Secret, env)
+}
+
+// Helper to get the client certificate presented by the node, if any
+func clientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	return r.TLS.PeerCertificates[0]
+}
+
+// Helper to verify the client certificate of an enrolling node and get its fingerprint, only for
+// environments using mTLS. Other environments do not need client certificates
+func (h *HandlersTLS) enrollClientCert(r *http.Request, env environments.TLSEnvironment, uuid string) (string, error) {
+	if !env.ClientCerts {
+		return "", nil
+	}
+	return h.Envs.VerifyClientCert(env, clientCertificate(r), uuid)
+}
+
+// Helper to get a node by node_key, checking for environments using mTLS that the client certificate
+// presented is the one bound to the node. Otherwise the node is considered invalid, so it enrolls again
+func (h *HandlersTLS) nodeByKey(r *http.Request, env environments.TLSEnvironment, nodeKey string) (nodes.OsqueryNode, error) {
+	node, err := h.Nodes.GetByKey(nodeKey)
+	if err != nil || !env.ClientCerts {
+		return node, err
+	}
+	cert := clientCertificate(r)
+	if cert == nil || node.CertFingerprint == "" || environments.CertFingerprint(cert) != node.CertFingerprint {
+		log.Warn().Str("env_name", env.Name).Str("uuid", node.UUID).Msg("Client certificate does not match the node")
+		return node, fmt.Errorf("client certificate does not match node %s", node.UUID)
+	}
+	return node, nil
+}
+
 // Helper to check if the provided SecretPath is valid for enrolling in a environment
 func (h *HandlersTLS) checkValidEnrollSecretPath(env environments.TLSEnvironment, secretpath string) bool {
 	return h.checkValidRemovePath(secretpath, env.EnrollSecretPath)
@@ -101,6 +199,56 @@ func nodeFromEnroll(req types.EnrollRequest, env environments.TLSEnvironment, ip
 	}
 }
 
+// Helper to merge the nodes duplicated by UUID or hardware serial into the enrolled node, nodes
+// only sharing the hostname are reported but not merged automatically
+func (h *HandlersTLS) mergeDuplicates(enrolled nodes.OsqueryNode, duplicates []nodes.OsqueryNode) {
+	target, err := h.Nodes.GetByKey(enrolled.NodeKey)
+	if err != nil {
+		log.Err(err).Str("host_identifier", enrolled.UUID).Msg("error getting enrolled node to merge")
+		return
+	}
+	toMerge := sameHostDuplicates(target, duplicates)
+	manager := handlers.Managers{Envs: h.Envs, Nodes: h.Nodes, Tags: h.Tags}
+	if err := handlers.MergeNodes(manager, h.Queries, h.Carves, target, toMerge); err != nil {
+		log.Err(err).Str("host_identifier", enrolled.UUID).Msg("error merging duplicated nodes")
+		return
+	}
+	if h.AuditLog == nil {
+		return
+	}
+	for _, d := range toMerge {
+		h.AuditLog.NodeAction(h.AuditLog.Service, fmt.Sprintf("merge node %s into enrolled node %s", d.UUID, target.UUID), "", target.EnvironmentID)
+	}
+}
+
+// Helper to get the duplicates that identify the same host as the node, by UUID or hardware serial.
+// Nodes only sharing the hostname are not considered the same host
+func sameHostDuplicates(node nodes.OsqueryNode, duplicates []nodes.OsqueryNode) []nodes.OsqueryNode {
+	var res []nodes.OsqueryNode
+	for _, d := range duplicates {
+		if node.ID != 0 && d.ID == node.ID {
+			continue
+		}
+		switch nodes.DuplicateField(node, d) {
+		case nodes.DuplicateByUUID, nodes.DuplicateBySerial:
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+// Helper to get the duplicates that reject the enrollment of a node, other hosts with the same hardware
+// serial. Nodes with the same UUID are re-enrollments of the node, handled as such
+func rejectingDuplicates(node nodes.OsqueryNode, duplicates []nodes.OsqueryNode) []nodes.OsqueryNode {
+	var res []nodes.OsqueryNode
+	for _, d := range sameHostDuplicates(node, duplicates) {
+		if !strings.EqualFold(d.UUID, node.UUID) {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
 // Helper to remove duplicates from array of strings
 func uniq(duplicated []string) []string {
 	keys := make(map[string]bool)
@@ -118,3 +266,11 @@ func uniq(duplicated []string) []string {
 func genPackageFilename(envName, osctrlVersion, osqueryVersion, pkgType string) string {
 	return fmt.Sprintf("osctrl-%s-%s-osquery-%s.%s", envName, osctrlVersion, osqueryVersion, pkgType)
 }
+
+// Helper to respond with a 503 so osquery keeps the buffered data and retries later
+func (h *HandlersTLS) retryLater(w http.ResponseWriter) {
+	if h.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(h.RetryAfter.Seconds())))
+	}
+	utils.HTTPResponse(w, "", http.StatusServiceUnavailable, []byte(""))
+}
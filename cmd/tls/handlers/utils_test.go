@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -9,6 +13,7 @@ import (
 	"github.com/jmpsec/osctrl/pkg/nodes"
 	"github.com/jmpsec/osctrl/pkg/types"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 func TestGenerateNodeKey(t *testing.T) {
@@ -110,8 +115,47 @@ func TestUniq(t *testing.T) {
 	assert.Equal(t, bb, aa)
 }
 
+func TestRejectingDuplicates(t *testing.T) {
+	enrolling := nodes.OsqueryNode{UUID: "UUID-1", Hostname: "web-1", HardwareSerial: "SERIAL-1", EnvironmentID: 1}
+	sameUUID := nodes.OsqueryNode{Model: gorm.Model{ID: 1}, UUID: "uuid-1", Hostname: "web-1", HardwareSerial: "SERIAL-1", EnvironmentID: 1}
+	sameSerial := nodes.OsqueryNode{Model: gorm.Model{ID: 2}, UUID: "UUID-2", Hostname: "web-2", HardwareSerial: "SERIAL-1", EnvironmentID: 1}
+	sameHostname := nodes.OsqueryNode{Model: gorm.Model{ID: 3}, UUID: "UUID-3", Hostname: "web-1", HardwareSerial: "0", EnvironmentID: 1}
+	duplicates := []nodes.OsqueryNode{sameUUID, sameSerial, sameHostname}
+
+	// Merging uses UUID and hardware serial, rejecting only other hosts with the same serial
+	assert.Equal(t, []nodes.OsqueryNode{sameUUID, sameSerial}, sameHostDuplicates(enrolling, duplicates))
+	assert.Equal(t, []nodes.OsqueryNode{sameSerial}, rejectingDuplicates(enrolling, duplicates))
+	assert.Empty(t, rejectingDuplicates(enrolling, []nodes.OsqueryNode{sameUUID, sameHostname}))
+}
+
 func TestPackageFilename(t *testing.T) {
 	aa := genPackageFilename("test", "1.2.3", "3.2.1", "msi")
 	bb := "osctrl-test-1.2.3-osquery-3.2.1.msi"
 	assert.Equal(t, bb, aa)
 }
+
+func TestClientCertificate(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/env/config", nil)
+	assert.Nil(t, clientCertificate(req))
+	req.TLS = &tls.ConnectionState{}
+	assert.Nil(t, clientCertificate(req))
+	cert := &x509.Certificate{Raw: []byte("certificate")}
+	req.TLS.PeerCertificates = []*x509.Certificate{cert}
+	assert.Equal(t, cert, clientCertificate(req))
+}
+
+func TestCheckClientCertSecret(t *testing.T) {
+	h := &HandlersTLS{}
+	env := environments.TLSEnvironment{Name: "dev", Secret: "secret", ClientCerts: true}
+	req := types.ClientCertRequest{Secret: "secret", UUID: "NODE-UUID"}
+
+	// The enroll secret alone only gets certificates when new nodes need approval
+	assert.False(t, h.checkClientCertSecret(req, env))
+	env.EnrollApproval = true
+	assert.True(t, h.checkClientCertSecret(req, env))
+	req.Secret = "invalid"
+	assert.False(t, h.checkClientCertSecret(req, env))
+	req.Secret = "secret"
+	env.EnrollTokens = true
+	assert.False(t, h.checkClientCertSecret(req, env))
+}
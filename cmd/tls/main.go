@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"net/http"
@@ -11,12 +12,14 @@ import (
 	"time"
 
 	"github.com/jmpsec/osctrl/cmd/tls/handlers"
+	"github.com/jmpsec/osctrl/pkg/auditlog"
 	"github.com/jmpsec/osctrl/pkg/backend"
 	"github.com/jmpsec/osctrl/pkg/cache"
 	"github.com/jmpsec/osctrl/pkg/carves"
 	"github.com/jmpsec/osctrl/pkg/config"
 	"github.com/jmpsec/osctrl/pkg/environments"
 	"github.com/jmpsec/osctrl/pkg/logging"
+	"github.com/jmpsec/osctrl/pkg/migrations"
 	"github.com/jmpsec/osctrl/pkg/nodes"
 	"github.com/jmpsec/osctrl/pkg/queries"
 	"github.com/jmpsec/osctrl/pkg/settings"
@@ -60,23 +63,26 @@ var (
 
 // Global variables
 var (
-	err         error
-	db          *backend.DBManager
-	redis       *cache.RedisManager
-	settingsmgr *settings.Settings
-	envs        *environments.EnvManager
-	envsmap     environments.MapEnvironments
-	settingsmap settings.MapSettings
-	nodesmgr    *nodes.NodeManager
-	queriesmgr  *queries.Queries
-	filecarves  *carves.Carves
-	loggerTLS   *logging.LoggerTLS
-	handlersTLS *handlers.HandlersTLS
-	tagsmgr     *tags.TagManager
-	carvers3    *carves.CarverS3
-	app         *cli.App
-	flags       []cli.Flag
-	flagParams  config.ServiceFlagParams
+	err           error
+	db            *backend.DBManager
+	redis         *cache.RedisManager
+	invalidations cache.InvalidationBus
+	settingsmgr   *settings.Settings
+	envs          *environments.EnvManager
+	envsmap       environments.MapEnvironments
+	settingsmap   settings.MapSettings
+	nodesmgr      *nodes.NodeManager
+	queriesmgr    *queries.Queries
+	filecarves    *carves.Carves
+	loggerTLS     *logging.LoggerTLS
+	handlersTLS   *handlers.HandlersTLS
+	tagsmgr       *tags.TagManager
+	carvers3      *carves.CarverS3
+	carverlocal   *carves.CarverLocal
+	auditLog      *auditlog.AuditLogManager
+	app           *cli.App
+	flags         []cli.Flag
+	flagParams    config.ServiceFlagParams
 )
 
 // Valid values for authentication in configuration
@@ -95,6 +101,7 @@ var validLogging = map[string]bool{
 	config.LoggingLogstash: true,
 	config.LoggingKinesis:  true,
 	config.LoggingS3:       true,
+	config.LoggingKafka:    true,
 	config.LoggingElastic:  true,
 }
 
@@ -175,6 +182,11 @@ func osctrlService() {
 		log.Info().Msgf("Backend NOT ready! Retrying in %d seconds...\n", flagParams.DBConfigValues.ConnRetry)
 		time.Sleep(time.Duration(flagParams.DBConfigValues.ConnRetry) * time.Second)
 	}
+	// ////////////////////////////// Schema
+	log.Info().Msg("Checking database schema...")
+	if err := migrations.Startup(db.Conn, flagParams.DBSchemaCheck); err != nil {
+		log.Fatal().Msgf("Error with database schema - %v", err)
+	}
 	// ////////////////////////////// Cache
 	log.Info().Msg("Initializing cache...")
 	// Attempt to connect to cache waiting until is ready
@@ -205,7 +217,23 @@ func osctrlService() {
 	log.Info().Msg("Initialize queries")
 	queriesmgr = queries.CreateQueries(db.Conn)
 	log.Info().Msg("Initialize carves")
-	filecarves = carves.CreateFileCarves(db.Conn, flagParams.ConfigValues.Carver, carvers3)
+	filecarves = carves.CreateFileCarves(db.Conn, flagParams.ConfigValues.Carver, carvers3, carverlocal)
+	log.Info().Msg("Initialize cache invalidation")
+	invalidations, err = cache.NewRedisBus(redis)
+	if err != nil {
+		log.Fatal().Msgf("Error initializing cache invalidation - %v", err)
+	}
+	envs.Bus = invalidations
+	settingsmgr.Bus = invalidations
+	nodesmgr.SetInvalidationBus(invalidations)
+	invalidations.Subscribe(cache.TopicEnvironments, func(ctx context.Context, inv cache.Invalidation) {
+		envsmap = refreshEnvironments()
+	})
+	invalidations.Subscribe(cache.TopicSettings, func(ctx context.Context, inv cache.Invalidation) {
+		if inv.Key == "" || inv.Key == config.ServiceTLS {
+			settingsmap = refreshSettings()
+		}
+	})
 	log.Info().Msg("Loading service settings")
 	if err := loadingSettings(settingsmgr, flagParams.ConfigValues); err != nil {
 		log.Fatal().Msgf("Error loading settings - %s: %v", flagParams.ConfigValues.Logger, err)
@@ -261,6 +289,7 @@ func osctrlService() {
 		// Register Prometheus metrics
 		handlers.RegisterMetrics(prometheus.DefaultRegisterer)
 		cache.RegisterMetrics(prometheus.DefaultRegisterer)
+		logging.RegisterMetrics(prometheus.DefaultRegisterer)
 		// Creating a new prometheus service
 		prometheusServer := http.NewServeMux()
 		prometheusServer.Handle("/metrics", promhttp.Handler())
@@ -272,6 +301,16 @@ func osctrlService() {
 			}
 		}()
 	}
+	// Initialize audit log manager
+	if flagParams.AuditLog {
+		log.Info().Msg("Initialize audit log (enabled)")
+	} else {
+		log.Info().Msg("Initialize audit log (disabled)")
+	}
+	auditLog, err = auditlog.CreateAuditLogManager(db.Conn, serviceName, flagParams.AuditLog)
+	if err != nil {
+		log.Fatal().Msgf("Error initializing audit log manager - %v", err)
+	}
 	// Initialize TLS handlers before router
 	log.Info().Msg("Initializing handlers")
 	handlersTLS = handlers.CreateHandlersTLS(
@@ -281,10 +320,12 @@ func osctrlService() {
 		handlers.WithTags(tagsmgr),
 		handlers.WithQueries(queriesmgr),
 		handlers.WithCarves(filecarves),
+		handlers.WithAuditLog(auditLog),
 		handlers.WithSettings(settingsmgr),
 		handlers.WithSettingsMap(&settingsmap),
 		handlers.WithLogs(loggerTLS),
 		handlers.WithWriteHandler(tlsWriter),
+		handlers.WithIngestQueues(flagParams.IngestConfig),
 		handlers.WithOsqueryValues(&flagParams.OsqueryConfigValues),
 		handlers.WithDebugHTTP(&flagParams.DebugHTTPValues),
 	)
@@ -327,6 +368,8 @@ func osctrlService() {
 		muxTLS.HandleFunc("POST /{env}/"+environments.DefaultFlagsPath, handlersTLS.FlagsHandler)
 		// TLS: osctrld retrieve certificate
 		muxTLS.HandleFunc("POST /{env}/"+environments.DefaultCertPath, handlersTLS.CertHandler)
+		// TLS: osctrld client certificate for environments using mTLS
+		muxTLS.HandleFunc("POST /{env}/"+environments.DefaultClientCertPath, handlersTLS.ClientCertHandler)
 		// TLS: osctrld verification
 		muxTLS.HandleFunc("POST /{env}/"+environments.DefaultVerifyPath, handlersTLS.VerifyHandler)
 		// TLS: osctrld retrieve script to install/remove osquery
@@ -347,6 +390,8 @@ func osctrlService() {
 				tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
 				tls.TLS_RSA_WITH_AES_256_CBC_SHA,
 			},
+			// Client certificates are verified per environment, only for environments using mTLS
+			ClientAuth: tls.RequestClientCert,
 		}
 		srv := &http.Server{
 			Addr:         serviceListener,
@@ -360,6 +405,7 @@ func osctrlService() {
 			log.Fatal().Msgf("ListenAndServeTLS: %v", err)
 		}
 	} else {
+		log.Info().Msg("TLS Termination is disabled, client certificates (mTLS) are not available")
 		log.Info().Msgf("%s v%s - HTTP listening %s", serviceName, buildVersion, serviceListener)
 		log.Info().Msgf("%s - commit=%s - build date=%s", serviceName, buildCommit, buildDate)
 		if err := http.ListenAndServe(serviceListener, muxTLS); err != nil {
@@ -402,6 +448,16 @@ func cliAction(c *cli.Context) error {
 			return fmt.Errorf("failed to initiate s3 carver - %w", err)
 		}
 	}
+	if flagParams.ConfigValues.Carver == config.CarverLocal {
+		if flagParams.LocalCarverConfig.Dir != "" {
+			carverlocal, err = carves.CreateCarverLocal(flagParams.LocalCarverConfig)
+		} else {
+			carverlocal, err = carves.CreateCarverLocalFile(flagParams.CarverConfigFile)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to initiate local carver - %w", err)
+		}
+	}
 	return nil
 }
 
@@ -46,17 +46,13 @@ type AuditLogManager struct {
 	Enabled bool
 }
 
-// CreateAuditLogManager to initialize the audit log struct and tables
+// CreateAuditLogManager to initialize the audit log struct, tables are created by migrations
 func CreateAuditLogManager(backend *gorm.DB, service string, enabled bool) (*AuditLogManager, error) {
 	var t *AuditLogManager = &AuditLogManager{
 		DB:      backend,
 		Service: service,
 		Enabled: enabled,
 	}
-	// table audit_log
-	if err := backend.AutoMigrate(&AuditLog{}); err != nil {
-		return t, fmt.Errorf("failed to AutoMigrate table (audit_log): %w", err)
-	}
 	return t, nil
 }
 
@@ -114,6 +110,28 @@ func (m *AuditLogManager) NewLogin(username, ip string) {
 	}
 }
 
+// LoginFailed - create new failed login audit log entry
+func (m *AuditLogManager) LoginFailed(username, reason, ip string) {
+	if !m.Enabled {
+		return
+	}
+	line := fmt.Sprintf("user %s failed to login: %s", username, reason)
+	if err := m.CreateNew(username, line, ip, LogTypeLogin, SeverityWarning, NoEnvironment); err != nil {
+		log.Err(err).Msg("error creating failed login audit log")
+	}
+}
+
+// Lockout - create new user lockout audit log entry
+func (m *AuditLogManager) Lockout(username, ip string) {
+	if !m.Enabled {
+		return
+	}
+	line := fmt.Sprintf("user %s locked out after too many failed logins", username)
+	if err := m.CreateNew(username, line, ip, LogTypeLogin, SeverityError, NoEnvironment); err != nil {
+		log.Err(err).Msg("error creating lockout audit log")
+	}
+}
+
 // NewLogout - create new logout audit log entry
 func (m *AuditLogManager) NewLogout(username, ip string) {
 	if !m.Enabled {
@@ -169,6 +187,44 @@ func (m *AuditLogManager) CarveAction(username, action, ip string, envID uint) {
 	}
 }
 
+// CarveIntegrity - create new carve verification audit log entry
+func (m *AuditLogManager) CarveIntegrity(sessionid, detail string, verified bool, envID uint) {
+	if !m.Enabled {
+		return
+	}
+	severity := uint(SeverityInfo)
+	line := fmt.Sprintf("carve %s verified with sha256: %s", sessionid, detail)
+	if !verified {
+		severity = SeverityError
+		line = fmt.Sprintf("carve %s failed verification: %s", sessionid, detail)
+	}
+	if err := m.CreateNew(m.Service, line, "", LogTypeCarve, severity, envID); err != nil {
+		log.Err(err).Msg("error creating carve integrity audit log")
+	}
+}
+
+// CarvePurge - create new carve purge audit log entry
+func (m *AuditLogManager) CarvePurge(sessionid, reason string, envID uint) {
+	if !m.Enabled {
+		return
+	}
+	line := fmt.Sprintf("carve %s purged: %s", sessionid, reason)
+	if err := m.CreateNew(m.Service, line, "", LogTypeCarve, SeverityInfo, envID); err != nil {
+		log.Err(err).Msg("error creating carve purge audit log")
+	}
+}
+
+// CarveStale - create new stale carve audit log entry
+func (m *AuditLogManager) CarveStale(sessionid, reason string, envID uint) {
+	if !m.Enabled {
+		return
+	}
+	line := fmt.Sprintf("carve %s marked as stale: %s", sessionid, reason)
+	if err := m.CreateNew(m.Service, line, "", LogTypeCarve, SeverityWarning, envID); err != nil {
+		log.Err(err).Msg("error creating stale carve audit log")
+	}
+}
+
 // Visit - create new visit tag audit log entry
 func (m *AuditLogManager) Visit(username, path, ip string, envID uint) {
 	if !m.Enabled {
@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	redis "github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// InvalidationChannel is the Redis channel used to publish cache invalidations
+	InvalidationChannel = "osctrl:invalidation"
+	// TopicEnvironments for changes in environments, keyed by environment UUID
+	TopicEnvironments = "environments"
+	// TopicNodes for changes in nodes, keyed by node_key
+	TopicNodes = "nodes"
+	// TopicSettings for changes in settings, keyed by service
+	TopicSettings = "settings"
+)
+
+// Invalidation represents one change to be evicted from the caches of all replicas.
+// An empty key means that all the entries for the topic must be evicted.
+type Invalidation struct {
+	Topic  string `json:"topic"`
+	Key    string `json:"key"`
+	Origin string `json:"origin"`
+}
+
+// InvalidationHandler is called for every invalidation received for a topic
+type InvalidationHandler func(ctx context.Context, inv Invalidation)
+
+// InvalidationBus interface defines methods to publish and receive cache invalidations
+type InvalidationBus interface {
+	// Publish sends an invalidation for a topic and key to all the subscribers
+	Publish(ctx context.Context, topic, key string) error
+
+	// Subscribe registers a handler for all the invalidations of a topic
+	Subscribe(topic string, handler InvalidationHandler)
+
+	// Close stops receiving invalidations and releases resources
+	Close() error
+}
+
+// handlerRegistry keeps the invalidation handlers by topic
+type handlerRegistry struct {
+	handlers map[string][]InvalidationHandler
+	mutex    sync.RWMutex
+}
+
+// add registers a handler for a topic
+func (r *handlerRegistry) add(topic string, handler InvalidationHandler) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.handlers == nil {
+		r.handlers = make(map[string][]InvalidationHandler)
+	}
+	r.handlers[topic] = append(r.handlers[topic], handler)
+}
+
+// dispatch calls all the handlers registered for the topic of the invalidation
+func (r *handlerRegistry) dispatch(ctx context.Context, inv Invalidation) {
+	r.mutex.RLock()
+	handlers := r.handlers[inv.Topic]
+	r.mutex.RUnlock()
+
+	for _, h := range handlers {
+		h(ctx, inv)
+	}
+	CacheInvalidations.WithLabelValues(inv.Topic).Inc()
+}
+
+// LocalBus provides an in-process implementation of the InvalidationBus interface,
+// used when there is only one replica and in tests
+type LocalBus struct {
+	registry handlerRegistry
+}
+
+// NewLocalBus creates a new in-process invalidation bus
+func NewLocalBus() *LocalBus {
+	return &LocalBus{}
+}
+
+// Publish calls the handlers of the topic synchronously
+func (b *LocalBus) Publish(ctx context.Context, topic, key string) error {
+	b.registry.dispatch(ctx, Invalidation{Topic: topic, Key: key})
+	return nil
+}
+
+// Subscribe registers a handler for a topic
+func (b *LocalBus) Subscribe(topic string, handler InvalidationHandler) {
+	b.registry.add(topic, handler)
+}
+
+// Close does nothing for the in-process bus
+func (b *LocalBus) Close() error {
+	return nil
+}
+
+// RedisBus provides an implementation of the InvalidationBus interface using Redis pub/sub,
+// so invalidations published by one replica evict the entries in every replica
+type RedisBus struct {
+	registry handlerRegistry
+	client   *redis.Client
+	pubsub   *redis.PubSub
+	origin   string
+}
+
+// NewRedisBus creates a new invalidation bus using the client of the Redis manager,
+// and starts receiving the invalidations published by all replicas
+func NewRedisBus(rm *RedisManager) (*RedisBus, error) {
+	ctx := context.Background()
+	origin := make([]byte, 8)
+	if _, err := rand.Read(origin); err != nil {
+		return nil, err
+	}
+	b := &RedisBus{
+		client: rm.Client,
+		origin: hex.EncodeToString(origin),
+	}
+	b.pubsub = rm.Client.Subscribe(ctx, InvalidationChannel)
+	// Wait for the subscription to be confirmed before publishing anything
+	if _, err := b.pubsub.Receive(ctx); err != nil {
+		_ = b.pubsub.Close()
+		return nil, err
+	}
+	go b.receive()
+	return b, nil
+}
+
+// Publish evicts locally and sends the invalidation to all the other replicas
+func (b *RedisBus) Publish(ctx context.Context, topic, key string) error {
+	inv := Invalidation{Topic: topic, Key: key, Origin: b.origin}
+	b.registry.dispatch(ctx, inv)
+	payload, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, InvalidationChannel, payload).Err()
+}
+
+// Subscribe registers a handler for a topic
+func (b *RedisBus) Subscribe(topic string, handler InvalidationHandler) {
+	b.registry.add(topic, handler)
+}
+
+// Close stops receiving invalidations
+func (b *RedisBus) Close() error {
+	return b.pubsub.Close()
+}
+
+// receive dispatches the invalidations published by other replicas until the bus is closed
+func (b *RedisBus) receive() {
+	ctx := context.Background()
+	for msg := range b.pubsub.Channel() {
+		var inv Invalidation
+		if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+			log.Err(err).Msg("error parsing cache invalidation")
+			continue
+		}
+		// Invalidations from this replica were already dispatched when published
+		if inv.Origin == b.origin {
+			continue
+		}
+		log.Debug().Msgf("Cache invalidation %s [%s]", inv.Topic, inv.Key)
+		b.registry.dispatch(ctx, inv)
+	}
+}
+
+// PublishInvalidation is a helper to publish to a bus that may not be configured, logging errors
+func PublishInvalidation(bus InvalidationBus, topic, key string) {
+	if bus == nil {
+		return
+	}
+	if err := bus.Publish(context.Background(), topic, key); err != nil {
+		log.Err(err).Msgf("error publishing cache invalidation %s [%s]", topic, key)
+	}
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalBus_PublishSubscribe(t *testing.T) {
+	bus := NewLocalBus()
+	defer bus.Close()
+
+	ctx := context.Background()
+	cache := NewMemoryCache[string]()
+	defer cache.Stop()
+
+	cache.Set(ctx, "key1", "value1", 0)
+	cache.Set(ctx, "key2", "value2", 0)
+
+	var received []Invalidation
+	bus.Subscribe(TopicNodes, func(ctx context.Context, inv Invalidation) {
+		received = append(received, inv)
+		if inv.Key == "" {
+			cache.Clear(ctx)
+			return
+		}
+		cache.Delete(ctx, inv.Key)
+	})
+
+	// Publishing to another topic does not evict anything
+	assert.NoError(t, bus.Publish(ctx, TopicEnvironments, "key1"))
+	assert.Equal(t, 2, cache.ItemCount(), "Expected no evictions for other topics")
+
+	// Publishing a key evicts only that key
+	assert.NoError(t, bus.Publish(ctx, TopicNodes, "key1"))
+	_, found := cache.Get(ctx, "key1")
+	assert.False(t, found, "Expected key to be evicted")
+	_, found = cache.Get(ctx, "key2")
+	assert.True(t, found, "Expected other keys to remain")
+
+	// Publishing without key evicts everything
+	PublishInvalidation(bus, TopicNodes, "")
+	assert.Equal(t, 0, cache.ItemCount(), "Expected cache to be empty")
+	assert.Equal(t, []Invalidation{{Topic: TopicNodes, Key: "key1"}, {Topic: TopicNodes}}, received)
+}
+
+func TestLocalBus_MultipleSubscribers(t *testing.T) {
+	bus := NewLocalBus()
+	defer bus.Close()
+
+	calls := 0
+	for i := 0; i < 3; i++ {
+		bus.Subscribe(TopicSettings, func(ctx context.Context, inv Invalidation) {
+			calls++
+		})
+	}
+	assert.NoError(t, bus.Publish(context.Background(), TopicSettings, "tls"))
+	assert.Equal(t, 3, calls, "Expected all subscribers to be called")
+}
+
+func TestPublishInvalidation_NoBus(t *testing.T) {
+	assert.NotPanics(t, func() {
+		PublishInvalidation(nil, TopicEnvironments, "key1")
+	})
+}
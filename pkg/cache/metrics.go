@@ -6,14 +6,16 @@ import (
 
 // Metric names and help text
 const (
-	cacheHitsName      = "osctrl_cache_hits_total"
-	cacheHitsHelp      = "Total number of cache hits"
-	cacheMissesName    = "osctrl_cache_misses_total"
-	cacheMissesHelp    = "Total number of cache misses"
-	cacheEvictionsName = "osctrl_cache_evictions_total"
-	cacheEvictionsHelp = "Total number of cache evictions"
-	cacheItemsName     = "osctrl_cache_items"
-	cacheItemsHelp     = "Current number of items in cache"
+	cacheHitsName          = "osctrl_cache_hits_total"
+	cacheHitsHelp          = "Total number of cache hits"
+	cacheMissesName        = "osctrl_cache_misses_total"
+	cacheMissesHelp        = "Total number of cache misses"
+	cacheEvictionsName     = "osctrl_cache_evictions_total"
+	cacheEvictionsHelp     = "Total number of cache evictions"
+	cacheItemsName         = "osctrl_cache_items"
+	cacheItemsHelp         = "Current number of items in cache"
+	cacheInvalidationsName = "osctrl_cache_invalidations_total"
+	cacheInvalidationsHelp = "Total number of cache invalidations received"
 )
 
 var (
@@ -52,6 +54,15 @@ var (
 		},
 		[]string{"cache_name"},
 	)
+
+	// CacheInvalidations tracks the number of invalidations dispatched by topic
+	CacheInvalidations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: cacheInvalidationsName,
+			Help: cacheInvalidationsHelp,
+		},
+		[]string{"topic"},
+	)
 )
 
 // RegisterMetrics registers all cache metrics with the provided registerer
@@ -60,4 +71,5 @@ func RegisterMetrics(reg prometheus.Registerer) {
 	reg.MustRegister(CacheMisses)
 	reg.MustRegister(CacheEvictions)
 	reg.MustRegister(CacheItems)
+	reg.MustRegister(CacheInvalidations)
 }
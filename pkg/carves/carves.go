@@ -25,6 +25,8 @@ const (
 	StatusInProgress string = "IN PROGRESS"
 	// StatusCompleted for carves that finalized
 	StatusCompleted string = "COMPLETED"
+	// StatusFailed for carves that finalized but failed the integrity verification
+	StatusFailed string = "FAILED"
 	// TarFileExtension to identify Tar files extension
 	TarFileExtension string = ".tar"
 	// ZstFileExtension to identify ZST compressed files
@@ -61,20 +63,13 @@ type CarveResult struct {
 type Carves struct {
 	DB     *gorm.DB
 	S3     *CarverS3
+	Local  *CarverLocal
 	Carver string
 }
 
-// CreateFileCarves to initialize the carves struct and tables
-func CreateFileCarves(backend *gorm.DB, carverType string, s3 *CarverS3) *Carves {
-	var c *Carves = &Carves{DB: backend, Carver: carverType, S3: s3}
-	// table carved_files
-	if err := backend.AutoMigrate(&CarvedFile{}); err != nil {
-		log.Fatal().Msgf("Failed to AutoMigrate table (carved_files): %v", err)
-	}
-	// table carved_blocks
-	if err := backend.AutoMigrate(&CarvedBlock{}); err != nil {
-		log.Fatal().Msgf("Failed to AutoMigrate table (carved_blocks): %v", err)
-	}
+// CreateFileCarves to initialize the carves struct, tables are created by migrations
+func CreateFileCarves(backend *gorm.DB, carverType string, s3 *CarverS3, local *CarverLocal) *Carves {
+	var c *Carves = &Carves{DB: backend, Carver: carverType, S3: s3, Local: local}
 	return c
 }
 
@@ -129,10 +124,13 @@ func (c *Carves) GetCheckCarve(sessionid, requestid string) (CarvedFile, error)
 // InitateBlock to initiate a block based on the configured carver
 func (c *Carves) InitateBlock(env, uuid, requestid, sessionid, data string, blockid int, envid uint) CarvedBlock {
 	var cData string
-	if c.Carver != config.CarverS3 {
-		cData = data
-	} else {
+	switch c.Carver {
+	case config.CarverS3:
 		cData = GenerateS3Data(c.S3.S3Config.Bucket, env, uuid, sessionid, blockid)
+	case config.CarverLocal:
+		cData = c.Local.BlockPath(env, sessionid, blockid)
+	default:
+		cData = data
 	}
 	res := CarvedBlock{
 		RequestID:     requestid,
@@ -149,6 +147,11 @@ func (c *Carves) InitateBlock(env, uuid, requestid, sessionid, data string, bloc
 
 // CreateBlock to create a new block for a carve
 func (c *Carves) CreateBlock(block CarvedBlock, uuid, data string) error {
+	hash, err := HashBlock(data)
+	if err != nil {
+		return err
+	}
+	block.SHA256 = hash
 	switch c.Carver {
 	case config.CarverDB:
 		return c.DB.Create(&block).Error // can be nil or err
@@ -160,6 +163,14 @@ func (c *Carves) CreateBlock(block CarvedBlock, uuid, data string) error {
 			return c.S3.Upload(block, uuid, data)
 		}
 		return fmt.Errorf("S3 carver not initialized")
+	case config.CarverLocal:
+		if c.Local != nil {
+			if err := c.Local.Save(block, data); err != nil {
+				return err
+			}
+			return c.DB.Create(&block).Error
+		}
+		return fmt.Errorf("local carver not initialized")
 	}
 	return fmt.Errorf("Unknown carver") // can be nil or err
 }
@@ -173,6 +184,18 @@ func (c *Carves) Delete(carveid string) error {
 	if err := c.DB.Unscoped().Delete(&carve).Error; err != nil {
 		return fmt.Errorf("Delete %w", err)
 	}
+	if carve.Carver == config.CarverLocal {
+		if carve.ArchivePath != "" {
+			if err := os.Remove(carve.ArchivePath); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("Remove %w", err)
+			}
+		}
+		if c.Local != nil {
+			if err := c.Local.Cleanup(carve.Environment, carve.SessionID); err != nil {
+				return fmt.Errorf("Cleanup %w", err)
+			}
+		}
+	}
 	return nil
 }
 
@@ -253,6 +276,18 @@ func (c *Carves) GetNodeCarves(uuid string) ([]CarvedFile, error) {
 	return carves, nil
 }
 
+// MoveNodeCarves to move the carves of a node to another node
+func (c *Carves) MoveNodeCarves(fromID, toID uint, uuid string) error {
+	toUpdate := map[string]interface{}{
+		"node_id": toID,
+		"uuid":    uuid,
+	}
+	if err := c.DB.Model(&CarvedFile{}).Where("node_id = ?", fromID).Updates(toUpdate).Error; err != nil {
+		return fmt.Errorf("Updates %w", err)
+	}
+	return nil
+}
+
 // ChangeStatus to change the status of a carve
 func (c *Carves) ChangeStatus(status, sessionid string) error {
 	carve, err := c.GetBySession(sessionid)
@@ -328,7 +363,18 @@ func (c *Carves) Archive(sessionid, destPath string) (*CarveResult, error) {
 	}
 	switch c.Carver {
 	case config.CarverLocal:
-		return c.ArchiveLocal(destPath, carve, blocks)
+		if c.Local == nil {
+			return nil, fmt.Errorf("local carver not initialized")
+		}
+		res, err := c.Local.Archive(destPath, carve, blocks)
+		if err != nil {
+			return nil, err
+		}
+		// Blocks are not needed once the carve has been reassembled
+		if err := c.Local.Cleanup(carve.Environment, carve.SessionID); err != nil {
+			log.Err(err).Msgf("error cleaning up blocks for %s", carve.SessionID)
+		}
+		return res, nil
 	case config.CarverDB:
 		return c.ArchiveLocal(destPath, carve, blocks)
 	case config.CarverS3:
@@ -359,6 +405,7 @@ func (c *Carves) ArchiveLocal(destPath string, carve CarvedFile, blocks []Carved
 	// Also check for compressed
 	_f, err = os.Stat(res.File + ZstFileExtension)
 	if err == nil {
+		res.File += ZstFileExtension
 		res.Size = _f.Size()
 		return res, nil
 	}
@@ -27,17 +27,24 @@ type CarvedFile struct {
 	Archived        bool
 	ArchivePath     string
 	EnvironmentID   uint
+	SHA256          string
+	Integrity       string
+	IntegrityReason string
+	StatusReason    string
+	ReissueQuery    string
+	Reissues        int
 }
 
 // CarvedBlock to store each block from a carve
 type CarvedBlock struct {
 	gorm.Model
 	RequestID     string `gorm:"index"`
-	SessionID     string `gorm:"index"`
+	SessionID     string `gorm:"index;uniqueIndex:idx_carved_blocks_session_block"`
 	Environment   string
-	BlockID       int
+	BlockID       int `gorm:"uniqueIndex:idx_carved_blocks_session_block"`
 	Data          string
 	Size          int
 	Carver        string
 	EnvironmentID uint
+	SHA256        string
 }
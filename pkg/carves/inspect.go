@@ -0,0 +1,216 @@
+package carves
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrEntryNotFound is returned when a file does not exist inside the carve archive
+var ErrEntryNotFound = errors.New("entry not found in carve")
+
+// CarveEntry to hold the metadata of one file inside the tar of a carve
+type CarveEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"mtime"`
+	SHA256  string    `json:"sha256"`
+}
+
+// ArchiveEntry to read the content of one file inside the tar of a carve
+type ArchiveEntry struct {
+	Header *tar.Header
+	reader io.Reader
+	close  func() error
+}
+
+// Read to read the content of the file inside the carve
+func (e *ArchiveEntry) Read(p []byte) (int, error) {
+	return e.reader.Read(p)
+}
+
+// Close to release the carve archive
+func (e *ArchiveEntry) Close() error {
+	return e.close()
+}
+
+// multiCloser closes all readers used to stream a carve
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close to close all the underlying readers
+func (m *multiCloser) Close() error {
+	var errs []error
+	for _, c := range m.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Reader to stream the raw content of a completed carve, from the archive if it was
+// archived already or from the blocks otherwise
+func (c *Carves) Reader(carve CarvedFile) (io.ReadCloser, error) {
+	if !carve.Archived && carve.Status != StatusCompleted {
+		return nil, fmt.Errorf("carve %s is not completed", carve.SessionID)
+	}
+	return c.reader(carve)
+}
+
+// Helper to stream the raw content of a carve, without checking if all blocks were received
+func (c *Carves) reader(carve CarvedFile) (io.ReadCloser, error) {
+	if carve.Archived {
+		if carve.Carver == config.CarverS3 {
+			if c.S3 == nil {
+				return nil, fmt.Errorf("S3 carver not initialized")
+			}
+			return c.S3.Reader(carve)
+		}
+		return os.Open(carve.ArchivePath)
+	}
+	blocks, err := c.GetBlocks(carve.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting blocks - %w", err)
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("no blocks for %s", carve.SessionID)
+	}
+	readers := make([]io.Reader, 0, len(blocks))
+	res := &multiCloser{}
+	switch carve.Carver {
+	case config.CarverDB:
+		for _, b := range blocks {
+			readers = append(readers, base64.NewDecoder(base64.StdEncoding, strings.NewReader(b.Data)))
+		}
+	case config.CarverLocal:
+		for _, b := range blocks {
+			f, err := os.Open(b.Data)
+			if err != nil {
+				_ = res.Close()
+				return nil, fmt.Errorf("block %d - %w", b.BlockID, err)
+			}
+			readers = append(readers, f)
+			res.closers = append(res.closers, f)
+		}
+	default:
+		return nil, fmt.Errorf("carve %s is not archived", carve.SessionID)
+	}
+	res.Reader = io.MultiReader(readers...)
+	return res, nil
+}
+
+// ListEntries to get all the files inside the tar of a carve
+func (c *Carves) ListEntries(carve CarvedFile) ([]CarveEntry, error) {
+	r, err := c.Reader(carve)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ListArchive(r)
+}
+
+// OpenEntry to get a reader for one file inside the tar of a carve, the caller must close it
+func (c *Carves) OpenEntry(carve CarvedFile, name string) (*ArchiveEntry, error) {
+	r, err := c.Reader(carve)
+	if err != nil {
+		return nil, err
+	}
+	tr, closeTar, err := TarReader(r)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	res := &ArchiveEntry{
+		reader: tr,
+		close: func() error {
+			closeTar()
+			return r.Close()
+		},
+	}
+	name = cleanEntryPath(name)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			res.Close()
+			return nil, fmt.Errorf("error reading tar - %w", err)
+		}
+		if hdr.Typeflag == tar.TypeReg && cleanEntryPath(hdr.Name) == name {
+			res.Header = hdr
+			return res, nil
+		}
+	}
+	res.Close()
+	return nil, fmt.Errorf("%w: %s", ErrEntryNotFound, name)
+}
+
+// TarReader - Function to get a tar reader for a carve, decompressing if the data is compressed with zstd
+func TarReader(r io.Reader) (*tar.Reader, func(), error) {
+	buf := bufio.NewReader(r)
+	header, err := buf.Peek(len(CompressionHeader))
+	if err != nil && err != io.EOF {
+		return nil, nil, fmt.Errorf("error reading header - %w", err)
+	}
+	if !CheckCompressionRaw(header) {
+		return tar.NewReader(buf), func() {}, nil
+	}
+	dec, err := zstd.NewReader(buf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decompressing - %w", err)
+	}
+	return tar.NewReader(dec), dec.Close, nil
+}
+
+// ListArchive - Function to list all the entries of a carve tar, with the SHA-256 of each file
+func ListArchive(r io.Reader) ([]CarveEntry, error) {
+	var entries []CarveEntry
+	tr, closeTar, err := TarReader(r)
+	if err != nil {
+		return entries, err
+	}
+	defer closeTar()
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return entries, fmt.Errorf("error reading tar - %w", err)
+		}
+		entry := CarveEntry{
+			Path:    cleanEntryPath(hdr.Name),
+			Size:    hdr.Size,
+			Mode:    hdr.FileInfo().Mode().String(),
+			ModTime: hdr.ModTime,
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			h := sha256.New()
+			if _, err := io.Copy(h, tr); err != nil {
+				return entries, fmt.Errorf("error hashing %s - %w", hdr.Name, err)
+			}
+			entry.SHA256 = fmt.Sprintf("%x", h.Sum(nil))
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Helper to normalize the paths of entries, so they can be matched regardless of how osquery stored them
+func cleanEntryPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
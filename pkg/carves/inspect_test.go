@@ -0,0 +1,99 @@
+package carves
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Helper to generate a tar with the provided files
+func testTar(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: time.Unix(0, 0)}))
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			ModTime:  time.Unix(1700000000, 0),
+		}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func TestListArchive(t *testing.T) {
+	raw := testTar(t, map[string]string{"etc/hosts": "127.0.0.1 localhost\n"})
+	var compressed bytes.Buffer
+	enc, err := zstd.NewWriter(&compressed)
+	require.NoError(t, err)
+	_, err = enc.Write(raw)
+	require.NoError(t, err)
+	require.NoError(t, enc.Close())
+
+	for name, data := range map[string][]byte{"tar": raw, "zstd": compressed.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			entries, err := ListArchive(bytes.NewReader(data))
+			require.NoError(t, err)
+			require.Len(t, entries, 2)
+			assert.Equal(t, "etc", entries[0].Path)
+			assert.Empty(t, entries[0].SHA256)
+			assert.Equal(t, "etc/hosts", entries[1].Path)
+			assert.Equal(t, int64(20), entries[1].Size)
+			assert.Equal(t, "-rw-r--r--", entries[1].Mode)
+			assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte("127.0.0.1 localhost\n"))), entries[1].SHA256)
+		})
+	}
+}
+
+func TestOpenEntryBlocks(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&CarvedFile{}, &CarvedBlock{}))
+	c := CreateFileCarves(db, config.CarverDB, nil, nil)
+	raw := testTar(t, map[string]string{"etc/hosts": "hosts", "etc/passwd": "root:x:0:0"})
+	carve := CarvedFile{
+		CarveID:     "carve-id",
+		SessionID:   "session-id",
+		Environment: "dev",
+		Carver:      config.CarverDB,
+		Status:      StatusCompleted,
+	}
+	require.NoError(t, c.CreateCarve(carve))
+	// Split the tar in two blocks
+	half := len(raw) / 2
+	for i, chunk := range [][]byte{raw[:half], raw[half:]} {
+		data := base64.StdEncoding.EncodeToString(chunk)
+		require.NoError(t, c.CreateBlock(c.InitateBlock("dev", "NODE-UUID", "request-id", "session-id", data, i, 1), "NODE-UUID", data))
+	}
+
+	entries, err := c.ListEntries(carve)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	entry, err := c.OpenEntry(carve, "/etc/passwd")
+	require.NoError(t, err)
+	content, err := io.ReadAll(entry)
+	require.NoError(t, err)
+	require.NoError(t, entry.Close())
+	assert.Equal(t, "root:x:0:0", string(content))
+
+	_, err = c.OpenEntry(carve, "etc/shadow")
+	assert.ErrorIs(t, err, ErrEntryNotFound)
+}
@@ -0,0 +1,145 @@
+package carves
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	// IntegrityVerified for carves with all blocks received and hashed
+	IntegrityVerified string = "VERIFIED"
+	// IntegrityFailed for carves with missing, duplicated or out of range blocks
+	IntegrityFailed string = "FAILED"
+)
+
+// ErrIntegrity is returned when the blocks of a carve do not match the expected blocks
+var ErrIntegrity = errors.New("carve integrity check failed")
+
+// BlockIntegrity to hold the hash of one block of a carve
+type BlockIntegrity struct {
+	BlockID int    `json:"block_id"`
+	Size    int    `json:"size"`
+	SHA256  string `json:"sha256"`
+}
+
+// CarveIntegrity to hold the hashes and verification status of a carve
+type CarveIntegrity struct {
+	SessionID string           `json:"session_id"`
+	Status    string           `json:"status"`
+	Reason    string           `json:"reason"`
+	SHA256    string           `json:"sha256"`
+	Blocks    []BlockIntegrity `json:"blocks"`
+}
+
+// HashBlock - Function to calculate the SHA-256 of the decoded data of a block
+func HashBlock(data string) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", fmt.Errorf("error decoding data - %w", err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(decoded)), nil
+}
+
+// BlockExists to check if a block for a carve has been received already
+func (c *Carves) BlockExists(sessionid string, blockid int) bool {
+	var results int64
+	c.DB.Model(&CarvedBlock{}).Where("session_id = ? AND block_id = ?", sessionid, blockid).Count(&results)
+	return (results > 0)
+}
+
+// VerifyBlocks to check that all the blocks of a carve have been received once
+func (c *Carves) VerifyBlocks(carve CarvedFile) error {
+	blocks, err := c.GetBlocks(carve.SessionID)
+	if err != nil {
+		return fmt.Errorf("error getting blocks - %w", err)
+	}
+	received := make(map[int]int)
+	var duplicated, outOfRange, missing []int
+	for _, b := range blocks {
+		if b.BlockID < 0 || b.BlockID >= carve.TotalBlocks {
+			outOfRange = append(outOfRange, b.BlockID)
+			continue
+		}
+		received[b.BlockID]++
+		if received[b.BlockID] == 2 {
+			duplicated = append(duplicated, b.BlockID)
+		}
+	}
+	for i := 0; i < carve.TotalBlocks; i++ {
+		if received[i] == 0 {
+			missing = append(missing, i)
+		}
+	}
+	var reasons []string
+	if len(missing) > 0 {
+		reasons = append(reasons, fmt.Sprintf("missing blocks %v", missing))
+	}
+	if len(duplicated) > 0 {
+		sort.Ints(duplicated)
+		reasons = append(reasons, fmt.Sprintf("duplicated blocks %v", duplicated))
+	}
+	if len(outOfRange) > 0 {
+		sort.Ints(outOfRange)
+		reasons = append(reasons, fmt.Sprintf("out of range blocks %v", outOfRange))
+	}
+	if len(reasons) > 0 {
+		return fmt.Errorf("%w: %s", ErrIntegrity, strings.Join(reasons, ", "))
+	}
+	return nil
+}
+
+// HashArchive to calculate the SHA-256 of the reassembled carve
+func (c *Carves) HashArchive(carve CarvedFile) (string, error) {
+	r, err := c.reader(carve)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", fmt.Errorf("error hashing carve - %w", err)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// SetIntegrity to record the result of the verification of a carve
+func (c *Carves) SetIntegrity(sessionid, integrity, reason, hash string) error {
+	carve, err := c.GetBySession(sessionid)
+	if err != nil {
+		return fmt.Errorf("getCarveBySessionID %w", err)
+	}
+	toUpdate := map[string]interface{}{
+		"integrity":        integrity,
+		"integrity_reason": reason,
+		"sha256":           hash,
+	}
+	if err := c.DB.Model(&carve).Updates(toUpdate).Error; err != nil {
+		return fmt.Errorf("Updates %w", err)
+	}
+	return nil
+}
+
+// GetIntegrity to get the hashes and verification status of a carve
+func (c *Carves) GetIntegrity(carve CarvedFile) (CarveIntegrity, error) {
+	res := CarveIntegrity{
+		SessionID: carve.SessionID,
+		Status:    carve.Integrity,
+		Reason:    carve.IntegrityReason,
+		SHA256:    carve.SHA256,
+		Blocks:    []BlockIntegrity{},
+	}
+	// Only select the metadata, data of blocks can be large
+	var blocks []CarvedBlock
+	if err := c.DB.Select("block_id", "size", "sha256").Where("session_id = ?", carve.SessionID).Order("block_id").Find(&blocks).Error; err != nil {
+		return res, err
+	}
+	for _, b := range blocks {
+		res.Blocks = append(res.Blocks, BlockIntegrity{BlockID: b.BlockID, Size: b.Size, SHA256: b.SHA256})
+	}
+	return res, nil
+}
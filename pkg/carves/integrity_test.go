@@ -0,0 +1,59 @@
+package carves
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestVerifyBlocks(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&CarvedFile{}, &CarvedBlock{}))
+	c := CreateFileCarves(db, config.CarverDB, nil, nil)
+	carve := CarvedFile{CarveID: "carve-id", SessionID: "session-id", Carver: config.CarverDB, TotalBlocks: 3}
+	require.NoError(t, c.CreateCarve(carve))
+
+	chunks := []string{"first|", "second|", "third"}
+	for i, chunk := range chunks {
+		data := base64.StdEncoding.EncodeToString([]byte(chunk))
+		block := c.InitateBlock("dev", "NODE-UUID", "request-id", "session-id", data, i, 1)
+		require.NoError(t, c.CreateBlock(block, "NODE-UUID", data))
+	}
+	assert.True(t, c.BlockExists("session-id", 2))
+	assert.False(t, c.BlockExists("session-id", 3))
+	require.NoError(t, c.VerifyBlocks(carve))
+
+	// Each block is hashed when received
+	integrity, err := c.GetIntegrity(carve)
+	require.NoError(t, err)
+	require.Len(t, integrity.Blocks, 3)
+	assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte("second|"))), integrity.Blocks[1].SHA256)
+
+	// Hash of the reassembled carve
+	hash, err := c.HashArchive(carve)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte("first|second|third"))), hash)
+	require.NoError(t, c.SetIntegrity("session-id", IntegrityVerified, "", hash))
+	stored, err := c.GetBySession("session-id")
+	require.NoError(t, err)
+	assert.Equal(t, IntegrityVerified, stored.Integrity)
+	assert.Equal(t, hash, stored.SHA256)
+
+	// Duplicated blocks are rejected, out of range and missing blocks fail the verification
+	data := base64.StdEncoding.EncodeToString([]byte("extra"))
+	assert.Error(t, c.CreateBlock(c.InitateBlock("dev", "NODE-UUID", "request-id", "session-id", data, 0, 1), "NODE-UUID", data))
+	require.NoError(t, db.Where("block_id = ?", 1).Delete(&CarvedBlock{}).Error)
+	require.NoError(t, c.CreateBlock(c.InitateBlock("dev", "NODE-UUID", "request-id", "session-id", data, 5, 1), "NODE-UUID", data))
+	err = c.VerifyBlocks(carve)
+	assert.ErrorIs(t, err, ErrIntegrity)
+	assert.Contains(t, err.Error(), "missing blocks [1]")
+	assert.Contains(t, err.Error(), "out of range blocks [5]")
+}
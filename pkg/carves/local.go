@@ -0,0 +1,199 @@
+package carves
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	osctrl_config "github.com/jmpsec/osctrl/pkg/config"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	// LocalBlocksDir is the folder inside the carver directory to store blocks
+	LocalBlocksDir = "blocks"
+	// LocalArchivesDir is the folder inside the carver directory to store reassembled carves
+	LocalArchivesDir = "archives"
+	// LocalBlockExtension to identify the files of each carve block
+	LocalBlockExtension = ".block"
+)
+
+// CarverLocal will be used to carve files using a local directory as destination
+type CarverLocal struct {
+	Config osctrl_config.LocalCarverConfiguration
+}
+
+// CreateCarverLocalFile to initialize the carver from a JSON file
+func CreateCarverLocalFile(localFile string) (*CarverLocal, error) {
+	cfg, err := LoadLocal(localFile)
+	if err != nil {
+		return nil, err
+	}
+	return CreateCarverLocal(cfg)
+}
+
+// CreateCarverLocal to initialize the carver, creating the directories for blocks and archives
+func CreateCarverLocal(localConfig osctrl_config.LocalCarverConfiguration) (*CarverLocal, error) {
+	if localConfig.Dir == "" {
+		return nil, fmt.Errorf("empty directory for local carver")
+	}
+	l := &CarverLocal{Config: localConfig}
+	for _, d := range []string{l.BlocksDir(), l.ArchivesDir()} {
+		if err := os.MkdirAll(d, 0750); err != nil {
+			return nil, fmt.Errorf("error creating %s - %w", d, err)
+		}
+	}
+	return l, nil
+}
+
+// LoadLocal - Function to load the local carver configuration from JSON file
+func LoadLocal(file string) (osctrl_config.LocalCarverConfiguration, error) {
+	var _localCfg osctrl_config.LocalCarverConfiguration
+	log.Info().Msgf("Loading %s", file)
+	// Load file and read config
+	viper.SetConfigFile(file)
+	if err := viper.ReadInConfig(); err != nil {
+		return _localCfg, err
+	}
+	cfgRaw := viper.Sub(osctrl_config.CarverLocal)
+	if cfgRaw == nil {
+		return _localCfg, fmt.Errorf("JSON key %s not found in %s", osctrl_config.CarverLocal, file)
+	}
+	if err := cfgRaw.Unmarshal(&_localCfg); err != nil {
+		return _localCfg, err
+	}
+	// No errors!
+	return _localCfg, nil
+}
+
+// BlocksDir - Function to get the directory where blocks are stored
+func (carveLocal *CarverLocal) BlocksDir() string {
+	return filepath.Join(carveLocal.Config.Dir, LocalBlocksDir)
+}
+
+// ArchivesDir - Function to get the directory where reassembled carves are stored
+func (carveLocal *CarverLocal) ArchivesDir() string {
+	return filepath.Join(carveLocal.Config.Dir, LocalArchivesDir)
+}
+
+// SessionDir - Function to get the directory for all the blocks of one carve session
+func (carveLocal *CarverLocal) SessionDir(env, sessionid string) string {
+	return filepath.Join(carveLocal.BlocksDir(), SafeFileName(env), SafeFileName(sessionid))
+}
+
+// BlockPath - Function to get the file for one block of a carve session
+func (carveLocal *CarverLocal) BlockPath(env, sessionid string, blockid int) string {
+	return filepath.Join(carveLocal.SessionDir(env, sessionid), fmt.Sprintf("%d%s", blockid, LocalBlockExtension))
+}
+
+// Save - Function to decode and write the data of one block to its file
+func (carveLocal *CarverLocal) Save(block CarvedBlock, data string) error {
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return fmt.Errorf("error decoding data - %w", err)
+	}
+	if err := os.MkdirAll(carveLocal.SessionDir(block.Environment, block.SessionID), 0750); err != nil {
+		return fmt.Errorf("error creating session directory - %w", err)
+	}
+	// Write to a temporary file first, so a partial block is never archived
+	tmp, err := os.CreateTemp(carveLocal.SessionDir(block.Environment, block.SessionID), "tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating block file - %w", err)
+	}
+	if _, err := tmp.Write(decoded); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing block file - %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error closing block file - %w", err)
+	}
+	return os.Rename(tmp.Name(), block.Data)
+}
+
+// Archive - Function to reassemble the blocks of a carve into one file, streaming one block at a time
+func (carveLocal *CarverLocal) Archive(destPath string, carve CarvedFile, blocks []CarvedBlock) (*CarveResult, error) {
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("can not archive 0 blocks for %s", carve.SessionID)
+	}
+	if destPath == "" {
+		destPath = carveLocal.ArchivesDir()
+	}
+	res := &CarveResult{
+		File: filepath.Join(destPath, GenerateArchiveName(carve)),
+	}
+	// If file already exists, no need to re-generate it from blocks
+	for _, existing := range []string{res.File, res.File + ZstFileExtension} {
+		if _f, err := os.Stat(existing); err == nil {
+			res.File = existing
+			res.Size = _f.Size()
+			return res, nil
+		}
+	}
+	// Check if data is compressed with the header of the first block
+	zstd, err := carveLocal.checkCompression(blocks[0])
+	if err != nil {
+		return nil, fmt.Errorf("compression check - %w", err)
+	}
+	if zstd {
+		res.File += ZstFileExtension
+	}
+	// Reassemble in a temporary file, renamed when all blocks are written
+	tmp, err := os.CreateTemp(destPath, "tmp-*")
+	if err != nil {
+		return nil, fmt.Errorf("file creation - %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	for _, b := range blocks {
+		written, err := appendFile(tmp, b.Data)
+		if err != nil {
+			tmp.Close()
+			return nil, fmt.Errorf("block %d - %w", b.BlockID, err)
+		}
+		res.Size += written
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("closing file - %w", err)
+	}
+	if err := os.Rename(tmp.Name(), res.File); err != nil {
+		return nil, fmt.Errorf("renaming file - %w", err)
+	}
+	return res, nil
+}
+
+// Cleanup - Function to remove all the blocks of a carve session
+func (carveLocal *CarverLocal) Cleanup(env, sessionid string) error {
+	return os.RemoveAll(carveLocal.SessionDir(env, sessionid))
+}
+
+// Helper to check the compression header in the file of the first block
+func (carveLocal *CarverLocal) checkCompression(block CarvedBlock) (bool, error) {
+	if block.BlockID != 0 {
+		return false, fmt.Errorf("block_id is not 0 (%d)", block.BlockID)
+	}
+	f, err := os.Open(block.Data)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	header := make([]byte, len(CompressionHeader))
+	if _, err := io.ReadFull(f, header); err != nil {
+		// Blocks smaller than the header can not be compressed
+		return false, nil
+	}
+	return CheckCompressionRaw(header), nil
+}
+
+// Helper to append the content of a file to a writer
+func appendFile(w io.Writer, file string) (int64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return io.Copy(w, f)
+}
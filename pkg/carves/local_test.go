@@ -0,0 +1,69 @@
+package carves
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestLocalCarverArchive(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&CarvedFile{}, &CarvedBlock{}))
+	local, err := CreateCarverLocal(config.LocalCarverConfiguration{Dir: t.TempDir()})
+	require.NoError(t, err)
+	c := CreateFileCarves(db, config.CarverLocal, nil, local)
+
+	carve := CarvedFile{
+		CarveID:     "carve-id",
+		SessionID:   "session-id",
+		UUID:        "NODE-UUID",
+		Environment: "dev",
+		Path:        "/etc/hosts",
+		Carver:      config.CarverLocal,
+		TotalBlocks: 2,
+	}
+	require.NoError(t, c.CreateCarve(carve))
+
+	chunks := []string{"first block|", "second block"}
+	for i, chunk := range chunks {
+		data := base64.StdEncoding.EncodeToString([]byte(chunk))
+		block := c.InitateBlock("dev", "NODE-UUID", "request-id", "session-id", data, i, 1)
+		assert.Equal(t, local.BlockPath("dev", "session-id", i), block.Data)
+		require.NoError(t, c.CreateBlock(block, "NODE-UUID", data))
+	}
+
+	res, err := c.Archive("session-id", "")
+	require.NoError(t, err)
+	assert.Equal(t, local.ArchivesDir(), filepath.Dir(res.File))
+	assert.Equal(t, int64(len(chunks[0])+len(chunks[1])), res.Size)
+	content, err := os.ReadFile(res.File)
+	require.NoError(t, err)
+	assert.Equal(t, "first block|second block", string(content))
+
+	// Blocks are removed once archived
+	_, err = os.Stat(local.SessionDir("dev", "session-id"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Archive is removed with the carve
+	require.NoError(t, c.ArchiveCarve("session-id", res.File))
+	require.NoError(t, c.Delete("carve-id"))
+	_, err = os.Stat(res.File)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLocalCarverSessionDir(t *testing.T) {
+	local, err := CreateCarverLocal(config.LocalCarverConfiguration{Dir: t.TempDir()})
+	require.NoError(t, err)
+	dir := local.SessionDir("../dev", "../../session")
+	assert.Equal(t, local.BlocksDir(), filepath.Dir(filepath.Dir(dir)))
+	_, err = CreateCarverLocal(config.LocalCarverConfiguration{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,132 @@
+package carves
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jmpsec/osctrl/pkg/config"
+)
+
+const (
+	// StatusRejected for carves rejected because of the limits of the environment
+	StatusRejected string = "REJECTED"
+	// StatusPurged for carves whose data has been removed by the retention policy
+	StatusPurged string = "PURGED"
+)
+
+// Reject to mark the carve of a node as rejected, keeping the requested size for reference
+func (c *Carves) Reject(requestid, uuid string, size int) error {
+	toUpdate := map[string]interface{}{
+		"carve_size": size,
+		"status":     StatusRejected,
+	}
+	if err := c.DB.Model(&CarvedFile{}).Where("request_id = ? AND uuid = ?", requestid, uuid).Updates(toUpdate).Error; err != nil {
+		return fmt.Errorf("Updates %w", err)
+	}
+	return nil
+}
+
+// UsedBytes to get the total size of the carves stored for an environment
+func (c *Carves) UsedBytes(envid uint) (int64, error) {
+	var total int64
+	if err := c.DB.Model(&CarvedFile{}).Select("COALESCE(SUM(carve_size), 0)").Where(
+		"environment_id = ? AND status NOT IN ?", envid, []string{StatusRejected, StatusPurged, StatusStale}).Scan(&total).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// GetExpired to get the carves of an environment older than the retention in days
+func (c *Carves) GetExpired(envid uint, retention int) ([]CarvedFile, error) {
+	var carves []CarvedFile
+	if retention <= 0 {
+		return carves, nil
+	}
+	older := time.Now().AddDate(0, 0, -retention)
+	if err := c.DB.Where("environment_id = ? AND created_at < ? AND status NOT IN ?", envid, older, []string{StatusPurged}).Find(&carves).Error; err != nil {
+		return carves, err
+	}
+	return carves, nil
+}
+
+// Purge to remove the archive and the blocks of a carve, the carve is kept as purged
+func (c *Carves) Purge(carve CarvedFile) error {
+	if err := c.removeData(carve); err != nil {
+		return err
+	}
+	toUpdate := map[string]interface{}{
+		"status":       StatusPurged,
+		"archived":     false,
+		"archive_path": "",
+	}
+	if err := c.DB.Model(&carve).Updates(toUpdate).Error; err != nil {
+		return fmt.Errorf("Updates %w", err)
+	}
+	return nil
+}
+
+// Helper to remove the archive and the blocks of a carve, from the carver and from the database
+func (c *Carves) removeData(carve CarvedFile) error {
+	var errs []error
+	// Remove archive
+	if carve.Archived && carve.ArchivePath != "" {
+		switch carve.Carver {
+		case config.CarverS3:
+			if c.S3 == nil {
+				return fmt.Errorf("S3 carver not initialized")
+			}
+			errs = append(errs, c.S3.Delete(carve.ArchivePath))
+		default:
+			if err := os.Remove(carve.ArchivePath); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	// Remove data of blocks outside of the database
+	switch carve.Carver {
+	case config.CarverLocal:
+		if c.Local != nil {
+			errs = append(errs, c.Local.Cleanup(carve.Environment, carve.SessionID))
+		}
+	case config.CarverS3:
+		if c.S3 == nil {
+			return fmt.Errorf("S3 carver not initialized")
+		}
+		blocks, err := c.GetBlocks(carve.SessionID)
+		if err != nil {
+			return fmt.Errorf("getBlocksBySessionID %w", err)
+		}
+		for _, b := range blocks {
+			errs = append(errs, c.S3.Delete(b.Data))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("error removing data for %s - %w", carve.SessionID, err)
+	}
+	if carve.SessionID != "" {
+		if err := c.DeleteBlocks(carve.SessionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PurgeExpired to purge all the carves of an environment older than the retention in days
+func (c *Carves) PurgeExpired(envid uint, retention int) ([]CarvedFile, error) {
+	var purged []CarvedFile
+	expired, err := c.GetExpired(envid, retention)
+	if err != nil {
+		return purged, fmt.Errorf("error getting expired carves - %w", err)
+	}
+	var errs []error
+	for _, carve := range expired {
+		if err := c.Purge(carve); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		purged = append(purged, carve)
+	}
+	return purged, errors.Join(errs...)
+}
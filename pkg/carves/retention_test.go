@@ -0,0 +1,94 @@
+package carves
+
+import (
+	"encoding/base64"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestUsedBytes(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&CarvedFile{}, &CarvedBlock{}))
+	c := CreateFileCarves(db, config.CarverDB, nil, nil)
+	used, err := c.UsedBytes(1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), used)
+
+	require.NoError(t, c.CreateCarve(CarvedFile{CarveID: "c1", RequestID: "r1", UUID: "NODE1", EnvironmentID: 1, CarveSize: 100, Status: StatusCompleted}))
+	require.NoError(t, c.CreateCarve(CarvedFile{CarveID: "c2", RequestID: "r2", UUID: "NODE1", EnvironmentID: 1, Status: StatusScheduled}))
+	require.NoError(t, c.CreateCarve(CarvedFile{CarveID: "c3", RequestID: "r3", UUID: "NODE1", EnvironmentID: 2, CarveSize: 50, Status: StatusCompleted}))
+	require.NoError(t, c.CreateCarve(CarvedFile{CarveID: "c4", RequestID: "r4", UUID: "NODE1", EnvironmentID: 1, CarveSize: 10, Status: StatusPurged}))
+
+	// Rejected carves do not count towards the quota
+	require.NoError(t, c.Reject("r2", "NODE1", 1000))
+	rejected, err := c.GetByCarve("c2")
+	require.NoError(t, err)
+	assert.Equal(t, StatusRejected, rejected.Status)
+	assert.Equal(t, 1000, rejected.CarveSize)
+
+	used, err = c.UsedBytes(1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), used)
+}
+
+func TestPurgeExpired(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&CarvedFile{}, &CarvedBlock{}))
+	local, err := CreateCarverLocal(config.LocalCarverConfiguration{Dir: t.TempDir()})
+	require.NoError(t, err)
+	c := CreateFileCarves(db, config.CarverLocal, nil, local)
+
+	for _, id := range []string{"old", "new"} {
+		require.NoError(t, c.CreateCarve(CarvedFile{
+			CarveID:       id,
+			SessionID:     id,
+			Environment:   "dev",
+			Carver:        config.CarverLocal,
+			EnvironmentID: 1,
+			TotalBlocks:   1,
+			Status:        StatusCompleted,
+		}))
+		data := base64.StdEncoding.EncodeToString([]byte("block"))
+		require.NoError(t, c.CreateBlock(c.InitateBlock("dev", "NODE-UUID", "request-id", id, data, 0, 1), "NODE-UUID", data))
+	}
+	res, err := c.Archive("old", "")
+	require.NoError(t, err)
+	require.NoError(t, c.ArchiveCarve("old", res.File))
+	require.NoError(t, db.Model(&CarvedFile{}).Where("carve_id = ?", "old").Update("created_at", time.Now().AddDate(0, 0, -10)).Error)
+
+	purged, err := c.PurgeExpired(1, 7)
+	require.NoError(t, err)
+	require.Len(t, purged, 1)
+	assert.Equal(t, "old", purged[0].SessionID)
+
+	old, err := c.GetBySession("old")
+	require.NoError(t, err)
+	assert.Equal(t, StatusPurged, old.Status)
+	assert.False(t, old.Archived)
+	_, err = os.Stat(res.File)
+	assert.True(t, os.IsNotExist(err))
+	blocks, err := c.GetBlocks("old")
+	require.NoError(t, err)
+	assert.Empty(t, blocks)
+
+	// Carves within the retention are kept
+	blocks, err = c.GetBlocks("new")
+	require.NoError(t, err)
+	assert.Len(t, blocks, 1)
+	_, err = os.Stat(local.BlockPath("dev", "new", 0))
+	assert.NoError(t, err)
+
+	// Purged carves are not purged again
+	purged, err = c.PurgeExpired(1, 7)
+	require.NoError(t, err)
+	assert.Empty(t, purged)
+}
@@ -223,6 +223,38 @@ func (carveS3 *CarverS3) Download(carve CarvedFile) (io.WriterAt, error) {
 	return fileReader, nil
 }
 
+// Reader - Function to stream an archived carve from s3, the caller must close it
+func (carveS3 *CarverS3) Reader(carve CarvedFile) (io.ReadCloser, error) {
+	ctx := context.Background()
+	if carveS3.Debug {
+		log.Debug().Msgf("Reading %s from S3", carve.ArchivePath)
+	}
+	obj, err := carveS3.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(carveS3.S3Config.Bucket),
+		Key:    aws.String(S3URLtoKey(carve.ArchivePath, carveS3.S3Config.Bucket)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetObject - %w", err)
+	}
+	return obj.Body, nil
+}
+
+// Delete - Function to delete an object from s3, by its s3:// URL
+func (carveS3 *CarverS3) Delete(s3url string) error {
+	ctx := context.Background()
+	if carveS3.Debug {
+		log.Debug().Msgf("Deleting %s from S3", s3url)
+	}
+	_, err := carveS3.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(carveS3.S3Config.Bucket),
+		Key:    aws.String(S3URLtoKey(s3url, carveS3.S3Config.Bucket)),
+	})
+	if err != nil {
+		return fmt.Errorf("DeleteObject - %w", err)
+	}
+	return nil
+}
+
 // GetDownloadLink - Function to generate a pre-signed link to download directly from s3
 func (carveS3 *CarverS3) GetDownloadLink(carve CarvedFile) (string, error) {
 	ctx := context.Background()
@@ -0,0 +1,62 @@
+package carves
+
+import (
+	"fmt"
+	"time"
+)
+
+// StatusStale for carves that did not receive new blocks in time, partial data is removed
+const StatusStale string = "STALE"
+
+// GetStale to get the carves in progress of an environment without new blocks for the provided duration.
+// Scheduled carves are not included, since nodes may be offline before the carve starts
+func (c *Carves) GetStale(envid uint, idle time.Duration) ([]CarvedFile, error) {
+	var carves []CarvedFile
+	older := time.Now().Add(-idle)
+	recentBlocks := c.DB.Model(&CarvedBlock{}).
+		Select("1").
+		Where("carved_blocks.session_id = carved_files.session_id AND carved_blocks.created_at >= ?", older)
+	if err := c.DB.Where("environment_id = ? AND status = ? AND updated_at < ?", envid, StatusInProgress, older).
+		Where("NOT EXISTS (?)", recentBlocks).
+		Find(&carves).Error; err != nil {
+		return carves, err
+	}
+	return carves, nil
+}
+
+// MarkStale to mark a carve as stale with the reason, removing the blocks received so far
+func (c *Carves) MarkStale(carve CarvedFile, reason string) error {
+	if err := c.removeData(carve); err != nil {
+		return err
+	}
+	toUpdate := map[string]interface{}{
+		"status":        StatusStale,
+		"status_reason": reason,
+	}
+	if err := c.DB.Model(&carve).Updates(toUpdate).Error; err != nil {
+		return fmt.Errorf("Updates %w", err)
+	}
+	return nil
+}
+
+// PreviousReissues to get how many times the carve has been re-issued already, following
+// the stale carves of the same node that were re-issued with the query of this carve
+func (c *Carves) PreviousReissues(carve CarvedFile) int {
+	var previous CarvedFile
+	if err := c.DB.Where("reissue_query = ? AND uuid = ?", carve.QueryName, carve.UUID).Find(&previous).Error; err != nil {
+		return 0
+	}
+	return previous.Reissues
+}
+
+// SetReissued to record the query used to re-issue a stale carve
+func (c *Carves) SetReissued(carve CarvedFile, queryName string, reissues int) error {
+	toUpdate := map[string]interface{}{
+		"reissue_query": queryName,
+		"reissues":      reissues,
+	}
+	if err := c.DB.Model(&carve).Updates(toUpdate).Error; err != nil {
+		return fmt.Errorf("Updates %w", err)
+	}
+	return nil
+}
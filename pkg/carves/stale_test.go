@@ -0,0 +1,73 @@
+package carves
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestStaleCarves(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&CarvedFile{}, &CarvedBlock{}))
+	c := CreateFileCarves(db, config.CarverDB, nil, nil)
+
+	for _, id := range []string{"idle", "active"} {
+		require.NoError(t, c.CreateCarve(CarvedFile{
+			CarveID:       id,
+			SessionID:     id,
+			QueryName:     "carve_" + id,
+			UUID:          "NODE-UUID",
+			Carver:        config.CarverDB,
+			EnvironmentID: 1,
+			TotalBlocks:   2,
+			Status:        StatusInProgress,
+		}))
+		data := base64.StdEncoding.EncodeToString([]byte("block"))
+		require.NoError(t, c.CreateBlock(c.InitateBlock("dev", "NODE-UUID", "request-id", id, data, 0, 1), "NODE-UUID", data))
+	}
+	require.NoError(t, c.CreateCarve(CarvedFile{
+		CarveID:       "scheduled",
+		QueryName:     "carve_scheduled",
+		UUID:          "NODE-UUID",
+		Carver:        config.CarverDB,
+		EnvironmentID: 1,
+		Status:        StatusScheduled,
+	}))
+	// Only the idle carve has no recent blocks, the active one got a block after its last update
+	past := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, db.Model(&CarvedFile{}).Where("carve_id IN ?", []string{"idle", "active", "scheduled"}).UpdateColumn("updated_at", past).Error)
+	require.NoError(t, db.Model(&CarvedBlock{}).Where("session_id = ?", "idle").UpdateColumn("created_at", past).Error)
+
+	stale, err := c.GetStale(1, time.Hour)
+	require.NoError(t, err)
+	require.Len(t, stale, 1)
+	assert.Equal(t, "idle", stale[0].CarveID)
+
+	require.NoError(t, c.MarkStale(stale[0], "no blocks"))
+	idle, err := c.GetByCarve("idle")
+	require.NoError(t, err)
+	assert.Equal(t, StatusStale, idle.Status)
+	assert.Equal(t, "no blocks", idle.StatusReason)
+	blocks, err := c.GetBlocks("idle")
+	require.NoError(t, err)
+	assert.Empty(t, blocks)
+
+	// Stale carves are not returned again
+	stale, err = c.GetStale(1, time.Hour)
+	require.NoError(t, err)
+	assert.Empty(t, stale)
+
+	// Re-issues are counted following the chain of carves
+	assert.Equal(t, 0, c.PreviousReissues(idle))
+	require.NoError(t, c.SetReissued(idle, "carve_active", 1))
+	active, err := c.GetByCarve("active")
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.PreviousReissues(active))
+}
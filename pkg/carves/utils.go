@@ -53,6 +53,15 @@ func GenerateArchiveName(carve CarvedFile) string {
 	return fmt.Sprintf(LocalFile, carve.UUID, carve.SessionID, cPath)
 }
 
+// Function to sanitize values sent by nodes before using them as file names
+func SafeFileName(name string) string {
+	name = strings.ReplaceAll(strings.ReplaceAll(name, "/", "-"), "\\", "-")
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
 // Function to check if data is compressed using zstd
 // https://github.com/facebook/zstd
 func CheckCompressionRaw(data []byte) bool {
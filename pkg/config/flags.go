@@ -25,6 +25,8 @@ const (
 	defOsqueryTablesFile string = "data/" + defOsqueryTablesVersion + ".json"
 	// Default SAML configuration file
 	defSAMLConfigurationFile string = "config/saml.json"
+	// Default OIDC configuration file
+	defOIDCConfigurationFile string = "config/oidc.json"
 	// Default JWT configuration file
 	defJWTConfigurationFile string = "config/jwt.json"
 	// Default TLS certificate file
@@ -59,6 +61,8 @@ type ServiceFlagParams struct {
 	DBFlag bool
 	// DB configuration file
 	DBConfigFile string
+	// Refuse to start if the DB schema is not at the latest version, instead of migrating
+	DBSchemaCheck bool
 	// Redis configuration will be loaded from a file
 	RedisFlag bool
 	// Redis configuration file
@@ -77,6 +81,10 @@ type ServiceFlagParams struct {
 	LoggerDBSame bool
 	// Always log status and on-demand query logs from nodes in database
 	AlwaysLog bool
+	// Load the ordered list of logger sinks from the logger configuration file
+	LoggerSinks bool
+	// Disk spool configuration values for logger sinks
+	SpoolConfig JSONConfigurationSpool
 
 	// Carver configuration file
 	CarverConfigFile string
@@ -91,6 +99,8 @@ type ServiceFlagParams struct {
 
 	// SAML configuration file
 	SAMLConfigFile string
+	// OIDC configuration file
+	OIDCConfigFile string
 	// Static files folder
 	StaticFiles string
 	// Use offline static files
@@ -116,6 +126,8 @@ type ServiceFlagParams struct {
 	ConfigValues JSONConfigurationService
 	// DB writer configuration values
 	WriterConfig JSONConfigurationWriter
+	// Ingestion queue configuration values
+	IngestConfig JSONConfigurationIngest
 	// DB configuration values
 	DBConfigValues backend.JSONConfigurationDB
 	// Redis configuration values
@@ -124,6 +136,8 @@ type ServiceFlagParams struct {
 	S3LogConfig S3Configuration
 	// S3 carver configuration values
 	S3CarverConfig S3Configuration
+	// Local carver configuration values
+	LocalCarverConfig LocalCarverConfiguration
 	// Kafka logging configuration values
 	KafkaConfiguration KafkaConfiguration
 	// JWT configuration values
@@ -139,6 +153,7 @@ func InitTLSFlags(params *ServiceFlagParams) []cli.Flag {
 	allFlags = append(allFlags, initLoggingFlags(params, ServiceTLS)...)
 	allFlags = append(allFlags, initMetricsFlags(params)...)
 	allFlags = append(allFlags, initWriterFlags(params)...)
+	allFlags = append(allFlags, initIngestFlags(params)...)
 	allFlags = append(allFlags, initRedisFlags(params)...)
 	allFlags = append(allFlags, initDBFlags(params)...)
 	allFlags = append(allFlags, initTLSSecurityFlags(params)...)
@@ -147,6 +162,7 @@ func InitTLSFlags(params *ServiceFlagParams) []cli.Flag {
 	allFlags = append(allFlags, initCarverFlags(params, ServiceTLS)...)
 	allFlags = append(allFlags, initS3LoggingFlags(params)...)
 	allFlags = append(allFlags, initKafkaFlags(params)...)
+	allFlags = append(allFlags, initTLSFlags(params)...)
 	allFlags = append(allFlags, initDebugFlags(params, ServiceTLS)...)
 	return allFlags
 }
@@ -296,6 +312,41 @@ func initLoggingFlags(params *ServiceFlagParams, service string) []cli.Flag {
 			EnvVars:     []string{"ALWAYS_LOG"},
 			Destination: &params.AlwaysLog,
 		},
+		&cli.BoolFlag{
+			Name:        "logger-sinks",
+			Value:       false,
+			Usage:       "Load the ordered list of logger sinks from the logger configuration file",
+			EnvVars:     []string{"LOGGER_SINKS"},
+			Destination: &params.LoggerSinks,
+		},
+		&cli.StringFlag{
+			Name:        "logger-spool-dir",
+			Value:       "",
+			Usage:       "Directory to spool logs when sinks are unavailable, empty to disable the spool",
+			EnvVars:     []string{"LOGGER_SPOOL_DIR"},
+			Destination: &params.SpoolConfig.Dir,
+		},
+		&cli.Int64Flag{
+			Name:        "logger-spool-segment-size",
+			Value:       16 * 1024 * 1024,
+			Usage:       "Maximum size in bytes of each spool segment file",
+			EnvVars:     []string{"LOGGER_SPOOL_SEGMENT_SIZE"},
+			Destination: &params.SpoolConfig.MaxSegmentSize,
+		},
+		&cli.Int64Flag{
+			Name:        "logger-spool-max-size",
+			Value:       1024 * 1024 * 1024,
+			Usage:       "Maximum size in bytes of the spool for each sink",
+			EnvVars:     []string{"LOGGER_SPOOL_MAX_SIZE"},
+			Destination: &params.SpoolConfig.MaxSize,
+		},
+		&cli.DurationFlag{
+			Name:        "logger-spool-max-backoff",
+			Value:       5 * time.Minute,
+			Usage:       "Maximum wait time between retries to drain the spool",
+			EnvVars:     []string{"LOGGER_SPOOL_MAX_BACKOFF"},
+			Destination: &params.SpoolConfig.MaxBackoff,
+		},
 	}
 }
 
@@ -353,6 +404,40 @@ func initWriterFlags(params *ServiceFlagParams) []cli.Flag {
 	}
 }
 
+// initIngestFlags initializes ingestion queue-related flags
+func initIngestFlags(params *ServiceFlagParams) []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:        "ingest-workers",
+			Value:       16,
+			Usage:       "Number of workers processing logs and query results from nodes",
+			EnvVars:     []string{"INGEST_WORKERS"},
+			Destination: &params.IngestConfig.Workers,
+		},
+		&cli.IntFlag{
+			Name:        "ingest-queue-size",
+			Value:       1000,
+			Usage:       "Maximum number of logs and query results waiting to be processed",
+			EnvVars:     []string{"INGEST_QUEUE_SIZE"},
+			Destination: &params.IngestConfig.QueueSize,
+		},
+		&cli.BoolFlag{
+			Name:        "ingest-reject-full",
+			Value:       false,
+			Usage:       "Respond with HTTP 503 when the ingestion queue is full, instead of waiting",
+			EnvVars:     []string{"INGEST_REJECT_FULL"},
+			Destination: &params.IngestConfig.RejectWhenFull,
+		},
+		&cli.DurationFlag{
+			Name:        "ingest-retry-after",
+			Value:       30 * time.Second,
+			Usage:       "Time for nodes to retry when the ingestion queue is full",
+			EnvVars:     []string{"INGEST_RETRY_AFTER"},
+			Destination: &params.IngestConfig.RetryAfter,
+		},
+	}
+}
+
 // initRedisFlags initializes Redis-related flags
 func initRedisFlags(params *ServiceFlagParams) []cli.Flag {
 	return []cli.Flag{
@@ -520,6 +605,13 @@ func initDBFlags(params *ServiceFlagParams) []cli.Flag {
 			EnvVars:     []string{"DB_SQLITE_FILEPATH"},
 			Destination: &params.DBConfigValues.FilePath,
 		},
+		&cli.BoolFlag{
+			Name:        "db-schema-check",
+			Value:       false,
+			Usage:       "Refuse to start if the DB schema is not at the latest version, instead of applying pending migrations",
+			EnvVars:     []string{"DB_SCHEMA_CHECK"},
+			Destination: &params.DBSchemaCheck,
+		},
 	}
 }
 
@@ -598,6 +690,13 @@ func initCarverFlags(params *ServiceFlagParams, service string) []cli.Flag {
 			EnvVars:     []string{"CARVER_S3_SECRET"},
 			Destination: &params.S3CarverConfig.SecretAccessKey,
 		},
+		&cli.StringFlag{
+			Name:        "carver-local-dir",
+			Value:       "",
+			Usage:       "Directory to be used as configuration for the local carver",
+			EnvVars:     []string{"CARVER_LOCAL_DIR"},
+			Destination: &params.LocalCarverConfig.Dir,
+		},
 	}
 }
 
@@ -789,6 +888,13 @@ func initAdminFlags(params *ServiceFlagParams) []cli.Flag {
 			EnvVars:     []string{"SAML_CONFIG_FILE"},
 			Destination: &params.SAMLConfigFile,
 		},
+		&cli.StringFlag{
+			Name:        "oidc-file",
+			Value:       defOIDCConfigurationFile,
+			Usage:       "Load OIDC configuration from `FILE`",
+			EnvVars:     []string{"OIDC_CONFIG_FILE"},
+			Destination: &params.OIDCConfigFile,
+		},
 		&cli.StringFlag{
 			Name:        "static",
 			Aliases:     []string{"s"},
@@ -846,6 +952,20 @@ func initAdminFlags(params *ServiceFlagParams) []cli.Flag {
 	}
 }
 
+// initTLSFlags initializes all the flags specific to the osctrl-tls service
+func initTLSFlags(params *ServiceFlagParams) []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "audit-log",
+			Aliases:     []string{"audit"},
+			Value:       false,
+			Usage:       "Enable audit log for the osctrl-tls. It will log the verification of carves",
+			EnvVars:     []string{"AUDIT_LOG"},
+			Destination: &params.AuditLog,
+		},
+	}
+}
+
 // initApiFlags initializes all the flags specific to the osctrl-api service
 func initApiFlags(params *ServiceFlagParams) []cli.Flag {
 	return []cli.Flag{
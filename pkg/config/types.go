@@ -83,6 +83,7 @@ type TLSConfiguration struct {
 	Service     YAMLConfigurationService `mapstructure:"service"`
 	DB          YAMLConfigurationDB      `mapstructure:"db"`
 	BatchWriter YAMLConfigurationWriter  `mapstructure:"batchWriter"`
+	Ingest      YAMLConfigurationIngest  `mapstructure:"ingest"`
 	Redis       YAMLConfigurationRedis   `mapstructure:"redis"`
 	Osquery     YAMLConfigurationOsquery `mapstructure:"osquery"`
 	Osctrld     YAMLConfigurationOsctrld `mapstructure:"osctrld"`
@@ -188,6 +189,8 @@ type YAMLConfigurationLogger struct {
 	Type         string `yaml:"type"`
 	LoggerDBSame bool   `yaml:"loggerDBSame"`
 	AlwaysLog    bool   `yaml:"alwaysLog"`
+	Sinks        bool   `yaml:"sinks"`
+	SpoolDir     string `yaml:"spoolDir"`
 }
 
 // YAMLConfigurationCarver to hold the carver configuration values
@@ -230,6 +233,30 @@ type YAMLConfigurationWriter struct {
 	WriterBufferSize int `yaml:"writerBufferSize"`
 }
 
+// JSONConfigurationIngest to hold the ingestion queue configuration values
+type JSONConfigurationIngest struct {
+	Workers        int           `json:"workers"`
+	QueueSize      int           `json:"queueSize"`
+	RejectWhenFull bool          `json:"rejectWhenFull"`
+	RetryAfter     time.Duration `json:"retryAfter"`
+}
+
+// YAMLConfigurationIngest to hold the ingestion queue configuration values
+type YAMLConfigurationIngest struct {
+	Workers        int  `yaml:"workers"`
+	QueueSize      int  `yaml:"queueSize"`
+	RejectWhenFull bool `yaml:"rejectWhenFull"`
+	RetryAfter     int  `yaml:"retryAfter"`
+}
+
+// JSONConfigurationSpool to hold the disk spool configuration values for logger sinks
+type JSONConfigurationSpool struct {
+	Dir            string        `json:"dir"`
+	MaxSegmentSize int64         `json:"maxSegmentSize"`
+	MaxSize        int64         `json:"maxSize"`
+	MaxBackoff     time.Duration `json:"maxBackoff"`
+}
+
 // JSONConfigurationJWT to hold all JWT configuration values
 type JSONConfigurationJWT struct {
 	JWTSecret     string `json:"jwtSecret"`
@@ -262,6 +289,11 @@ type S3Configuration struct {
 	SecretAccessKey string `json:"secretAccesKey"`
 }
 
+// LocalCarverConfiguration to hold all local carver configuration values
+type LocalCarverConfiguration struct {
+	Dir string `json:"dir"`
+}
+
 type KafkaSASLConfigurations struct {
 	Mechanism string `json:"mechanism"`
 	Username  string `json:"username"`
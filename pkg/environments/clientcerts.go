@@ -0,0 +1,276 @@
+package environments
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/jmpsec/osctrl/pkg/cache"
+	"gorm.io/gorm"
+)
+
+const (
+	// ClientCAValidity is the validity of the certificate authority of each environment
+	ClientCAValidity = 10 * 365 * 24 * time.Hour
+	// ClientCertValidity is the validity of the client certificates issued to nodes
+	ClientCertValidity = 2 * 365 * 24 * time.Hour
+	// Organization for the subject of certificates issued by osctrl
+	clientCertOrganization string = "osctrl"
+)
+
+// ErrRevokedClientCert is returned when issuing a certificate for a node with revoked certificates
+var ErrRevokedClientCert = errors.New("node has revoked client certificates")
+
+// ClientCert to keep track of the client certificates issued to nodes of an environment
+type ClientCert struct {
+	gorm.Model
+	EnvironmentID uint   `gorm:"index"`
+	UUID          string `gorm:"index"`
+	SerialNumber  string
+	Fingerprint   string `gorm:"uniqueIndex"`
+	NotAfter      time.Time
+	Revoked       bool
+	RevokedBy     string
+}
+
+// CertFingerprint returns the SHA256 fingerprint of a certificate, used to bind it to nodes
+func CertFingerprint(cert *x509.Certificate) string {
+	return fmt.Sprintf("%x", sha256.Sum256(cert.Raw))
+}
+
+// Helper to generate a random serial number for certificates
+func certSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// Helper to encode a certificate and its private key as PEM
+func encodeCertKey(der []byte, key *ecdsa.PrivateKey) (string, string, error) {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", fmt.Errorf("MarshalECPrivateKey %w", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return string(certPEM), string(keyPEM), nil
+}
+
+// Helper to generate the certificate authority for client certificates of an environment
+func genClientCA(env TLSEnvironment) (string, string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("GenerateKey %w", err)
+	}
+	serial, err := certSerialNumber()
+	if err != nil {
+		return "", "", fmt.Errorf("serial %w", err)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{clientCertOrganization},
+			CommonName:   "osctrl " + env.Name + " client CA",
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(ClientCAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", "", fmt.Errorf("CreateCertificate %w", err)
+	}
+	return encodeCertKey(der, key)
+}
+
+// Helper to parse the certificate authority for client certificates of an environment
+func parseClientCA(env TLSEnvironment) (*x509.Certificate, crypto.Signer, error) {
+	certBlock, _ := pem.Decode([]byte(env.ClientCACert))
+	if certBlock == nil {
+		return nil, nil, fmt.Errorf("no client CA for environment %s", env.Name)
+	}
+	caCert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ParseCertificate %w", err)
+	}
+	keyBlock, _ := pem.Decode([]byte(env.ClientCAKey))
+	if keyBlock == nil {
+		return caCert, nil, fmt.Errorf("no client CA key for environment %s", env.Name)
+	}
+	caKey, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return caCert, nil, fmt.Errorf("ParseECPrivateKey %w", err)
+	}
+	return caCert, caKey, nil
+}
+
+// UpdateClientCerts to enable or disable client certificates (mTLS) for nodes of an environment.
+// The certificate authority is generated the first time, and kept if it is disabled later
+func (environment *EnvManager) UpdateClientCerts(name string, enabled bool) error {
+	env, err := environment.Get(name)
+	if err != nil {
+		return fmt.Errorf("error getting environment %w", err)
+	}
+	toUpdate := map[string]interface{}{
+		"client_certs": enabled,
+	}
+	if enabled && env.ClientCACert == "" {
+		caCert, caKey, err := genClientCA(env)
+		if err != nil {
+			return fmt.Errorf("error generating client CA %w", err)
+		}
+		toUpdate["client_ca_cert"] = caCert
+		toUpdate["client_ca_key"] = caKey
+	}
+	if err := environment.DB.Model(&env).Updates(toUpdate).Error; err != nil {
+		return fmt.Errorf("UpdatesClientCerts %w", err)
+	}
+	cache.PublishInvalidation(environment.Bus, cache.TopicEnvironments, env.UUID)
+	return nil
+}
+
+// IssueClientCert to issue a new client certificate for a node of an environment, returning the
+// certificate and the private key as PEM. Only the fingerprint of the certificate is stored.
+// Nodes with revoked certificates can not get new ones until the revoked certificates are cleared
+func (environment *EnvManager) IssueClientCert(env TLSEnvironment, uuid string) (string, string, error) {
+	uuid = strings.ToUpper(strings.TrimSpace(uuid))
+	if !env.ClientCerts {
+		return "", "", fmt.Errorf("client certificates are not enabled for %s", env.Name)
+	}
+	if uuid == "" {
+		return "", "", fmt.Errorf("empty node UUID")
+	}
+	if environment.HasRevokedClientCerts(env.ID, uuid) {
+		return "", "", fmt.Errorf("%w: %s", ErrRevokedClientCert, uuid)
+	}
+	caCert, caKey, err := parseClientCA(env)
+	if err != nil {
+		return "", "", err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("GenerateKey %w", err)
+	}
+	serial, err := certSerialNumber()
+	if err != nil {
+		return "", "", fmt.Errorf("serial %w", err)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization:       []string{clientCertOrganization},
+			OrganizationalUnit: []string{env.Name},
+			CommonName:         uuid,
+		},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(ClientCertValidity),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return "", "", fmt.Errorf("CreateCertificate %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return "", "", fmt.Errorf("ParseCertificate %w", err)
+	}
+	c := ClientCert{
+		EnvironmentID: env.ID,
+		UUID:          uuid,
+		SerialNumber:  serial.Text(16),
+		Fingerprint:   CertFingerprint(cert),
+		NotAfter:      cert.NotAfter,
+	}
+	if err := environment.DB.Create(&c).Error; err != nil {
+		return "", "", fmt.Errorf("Create ClientCert %w", err)
+	}
+	return encodeCertKey(der, key)
+}
+
+// VerifyClientCert to verify a client certificate presented by a node, checking that it was issued
+// by the environment CA for the node UUID and that it has not been revoked. Returns the fingerprint
+func (environment *EnvManager) VerifyClientCert(env TLSEnvironment, cert *x509.Certificate, uuid string) (string, error) {
+	if cert == nil {
+		return "", fmt.Errorf("no client certificate")
+	}
+	// Only the CA certificate is needed to verify, not the key
+	caCert, _, err := parseClientCA(env)
+	if caCert == nil {
+		return "", err
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	opts := x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if _, err := cert.Verify(opts); err != nil {
+		return "", fmt.Errorf("invalid client certificate %w", err)
+	}
+	if !strings.EqualFold(cert.Subject.CommonName, strings.TrimSpace(uuid)) {
+		return "", fmt.Errorf("client certificate for %s used by %s", cert.Subject.CommonName, uuid)
+	}
+	fingerprint := CertFingerprint(cert)
+	var c ClientCert
+	if err := environment.DB.Where("environment_id = ? AND fingerprint = ?", env.ID, fingerprint).First(&c).Error; err != nil {
+		return "", fmt.Errorf("unknown client certificate %w", err)
+	}
+	if c.Revoked {
+		return "", fmt.Errorf("client certificate %s is revoked", fingerprint)
+	}
+	return fingerprint, nil
+}
+
+// GetClientCerts to retrieve the client certificates issued to a node of an environment
+func (environment *EnvManager) GetClientCerts(envID uint, uuid string) ([]ClientCert, error) {
+	var certs []ClientCert
+	if err := environment.DB.Where("environment_id = ? AND uuid = ?", envID, uuid).Order("created_at").Find(&certs).Error; err != nil {
+		return certs, err
+	}
+	return certs, nil
+}
+
+// RevokeClientCerts to revoke all the client certificates issued to a node of an environment
+func (environment *EnvManager) RevokeClientCerts(envID uint, uuid, revokedBy string) (int64, error) {
+	res := environment.DB.Model(&ClientCert{}).
+		Where("environment_id = ? AND uuid = ? AND revoked = ?", envID, uuid, false).
+		Updates(map[string]interface{}{
+			"revoked":    true,
+			"revoked_by": revokedBy,
+		})
+	if res.Error != nil {
+		return 0, fmt.Errorf("Updates %w", res.Error)
+	}
+	return res.RowsAffected, nil
+}
+
+// HasRevokedClientCerts to check if a node of an environment has revoked client certificates
+func (environment *EnvManager) HasRevokedClientCerts(envID uint, uuid string) bool {
+	var results int64
+	environment.DB.Model(&ClientCert{}).Where("environment_id = ? AND uuid = ? AND revoked = ?", envID, strings.ToUpper(uuid), true).Count(&results)
+	return (results > 0)
+}
+
+// ClearRevokedClientCerts to remove the revoked client certificates of a node of an environment, so
+// new certificates can be issued to the node. Cleared certificates are still not valid
+func (environment *EnvManager) ClearRevokedClientCerts(envID uint, uuid string) (int64, error) {
+	res := environment.DB.Where("environment_id = ? AND uuid = ? AND revoked = ?", envID, strings.ToUpper(uuid), true).Delete(&ClientCert{})
+	if res.Error != nil {
+		return 0, fmt.Errorf("Delete %w", res.Error)
+	}
+	return res.RowsAffected, nil
+}
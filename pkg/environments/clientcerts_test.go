@@ -0,0 +1,107 @@
+package environments
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Helper to parse a PEM certificate in tests
+func parseTestCert(t *testing.T, certPEM string) *x509.Certificate {
+	block, _ := pem.Decode([]byte(certPEM))
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestClientCerts(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&TLSEnvironment{}, &EnrollToken{}, &ClientCert{}))
+	e := CreateEnvironment(db)
+	require.NoError(t, db.Create(&TLSEnvironment{Name: "dev", UUID: "env-uuid"}).Error)
+	require.NoError(t, db.Create(&TLSEnvironment{Name: "prod", UUID: "prod-uuid"}).Error)
+	env, err := e.Get("dev")
+	require.NoError(t, err)
+
+	// Certificates are only issued when mTLS is enabled
+	_, _, err = e.IssueClientCert(env, "NODE-1")
+	assert.Error(t, err)
+	require.NoError(t, e.UpdateClientCerts("dev", true))
+	env, err = e.Get("dev")
+	require.NoError(t, err)
+	assert.True(t, env.ClientCerts)
+	assert.NotEmpty(t, env.ClientCACert)
+	_, _, err = e.IssueClientCert(env, "")
+	assert.Error(t, err)
+
+	certPEM, keyPEM, err := e.IssueClientCert(env, "NODE-1")
+	require.NoError(t, err)
+	assert.Contains(t, keyPEM, "PRIVATE KEY")
+	cert := parseTestCert(t, certPEM)
+	assert.Equal(t, "NODE-1", cert.Subject.CommonName)
+
+	// Certificates are only valid for the node and environment they were issued for
+	fingerprint, err := e.VerifyClientCert(env, cert, "node-1")
+	require.NoError(t, err)
+	assert.Equal(t, CertFingerprint(cert), fingerprint)
+	_, err = e.VerifyClientCert(env, cert, "NODE-2")
+	assert.Error(t, err)
+	_, err = e.VerifyClientCert(env, nil, "NODE-1")
+	assert.Error(t, err)
+	require.NoError(t, e.UpdateClientCerts("prod", true))
+	prod, err := e.Get("prod")
+	require.NoError(t, err)
+	_, err = e.VerifyClientCert(prod, cert, "NODE-1")
+	assert.Error(t, err)
+
+	// Disabling keeps the CA, so it is the same when enabled again
+	require.NoError(t, e.UpdateClientCerts("dev", false))
+	require.NoError(t, e.UpdateClientCerts("dev", true))
+	renewed, err := e.Get("dev")
+	require.NoError(t, err)
+	assert.Equal(t, env.ClientCACert, renewed.ClientCACert)
+
+	// Revoked certificates are not valid anymore
+	revoked, err := e.RevokeClientCerts(env.ID, "NODE-1", "admin")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), revoked)
+	_, err = e.VerifyClientCert(env, cert, "NODE-1")
+	assert.Error(t, err)
+	certs, err := e.GetClientCerts(env.ID, "NODE-1")
+	require.NoError(t, err)
+	require.Len(t, certs, 1)
+	assert.True(t, certs[0].Revoked)
+	assert.Equal(t, "admin", certs[0].RevokedBy)
+
+	// Nodes with revoked certificates can not get new ones until they are cleared
+	assert.True(t, e.HasRevokedClientCerts(env.ID, "node-1"))
+	_, _, err = e.IssueClientCert(env, "NODE-1")
+	assert.ErrorIs(t, err, ErrRevokedClientCert)
+	cleared, err := e.ClearRevokedClientCerts(env.ID, "NODE-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cleared)
+	_, err = e.VerifyClientCert(env, cert, "NODE-1")
+	assert.Error(t, err)
+	_, _, err = e.IssueClientCert(env, "NODE-1")
+	require.NoError(t, err)
+
+	// Flags include the client certificate only for environments using mTLS
+	flags, err := e.GenerateFlagsClient(env, "", "", "/etc/osquery/client.crt", "/etc/osquery/client.key", config.OsqueryConfiguration{})
+	require.NoError(t, err)
+	assert.Contains(t, flags, "--tls_client_cert=/etc/osquery/client.crt")
+	flags, err = e.GenerateFlags(env, "", "", config.OsqueryConfiguration{})
+	require.NoError(t, err)
+	assert.Contains(t, flags, "--tls_client_key="+EmptyFlagClientKey)
+	env.ClientCerts = false
+	flags, err = e.GenerateFlags(env, "", "", config.OsqueryConfiguration{})
+	require.NoError(t, err)
+	assert.NotContains(t, flags, "tls_client_cert")
+}
@@ -0,0 +1,182 @@
+package environments
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmpsec/osctrl/pkg/cache"
+	"github.com/jmpsec/osctrl/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultEnrollTokenLength as default length for enrollment tokens
+	DefaultEnrollTokenLength int = 48
+	// Prefix for enrollment tokens, so they are not confused with the environment secret
+	enrollTokenPrefix string = "osctrl-et-"
+)
+
+// EnrollToken to hold the enrollment tokens of an environment, that can be used instead of the
+// shared secret a limited number of times and only by the bound host. Only the hash is stored
+type EnrollToken struct {
+	gorm.Model
+	TokenID       string `gorm:"uniqueIndex"`
+	EnvironmentID uint   `gorm:"index"`
+	TokenHash     string `gorm:"index" json:"-"`
+	Hostname      string
+	Serial        string
+	MaxUses       int
+	Uses          int
+	ExpiresAt     time.Time
+	LastUsed      time.Time
+	LastUsedBy    string
+	CreatedBy     string
+	Revoked       bool
+}
+
+// Helper to hash enrollment tokens before storing and looking them up
+func hashEnrollToken(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.TrimSpace(token))))
+}
+
+// IsEnrollToken checks if a provided enroll secret looks like an enrollment token
+func IsEnrollToken(secret string) bool {
+	return strings.HasPrefix(strings.TrimSpace(secret), enrollTokenPrefix)
+}
+
+// Usable checks if the token can still be used to enroll, without checking the host
+func (t EnrollToken) Usable() bool {
+	if t.Revoked {
+		return false
+	}
+	if !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt) {
+		return false
+	}
+	return t.MaxUses <= 0 || t.Uses < t.MaxUses
+}
+
+// Matches checks if the token can be used by a host with the provided hostname and serial
+func (t EnrollToken) Matches(hostname, serial string) bool {
+	if t.Hostname != "" && !strings.EqualFold(t.Hostname, strings.TrimSpace(hostname)) {
+		return false
+	}
+	if t.Serial != "" && !strings.EqualFold(t.Serial, strings.TrimSpace(serial)) {
+		return false
+	}
+	return true
+}
+
+// CreateEnrollToken to create a new enrollment token for an environment. Tokens with zero max uses
+// can be used without limit and tokens with zero expiration hours do not expire
+func (environment *EnvManager) CreateEnrollToken(envID uint, hostname, serial, createdBy string, maxUses, expHours int) (string, EnrollToken, error) {
+	if maxUses < 0 || expHours < 0 {
+		return "", EnrollToken{}, fmt.Errorf("invalid max uses or expiration")
+	}
+	token := enrollTokenPrefix + utils.GenRandomString(DefaultEnrollTokenLength)
+	t := EnrollToken{
+		TokenID:       utils.GenUUID(),
+		EnvironmentID: envID,
+		TokenHash:     hashEnrollToken(token),
+		Hostname:      strings.TrimSpace(hostname),
+		Serial:        strings.TrimSpace(serial),
+		MaxUses:       maxUses,
+		CreatedBy:     createdBy,
+	}
+	if expHours > 0 {
+		t.ExpiresAt = time.Now().Add(time.Duration(expHours) * time.Hour)
+	}
+	if err := environment.DB.Create(&t).Error; err != nil {
+		return "", EnrollToken{}, fmt.Errorf("Create EnrollToken %w", err)
+	}
+	return token, t, nil
+}
+
+// GetEnrollTokens to retrieve all the enrollment tokens of an environment
+func (environment *EnvManager) GetEnrollTokens(envID uint) ([]EnrollToken, error) {
+	var tokens []EnrollToken
+	if err := environment.DB.Where("environment_id = ?", envID).Order("created_at").Find(&tokens).Error; err != nil {
+		return tokens, err
+	}
+	return tokens, nil
+}
+
+// RevokeEnrollToken to revoke an enrollment token of an environment, so it can not be used anymore
+func (environment *EnvManager) RevokeEnrollToken(envID uint, tokenID string) error {
+	res := environment.DB.Model(&EnrollToken{}).Where("environment_id = ? AND token_id = ?", envID, tokenID).Update("revoked", true)
+	if res.Error != nil {
+		return fmt.Errorf("update %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("token %s not found", tokenID)
+	}
+	return nil
+}
+
+// CheckEnrollToken to verify an enrollment token for a host without registering its use
+func (environment *EnvManager) CheckEnrollToken(envID uint, token, hostname, serial string) (EnrollToken, error) {
+	var t EnrollToken
+	if err := environment.DB.Where("environment_id = ? AND token_hash = ?", envID, hashEnrollToken(token)).First(&t).Error; err != nil {
+		return t, fmt.Errorf("invalid token %w", err)
+	}
+	if !t.Usable() {
+		return t, fmt.Errorf("token %s can not be used", t.TokenID)
+	}
+	if !t.Matches(hostname, serial) {
+		return t, fmt.Errorf("token %s is not valid for host %s", t.TokenID, hostname)
+	}
+	return t, nil
+}
+
+// UseEnrollToken to verify an enrollment token for a host and register its use. The use is only
+// registered if the token has uses left, so concurrent enrollments can not exceed the limit
+func (environment *EnvManager) UseEnrollToken(envID uint, token, hostname, serial string) (EnrollToken, error) {
+	t, err := environment.CheckEnrollToken(envID, token, hostname, serial)
+	if err != nil {
+		return t, err
+	}
+	res := environment.DB.Model(&EnrollToken{}).
+		Where("id = ? AND (max_uses = 0 OR uses < max_uses)", t.ID).
+		Updates(map[string]interface{}{
+			"uses":         gorm.Expr("uses + ?", 1),
+			"last_used":    time.Now(),
+			"last_used_by": hostname,
+		})
+	if res.Error != nil {
+		return t, fmt.Errorf("Updates %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return t, fmt.Errorf("token %s has no uses left", t.TokenID)
+	}
+	t.Uses++
+	return t, nil
+}
+
+// ReleaseEnrollToken to revert the use of an enrollment token, when the enrollment of the node failed
+func (environment *EnvManager) ReleaseEnrollToken(envID uint, token string) error {
+	res := environment.DB.Model(&EnrollToken{}).
+		Where("environment_id = ? AND token_hash = ? AND uses > 0", envID, hashEnrollToken(token)).
+		Update("uses", gorm.Expr("uses - ?", 1))
+	if res.Error != nil {
+		return fmt.Errorf("Update %w", res.Error)
+	}
+	return nil
+}
+
+// UpdateEnrollPolicy to update if an environment only accepts enrollment tokens and if new nodes need approval
+func (environment *EnvManager) UpdateEnrollPolicy(name string, requireTokens, approval bool) error {
+	env, err := environment.Get(name)
+	if err != nil {
+		return fmt.Errorf("error getting environment %w", err)
+	}
+	toUpdate := map[string]interface{}{
+		"enroll_tokens":   requireTokens,
+		"enroll_approval": approval,
+	}
+	if err := environment.DB.Model(&env).Updates(toUpdate).Error; err != nil {
+		return fmt.Errorf("UpdatesEnrollPolicy %w", err)
+	}
+	cache.PublishInvalidation(environment.Bus, cache.TopicEnvironments, env.UUID)
+	return nil
+}
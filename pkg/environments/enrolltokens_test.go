@@ -0,0 +1,77 @@
+package environments
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestEnrollTokens(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&TLSEnvironment{}, &EnrollToken{}, &ClientCert{}))
+	e := CreateEnvironment(db)
+	require.NoError(t, db.Create(&TLSEnvironment{Name: "dev", UUID: "env-uuid"}).Error)
+	env, err := e.Get("dev")
+	require.NoError(t, err)
+
+	// Tokens bound to a host can be used only by that host and only the allowed times
+	token, et, err := e.CreateEnrollToken(env.ID, "web-1", "", "admin", 1, 0)
+	require.NoError(t, err)
+	assert.True(t, IsEnrollToken(token))
+	assert.False(t, IsEnrollToken(env.Secret))
+	assert.NotEqual(t, token, et.TokenHash)
+	_, err = e.UseEnrollToken(env.ID, token, "web-2", "")
+	assert.Error(t, err)
+	_, err = e.UseEnrollToken(env.ID+1, token, "web-1", "")
+	assert.Error(t, err)
+	// Checking the token does not register a use
+	checked, err := e.CheckEnrollToken(env.ID, token, "web-1", "")
+	require.NoError(t, err)
+	assert.Equal(t, 0, checked.Uses)
+	used, err := e.UseEnrollToken(env.ID, token, "WEB-1", "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, used.Uses)
+	// Released uses can be used again
+	require.NoError(t, e.ReleaseEnrollToken(env.ID, token))
+	used, err = e.UseEnrollToken(env.ID, token, "web-1", "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, used.Uses)
+	_, err = e.UseEnrollToken(env.ID, token, "web-1", "")
+	assert.Error(t, err)
+
+	// Unlimited tokens can be used until revoked
+	token, et, err = e.CreateEnrollToken(env.ID, "", "SERIAL-1", "admin", 0, 1)
+	require.NoError(t, err)
+	assert.False(t, et.ExpiresAt.IsZero())
+	for i := 0; i < 3; i++ {
+		_, err = e.UseEnrollToken(env.ID, token, "any", "serial-1")
+		require.NoError(t, err)
+	}
+	_, err = e.UseEnrollToken(env.ID, token, "any", "SERIAL-2")
+	assert.Error(t, err)
+	require.NoError(t, e.RevokeEnrollToken(env.ID, et.TokenID))
+	_, err = e.UseEnrollToken(env.ID, token, "any", "SERIAL-1")
+	assert.Error(t, err)
+	assert.Error(t, e.RevokeEnrollToken(env.ID, "missing"))
+	tokens, err := e.GetEnrollTokens(env.ID)
+	require.NoError(t, err)
+	assert.Len(t, tokens, 2)
+	_, _, err = e.CreateEnrollToken(env.ID, "", "", "admin", -1, 0)
+	assert.Error(t, err)
+
+	// Policy can be updated
+	require.NoError(t, e.UpdateEnrollPolicy("dev", true, true))
+	env, err = e.Get("dev")
+	require.NoError(t, err)
+	assert.True(t, env.EnrollTokens)
+	assert.True(t, env.EnrollApproval)
+	require.NoError(t, e.UpdateEnrollPolicy("dev", false, false))
+	env, err = e.Get("dev")
+	require.NoError(t, err)
+	assert.False(t, env.EnrollTokens)
+	assert.False(t, env.EnrollApproval)
+}
@@ -67,6 +67,18 @@ func (ec *EnvCache) UpdateEnvInCache(ctx context.Context, env TLSEnvironment) {
 	ec.cache.Set(ctx, env.UUID, env, 2*time.Hour)
 }
 
+// Subscribe evicts environments from the cache when changes are published to the bus,
+// removing all of them if the invalidation has no key
+func (ec *EnvCache) Subscribe(bus cache.InvalidationBus) {
+	bus.Subscribe(cache.TopicEnvironments, func(ctx context.Context, inv cache.Invalidation) {
+		if inv.Key == "" {
+			ec.InvalidateAll(ctx)
+			return
+		}
+		ec.InvalidateEnv(ctx, inv.Key)
+	})
+}
+
 // Close stops the cleanup goroutine and releases resources
 func (ec *EnvCache) Close() {
 	if ec.cache != nil {
@@ -4,9 +4,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/jmpsec/osctrl/pkg/cache"
 	"github.com/jmpsec/osctrl/pkg/settings"
 	"github.com/jmpsec/osctrl/pkg/utils"
-	"github.com/rs/zerolog/log"
 	"gorm.io/gorm"
 )
 
@@ -43,6 +43,8 @@ const (
 	DefaultFlagsPath string = "osctrld-flags"
 	// DefaultCertPath
 	DefaultCertPath string = "osctrld-cert"
+	// DefaultClientCertPath
+	DefaultClientCertPath string = "osctrld-client-cert"
 	// DefaultVerifyPath
 	DefaultVerifyPath string = "osctrld-verify"
 	// DefaultScriptPath
@@ -91,6 +93,17 @@ type TLSEnvironment struct {
 	CarverBlockPath  string
 	AcceptEnrolls    bool
 	UserID           uint
+	CarveMaxSize     int64
+	CarveQuota       int64
+	CarveRetention   int
+	NodeArchiveDays  int
+	NodePurgeDays    int
+	DuplicatePolicy  string
+	EnrollTokens     bool
+	EnrollApproval   bool
+	ClientCerts      bool
+	ClientCACert     string
+	ClientCAKey      string `json:"-"`
 }
 
 // MapEnvironments to hold the TLS environments by name and UUID
@@ -112,18 +125,29 @@ type MapEnvByString map[string]NameUUID
 // EnvManager keeps all TLS Environments
 type EnvManager struct {
 	DB *gorm.DB
+	// Bus to publish changes so cached environments are evicted in all replicas
+	Bus cache.InvalidationBus
 }
 
-// CreateEnvironment to initialize the environment struct and tables
+// CreateEnvironment to initialize the environment struct, tables are created by migrations
 func CreateEnvironment(backend *gorm.DB) *EnvManager {
 	var e *EnvManager = &EnvManager{DB: backend}
-	// table tls_environments
-	if err := backend.AutoMigrate(&TLSEnvironment{}); err != nil {
-		log.Fatal().Msgf("Failed to AutoMigrate table (tls_environments): %v", err)
-	}
 	return e
 }
 
+// Helper to publish the change of an environment by name or UUID, if it can not be
+// found then all the cached environments are evicted
+func (environment *EnvManager) invalidate(identifier string) {
+	if environment.Bus == nil {
+		return
+	}
+	key := ""
+	if env, err := environment.Get(identifier); err == nil {
+		key = env.UUID
+	}
+	cache.PublishInvalidation(environment.Bus, cache.TopicEnvironments, key)
+}
+
 // Get TLS Environment by name or UUID
 func (environment *EnvManager) Get(identifier string) (TLSEnvironment, error) {
 	var env TLSEnvironment
@@ -323,6 +347,7 @@ func (environment *EnvManager) Delete(identifier string) error {
 	if err := environment.DB.Unscoped().Delete(&env).Error; err != nil {
 		return fmt.Errorf("delete %w", err)
 	}
+	cache.PublishInvalidation(environment.Bus, cache.TopicEnvironments, env.UUID)
 	return nil
 }
 
@@ -335,6 +360,7 @@ func (environment *EnvManager) Update(e TLSEnvironment) error {
 	if err := environment.DB.Model(&env).Updates(e).Error; err != nil {
 		return fmt.Errorf("updates %w", err)
 	}
+	cache.PublishInvalidation(environment.Bus, cache.TopicEnvironments, env.UUID)
 	return nil
 }
 
@@ -343,6 +369,7 @@ func (environment *EnvManager) UpdateOptions(idEnv, options string) error {
 	if err := environment.DB.Model(&TLSEnvironment{}).Where("name = ? OR uuid = ?", idEnv, idEnv).Update("options", options).Error; err != nil {
 		return fmt.Errorf("Update options %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
 
@@ -351,6 +378,7 @@ func (environment *EnvManager) UpdateSchedule(idEnv, schedule string) error {
 	if err := environment.DB.Model(&TLSEnvironment{}).Where("name = ? OR uuid = ?", idEnv, idEnv).Update("schedule", schedule).Error; err != nil {
 		return fmt.Errorf("Update schedule %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
 
@@ -359,6 +387,7 @@ func (environment *EnvManager) UpdatePacks(idEnv, packs string) error {
 	if err := environment.DB.Model(&TLSEnvironment{}).Where("name = ? OR uuid = ?", idEnv, idEnv).Update("packs", packs).Error; err != nil {
 		return fmt.Errorf("Update packs %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
 
@@ -367,6 +396,7 @@ func (environment *EnvManager) UpdateDecorators(idEnv, decorators string) error
 	if err := environment.DB.Model(&TLSEnvironment{}).Where("name = ? OR uuid = ?", idEnv, idEnv).Update("decorators", decorators).Error; err != nil {
 		return fmt.Errorf("Update decorators %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
 
@@ -375,6 +405,7 @@ func (environment *EnvManager) UpdateATC(idEnv, atc string) error {
 	if err := environment.DB.Model(&TLSEnvironment{}).Where("name = ? OR uuid = ?", idEnv, idEnv).Update("atc", atc).Error; err != nil {
 		return fmt.Errorf("Update ATC %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
 
@@ -383,6 +414,7 @@ func (environment *EnvManager) UpdateCertificate(idEnv, certificate string) erro
 	if err := environment.DB.Model(&TLSEnvironment{}).Where("name = ? OR uuid = ?", idEnv, idEnv).Update("certificate", certificate).Error; err != nil {
 		return fmt.Errorf("UpdateUpdateCertificate %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
 
@@ -391,6 +423,7 @@ func (environment *EnvManager) UpdateDebPackage(idEnv, debpackage string) error
 	if err := environment.DB.Model(&TLSEnvironment{}).Where("name = ? OR uuid = ?", idEnv, idEnv).Update("deb_package", debpackage).Error; err != nil {
 		return fmt.Errorf("UpdateDebPackage %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
 
@@ -399,6 +432,7 @@ func (environment *EnvManager) UpdateRpmPackage(idEnv, rpmpackage string) error
 	if err := environment.DB.Model(&TLSEnvironment{}).Where("name = ? OR uuid = ?", idEnv, idEnv).Update("rpm_package", rpmpackage).Error; err != nil {
 		return fmt.Errorf("UpdateRpmPackage %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
 
@@ -407,6 +441,7 @@ func (environment *EnvManager) UpdateMsiPackage(idEnv, msipackage string) error
 	if err := environment.DB.Model(&TLSEnvironment{}).Where("name = ? OR uuid = ?", idEnv, idEnv).Update("msi_package", msipackage).Error; err != nil {
 		return fmt.Errorf("UpdateMsiPackage %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
 
@@ -415,6 +450,7 @@ func (environment *EnvManager) UpdatePkgPackage(idEnv, pkgpackage string) error
 	if err := environment.DB.Model(&TLSEnvironment{}).Where("name = ? OR uuid = ?", idEnv, idEnv).Update("pkg_package", pkgpackage).Error; err != nil {
 		return fmt.Errorf("UpdatePkgPackage %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
 
@@ -423,6 +459,7 @@ func (environment *EnvManager) UpdateFlags(idEnv, flags string) error {
 	if err := environment.DB.Model(&TLSEnvironment{}).Where("name = ? OR uuid = ?", idEnv, idEnv).Update("flags", flags).Error; err != nil {
 		return fmt.Errorf("Update flags %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
 
@@ -431,6 +468,7 @@ func (environment *EnvManager) UpdateHostname(idEnv, hostname string) error {
 	if err := environment.DB.Model(&TLSEnvironment{}).Where("name = ? OR uuid = ?", idEnv, idEnv).Update("hostname", hostname).Error; err != nil {
 		return fmt.Errorf("Update hostname %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
 
@@ -447,6 +485,55 @@ func (environment *EnvManager) UpdateIntervals(name string, csecs, lsecs, qsecs
 	if err := environment.DB.Model(&env).Updates(updated).Error; err != nil {
 		return fmt.Errorf("UpdatesUpdateIntervals %w", err)
 	}
+	cache.PublishInvalidation(environment.Bus, cache.TopicEnvironments, env.UUID)
+	return nil
+}
+
+// UpdateCarveLimits to update the carve limits for an environment, zero means no limit
+func (environment *EnvManager) UpdateCarveLimits(name string, maxSize, quota int64, retention int) error {
+	env, err := environment.Get(name)
+	if err != nil {
+		return fmt.Errorf("error getting environment %w", err)
+	}
+	toUpdate := map[string]interface{}{
+		"carve_max_size":  maxSize,
+		"carve_quota":     quota,
+		"carve_retention": retention,
+	}
+	if err := environment.DB.Model(&env).Updates(toUpdate).Error; err != nil {
+		return fmt.Errorf("UpdatesCarveLimits %w", err)
+	}
+	cache.PublishInvalidation(environment.Bus, cache.TopicEnvironments, env.UUID)
+	return nil
+}
+
+// UpdateNodeLifecycle to update the days to archive unseen nodes and to purge archived nodes, zero disables it
+func (environment *EnvManager) UpdateNodeLifecycle(name string, archiveDays, purgeDays int) error {
+	env, err := environment.Get(name)
+	if err != nil {
+		return fmt.Errorf("error getting environment %w", err)
+	}
+	toUpdate := map[string]interface{}{
+		"node_archive_days": archiveDays,
+		"node_purge_days":   purgeDays,
+	}
+	if err := environment.DB.Model(&env).Updates(toUpdate).Error; err != nil {
+		return fmt.Errorf("UpdatesNodeLifecycle %w", err)
+	}
+	cache.PublishInvalidation(environment.Bus, cache.TopicEnvironments, env.UUID)
+	return nil
+}
+
+// UpdateDuplicatePolicy to update how duplicated nodes are handled when enrolling in an environment
+func (environment *EnvManager) UpdateDuplicatePolicy(name, policy string) error {
+	env, err := environment.Get(name)
+	if err != nil {
+		return fmt.Errorf("error getting environment %w", err)
+	}
+	if err := environment.DB.Model(&env).Update("duplicate_policy", policy).Error; err != nil {
+		return fmt.Errorf("Update duplicate_policy %w", err)
+	}
+	cache.PublishInvalidation(environment.Bus, cache.TopicEnvironments, env.UUID)
 	return nil
 }
 
@@ -465,6 +552,7 @@ func (environment *EnvManager) RotateSecrets(name string) error {
 	if err := environment.DB.Model(&env).Updates(rotated).Error; err != nil {
 		return fmt.Errorf("UpdatesRotateSecrets %w", err)
 	}
+	cache.PublishInvalidation(environment.Bus, cache.TopicEnvironments, env.UUID)
 	return nil
 }
 
@@ -480,6 +568,7 @@ func (environment *EnvManager) RotateEnroll(name string) error {
 	if err := environment.DB.Model(&env).Updates(rotated).Error; err != nil {
 		return fmt.Errorf("UpdatesRotateEnrollPath %w", err)
 	}
+	cache.PublishInvalidation(environment.Bus, cache.TopicEnvironments, env.UUID)
 	return nil
 }
 
@@ -495,6 +584,7 @@ func (environment *EnvManager) RotateSecret(name string) error {
 	if err := environment.DB.Model(&env).Updates(rotated).Error; err != nil {
 		return fmt.Errorf("UpdatesRotateSecret %w", err)
 	}
+	cache.PublishInvalidation(environment.Bus, cache.TopicEnvironments, env.UUID)
 	return nil
 }
 
@@ -503,6 +593,7 @@ func (environment *EnvManager) ExpireEnroll(idEnv string) error {
 	if err := environment.DB.Model(&TLSEnvironment{}).Where("name = ? OR uuid = ?", idEnv, idEnv).Update("enroll_expire", time.Now()).Error; err != nil {
 		return fmt.Errorf("UpdateExpireEnroll %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
 
@@ -512,6 +603,7 @@ func (environment *EnvManager) ExtendEnroll(idEnv string) error {
 	if err := environment.DB.Model(&TLSEnvironment{}).Where("name = ? OR uuid = ?", idEnv, idEnv).Update("enroll_expire", extended).Error; err != nil {
 		return fmt.Errorf("UpdateExtendEnroll %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
 
@@ -520,6 +612,7 @@ func (environment *EnvManager) NotExpireEnroll(idEnv string) error {
 	if err := environment.DB.Model(&TLSEnvironment{}).Where("name = ? OR uuid = ?", idEnv, idEnv).Update("enroll_expire", time.Time{}).Error; err != nil {
 		return fmt.Errorf("NotExpireEnroll %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
 
@@ -535,6 +628,7 @@ func (environment *EnvManager) RotateRemove(name string) error {
 	if err := environment.DB.Model(&env).Updates(rotated).Error; err != nil {
 		return fmt.Errorf("UpdatesRotateRemove %w", err)
 	}
+	cache.PublishInvalidation(environment.Bus, cache.TopicEnvironments, env.UUID)
 	return nil
 }
 
@@ -543,6 +637,7 @@ func (environment *EnvManager) ExpireRemove(idEnv string) error {
 	if err := environment.DB.Model(&TLSEnvironment{}).Where("name = ? OR uuid = ?", idEnv, idEnv).Update("remove_expire", time.Now()).Error; err != nil {
 		return fmt.Errorf("UpdateExpireRemove %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
 
@@ -556,6 +651,7 @@ func (environment *EnvManager) ExtendRemove(idEnv string) error {
 	if err := environment.DB.Model(&env).Update("remove_expire", extended).Error; err != nil {
 		return fmt.Errorf("UpdateExtendRemove %w", err)
 	}
+	cache.PublishInvalidation(environment.Bus, cache.TopicEnvironments, env.UUID)
 	return nil
 }
 
@@ -564,5 +660,6 @@ func (environment *EnvManager) NotExpireRemove(idEnv string) error {
 	if err := environment.DB.Model(&TLSEnvironment{}).Where("name = ? OR uuid = ?", idEnv, idEnv).Update("remove_expire", time.Time{}).Error; err != nil {
 		return fmt.Errorf("NotExpireRemove %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
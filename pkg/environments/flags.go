@@ -15,6 +15,10 @@ const (
 	FlagGenericValue string = `--{{ .FlagName }}={{ .FlagValue }}`
 	// FlagTLSServerCerts for the --tls_server_certs flag
 	FlagNameTLSServerCerts string = `tls_server_certs`
+	// FlagNameTLSClientCert for the --tls_client_cert flag
+	FlagNameTLSClientCert string = `tls_client_cert`
+	// FlagNameTLSClientKey for the --tls_client_key flag
+	FlagNameTLSClientKey string = `tls_client_key`
 	// FlagCarverBlockSize for the --carver_block_size flag
 	FlagNameCarverBlockSize string = `carver_block_size`
 	// FlagsConfigPlugin to configure the config plugin
@@ -61,6 +65,7 @@ const (
 {{ .FlagsCarverPlugin }}
 --tls_hostname={{ .Environment.Hostname }}
 {{ .FlagServerCerts }}
+{{ .FlagClientCerts }}
 `
 )
 
@@ -69,6 +74,10 @@ const (
 	EmptyFlagSecret string = "__SECRET_FILE__"
 	// EmptyFlagCert to use as placeholder for the certificate file
 	EmptyFlagCert string = "__CERT_FILE__"
+	// EmptyFlagClientCert to use as placeholder for the client certificate file
+	EmptyFlagClientCert string = "__CLIENT_CERT_FILE__"
+	// EmptyFlagClientKey to use as placeholder for the client key file
+	EmptyFlagClientKey string = "__CLIENT_KEY_FILE__"
 )
 
 type flagData struct {
@@ -79,6 +88,7 @@ type flagData struct {
 	FlagsQueryPlugin  string
 	FlagsCarverPlugin string
 	FlagServerCerts   string
+	FlagClientCerts   string
 	FlagCarverBlock   string
 }
 
@@ -90,6 +100,14 @@ func GenServerCertsFlag(certificatePath string) string {
 	return GenSingleFlag("servercerts", FlagNameTLSServerCerts, certificatePath)
 }
 
+// GenClientCertsFlags to generate the --tls_client_cert and --tls_client_key flags
+func GenClientCertsFlags(certPath, keyPath string) string {
+	if certPath == "" || keyPath == "" {
+		return ""
+	}
+	return GenSingleFlag("clientcert", FlagNameTLSClientCert, certPath) + "\n" + GenSingleFlag("clientkey", FlagNameTLSClientKey, keyPath)
+}
+
 // GenCarveBlockSizeFlag to generate the --carver_block_size flag
 func GenCarveBlockSizeFlag(blockSize string) string {
 	if blockSize == "" {
@@ -158,6 +176,11 @@ func GenCarverFlags(env TLSEnvironment, carverBlock string) string {
 
 // GenerateFlags to generate flags
 func (environment *EnvManager) GenerateFlags(env TLSEnvironment, secretPath, certPath string, osqCfg config.OsqueryConfiguration) (string, error) {
+	return environment.GenerateFlagsClient(env, secretPath, certPath, "", "", osqCfg)
+}
+
+// GenerateFlagsClient to generate flags, including the client certificate and key if the environment uses mTLS
+func (environment *EnvManager) GenerateFlagsClient(env TLSEnvironment, secretPath, certPath, clientCertPath, clientKeyPath string, osqCfg config.OsqueryConfiguration) (string, error) {
 	flagSecret := secretPath
 	if secretPath == "" {
 		flagSecret = EmptyFlagSecret
@@ -170,6 +193,16 @@ func (environment *EnvManager) GenerateFlags(env TLSEnvironment, secretPath, cer
 	if env.Certificate == "" {
 		flagServerCerts = ""
 	}
+	var flagClientCerts string
+	if env.ClientCerts {
+		if clientCertPath == "" {
+			clientCertPath = EmptyFlagClientCert
+		}
+		if clientKeyPath == "" {
+			clientKeyPath = EmptyFlagClientKey
+		}
+		flagClientCerts = GenClientCertsFlags(clientCertPath, clientKeyPath)
+	}
 	var configFlags, loggerFlags, queryFlags, carverFlags string
 	if osqCfg.Config {
 		configFlags = GenConfigFlags(env)
@@ -191,6 +224,7 @@ func (environment *EnvManager) GenerateFlags(env TLSEnvironment, secretPath, cer
 		FlagsQueryPlugin:  queryFlags,
 		FlagsCarverPlugin: carverFlags,
 		FlagServerCerts:   flagServerCerts,
+		FlagClientCerts:   flagClientCerts,
 	}
 	return ParseFlagTemplate("flags", FlagsTemplate, data), nil
 }
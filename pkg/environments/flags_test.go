@@ -69,3 +69,18 @@ func TestParseFlagTemplate(t *testing.T) {
 		}
 	})
 }
+
+func TestGenClientCertsFlags(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		flag := GenClientCertsFlags("", "key")
+		if flag != "" {
+			t.Errorf("Expected empty flag, got %s", flag)
+		}
+	})
+	t.Run("not empty", func(t *testing.T) {
+		flag := GenClientCertsFlags("cert", "key")
+		if flag != "--tls_client_cert=cert\n--tls_client_key=key" {
+			t.Errorf("Expected client certificate flags, got %s", flag)
+		}
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+
+	"github.com/jmpsec/osctrl/pkg/cache"
 )
 
 // OsqueryConf to hold the structure for the configuration
@@ -104,6 +106,7 @@ func (environment *EnvManager) RefreshConfiguration(idEnv string) error {
 	if err := environment.DB.Model(&env).Update("configuration", indentedConf).Error; err != nil {
 		return fmt.Errorf("Update configuration %w", err)
 	}
+	cache.PublishInvalidation(environment.Bus, cache.TopicEnvironments, env.UUID)
 	return nil
 }
 
@@ -116,6 +119,7 @@ func (environment *EnvManager) UpdateConfiguration(idEnv string, cnf OsqueryConf
 	if err := environment.DB.Model(&TLSEnvironment{}).Where("name = ? OR uuid = ?", idEnv, idEnv).Update("configuration", indentedConf).Error; err != nil {
 		return fmt.Errorf("Update configuration %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
 
@@ -149,6 +153,7 @@ func (environment *EnvManager) UpdateConfigurationParts(idEnv string, cnf Osquer
 		ATC:        indentedATC}).Error; err != nil {
 		return fmt.Errorf("Update parts %w", err)
 	}
+	environment.invalidate(idEnv)
 	return nil
 }
 
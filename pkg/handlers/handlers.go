@@ -2,12 +2,18 @@ package handlers
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/jmpsec/osctrl/pkg/cache"
+	"github.com/jmpsec/osctrl/pkg/carves"
 	"github.com/jmpsec/osctrl/pkg/environments"
 	"github.com/jmpsec/osctrl/pkg/nodes"
 	"github.com/jmpsec/osctrl/pkg/queries"
 	"github.com/jmpsec/osctrl/pkg/tags"
 	"github.com/jmpsec/osctrl/pkg/utils"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
 )
 
 type ProcessingQuery struct {
@@ -16,10 +22,28 @@ type ProcessingQuery struct {
 	UUIDs         []string
 	Hosts         []string
 	Tags          []string
+	Selector      string
 	EnvID         uint
 	InactiveHours int64
 }
 
+// TargetPreview to hold the nodes that would be targeted by an On-demand Query or Carve
+type TargetPreview struct {
+	Count int                 `json:"count"`
+	Nodes []TargetPreviewNode `json:"nodes"`
+}
+
+// TargetPreviewNode to hold the details of a node in a preview of targets
+type TargetPreviewNode struct {
+	UUID            string    `json:"uuid"`
+	Hostname        string    `json:"hostname"`
+	Localname       string    `json:"localname"`
+	Platform        string    `json:"platform"`
+	PlatformVersion string    `json:"platform_version"`
+	OsqueryVersion  string    `json:"osquery_version"`
+	LastSeen        time.Time `json:"last_seen"`
+}
+
 type Managers struct {
 	Envs  *environments.EnvManager
 	Nodes *nodes.NodeManager
@@ -30,6 +54,8 @@ type Managers struct {
 func CreateQueryCarve(data ProcessingQuery, manager Managers, newQuery queries.DistributedQuery) ([]uint, error) {
 	var expected []uint
 	targetNodesID := []uint{}
+	// Track if any target was used, since an empty list of nodes means no filter when intersecting
+	filtered := false
 	// Environments target
 	if len(data.Envs) > 0 {
 		expected = []uint{}
@@ -46,6 +72,7 @@ func CreateQueryCarve(data ProcessingQuery, manager Managers, newQuery queries.D
 			}
 		}
 		targetNodesID = utils.Intersect(targetNodesID, expected)
+		filtered = true
 	}
 	// Platforms target
 	if len(data.Platforms) > 0 {
@@ -63,6 +90,7 @@ func CreateQueryCarve(data ProcessingQuery, manager Managers, newQuery queries.D
 			}
 		}
 		targetNodesID = utils.Intersect(targetNodesID, expected)
+		filtered = true
 	}
 	// UUIDs target
 	if len(data.UUIDs) > 0 {
@@ -77,6 +105,7 @@ func CreateQueryCarve(data ProcessingQuery, manager Managers, newQuery queries.D
 			}
 		}
 		targetNodesID = utils.Intersect(targetNodesID, expected)
+		filtered = true
 	}
 	// Hostnames target
 	if len(data.Hosts) > 0 {
@@ -91,6 +120,7 @@ func CreateQueryCarve(data ProcessingQuery, manager Managers, newQuery queries.D
 			}
 		}
 		targetNodesID = utils.Intersect(targetNodesID, expected)
+		filtered = true
 	}
 	// Tags target
 	if len(data.Tags) > 0 {
@@ -110,6 +140,359 @@ func CreateQueryCarve(data ProcessingQuery, manager Managers, newQuery queries.D
 			}
 		}
 		targetNodesID = utils.Intersect(targetNodesID, expected)
+		filtered = true
+	}
+	// Selector target
+	if data.Selector != "" {
+		// Other targets matched nothing, the selector can only narrow them down
+		if filtered && len(targetNodesID) == 0 {
+			return []uint{}, nil
+		}
+		selected, err := SelectNodes(data.Selector, data.EnvID, data.InactiveHours, manager)
+		if err != nil {
+			return targetNodesID, err
+		}
+		// Nothing matched, the intersection with other targets is empty as well
+		if len(selected) == 0 {
+			return []uint{}, nil
+		}
+		expected = []uint{}
+		for _, n := range selected {
+			expected = append(expected, n.ID)
+		}
+		targetNodesID = utils.Intersect(targetNodesID, expected)
+	}
+	// Nodes pending approval do not get queries, so they are never targeted
+	approved, err := manager.Nodes.GetApprovedIDs(targetNodesID)
+	if err != nil {
+		return targetNodesID, fmt.Errorf("error getting approved nodes: %w", err)
+	}
+	return approved, nil
+}
+
+// SelectNodes - Get the active nodes in an environment matching a selector expression
+func SelectNodes(selector string, envID uint, inactiveHours int64, manager Managers) ([]nodes.OsqueryNode, error) {
+	var selected []nodes.OsqueryNode
+	s, err := nodes.ParseTargetSelector(selector)
+	if err != nil {
+		return selected, fmt.Errorf("invalid selector: %w", err)
+	}
+	// Get only the tags used in the selector
+	envTags := make(map[string]tags.AdminTag)
+	tagIDs := make(map[string][]uint)
+	for _, t := range s.Tags() {
+		if _, loaded := envTags[t]; loaded {
+			continue
+		}
+		exist, tag := manager.Tags.ExistsGet(tags.GetStrTagName(t), envID)
+		if !exist {
+			continue
+		}
+		envTags[t] = tag
+		tagIDs[strings.ToLower(t)] = append(tagIDs[strings.ToLower(t)], tag.ID)
+	}
+	// Platforms, versions and tags are filtered in the database
+	pushdown, err := s.Pushdown(func(field string) ([]string, error) {
+		return manager.Nodes.GetEnvIDFieldValues(envID, field)
+	}, tagIDs)
+	if err != nil {
+		return selected, fmt.Errorf("error filtering nodes: %w", err)
+	}
+	// Load the tagged nodes only for tags matched in memory
+	nodeTags := make(map[uint][]string)
+	for _, t := range pushdown.Tags() {
+		tag, exist := envTags[t]
+		if !exist {
+			continue
+		}
+		tagged, err := manager.Tags.GetTaggedNodes(tag)
+		if err != nil {
+			return selected, fmt.Errorf("error getting tagged nodes for tag %s: %w", t, err)
+		}
+		for _, tn := range tagged {
+			nodeTags[tn.NodeID] = append(nodeTags[tn.NodeID], t)
+		}
+	}
+	envNodes, err := manager.Nodes.GetByEnvIDSelector(envID, pushdown, nodes.ActiveNodes, inactiveHours)
+	if err != nil {
+		return selected, fmt.Errorf("error getting nodes by environment: %w", err)
+	}
+	now := time.Now()
+	for _, n := range envNodes {
+		if pushdown.Match(n, nodeTags[n.ID], now) {
+			selected = append(selected, n)
+		}
+	}
+	return selected, nil
+}
+
+// ValidateSelector - Check a selector expression before creating an On-demand Query or Carve, empty is valid
+func ValidateSelector(selector string) error {
+	if selector == "" {
+		return nil
+	}
+	if _, err := nodes.ParseTargetSelector(selector); err != nil {
+		return fmt.Errorf("invalid selector: %w", err)
+	}
+	return nil
+}
+
+// PreviewQueryCarve - Get the nodes that would be targeted by an On-demand Query or Carve, without creating it
+func PreviewQueryCarve(data ProcessingQuery, manager Managers) (TargetPreview, error) {
+	preview := TargetPreview{Nodes: []TargetPreviewNode{}}
+	targetNodesID, err := CreateQueryCarve(data, manager, queries.DistributedQuery{})
+	if err != nil {
+		return preview, err
+	}
+	targets, err := manager.Nodes.GetByIDs(targetNodesID)
+	if err != nil {
+		return preview, fmt.Errorf("error getting target nodes: %w", err)
+	}
+	for _, n := range targets {
+		preview.Nodes = append(preview.Nodes, TargetPreviewNode{
+			UUID:            n.UUID,
+			Hostname:        n.Hostname,
+			Localname:       n.Localname,
+			Platform:        n.Platform,
+			PlatformVersion: n.PlatformVersion,
+			OsqueryVersion:  n.OsqueryVersion,
+			LastSeen:        n.LastSeen,
+		})
+	}
+	preview.Count = len(preview.Nodes)
+	return preview, nil
+}
+
+// RunRecurringQuery - Create the distributed query for one run of a recurring query, to be used in osctrl-admin
+func RunRecurringQuery(rq queries.RecurringQuery, queriesmgr *queries.Queries, manager Managers, inactiveHours int64, now time.Time) (queries.RecurringQueryRun, error) {
+	run := queries.RecurringQueryRun{
+		RecurringQueryID: rq.ID,
+		QueryName:        queries.GenQueryName(),
+		RunAt:            now,
+	}
+	targets, err := rq.GetTargets()
+	if err != nil {
+		return run, err
+	}
+	expTime := time.Time{}
+	if rq.ExpHours > 0 {
+		expTime = now.Add(time.Duration(rq.ExpHours) * time.Hour)
+	}
+	newQuery := queries.DistributedQuery{
+		Query:         rq.Query,
+		Name:          run.QueryName,
+		Creator:       rq.Creator,
+		Active:        true,
+		Expiration:    expTime,
+		Hidden:        rq.Hidden,
+		Type:          queries.StandardQueryType,
+		EnvironmentID: rq.EnvironmentID,
+		ExtraData:     rq.Name,
+	}
+	data := ProcessingQuery{
+		Envs:          targets.Environments,
+		Platforms:     targets.Platforms,
+		UUIDs:         targets.UUIDs,
+		Hosts:         targets.Hosts,
+		Tags:          targets.Tags,
+		Selector:      targets.Selector,
+		EnvID:         rq.EnvironmentID,
+		InactiveHours: inactiveHours,
+	}
+	targetNodesID, err := CreateQueryCarve(data, manager, newQuery)
+	if err != nil {
+		return run, err
+	}
+	// Create the query and its node queries together, so failed runs do not leave queries behind
+	err = queriesmgr.DB.Transaction(func(tx *gorm.DB) error {
+		queriesTx := &queries.Queries{DB: tx}
+		if err := queriesTx.Create(&newQuery); err != nil {
+			return fmt.Errorf("error creating query: %w", err)
+		}
+		// If the list is empty, we don't need to create node queries
+		if len(targetNodesID) != 0 {
+			if err := queriesTx.CreateNodeQueries(targetNodesID, newQuery.ID); err != nil {
+				return fmt.Errorf("error creating node queries: %w", err)
+			}
+		}
+		if err := queriesTx.SetExpected(newQuery.Name, len(targetNodesID), rq.EnvironmentID); err != nil {
+			return fmt.Errorf("error setting expected: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return run, err
+	}
+	run.Expected = len(targetNodesID)
+	return run, nil
+}
+
+// RunDueRecurringQueries - Create runs for all the recurring queries due, keeping the history of runs
+func RunDueRecurringQueries(queriesmgr *queries.Queries, manager Managers, inactiveHours int64, now time.Time) error {
+	due, err := queriesmgr.GetDueRecurring(now)
+	if err != nil {
+		return fmt.Errorf("error getting recurring queries: %w", err)
+	}
+	for _, rq := range due {
+		claimed, err := queriesmgr.ClaimRecurring(rq, now)
+		if err != nil {
+			log.Err(err).Msgf("error scheduling recurring query %s", rq.Name)
+			continue
+		}
+		// Another service already created this run
+		if !claimed {
+			continue
+		}
+		run, err := RunRecurringQuery(rq, queriesmgr, manager, inactiveHours, now)
+		if err != nil {
+			log.Err(err).Msgf("error running recurring query %s", rq.Name)
+			run.Error = err.Error()
+		}
+		if err := queriesmgr.CreateRecurringRun(&run); err != nil {
+			log.Err(err).Msgf("error saving run for recurring query %s", rq.Name)
+		}
+	}
+	return nil
+}
+
+// ReissueCarve - Create a new carve for the node of a stale carve, using the original carve query when available
+func ReissueCarve(carve carves.CarvedFile, queriesmgr *queries.Queries, manager Managers, inactiveHours int64, now time.Time) (queries.DistributedQuery, error) {
+	newQuery := queries.DistributedQuery{
+		Query:         carves.GenCarveQuery(carve.Path, false),
+		Name:          carves.GenCarveName(),
+		Creator:       "osctrl",
+		Active:        true,
+		Type:          queries.CarveQueryType,
+		Path:          carve.Path,
+		EnvironmentID: carve.EnvironmentID,
+		ExtraData:     carve.QueryName,
+	}
+	if original, err := queriesmgr.Get(carve.QueryName, carve.EnvironmentID); err == nil && original.Query != "" {
+		newQuery.Query = original.Query
+		newQuery.Creator = original.Creator
+		if original.Path != "" {
+			newQuery.Path = original.Path
+		}
+		// Keep the same time to expire than the original carve
+		if !original.Expiration.IsZero() {
+			newQuery.Expiration = now.Add(original.Expiration.Sub(original.CreatedAt))
+		}
+	}
+	if err := queriesmgr.Create(&newQuery); err != nil {
+		return newQuery, fmt.Errorf("error creating query: %w", err)
+	}
+	data := ProcessingQuery{
+		UUIDs:         []string{carve.UUID},
+		EnvID:         carve.EnvironmentID,
+		InactiveHours: inactiveHours,
+	}
+	targetNodesID, err := CreateQueryCarve(data, manager, newQuery)
+	if err != nil {
+		return newQuery, err
+	}
+	if len(targetNodesID) != 0 {
+		if err := queriesmgr.CreateNodeQueries(targetNodesID, newQuery.ID); err != nil {
+			return newQuery, fmt.Errorf("error creating node queries: %w", err)
+		}
+	}
+	if err := queriesmgr.SetExpected(newQuery.Name, len(targetNodesID), carve.EnvironmentID); err != nil {
+		return newQuery, fmt.Errorf("error setting expected: %w", err)
+	}
+	return newQuery, nil
+}
+
+// ProcessStaleCarves - Mark as stale the carves of an environment without new blocks and re-issue them
+// if they have not been re-issued more than maxReissues times, returning the carves marked as stale
+func ProcessStaleCarves(carvesmgr *carves.Carves, queriesmgr *queries.Queries, manager Managers, envID uint, idle time.Duration, maxReissues, inactiveHours int64, now time.Time) ([]carves.CarvedFile, error) {
+	var res []carves.CarvedFile
+	stale, err := carvesmgr.GetStale(envID, idle)
+	if err != nil {
+		return res, fmt.Errorf("error getting stale carves: %w", err)
+	}
+	for _, c := range stale {
+		c.StatusReason = fmt.Sprintf("no blocks received since %s, %d of %d blocks completed", c.UpdatedAt.Format(time.RFC3339), c.CompletedBlocks, c.TotalBlocks)
+		if err := carvesmgr.MarkStale(c, c.StatusReason); err != nil {
+			log.Err(err).Msgf("error marking carve %s as stale", c.CarveID)
+			continue
+		}
+		c.Status = carves.StatusStale
+		reissues := carvesmgr.PreviousReissues(c)
+		if int64(reissues) < maxReissues {
+			newQuery, err := ReissueCarve(c, queriesmgr, manager, inactiveHours, now)
+			if err != nil {
+				log.Err(err).Msgf("error re-issuing carve %s", c.CarveID)
+			} else if err := carvesmgr.SetReissued(c, newQuery.Name, reissues+1); err != nil {
+				log.Err(err).Msgf("error updating re-issued carve %s", c.CarveID)
+			} else {
+				c.ReissueQuery = newQuery.Name
+				c.Reissues = reissues + 1
+			}
+		}
+		res = append(res, c)
+	}
+	return res, nil
+}
+
+// MergeNodes - Merge duplicated nodes into the target node, moving tags, on-demand queries and carves,
+// to be used in osctrl-tls, osctrl-api and osctrl-cli. The duplicated nodes are archived and all the
+// changes are done in a single transaction, so a failure does not leave nodes partially merged.
+func MergeNodes(manager Managers, queriesmgr *queries.Queries, carvesmgr *carves.Carves, target nodes.OsqueryNode, duplicates []nodes.OsqueryNode) error {
+	var merged []nodes.OsqueryNode
+	err := manager.Nodes.DB.Transaction(func(tx *gorm.DB) error {
+		nodesTx := &nodes.NodeManager{DB: tx}
+		tagsTx := &tags.TagManager{DB: tx}
+		queriesTx := &queries.Queries{DB: tx}
+		var carvesTx *carves.Carves
+		if carvesmgr != nil {
+			c := *carvesmgr
+			c.DB = tx
+			carvesTx = &c
+		}
+		for _, d := range duplicates {
+			if d.ID == target.ID {
+				continue
+			}
+			if d.EnvironmentID != target.EnvironmentID {
+				return fmt.Errorf("node %d is not in the same environment as %d", d.ID, target.ID)
+			}
+			if err := tagsTx.MoveNodeTags(d.ID, target.ID); err != nil {
+				return fmt.Errorf("error moving tags of %s: %w", d.UUID, err)
+			}
+			if err := queriesTx.MoveNodeQueries(d.ID, target.ID); err != nil {
+				return fmt.Errorf("error moving queries of %s: %w", d.UUID, err)
+			}
+			if carvesTx != nil {
+				if err := carvesTx.MoveNodeCarves(d.ID, target.ID, target.UUID); err != nil {
+					return fmt.Errorf("error moving carves of %s: %w", d.UUID, err)
+				}
+			}
+			if err := nodesTx.MergeInto(target, d); err != nil {
+				return fmt.Errorf("error archiving %s: %w", d.UUID, err)
+			}
+			target.BytesReceived += d.BytesReceived
+			merged = append(merged, d)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Cached nodes are evicted once the changes are committed
+	for _, d := range merged {
+		cache.PublishInvalidation(manager.Nodes.Bus, cache.TopicNodes, d.NodeKey)
+	}
+	return nil
+}
+
+// RevokeNodeCert - Revoke the client certificates issued to a node and unbind them from the node,
+// to be used in osctrl-admin, osctrl-api and osctrl-cli. Returns the number of revoked certificates.
+func RevokeNodeCert(manager Managers, node nodes.OsqueryNode, revokedBy string) (int64, error) {
+	revoked, err := manager.Envs.RevokeClientCerts(node.EnvironmentID, node.UUID, revokedBy)
+	if err != nil {
+		return 0, fmt.Errorf("error revoking certificates of %s: %w", node.UUID, err)
+	}
+	if err := manager.Nodes.UnbindCert(node); err != nil {
+		return revoked, fmt.Errorf("error unbinding certificate of %s: %w", node.UUID, err)
 	}
-	return targetNodesID, nil
+	return revoked, nil
 }
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmpsec/osctrl/pkg/environments"
+	"github.com/jmpsec/osctrl/pkg/nodes"
+	"github.com/jmpsec/osctrl/pkg/queries"
+	"github.com/jmpsec/osctrl/pkg/tags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// testManagers creates the managers with an in-memory SQLite database for testing
+func testManagers(t *testing.T) (Managers, *queries.Queries) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&environments.TLSEnvironment{}, &nodes.OsqueryNode{}, &tags.AdminTag{}, &tags.TaggedNode{}, &queries.DistributedQuery{}, &queries.NodeQuery{}))
+	manager := Managers{
+		Envs:  environments.CreateEnvironment(db),
+		Nodes: nodes.CreateNodes(db),
+		Tags:  tags.CreateTagManager(db),
+	}
+	t.Cleanup(manager.Nodes.Cache.Close)
+	return manager, queries.CreateQueries(db)
+}
+
+func TestRunRecurringQueryPendingNodes(t *testing.T) {
+	manager, q := testManagers(t)
+	now := time.Now()
+	for _, n := range []nodes.OsqueryNode{
+		{UUID: "UUID-1", NodeKey: "k1", Environment: "dev", EnvironmentID: 1, Platform: "ubuntu", LastSeen: now},
+		{UUID: "UUID-2", NodeKey: "k2", Environment: "dev", EnvironmentID: 1, Platform: "ubuntu", LastSeen: now, Pending: true},
+	} {
+		require.NoError(t, manager.Nodes.DB.Create(&n).Error)
+	}
+	env := manager.Envs.Empty("dev", "localhost")
+	require.NoError(t, manager.Envs.Create(&env))
+
+	rq := queries.RecurringQuery{Model: gorm.Model{ID: 1}, Name: "recurring", Query: "SELECT 1;", EnvironmentID: 1}
+	require.NoError(t, rq.SetTargets(queries.RecurringQueryTargets{Environments: []string{"dev"}}))
+	run, err := RunRecurringQuery(rq, q, manager, 24, now)
+	require.NoError(t, err)
+	// The node pending approval is not expected to run the query
+	assert.Equal(t, 1, run.Expected)
+	query, err := q.Get(run.QueryName, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, query.Expected)
+	targets, err := q.GetNodeQueriesPage(query.ID, queries.NodeQueryFilter{}, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, targets, 1)
+	assert.Equal(t, "UUID-1", targets[0].UUID)
+
+	// Including the preview of targets
+	preview, err := PreviewQueryCarve(ProcessingQuery{Platforms: []string{"ubuntu"}, EnvID: 1, InactiveHours: 24}, manager)
+	require.NoError(t, err)
+	assert.Equal(t, 1, preview.Count)
+}
+
+func TestCreateQueryCarveSelectorNoMatch(t *testing.T) {
+	manager, _ := testManagers(t)
+	now := time.Now()
+	testNodes := []nodes.OsqueryNode{
+		{UUID: "UUID-1", NodeKey: "k1", Environment: "dev", EnvironmentID: 1, Platform: "ubuntu", LastSeen: now},
+		{UUID: "UUID-2", NodeKey: "k2", Environment: "dev", EnvironmentID: 1, Platform: "darwin", LastSeen: now},
+	}
+	for i := range testNodes {
+		require.NoError(t, manager.Nodes.DB.Create(&testNodes[i]).Error)
+		require.NoError(t, manager.Tags.TagNode("prod", testNodes[i], "test", false, tags.TagTypeCustom, ""))
+	}
+
+	tests := []struct {
+		name     string
+		data     ProcessingQuery
+		expected []uint
+	}{
+		{"selector only", ProcessingQuery{Selector: "tag=prod", EnvID: 1, InactiveHours: 24}, []uint{1, 2}},
+		{"selector narrows platform", ProcessingQuery{Platforms: []string{"ubuntu"}, Selector: "tag=prod", EnvID: 1, InactiveHours: 24}, []uint{1}},
+		{"platform without nodes", ProcessingQuery{Platforms: []string{"windows"}, Selector: "tag=prod", EnvID: 1, InactiveHours: 24}, []uint{}},
+		{"tag without nodes", ProcessingQuery{Tags: []string{"staging"}, Selector: "tag=prod", EnvID: 1, InactiveHours: 24}, []uint{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, err := CreateQueryCarve(tt.data, manager, queries.DistributedQuery{})
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.expected, targets)
+		})
+	}
+}
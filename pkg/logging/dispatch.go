@@ -15,9 +15,8 @@ func (l *LoggerTLS) DispatchLogs(data []byte, uuid, logType, environment string,
 		log.Err(err).Msg("error updating metadata")
 	}
 	// Send data to storage
-	// FIXME allow multiple types of logging
 	if debug {
-		log.Debug().Msgf("dispatching logs to %s", l.Logging)
+		log.Debug().Msgf("dispatching logs to sinks %s", l.Logging)
 	}
 	l.Log(logType, data, environment, uuid, debug)
 }
@@ -30,9 +29,8 @@ func (l *LoggerTLS) DispatchQueries(queryData types.QueryWriteData, node nodes.O
 		log.Err(err).Msg("error preparing data")
 	}
 	// Send data to storage
-	// FIXME allow multiple types of logging
 	if debug {
-		log.Debug().Msgf("dispatching queries to %s", l.Logging)
+		log.Debug().Msgf("dispatching queries to sinks %s", l.Logging)
 	}
 	l.QueryLog(
 		types.QueryLog,
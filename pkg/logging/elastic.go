@@ -99,6 +99,13 @@ func (logE *LoggerElastic) Settings(mgr *settings.Settings) {
 
 // Send - Function that sends JSON logs to Elastic
 func (logE *LoggerElastic) Send(logType string, data []byte, environment, uuid string, debug bool) {
+	if err := logE.SendLog(logType, data, environment, uuid, debug); err != nil {
+		log.Err(err).Msg("Error indexing document")
+	}
+}
+
+// SendLog - Function that sends JSON logs to Elastic and returns delivery errors
+func (logE *LoggerElastic) SendLog(logType string, data []byte, environment, uuid string, debug bool) error {
 	if debug {
 		log.Debug().Msgf("Send %s to Elastic", logType)
 	}
@@ -132,14 +139,27 @@ func (logE *LoggerElastic) Send(logType string, data []byte, environment, uuid s
 		}
 		res, err := req.Do(context.Background(), logE.Client)
 		if err != nil {
-			log.Err(err).Msg("Error indexing document")
+			return fmt.Errorf("error indexing document - %w", err)
 		}
-		defer res.Body.Close()
 		if res.IsError() {
-			log.Error().Msgf("Error response from Elasticsearch: %s", res.String())
+			err := fmt.Errorf("error response from Elasticsearch: %s", res.String())
+			res.Body.Close()
+			return err
 		}
+		res.Body.Close()
 	}
 	if debug {
 		log.Debug().Msgf("Sent %d bytes of %s to Elastic from %s:%s", len(data), logType, uuid, environment)
 	}
+	return nil
+}
+
+// Log - Function that sends JSON result/status logs to Elastic
+func (logE *LoggerElastic) Log(logType string, data []byte, environment, uuid string, debug bool) {
+	logE.Send(logType, data, environment, uuid, debug)
+}
+
+// Query - Function that sends JSON query logs to Elastic
+func (logE *LoggerElastic) Query(data []byte, environment, uuid, name string, status int, debug bool) {
+	logE.Send(types.QueryLog, data, environment, uuid, debug)
 }
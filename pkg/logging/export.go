@@ -0,0 +1,177 @@
+package logging
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/jmpsec/osctrl/pkg/nodes"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const (
+	// ExportCSV to export query results as CSV
+	ExportCSV = "csv"
+	// ExportNDJSON to export query results as newline delimited JSON
+	ExportNDJSON = "ndjson"
+	// ExportParquet to export query results as Parquet
+	ExportParquet = "parquet"
+	// ExportColumnUUID is the column with the node UUID in each exported row
+	ExportColumnUUID = "node_uuid"
+	// ExportColumnHostname is the column with the node hostname in each exported row
+	ExportColumnHostname = "node_hostname"
+	// Number of query logs read from the database at once
+	exportBatchSize = 100
+	// Size of each Parquet row group kept in memory before flushing
+	exportRowGroupSize = 8 * 1024 * 1024
+)
+
+// ExportContentTypes with the content type for each supported export format
+var ExportContentTypes = map[string]string{
+	ExportCSV:     "text/csv",
+	ExportNDJSON:  "application/x-ndjson",
+	ExportParquet: "application/vnd.apache.parquet",
+}
+
+// exportWriter to write rows of values in one export format
+type exportWriter interface {
+	Write(values []string) error
+	Close() error
+}
+
+// csvExport to write rows as CSV, with a header
+type csvExport struct {
+	w *csv.Writer
+}
+
+func (e *csvExport) Write(values []string) error {
+	return e.w.Write(values)
+}
+
+func (e *csvExport) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// ndjsonExport to write rows as one JSON object per line
+type ndjsonExport struct {
+	enc     *json.Encoder
+	columns []string
+}
+
+func (e *ndjsonExport) Write(values []string) error {
+	obj := make(map[string]string, len(e.columns))
+	for i, c := range e.columns {
+		obj[c] = values[i]
+	}
+	return e.enc.Encode(obj)
+}
+
+func (e *ndjsonExport) Close() error {
+	return nil
+}
+
+// Helper to create the writer for an export format
+func newExportWriter(w io.Writer, format string, columns []string) (exportWriter, error) {
+	switch format {
+	case ExportCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(columns); err != nil {
+			return nil, err
+		}
+		return &csvExport{w: cw}, nil
+	case ExportNDJSON:
+		return &ndjsonExport{enc: json.NewEncoder(w), columns: columns}, nil
+	case ExportParquet:
+		return newParquetWriter(w, columns, exportRowGroupSize)
+	}
+	return nil, fmt.Errorf("unsupported export format %s", format)
+}
+
+// Helper to iterate through the logs of a query in batches
+func eachQueryLog(logsDB *gorm.DB, name string, fn func(OsqueryQueryData) error) error {
+	var batch []OsqueryQueryData
+	var fnErr error
+	res := logsDB.Where("name = ?", name).FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+		for _, l := range batch {
+			if fnErr = fn(l); fnErr != nil {
+				return fnErr
+			}
+		}
+		return nil
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	return res.Error
+}
+
+// ExportQueryResults - Function to stream the results of a distributed query, with one row per result row
+// and the node UUID and hostname columns. Logs are read in batches twice, first to collect the columns
+// and then to write the rows, so the full result set is never loaded in memory.
+func ExportQueryResults(w io.Writer, format string, logsDB *gorm.DB, nodesmgr *nodes.NodeManager, name string) error {
+	if _, ok := ExportContentTypes[format]; !ok {
+		return fmt.Errorf("unsupported export format %s", format)
+	}
+	// First pass to get all the result columns
+	seen := map[string]bool{ExportColumnUUID: true, ExportColumnHostname: true}
+	var resultCols []string
+	if err := eachQueryLog(logsDB, name, func(l OsqueryQueryData) error {
+		rows, _, err := FlattenQueryData(l.Data)
+		if err != nil {
+			return nil
+		}
+		for _, r := range rows {
+			for k := range r {
+				if !seen[k] {
+					seen[k] = true
+					resultCols = append(resultCols, k)
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("error reading query logs - %w", err)
+	}
+	sort.Strings(resultCols)
+	columns := append([]string{ExportColumnUUID, ExportColumnHostname}, resultCols...)
+	ew, err := newExportWriter(w, format, columns)
+	if err != nil {
+		return err
+	}
+	// Second pass to write the rows, with hostnames cached by node
+	hostnames := make(map[string]string)
+	if err := eachQueryLog(logsDB, name, func(l OsqueryQueryData) error {
+		rows, _, err := FlattenQueryData(l.Data)
+		if err != nil {
+			log.Debug().Msgf("skipping query log %d - %v", l.ID, err)
+			return nil
+		}
+		hostname, ok := hostnames[l.UUID]
+		if !ok && nodesmgr != nil {
+			if node, err := nodesmgr.GetByUUID(l.UUID); err == nil {
+				hostname = node.Hostname
+			}
+			hostnames[l.UUID] = hostname
+		}
+		for _, r := range rows {
+			values := make([]string, len(columns))
+			values[0] = strings.ToUpper(l.UUID)
+			values[1] = hostname
+			for i, c := range resultCols {
+				values[i+2] = r[c]
+			}
+			if err := ew.Write(values); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("error exporting query logs - %w", err)
+	}
+	return ew.Close()
+}
@@ -0,0 +1,252 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/jmpsec/osctrl/pkg/nodes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func testExportDB(t *testing.T) (*gorm.DB, *nodes.NodeManager) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&nodes.OsqueryNode{}))
+	nodesmgr := nodes.CreateNodes(db)
+	require.NoError(t, db.AutoMigrate(&OsqueryQueryData{}))
+	require.NoError(t, db.Create(&nodes.OsqueryNode{UUID: "UUID-ALPHA", Hostname: "alpha"}).Error)
+	require.NoError(t, db.Create(&OsqueryQueryData{UUID: "UUID-ALPHA", Name: "q", Data: `{"name":"q","result":[{"pid":"1","name":"launchd"},{"pid":"2","name":"with,comma"}],"status":0}`}).Error)
+	require.NoError(t, db.Create(&OsqueryQueryData{UUID: "UUID-BRAVO", Name: "q", Data: `{"name":"q","result":[{"pid":"3","path":"/bin/sh"}],"status":0}`}).Error)
+	require.NoError(t, db.Create(&OsqueryQueryData{UUID: "UUID-BRAVO", Name: "other", Data: `{"name":"other","result":[{"x":"y"}],"status":0}`}).Error)
+	return db, nodesmgr
+}
+
+func TestExportQueryResultsCSV(t *testing.T) {
+	db, nodesmgr := testExportDB(t)
+	var buf bytes.Buffer
+	require.NoError(t, ExportQueryResults(&buf, ExportCSV, db, nodesmgr, "q"))
+	expected := "node_uuid,node_hostname,name,path,pid\n" +
+		"UUID-ALPHA,alpha,launchd,,1\n" +
+		"UUID-ALPHA,alpha,\"with,comma\",,2\n" +
+		"UUID-BRAVO,,,/bin/sh,3\n"
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestExportQueryResultsNDJSON(t *testing.T) {
+	db, nodesmgr := testExportDB(t)
+	var buf bytes.Buffer
+	require.NoError(t, ExportQueryResults(&buf, ExportNDJSON, db, nodesmgr, "q"))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.JSONEq(t, `{"node_uuid":"UUID-ALPHA","node_hostname":"alpha","name":"launchd","path":"","pid":"1"}`, lines[0])
+}
+
+func TestExportQueryResultsParquet(t *testing.T) {
+	db, nodesmgr := testExportDB(t)
+	var buf bytes.Buffer
+	require.NoError(t, ExportQueryResults(&buf, ExportParquet, db, nodesmgr, "q"))
+	raw := buf.Bytes()
+	require.Greater(t, len(raw), 12)
+	assert.Equal(t, parquetMagic, string(raw[:4]))
+	assert.Equal(t, parquetMagic, string(raw[len(raw)-4:]))
+	footer := int(binary.LittleEndian.Uint32(raw[len(raw)-8 : len(raw)-4]))
+	require.Less(t, footer, len(raw)-12)
+	meta := raw[len(raw)-8-footer : len(raw)-8]
+	for _, c := range []string{"schema", ExportColumnUUID, ExportColumnHostname, "name", "path", "pid"} {
+		assert.True(t, bytes.Contains(meta, []byte(c)), c)
+	}
+	assert.True(t, bytes.Contains(raw, []byte("/bin/sh")))
+	columns, rows := readParquet(t, raw)
+	assert.Equal(t, []string{ExportColumnUUID, ExportColumnHostname, "name", "path", "pid"}, columns)
+	assert.Equal(t, [][]string{
+		{"UUID-ALPHA", "alpha", "launchd", "", "1"},
+		{"UUID-ALPHA", "alpha", "with,comma", "", "2"},
+		{"UUID-BRAVO", "", "", "/bin/sh", "3"},
+	}, rows)
+}
+
+func TestExportQueryResultsInvalidFormat(t *testing.T) {
+	db, nodesmgr := testExportDB(t)
+	var buf bytes.Buffer
+	assert.Error(t, ExportQueryResults(&buf, "xlsx", db, nodesmgr, "q"))
+	assert.Zero(t, buf.Len())
+}
+
+func TestParquetWriterRowGroups(t *testing.T) {
+	var buf bytes.Buffer
+	pw, err := newParquetWriter(&buf, []string{"a", "b"}, 16)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, pw.Write([]string{"value", "other"}))
+	}
+	assert.Error(t, pw.Write([]string{"missing"}))
+	require.NoError(t, pw.Close())
+	assert.Len(t, pw.rowGroups, 5)
+	assert.Equal(t, int64(5), pw.totalRows)
+}
+
+func TestParquetWriterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	pw, err := newParquetWriter(&buf, []string{"id", "value"}, 32)
+	require.NoError(t, err)
+	var expected [][]string
+	for i := 0; i < 20; i++ {
+		row := []string{strings.Repeat("x", i), "válüe ✓"}
+		if i%3 == 0 {
+			row[1] = ""
+		}
+		expected = append(expected, row)
+		require.NoError(t, pw.Write(row))
+	}
+	require.NoError(t, pw.Close())
+	require.Greater(t, len(pw.rowGroups), 1)
+	columns, rows := readParquet(t, buf.Bytes())
+	assert.Equal(t, []string{"id", "value"}, columns)
+	assert.Equal(t, expected, rows)
+}
+
+// Helper to read back a Parquet file of string columns, decoding the metadata and the pages
+// from the format definitions without using the writer code
+func readParquet(t *testing.T, raw []byte) ([]string, [][]string) {
+	t.Helper()
+	require.Greater(t, len(raw), 12)
+	require.Equal(t, parquetMagic, string(raw[:4]))
+	require.Equal(t, parquetMagic, string(raw[len(raw)-4:]))
+	size := int(binary.LittleEndian.Uint32(raw[len(raw)-8 : len(raw)-4]))
+	require.LessOrEqual(t, size, len(raw)-12)
+	footer := &thriftReader{t: t, data: raw[len(raw)-8-size : len(raw)-8]}
+	meta := footer.readStruct()
+	// FileMetaData: 2 schema, 3 num_rows, 4 row_groups
+	var columns []string
+	schema := meta[2].([]interface{})
+	for _, e := range schema[1:] {
+		element := e.(map[int16]interface{})
+		require.Equal(t, int64(parquetTypeByteArray), element[1], "column type")
+		columns = append(columns, element[4].(string))
+	}
+	var rows [][]string
+	for _, g := range meta[4].([]interface{}) {
+		// RowGroup: 1 columns, 3 num_rows
+		rg := g.(map[int16]interface{})
+		chunks := rg[1].([]interface{})
+		require.Len(t, chunks, len(columns))
+		numRows := int(rg[3].(int64))
+		group := make([][]string, numRows)
+		for i := range group {
+			group[i] = make([]string, len(columns))
+		}
+		for c, ch := range chunks {
+			// ColumnChunk: 3 meta_data, ColumnMetaData: 4 codec, 5 num_values, 9 data_page_offset
+			md := ch.(map[int16]interface{})[3].(map[int16]interface{})
+			require.Equal(t, int64(parquetCodecNone), md[4], "codec")
+			require.Equal(t, int64(numRows), md[5], "values")
+			page := &thriftReader{t: t, data: raw[md[9].(int64):]}
+			// PageHeader: 1 type, 3 compressed_page_size, 5 data_page_header with 1 num_values and 2 encoding
+			header := page.readStruct()
+			require.Equal(t, int64(parquetPageTypeData), header[1], "page type")
+			dph := header[5].(map[int16]interface{})
+			require.Equal(t, int64(numRows), dph[1], "page values")
+			require.Equal(t, int64(parquetEncodingPlain), dph[2], "page encoding")
+			data := page.data[page.pos : page.pos+int(header[3].(int64))]
+			for r := 0; r < numRows; r++ {
+				require.GreaterOrEqual(t, len(data), 4)
+				l := int(binary.LittleEndian.Uint32(data[:4]))
+				group[r][c] = string(data[4 : 4+l])
+				data = data[4+l:]
+			}
+			assert.Empty(t, data)
+		}
+		rows = append(rows, group...)
+	}
+	require.Equal(t, int64(len(rows)), meta[3], "rows")
+	return columns, rows
+}
+
+// thriftReader to decode Thrift compact protocol structs as maps of field IDs to values
+type thriftReader struct {
+	t    *testing.T
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) readByte() byte {
+	if r.pos >= len(r.data) {
+		r.t.Fatalf("unexpected end of thrift data")
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.t.Fatalf("invalid thrift varint at %d", r.pos)
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) readValue(typ byte) interface{} {
+	switch typ {
+	case 1, 2:
+		return typ == 1
+	case 3:
+		return int64(int8(r.readByte()))
+	case 4, thriftI32, thriftI64:
+		return r.zigzag()
+	case 7:
+		v := binary.LittleEndian.Uint64(r.data[r.pos : r.pos+8])
+		r.pos += 8
+		return math.Float64frombits(v)
+	case thriftBinary:
+		n := int(r.varint())
+		s := string(r.data[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case thriftList, 10:
+		h := r.readByte()
+		size := int(h >> 4)
+		if size == 15 {
+			size = int(r.varint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.readValue(h & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	r.t.Fatalf("unsupported thrift type %d", typ)
+	return nil
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var last int16
+	for {
+		h := r.readByte()
+		if h == 0 {
+			return fields
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.readValue(h & 0x0f)
+		last = id
+	}
+}
@@ -95,6 +95,13 @@ func (logGL *LoggerGraylog) Settings(mgr *settings.Settings) {
 
 // Send - Function that sends JSON logs to Graylog
 func (logGL *LoggerGraylog) Send(logType string, data []byte, environment, uuid string, debug bool) {
+	if err := logGL.SendLog(logType, data, environment, uuid, debug); err != nil {
+		log.Err(err).Msg("error sending request")
+	}
+}
+
+// SendLog - Function that sends JSON logs to Graylog and returns delivery errors
+func (logGL *LoggerGraylog) SendLog(logType string, data []byte, environment, uuid string, debug bool) error {
 	if debug {
 		log.Debug().Msgf("Send %s via graylog", logType)
 	}
@@ -135,6 +142,7 @@ func (logGL *LoggerGraylog) Send(logType string, data []byte, environment, uuid
 		jsonMessage, err := json.Marshal(messsageData)
 		if err != nil {
 			log.Err(err).Msg("error marshaling data")
+			continue
 		}
 		jsonParam := bytes.NewReader(jsonMessage)
 		if debug {
@@ -143,11 +151,24 @@ func (logGL *LoggerGraylog) Send(logType string, data []byte, environment, uuid
 		// Send log with a POST to the Graylog URL
 		resp, body, err := utils.SendRequest(GraylogMethod, logGL.Configuration.URL, jsonParam, logGL.Headers)
 		if err != nil {
-			log.Err(err).Msg("error sending request")
-			return
+			return fmt.Errorf("error sending request to Graylog - %w", err)
 		}
 		if debug {
 			log.Debug().Msgf("HTTP %d %s", resp, body)
 		}
+		if err := checkResponseCode(resp, body); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// Log - Function that sends JSON result/status logs to Graylog
+func (logGL *LoggerGraylog) Log(logType string, data []byte, environment, uuid string, debug bool) {
+	logGL.Send(logType, data, environment, uuid, debug)
+}
+
+// Query - Function that sends JSON query logs to Graylog
+func (logGL *LoggerGraylog) Query(data []byte, environment, uuid, name string, status int, debug bool) {
+	logGL.Send(types.QueryLog, data, environment, uuid, debug)
 }
@@ -152,3 +152,13 @@ func (l *LoggerKafka) Send(logType string, data []byte, environment, uuid string
 			len(records), logType, l.config.Topic, uuid, environment)
 	}
 }
+
+// Log - Function that sends JSON result/status logs to Kafka
+func (l *LoggerKafka) Log(logType string, data []byte, environment, uuid string, debug bool) {
+	l.Send(logType, data, environment, uuid, debug)
+}
+
+// Query - Function that sends JSON query logs to Kafka
+func (l *LoggerKafka) Query(data []byte, environment, uuid, name string, status int, debug bool) {
+	l.Send(types.QueryLog, data, environment, uuid, debug)
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/jmpsec/osctrl/pkg/config"
 	"github.com/jmpsec/osctrl/pkg/settings"
+	"github.com/jmpsec/osctrl/pkg/types"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 
@@ -102,3 +103,13 @@ func (logSK *LoggerKinesis) Send(logType string, data []byte, environment, uuid
 		log.Debug().Msgf("PutRecordOutput %s", putOutput.String())
 	}
 }
+
+// Log - Function that sends JSON result/status logs to Kinesis
+func (logSK *LoggerKinesis) Log(logType string, data []byte, environment, uuid string, debug bool) {
+	logSK.Send(logType, data, environment, uuid, debug)
+}
+
+// Query - Function that sends JSON query logs to Kinesis
+func (logSK *LoggerKinesis) Query(data []byte, environment, uuid, name string, status int, debug bool) {
+	logSK.Send(types.QueryLog, data, environment, uuid, debug)
+}
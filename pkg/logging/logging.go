@@ -1,6 +1,10 @@
 package logging
 
 import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
 	"github.com/jmpsec/osctrl/pkg/config"
 	"github.com/jmpsec/osctrl/pkg/nodes"
 	"github.com/jmpsec/osctrl/pkg/queries"
@@ -14,60 +18,77 @@ const (
 	DefaultFileLog = "osctrl.log"
 )
 
+// Logger - Interface to be implemented by all the logging methods
+type Logger interface {
+	// Settings - Function to prepare settings for the logger
+	Settings(mgr *settings.Settings)
+	// Log - Function to send status/result logs
+	Log(logType string, data []byte, environment, uuid string, debug bool)
+	// Query - Function to send on-demand query logs
+	Query(data []byte, environment, uuid, name string, status int, debug bool)
+}
+
 // LoggerTLS will be used to handle logging for the TLS endpoint
 type LoggerTLS struct {
-	Logging      string
-	Logger       interface{}
-	AlwaysLogger *LoggerDB
-	Nodes        *nodes.NodeManager
-	Queries      *queries.Queries
+	Logging string
+	Sinks   []*LoggerSink
+	Nodes   *nodes.NodeManager
+	Queries *queries.Queries
 }
 
 // CreateLoggerTLS to instantiate a new logger for the TLS endpoint
 func CreateLoggerTLS(cfg config.ServiceFlagParams, mgr *settings.Settings, nodes *nodes.NodeManager, queries *queries.Queries) (*LoggerTLS, error) {
 	l := &LoggerTLS{
-		Logging: cfg.ConfigValues.Logger,
 		Nodes:   nodes,
 		Queries: queries,
 	}
-	switch cfg.ConfigValues.Logger {
-	case config.LoggingSplunk:
-		s, err := CreateLoggerSplunk(cfg.LoggerFile)
+	// Load the ordered list of sinks, or build it from the single logger values
+	var sinksCfg []SinkConfiguration
+	if cfg.LoggerSinks {
+		var err error
+		sinksCfg, err = LoadSinks(cfg.LoggerFile)
 		if err != nil {
 			return nil, err
 		}
-		s.Settings(mgr)
-		l.Logger = s
-	case config.LoggingGraylog:
-		g, err := CreateLoggerGraylog(cfg.LoggerFile)
+	} else {
+		sinksCfg = legacySinks(cfg)
+	}
+	for i, s := range sinksCfg {
+		sink, err := CreateLoggerSink(s, cfg, mgr)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("error creating sink %s - %w", s.Type, err)
 		}
-		g.Settings(mgr)
-		l.Logger = g
-	case config.LoggingDB:
-		if cfg.LoggerDBSame {
-			d, err := CreateLoggerDBConfig(cfg.DBConfigValues)
+		// Spool logs to disk for sinks able to report delivery errors
+		if sender, ok := sink.Logger.(LogSender); ok && cfg.SpoolConfig.Dir != "" {
+			name := fmt.Sprintf("%d-%s", i, s.Type)
+			spool, err := NewSpool(name, filepath.Join(cfg.SpoolConfig.Dir, name), cfg.SpoolConfig.MaxSegmentSize, cfg.SpoolConfig.MaxSize)
 			if err != nil {
-				return nil, err
+				return nil, fmt.Errorf("error creating spool for sink %s - %w", s.Type, err)
 			}
-			d.Settings(mgr)
-			l.Logger = d
-		} else {
-			d, err := CreateLoggerDBFile(cfg.LoggerFile)
-			if err != nil {
-				return nil, err
+			if cfg.SpoolConfig.MaxBackoff > 0 {
+				spool.MaxBackoff = cfg.SpoolConfig.MaxBackoff
 			}
-			d.Settings(mgr)
-			l.Logger = d
+			sink.Logger = NewLoggerSpool(sender, spool)
 		}
-	case config.LoggingStdout:
-		d, err := CreateLoggerStdout()
-		if err != nil {
-			return nil, err
+		l.AddSink(sink)
+	}
+	return l, nil
+}
+
+// CreateLogger to instantiate a logger by type, using the configuration from the service flags
+func CreateLogger(loggerType string, cfg config.ServiceFlagParams) (Logger, error) {
+	switch loggerType {
+	case config.LoggingSplunk:
+		return CreateLoggerSplunk(cfg.LoggerFile)
+	case config.LoggingGraylog:
+		return CreateLoggerGraylog(cfg.LoggerFile)
+	case config.LoggingDB:
+		if cfg.LoggerDBSame {
+			return CreateLoggerDBConfig(cfg.DBConfigValues)
 		}
-		d.Settings(mgr)
-		l.Logger = d
+		return CreateLoggerDBFile(cfg.LoggerFile)
+	case config.LoggingStdout:
+		return CreateLoggerStdout()
 	case config.LoggingFile:
 		// TODO: All this should be customizable
 		rotateCfg := LumberjackConfig{
@@ -76,264 +97,58 @@ func CreateLoggerTLS(cfg config.ServiceFlagParams, mgr *settings.Settings, nodes
 			MaxAge:     10,
 			Compress:   true,
 		}
-		d, err := CreateLoggerFile(DefaultFileLog, rotateCfg)
-		if err != nil {
-			return nil, err
-		}
-		d.Settings(mgr)
-		l.Logger = d
+		return CreateLoggerFile(DefaultFileLog, rotateCfg)
 	case config.LoggingNone:
-		d, err := CreateLoggerNone()
-		if err != nil {
-			return nil, err
-		}
-		d.Settings(mgr)
-		l.Logger = d
+		return CreateLoggerNone()
 	case config.LoggingKinesis:
-		d, err := CreateLoggerKinesis(cfg.LoggerFile)
-		if err != nil {
-			return nil, err
-		}
-		d.Settings(mgr)
-		l.Logger = d
+		return CreateLoggerKinesis(cfg.LoggerFile)
 	case config.LoggingS3:
-		var d *LoggerS3
-		var err error
 		if cfg.S3LogConfig.Bucket != "" {
-			d, err = CreateLoggerS3(cfg.S3LogConfig)
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			d, err = CreateLoggerS3File(cfg.LoggerFile)
-			if err != nil {
-				return nil, err
-			}
+			return CreateLoggerS3(cfg.S3LogConfig)
 		}
-		d.Settings(mgr)
-		l.Logger = d
+		return CreateLoggerS3File(cfg.LoggerFile)
 	case config.LoggingLogstash:
-		d, err := CreateLoggerLogstash(cfg.LoggerFile)
-		if err != nil {
-			return nil, err
-		}
-		d.Settings(mgr)
-		l.Logger = d
+		return CreateLoggerLogstash(cfg.LoggerFile)
 	case config.LoggingKafka:
-		k, err := CreateLoggerKafka(cfg.KafkaConfiguration)
-		if err != nil {
-			return nil, err
-		}
-		k.Settings(mgr)
-		l.Logger = k
+		return CreateLoggerKafka(cfg.KafkaConfiguration)
 	case config.LoggingElastic:
-		e, err := CreateLoggerElastic(cfg.LoggerFile)
-		if err != nil {
-			return nil, err
-		}
-		e.Settings(mgr)
-		l.Logger = e
+		return CreateLoggerElastic(cfg.LoggerFile)
 	}
-	// Initialize the logger that will always log to DB
-	if cfg.AlwaysLog {
-		always, err := CreateLoggerDBConfig(cfg.DBConfigValues)
-		if err != nil {
-			return nil, err
-		}
-		always.Settings(mgr)
-		l.AlwaysLogger = always
+	return nil, fmt.Errorf("unknown logger %s", loggerType)
+}
+
+// AddSink to append a sink at the end of the list of sinks
+func (logTLS *LoggerTLS) AddSink(sink *LoggerSink) {
+	logTLS.Sinks = append(logTLS.Sinks, sink)
+	names := make([]string, 0, len(logTLS.Sinks))
+	for _, s := range logTLS.Sinks {
+		names = append(names, s.Name)
 	}
-	return l, nil
+	logTLS.Logging = strings.Join(names, ",")
 }
 
-// Log will send status/result logs via the configured method of logging
+// Log will send status/result logs to all the sinks accepting them, in order
 func (logTLS *LoggerTLS) Log(logType string, data []byte, environment, uuid string, debug bool) {
-	switch logTLS.Logging {
-	case config.LoggingSplunk:
-		l, ok := logTLS.Logger.(*LoggerSplunk)
-		if !ok {
-			log.Error().Msgf("error casting logger to %s", config.LoggingSplunk)
+	for _, s := range logTLS.Sinks {
+		if !s.Accepts(logType, environment) {
+			continue
 		}
-		if l.Enabled {
-			l.Send(logType, data, environment, uuid, debug)
-		}
-	case config.LoggingGraylog:
-		l, ok := logTLS.Logger.(*LoggerGraylog)
-		if !ok {
-			log.Error().Msgf("error casting logger to %s", config.LoggingGraylog)
-		}
-		if l.Enabled {
-			l.Send(logType, data, environment, uuid, debug)
-		}
-	case config.LoggingDB:
-		l, ok := logTLS.Logger.(*LoggerDB)
-		if !ok {
-			log.Error().Msgf("error casting logger to %s", config.LoggingDB)
-		}
-		if l.Enabled {
-			l.Log(logType, data, environment, uuid, debug)
-		}
-	case config.LoggingStdout:
-		l, ok := logTLS.Logger.(*LoggerStdout)
-		if !ok {
-			log.Error().Msgf("error casting logger to %s", config.LoggingStdout)
-		}
-		if l.Enabled {
-			l.Log(logType, data, environment, uuid, debug)
-		}
-	case config.LoggingFile:
-		l, ok := logTLS.Logger.(*LoggerFile)
-		if !ok {
-			log.Error().Msgf("error casting logger to %s", config.LoggingFile)
-		}
-		if l.Enabled {
-			l.Log(logType, data, environment, uuid, debug)
-		}
-	case config.LoggingNone:
-		l, ok := logTLS.Logger.(*LoggerNone)
-		if !ok {
-			log.Error().Msgf("error casting logger to %s", config.LoggingNone)
-		}
-		if l.Enabled {
-			l.Log(logType, data, environment, uuid, debug)
-		}
-	case config.LoggingKinesis:
-		l, ok := logTLS.Logger.(*LoggerKinesis)
-		if !ok {
-			log.Error().Msgf("error casting logger to %s", config.LoggingKinesis)
-		}
-		if l.Enabled {
-			l.Send(logType, data, environment, uuid, debug)
-		}
-	case config.LoggingS3:
-		l, ok := logTLS.Logger.(*LoggerS3)
-		if !ok {
-			log.Error().Msgf("error casting logger to %s", config.LoggingS3)
-		}
-		if l.Enabled {
-			l.Send(logType, data, environment, uuid, debug)
-		}
-	case config.LoggingKafka:
-		k, ok := logTLS.Logger.(*LoggerKafka)
-		if !ok {
-			log.Printf("error casting logger to %s", config.LoggingKafka)
-		}
-		if k.Enabled {
-			k.Send(logType, data, environment, uuid, debug)
-		}
-	case config.LoggingElastic:
-		k, ok := logTLS.Logger.(*LoggerElastic)
-		if !ok {
-			log.Printf("error casting logger to %s", config.LoggingElastic)
-		}
-		if k.Enabled {
-			k.Send(logType, data, environment, uuid, debug)
-		}
-	}
-	// If logs are status, write via always logger
-	if logTLS.AlwaysLogger != nil && logTLS.AlwaysLogger.Enabled && logType == types.StatusLog {
-		// Check if configured logger is DB so we skip logging the same data twice
-		logAlways := true
-		if logTLS.Logger == config.LoggingDB {
-			l, ok := logTLS.Logger.(*LoggerDB)
-			if ok {
-				logAlways = !sameConfigDB(*l.Database.Config, *logTLS.AlwaysLogger.Database.Config)
-			}
-		}
-		if logAlways {
-			logTLS.AlwaysLogger.Log(logType, data, environment, uuid, debug)
+		if debug {
+			log.Debug().Msgf("sending %s logs to sink %s", logType, s.Name)
 		}
+		s.Logger.Log(logType, data, environment, uuid, debug)
 	}
 }
 
-// QueryLog will send query result logs via the configured method of logging
+// QueryLog will send query result logs to all the sinks accepting them, in order
 func (logTLS *LoggerTLS) QueryLog(logType string, data []byte, environment, uuid, name string, status int, debug bool) {
-	switch logTLS.Logging {
-	case config.LoggingSplunk:
-		l, ok := logTLS.Logger.(*LoggerSplunk)
-		if !ok {
-			log.Error().Msgf("error casting logger to %s", config.LoggingSplunk)
-		}
-		if l.Enabled {
-			l.Send(logType, data, environment, uuid, debug)
-		}
-	case config.LoggingGraylog:
-		l, ok := logTLS.Logger.(*LoggerGraylog)
-		if !ok {
-			log.Error().Msgf("error casting logger to %s", config.LoggingGraylog)
-		}
-		if l.Enabled {
-			l.Send(logType, data, environment, uuid, debug)
-		}
-	case config.LoggingDB:
-		l, ok := logTLS.Logger.(*LoggerDB)
-		if !ok {
-			log.Error().Msgf("error casting logger to %s", config.LoggingDB)
-		}
-		if l.Enabled {
-			l.Query(data, environment, uuid, name, status, debug)
-		}
-	case config.LoggingStdout:
-		l, ok := logTLS.Logger.(*LoggerStdout)
-		if !ok {
-			log.Error().Msgf("error casting logger to %s", config.LoggingStdout)
-		}
-		if l.Enabled {
-			l.Query(data, environment, uuid, name, status, debug)
-		}
-	case config.LoggingFile:
-		l, ok := logTLS.Logger.(*LoggerFile)
-		if !ok {
-			log.Error().Msgf("error casting logger to %s", config.LoggingFile)
-		}
-		if l.Enabled {
-			l.Query(data, environment, uuid, name, status, debug)
-		}
-	case config.LoggingNone:
-		l, ok := logTLS.Logger.(*LoggerNone)
-		if !ok {
-			log.Error().Msgf("error casting logger to %s", config.LoggingNone)
-		}
-		if l.Enabled {
-			l.Query(data, environment, uuid, name, status, debug)
-		}
-	case config.LoggingKinesis:
-		l, ok := logTLS.Logger.(*LoggerKinesis)
-		if !ok {
-			log.Error().Msgf("error casting logger to %s", config.LoggingKinesis)
-		}
-		if l.Enabled {
-			l.Send(logType, data, environment, uuid, debug)
-		}
-	case config.LoggingS3:
-		l, ok := logTLS.Logger.(*LoggerS3)
-		if !ok {
-			log.Error().Msgf("error casting logger to %s", config.LoggingS3)
-		}
-		if l.Enabled {
-			l.Send(logType, data, environment, uuid, debug)
-		}
-	case config.LoggingKafka:
-		k, ok := logTLS.Logger.(*LoggerKafka)
-		if !ok {
-			log.Printf("error casting logger to %s", config.LoggingKafka)
-		}
-		if k.Enabled {
-			k.Send(logType, data, environment, uuid, debug)
-		}
-	}
-	// Always log results to DB if always logger is enabled
-	if logTLS.AlwaysLogger != nil && logTLS.AlwaysLogger.Enabled {
-		// Check if configured logger is DB so we skip logging the same data twice
-		logAlways := true
-		if logTLS.Logger == config.LoggingDB {
-			l, ok := logTLS.Logger.(*LoggerDB)
-			if ok {
-				logAlways = !sameConfigDB(*l.Database.Config, *logTLS.AlwaysLogger.Database.Config)
-			}
+	for _, s := range logTLS.Sinks {
+		if !s.Accepts(types.QueryLog, environment) {
+			continue
 		}
-		if logAlways {
-			logTLS.AlwaysLogger.Query(data, environment, uuid, name, status, debug)
+		if debug {
+			log.Debug().Msgf("sending %s logs to sink %s", logType, s.Name)
 		}
+		s.Logger.Query(data, environment, uuid, name, status, debug)
 	}
 }
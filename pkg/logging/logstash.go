@@ -7,6 +7,7 @@ import (
 
 	"github.com/jmpsec/osctrl/pkg/config"
 	"github.com/jmpsec/osctrl/pkg/settings"
+	"github.com/jmpsec/osctrl/pkg/types"
 	"github.com/jmpsec/osctrl/pkg/utils"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
@@ -96,7 +97,7 @@ func (logLS *LoggerLogstash) Settings(mgr *settings.Settings) {
 }
 
 // SendHTTP - Function that sends JSON logs to Logstash via HTTP
-func (logLS *LoggerLogstash) SendHTTP(logType string, data []byte, environment, uuid string, debug bool) {
+func (logLS *LoggerLogstash) SendHTTP(logType string, data []byte, environment, uuid string, debug bool) error {
 	if debug {
 		log.Debug().Msgf("Send %s via Logstash HTTP", logType)
 	}
@@ -105,58 +106,82 @@ func (logLS *LoggerLogstash) SendHTTP(logType string, data []byte, environment,
 		log.Debug().Msgf("Sending %d bytes to Logstash HTTP for %s - %s", len(data), environment, uuid)
 	}
 	httpURL := fmt.Sprintf("http://%s:%s", logLS.Configuration.Host, logLS.Configuration.Port)
-	// Send log with a POST to the Splunk URL
+	// Send log with a POST to the Logstash URL
 	resp, body, err := utils.SendRequest(LogstashMethod, httpURL, jsonData, logLS.Headers)
 	if err != nil {
-		log.Err(err).Msg("Error sending request")
+		return fmt.Errorf("error sending request to Logstash - %w", err)
 	}
 	if debug {
 		log.Debug().Msgf("HTTP %d %s", resp, body)
 	}
+	return checkResponseCode(resp, body)
 }
 
 // SendUDP - Function that sends data to Logstash via UDP
-func (logLS *LoggerLogstash) SendUDP(logType string, data []byte, environment, uuid string, debug bool) {
+func (logLS *LoggerLogstash) SendUDP(logType string, data []byte, environment, uuid string, debug bool) error {
 	if debug {
-		log.Debug().Msgf("Send %s via Logstash TCP", logType)
-	}
-	if debug {
-		log.Debug().Msgf("Sending %d bytes to Logstash TCP for %s - %s", len(data), environment, uuid)
-	}
-	connAddr := fmt.Sprintf(LogstashConnStr, logLS.Configuration.Host, logLS.Configuration.Port)
-	conn, err := net.Dial("udp", connAddr)
-	if err != nil {
-		log.Err(err).Msg("Error connecting to Logstash")
-	}
-	defer conn.Close()
-	_, err = conn.Write(data)
-	if err != nil {
-		log.Err(err).Msg("Error writing to Logstash")
+		log.Debug().Msgf("Send %s via Logstash UDP", logType)
 	}
 	if debug {
-		log.Debug().Msg("Sent data to Logstash TCP")
+		log.Debug().Msgf("Sending %d bytes to Logstash UDP for %s - %s", len(data), environment, uuid)
 	}
+	return logLS.sendConn("udp", data, debug)
 }
 
 // SendTCP - Function that sends data to Logstash via TCP
-func (logLS *LoggerLogstash) SendTCP(logType string, data []byte, environment, uuid string, debug bool) {
+func (logLS *LoggerLogstash) SendTCP(logType string, data []byte, environment, uuid string, debug bool) error {
 	if debug {
-		log.Debug().Msgf("Send %s via Logstash UDP", logType)
+		log.Debug().Msgf("Send %s via Logstash TCP", logType)
 	}
 	if debug {
-		log.Debug().Msgf("Sending %d bytes to Logstash UDP for %s - %s", len(data), environment, uuid)
+		log.Debug().Msgf("Sending %d bytes to Logstash TCP for %s - %s", len(data), environment, uuid)
 	}
-	connAddr := fmt.Sprintf(LogstashConnStr, logLS.Configuration.Host, logLS.Configuration.Port)
-	conn, err := net.Dial("tcp", connAddr)
+	return logLS.sendConn("tcp", data, debug)
+}
+
+// Helper to write data to Logstash over a TCP or UDP connection
+func (logLS *LoggerLogstash) sendConn(network string, data []byte, debug bool) error {
+	connAddr := net.JoinHostPort(logLS.Configuration.Host, logLS.Configuration.Port)
+	conn, err := net.Dial(network, connAddr)
 	if err != nil {
-		log.Err(err).Msg("Error connecting to Logstash")
+		return fmt.Errorf("error connecting to Logstash - %w", err)
 	}
 	defer conn.Close()
-	_, err = conn.Write(data)
-	if err != nil {
-		log.Err(err).Msg("Error writing to Logstash")
+	if _, err := conn.Write(data); err != nil {
+		return fmt.Errorf("error writing to Logstash - %w", err)
 	}
 	if debug {
-		log.Debug().Msg("Sent data to Logstash UDP")
+		log.Debug().Msgf("Sent data to Logstash %s", network)
+	}
+	return nil
+}
+
+// Send - Function that sends data to Logstash using the configured protocol
+func (logLS *LoggerLogstash) Send(logType string, data []byte, environment, uuid string, debug bool) {
+	if err := logLS.SendLog(logType, data, environment, uuid, debug); err != nil {
+		log.Err(err).Msg("Error sending to Logstash")
 	}
 }
+
+// SendLog - Function that sends data to Logstash using the configured protocol and returns delivery errors
+func (logLS *LoggerLogstash) SendLog(logType string, data []byte, environment, uuid string, debug bool) error {
+	switch logLS.Configuration.Protocol {
+	case LogstashHTTP:
+		return logLS.SendHTTP(logType, data, environment, uuid, debug)
+	case LogstashUDP:
+		return logLS.SendUDP(logType, data, environment, uuid, debug)
+	case LogstashTCP:
+		return logLS.SendTCP(logType, data, environment, uuid, debug)
+	}
+	return fmt.Errorf("unknown Logstash protocol %s", logLS.Configuration.Protocol)
+}
+
+// Log - Function that sends JSON result/status logs to Logstash
+func (logLS *LoggerLogstash) Log(logType string, data []byte, environment, uuid string, debug bool) {
+	logLS.Send(logType, data, environment, uuid, debug)
+}
+
+// Query - Function that sends JSON query logs to Logstash
+func (logLS *LoggerLogstash) Query(data []byte, environment, uuid, name string, status int, debug bool) {
+	logLS.Send(types.QueryLog, data, environment, uuid, debug)
+}
@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metric names and help text
+const (
+	spoolSizeName      = "osctrl_logger_spool_size_bytes"
+	spoolSizeHelp      = "Current size of the logs spooled to disk for each sink"
+	spoolOldestAgeName = "osctrl_logger_spool_oldest_entry_age_seconds"
+	spoolOldestAgeHelp = "Age of the oldest log spooled to disk for each sink"
+	spoolDroppedName   = "osctrl_logger_spool_dropped_total"
+	spoolDroppedHelp   = "Total number of logs dropped because the spool was full"
+)
+
+var (
+	// spoolSize tracks the size of each spool
+	spoolSize = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: spoolSizeName,
+			Help: spoolSizeHelp,
+		},
+		[]string{"sink"},
+	)
+	// spoolOldestAge tracks the age of the oldest entry in each spool
+	spoolOldestAge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: spoolOldestAgeName,
+			Help: spoolOldestAgeHelp,
+		},
+		[]string{"sink"},
+	)
+	// spoolDropped tracks the entries dropped by each spool
+	spoolDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: spoolDroppedName,
+			Help: spoolDroppedHelp,
+		},
+		[]string{"sink"},
+	)
+)
+
+// RegisterMetrics registers all logging metrics with the provided registerer
+func RegisterMetrics(reg prometheus.Registerer) {
+	reg.MustRegister(spoolSize)
+	reg.MustRegister(spoolOldestAge)
+	reg.MustRegister(spoolDropped)
+}
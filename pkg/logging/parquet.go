@@ -0,0 +1,271 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Minimal Parquet writer for query results: every column is a required UTF8 string,
+// PLAIN encoded and uncompressed, with one data page per column in each row group.
+// Metadata follows the Parquet format definitions, serialized with the Thrift compact protocol.
+
+const (
+	parquetMagic = "PAR1"
+	// Parquet values used by the writer
+	parquetTypeByteArray = 6
+	parquetRepRequired   = 0
+	parquetConvertedUTF8 = 0
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+	parquetCodecNone     = 0
+	parquetPageTypeData  = 0
+	parquetFormatVersion = 1
+	parquetCreatedBy     = "osctrl"
+	// Thrift compact protocol types
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// parquetWriter to write rows of strings as Parquet, buffering one row group in memory
+type parquetWriter struct {
+	w            io.Writer
+	columns      []string
+	buffers      []bytes.Buffer
+	rows         int64
+	buffered     int
+	offset       int64
+	rowGroupSize int
+	rowGroups    []parquetRowGroup
+	totalRows    int64
+}
+
+// parquetRowGroup to keep the metadata of each written row group
+type parquetRowGroup struct {
+	chunks    []parquetColumnChunk
+	numRows   int64
+	totalSize int64
+}
+
+// parquetColumnChunk to keep the metadata of each written column chunk
+type parquetColumnChunk struct {
+	offset int64
+	size   int64
+}
+
+// Helper to create a Parquet writer, writing the magic bytes
+func newParquetWriter(w io.Writer, columns []string, rowGroupSize int) (*parquetWriter, error) {
+	if _, err := io.WriteString(w, parquetMagic); err != nil {
+		return nil, err
+	}
+	return &parquetWriter{
+		w:            w,
+		columns:      columns,
+		buffers:      make([]bytes.Buffer, len(columns)),
+		offset:       int64(len(parquetMagic)),
+		rowGroupSize: rowGroupSize,
+	}, nil
+}
+
+// Write to add one row, flushing the row group when it is big enough
+func (p *parquetWriter) Write(values []string) error {
+	if len(values) != len(p.columns) {
+		return fmt.Errorf("expected %d values, got %d", len(p.columns), len(values))
+	}
+	var l [4]byte
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(l[:], uint32(len(v)))
+		p.buffers[i].Write(l[:])
+		p.buffers[i].WriteString(v)
+		p.buffered += 4 + len(v)
+	}
+	p.rows++
+	if p.buffered >= p.rowGroupSize {
+		return p.flush()
+	}
+	return nil
+}
+
+// Helper to write the buffered rows as a row group
+func (p *parquetWriter) flush() error {
+	if p.rows == 0 {
+		return nil
+	}
+	rg := parquetRowGroup{numRows: p.rows}
+	for i := range p.buffers {
+		data := p.buffers[i].Bytes()
+		header := newThriftWriter()
+		header.i32Field(1, parquetPageTypeData)
+		header.i32Field(2, int32(len(data)))
+		header.i32Field(3, int32(len(data)))
+		header.structField(5)
+		header.i32Field(1, int32(p.rows))
+		header.i32Field(2, parquetEncodingPlain)
+		header.i32Field(3, parquetEncodingRLE)
+		header.i32Field(4, parquetEncodingRLE)
+		header.structEnd()
+		header.structEnd()
+		if _, err := p.w.Write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if _, err := p.w.Write(data); err != nil {
+			return err
+		}
+		size := int64(header.buf.Len() + len(data))
+		rg.chunks = append(rg.chunks, parquetColumnChunk{offset: p.offset, size: size})
+		rg.totalSize += size
+		p.offset += size
+		p.buffers[i].Reset()
+	}
+	p.rowGroups = append(p.rowGroups, rg)
+	p.totalRows += p.rows
+	p.rows = 0
+	p.buffered = 0
+	return nil
+}
+
+// Close to flush the last row group and write the file metadata
+func (p *parquetWriter) Close() error {
+	if err := p.flush(); err != nil {
+		return err
+	}
+	meta := newThriftWriter()
+	meta.i32Field(1, parquetFormatVersion)
+	// Schema with the root element and one element per column
+	meta.listField(2, thriftStruct, len(p.columns)+1)
+	meta.structBegin()
+	meta.binaryField(4, "schema")
+	meta.i32Field(5, int32(len(p.columns)))
+	meta.structEnd()
+	for _, c := range p.columns {
+		meta.structBegin()
+		meta.i32Field(1, parquetTypeByteArray)
+		meta.i32Field(3, parquetRepRequired)
+		meta.binaryField(4, c)
+		meta.i32Field(6, parquetConvertedUTF8)
+		meta.structEnd()
+	}
+	meta.i64Field(3, p.totalRows)
+	meta.listField(4, thriftStruct, len(p.rowGroups))
+	for _, rg := range p.rowGroups {
+		meta.structBegin()
+		meta.listField(1, thriftStruct, len(rg.chunks))
+		for i, chunk := range rg.chunks {
+			meta.structBegin()
+			meta.i64Field(2, chunk.offset)
+			meta.structField(3)
+			meta.i32Field(1, parquetTypeByteArray)
+			meta.listField(2, thriftI32, 1)
+			meta.zigzag(parquetEncodingPlain)
+			meta.listField(3, thriftBinary, 1)
+			meta.binary(p.columns[i])
+			meta.i32Field(4, parquetCodecNone)
+			meta.i64Field(5, rg.numRows)
+			meta.i64Field(6, chunk.size)
+			meta.i64Field(7, chunk.size)
+			meta.i64Field(9, chunk.offset)
+			meta.structEnd()
+			meta.structEnd()
+		}
+		meta.i64Field(2, rg.totalSize)
+		meta.i64Field(3, rg.numRows)
+		meta.structEnd()
+	}
+	meta.binaryField(6, parquetCreatedBy)
+	meta.structEnd()
+	if _, err := p.w.Write(meta.buf.Bytes()); err != nil {
+		return err
+	}
+	var l [4]byte
+	binary.LittleEndian.PutUint32(l[:], uint32(meta.buf.Len()))
+	if _, err := p.w.Write(l[:]); err != nil {
+		return err
+	}
+	_, err := io.WriteString(p.w, parquetMagic)
+	return err
+}
+
+// thriftWriter to serialize structs with the Thrift compact protocol
+type thriftWriter struct {
+	buf bytes.Buffer
+	// Last field ID for each nested struct, to encode field headers as deltas
+	lastIDs []int16
+}
+
+// Helper to create a writer with the outer struct already begun
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{lastIDs: []int16{0}}
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	t.buf.Write(b[:n])
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftWriter) binary(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	last := t.lastIDs[len(t.lastIDs)-1]
+	if delta := id - last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.zigzag(int64(id))
+	}
+	t.lastIDs[len(t.lastIDs)-1] = id
+}
+
+func (t *thriftWriter) i32Field(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) i64Field(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) binaryField(id int16, s string) {
+	t.fieldHeader(id, thriftBinary)
+	t.binary(s)
+}
+
+// structBegin to begin a struct, as a field or as an element of a list
+func (t *thriftWriter) structBegin() {
+	t.lastIDs = append(t.lastIDs, 0)
+}
+
+// structEnd to finish a struct with the stop field
+func (t *thriftWriter) structEnd() {
+	t.buf.WriteByte(0)
+	t.lastIDs = t.lastIDs[:len(t.lastIDs)-1]
+}
+
+// structField to begin a nested struct field, finished with structEnd
+func (t *thriftWriter) structField(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.structBegin()
+}
+
+// listField to begin a list field, followed by the elements
+func (t *thriftWriter) listField(id int16, elemType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		t.buf.WriteByte(0xf0 | elemType)
+		t.varint(uint64(size))
+	}
+}
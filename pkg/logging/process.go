@@ -82,7 +82,7 @@ func (l *LoggerTLS) ProcessLogQueryResult(queriesWrite types.QueryWriteRequest,
 			log.Err(err).Msg("error updating query")
 		}
 		// Update query status
-		if err := l.Queries.UpdateQueryStatus(q, node.ID, queriesWrite.Statuses[q]); err != nil {
+		if err := l.Queries.UpdateQueryStatusMessage(q, node.ID, queriesWrite.Statuses[q], queriesWrite.Messages[q]); err != nil {
 			log.Err(err).Msg("error updating query status")
 		}
 	}
@@ -0,0 +1,187 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmpsec/osctrl/pkg/queries"
+	"github.com/jmpsec/osctrl/pkg/types"
+	"gorm.io/gorm"
+)
+
+// QueryResultRow to hold one row of results, with one value per column
+type QueryResultRow map[string]string
+
+// NodeQueryResult to hold the flattened results of a distributed query for one target node
+type NodeQueryResult struct {
+	UUID      string           `json:"uuid"`
+	Hostname  string           `json:"hostname"`
+	Localname string           `json:"localname"`
+	Status    string           `json:"status"`
+	Message   string           `json:"message,omitempty"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	Columns   []string         `json:"columns"`
+	Rows      []QueryResultRow `json:"rows"`
+}
+
+// NodeQueryResults to hold a page of results of a distributed query by target node
+type NodeQueryResults struct {
+	Name     string            `json:"name"`
+	Total    int64             `json:"total"`
+	Filtered int64             `json:"filtered"`
+	Offset   int               `json:"offset"`
+	Limit    int               `json:"limit"`
+	Columns  []string          `json:"columns"`
+	Nodes    []NodeQueryResult `json:"nodes"`
+}
+
+// QueryLogsNodes will retrieve the query logs of a list of nodes
+func QueryLogsNodes(db *gorm.DB, name string, uuids []string) ([]OsqueryQueryData, error) {
+	var logs []OsqueryQueryData
+	if len(uuids) == 0 {
+		return logs, nil
+	}
+	upper := make([]string, len(uuids))
+	for i, u := range uuids {
+		upper[i] = strings.ToUpper(u)
+	}
+	if err := db.Where("name = ? AND uuid IN ?", name, upper).Order("created_at").Find(&logs).Error; err != nil {
+		return logs, err
+	}
+	return logs, nil
+}
+
+// FlattenQueryData - Function to convert the raw JSON of one query log into rows of columns
+func FlattenQueryData(data string) ([]QueryResultRow, string, error) {
+	var rows []QueryResultRow
+	var written types.QueryWriteData
+	if err := json.Unmarshal([]byte(data), &written); err != nil {
+		return rows, "", fmt.Errorf("error parsing query data - %w", err)
+	}
+	if len(written.Result) == 0 || string(written.Result) == "null" {
+		return rows, written.Message, nil
+	}
+	var raw []map[string]interface{}
+	if err := json.Unmarshal(written.Result, &raw); err != nil {
+		return rows, written.Message, fmt.Errorf("error parsing query result - %w", err)
+	}
+	for _, r := range raw {
+		row := make(QueryResultRow, len(r))
+		for k, v := range r {
+			row[k] = resultValue(v)
+		}
+		rows = append(rows, row)
+	}
+	return rows, written.Message, nil
+}
+
+// Helper to convert a result value into a string, osquery sends strings but other types are serialized
+func resultValue(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case nil:
+		return ""
+	default:
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprintf("%v", value)
+		}
+		return string(raw)
+	}
+}
+
+// Helper to get the sorted columns for a list of rows
+func resultColumns(rows []QueryResultRow) []string {
+	seen := make(map[string]bool)
+	columns := []string{}
+	for _, r := range rows {
+		for k := range r {
+			if !seen[k] {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// QueryResultsPage - Function to get a page of results of a distributed query by target node.
+// The status comes from the target nodes in the main database and the results from the logger database.
+func QueryResultsPage(logsDB *gorm.DB, queriesmgr *queries.Queries, query queries.DistributedQuery, filter queries.NodeQueryFilter, offset, limit int) (NodeQueryResults, error) {
+	if limit <= 0 {
+		limit = queries.DefaultNodeQueriesLimit
+	}
+	if limit > queries.MaxNodeQueriesLimit {
+		limit = queries.MaxNodeQueriesLimit
+	}
+	page := NodeQueryResults{
+		Name:    query.Name,
+		Offset:  offset,
+		Limit:   limit,
+		Columns: []string{},
+		Nodes:   []NodeQueryResult{},
+	}
+	var err error
+	if page.Total, err = queriesmgr.CountNodeQueries(query.ID, queries.NodeQueryFilter{}); err != nil {
+		return page, err
+	}
+	if page.Filtered, err = queriesmgr.CountNodeQueries(query.ID, filter); err != nil {
+		return page, err
+	}
+	targets, err := queriesmgr.GetNodeQueriesPage(query.ID, filter, offset, limit)
+	if err != nil {
+		return page, err
+	}
+	uuids := make([]string, len(targets))
+	for i, t := range targets {
+		uuids[i] = t.UUID
+	}
+	var logs []OsqueryQueryData
+	if logsDB != nil {
+		if logs, err = QueryLogsNodes(logsDB, query.Name, uuids); err != nil {
+			return page, fmt.Errorf("error getting query logs - %w", err)
+		}
+	}
+	// Group flattened rows by node, a node could send more than one log for the same query
+	byNode := make(map[string]*NodeQueryResult)
+	for _, t := range targets {
+		byNode[strings.ToUpper(t.UUID)] = &NodeQueryResult{
+			UUID:      t.UUID,
+			Hostname:  t.Hostname,
+			Localname: t.Localname,
+			Status:    t.Status,
+			Message:   t.Message,
+			UpdatedAt: t.UpdatedAt,
+			Rows:      []QueryResultRow{},
+		}
+	}
+	for _, l := range logs {
+		n, ok := byNode[l.UUID]
+		if !ok {
+			continue
+		}
+		rows, msg, err := FlattenQueryData(l.Data)
+		if err != nil {
+			n.Message = err.Error()
+			continue
+		}
+		if msg != "" {
+			n.Message = msg
+		}
+		n.Rows = append(n.Rows, rows...)
+	}
+	var all []QueryResultRow
+	for _, t := range targets {
+		n := byNode[strings.ToUpper(t.UUID)]
+		n.Columns = resultColumns(n.Rows)
+		all = append(all, n.Rows...)
+		page.Nodes = append(page.Nodes, *n)
+	}
+	page.Columns = resultColumns(all)
+	return page, nil
+}
@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/jmpsec/osctrl/pkg/nodes"
+	"github.com/jmpsec/osctrl/pkg/queries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestFlattenQueryData(t *testing.T) {
+	rows, msg, err := FlattenQueryData(`{"name":"q","result":[{"pid":"1","name":"launchd"},{"pid":2,"extra":null}],"status":0,"message":""}`)
+	require.NoError(t, err)
+	assert.Empty(t, msg)
+	require.Len(t, rows, 2)
+	assert.Equal(t, QueryResultRow{"pid": "1", "name": "launchd"}, rows[0])
+	assert.Equal(t, QueryResultRow{"pid": "2", "extra": ""}, rows[1])
+	assert.Equal(t, []string{"extra", "name", "pid"}, resultColumns(rows))
+
+	rows, msg, err = FlattenQueryData(`{"name":"q","result":null,"status":1,"message":"no such table: foo"}`)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+	assert.Equal(t, "no such table: foo", msg)
+
+	_, _, err = FlattenQueryData(`not json`)
+	assert.Error(t, err)
+}
+
+func TestQueryResultsPage(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&nodes.OsqueryNode{}, &queries.DistributedQuery{}, &queries.NodeQuery{}))
+	nodes.CreateNodes(db)
+	q := queries.CreateQueries(db)
+	require.NoError(t, db.AutoMigrate(&OsqueryQueryData{}))
+
+	for i, host := range []string{"alpha", "bravo", "charlie"} {
+		require.NoError(t, db.Create(&nodes.OsqueryNode{Model: gorm.Model{ID: uint(i + 1)}, UUID: "UUID-" + host, Hostname: host}).Error)
+	}
+	query := queries.DistributedQuery{Name: "test_query", EnvironmentID: 1}
+	require.NoError(t, db.Create(&query).Error)
+	require.NoError(t, q.CreateNodeQueries([]uint{1, 2, 3}, query.ID))
+	require.NoError(t, q.UpdateQueryStatus(query.Name, 1, 0))
+	require.NoError(t, q.UpdateQueryStatus(query.Name, 2, 1))
+	require.NoError(t, db.Create(&OsqueryQueryData{UUID: "UUID-ALPHA", Name: query.Name, Data: `{"name":"test_query","result":[{"version":"5.10.2"}],"status":0}`}).Error)
+	require.NoError(t, db.Create(&OsqueryQueryData{UUID: "UUID-BRAVO", Name: query.Name, Data: `{"name":"test_query","result":null,"status":1,"message":"error"}`, Status: 1}).Error)
+
+	page, err := QueryResultsPage(db, q, query, queries.NodeQueryFilter{}, 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), page.Total)
+	assert.Equal(t, int64(3), page.Filtered)
+	assert.Equal(t, []string{"version"}, page.Columns)
+	require.Len(t, page.Nodes, 2)
+	assert.Equal(t, "alpha", page.Nodes[0].Hostname)
+	assert.Equal(t, queries.DistributedQueryStatusCompleted, page.Nodes[0].Status)
+	assert.Equal(t, []QueryResultRow{{"version": "5.10.2"}}, page.Nodes[0].Rows)
+	assert.Equal(t, queries.DistributedQueryStatusError, page.Nodes[1].Status)
+	assert.Equal(t, "error", page.Nodes[1].Message)
+	assert.Empty(t, page.Nodes[1].Rows)
+
+	page, err = QueryResultsPage(db, q, query, queries.NodeQueryFilter{Status: queries.DistributedQueryStatusPending}, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), page.Filtered)
+	require.Len(t, page.Nodes, 1)
+	assert.Equal(t, "charlie", page.Nodes[0].Hostname)
+	assert.Empty(t, page.Nodes[0].Rows)
+
+	// Without the logger database only the status is returned
+	page, err = QueryResultsPage(nil, q, query, queries.NodeQueryFilter{UUID: "uuid-alpha"}, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, page.Nodes, 1)
+	assert.Empty(t, page.Nodes[0].Rows)
+}
@@ -10,6 +10,7 @@ import (
 
 	osctrl_config "github.com/jmpsec/osctrl/pkg/config"
 	"github.com/jmpsec/osctrl/pkg/settings"
+	"github.com/jmpsec/osctrl/pkg/types"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 
@@ -109,3 +110,13 @@ func (logS3 *LoggerS3) Send(logType string, data []byte, environment, uuid strin
 		log.Debug().Msgf("S3 Upload %+v", result)
 	}
 }
+
+// Log - Function that sends JSON result/status logs to S3
+func (logS3 *LoggerS3) Log(logType string, data []byte, environment, uuid string, debug bool) {
+	logS3.Send(logType, data, environment, uuid, debug)
+}
+
+// Query - Function that sends JSON query logs to S3
+func (logS3 *LoggerS3) Query(data []byte, environment, uuid, name string, status int, debug bool) {
+	logS3.Send(types.QueryLog, data, environment, uuid, debug)
+}
@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"fmt"
+
+	"github.com/jmpsec/osctrl/pkg/backend"
+	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/jmpsec/osctrl/pkg/settings"
+	"github.com/jmpsec/osctrl/pkg/types"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	// LoggerSinksKey to identify the list of sinks in the logger configuration JSON
+	LoggerSinksKey = "sinks"
+)
+
+// SinkConfiguration to hold the configuration values for each logger sink
+type SinkConfiguration struct {
+	Type         string   `json:"type"`
+	LogTypes     []string `json:"logTypes"`
+	Environments []string `json:"environments"`
+	DBSame       bool     `json:"dbSame"`
+}
+
+// LoggerSink will be used to send logs to one logger, filtered by log type and environment
+type LoggerSink struct {
+	Name         string
+	Logger       Logger
+	LogTypes     map[string]bool
+	Environments map[string]bool
+}
+
+// NewLoggerSink to initialize a sink for an existing logger, empty filters accept everything
+func NewLoggerSink(name string, logger Logger, logTypes, environments []string) *LoggerSink {
+	s := &LoggerSink{
+		Name:         name,
+		Logger:       logger,
+		LogTypes:     make(map[string]bool),
+		Environments: make(map[string]bool),
+	}
+	for _, t := range logTypes {
+		s.LogTypes[t] = true
+	}
+	for _, e := range environments {
+		s.Environments[e] = true
+	}
+	return s
+}
+
+// CreateLoggerSink to initialize the logger for a sink using the configuration from the service flags
+func CreateLoggerSink(sinkCfg SinkConfiguration, cfg config.ServiceFlagParams, mgr *settings.Settings) (*LoggerSink, error) {
+	if sinkCfg.DBSame {
+		cfg.LoggerDBSame = true
+	}
+	l, err := CreateLogger(sinkCfg.Type, cfg)
+	if err != nil {
+		return nil, err
+	}
+	l.Settings(mgr)
+	return NewLoggerSink(sinkCfg.Type, l, sinkCfg.LogTypes, sinkCfg.Environments), nil
+}
+
+// Accepts - Function to check if the sink accepts logs of this type and environment
+func (s *LoggerSink) Accepts(logType, environment string) bool {
+	if len(s.LogTypes) > 0 && !s.LogTypes[logType] {
+		return false
+	}
+	if len(s.Environments) > 0 && !s.Environments[environment] {
+		return false
+	}
+	return true
+}
+
+// LoadSinks - Function to load the ordered list of sinks from the logger JSON file
+func LoadSinks(file string) ([]SinkConfiguration, error) {
+	var sinks []SinkConfiguration
+	log.Info().Msgf("Loading %s", file)
+	// Load file and read config
+	viper.SetConfigFile(file)
+	if err := viper.ReadInConfig(); err != nil {
+		return sinks, err
+	}
+	if !viper.IsSet(LoggerSinksKey) {
+		return sinks, fmt.Errorf("JSON key %s not found in %s", LoggerSinksKey, file)
+	}
+	if err := viper.UnmarshalKey(LoggerSinksKey, &sinks); err != nil {
+		return sinks, err
+	}
+	if len(sinks) == 0 {
+		return sinks, fmt.Errorf("no sinks configured in %s", file)
+	}
+	// No errors!
+	return sinks, nil
+}
+
+// Helper to build the list of sinks from the single logger and always-log values
+func legacySinks(cfg config.ServiceFlagParams) []SinkConfiguration {
+	sinks := []SinkConfiguration{
+		{Type: cfg.ConfigValues.Logger},
+	}
+	if !cfg.AlwaysLog {
+		return sinks
+	}
+	// Check if configured logger is DB so we skip logging the same data twice
+	if cfg.ConfigValues.Logger == config.LoggingDB {
+		if cfg.LoggerDBSame {
+			return sinks
+		}
+		dbCfg, err := backend.LoadConfiguration(cfg.LoggerFile, backend.DBKey)
+		if err == nil && sameConfigDB(dbCfg, cfg.DBConfigValues) {
+			return sinks
+		}
+	}
+	// Always log status and on-demand query logs in the main database
+	return append(sinks, SinkConfiguration{
+		Type:     config.LoggingDB,
+		LogTypes: []string{types.StatusLog, types.QueryLog},
+		DBSame:   true,
+	})
+}
@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/jmpsec/osctrl/pkg/settings"
+	"github.com/jmpsec/osctrl/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type MockLogger struct {
+	Logs    []string
+	Queries []string
+}
+
+func (m *MockLogger) Settings(mgr *settings.Settings) {}
+
+func (m *MockLogger) Log(logType string, data []byte, environment, uuid string, debug bool) {
+	m.Logs = append(m.Logs, logType+":"+environment)
+}
+
+func (m *MockLogger) Query(data []byte, environment, uuid, name string, status int, debug bool) {
+	m.Queries = append(m.Queries, name+":"+environment)
+}
+
+func TestLoggerSink_Accepts(t *testing.T) {
+	all := NewLoggerSink("all", &MockLogger{}, nil, nil)
+	assert.True(t, all.Accepts(types.StatusLog, "dev"))
+	assert.True(t, all.Accepts(types.QueryLog, "prod"))
+
+	filtered := NewLoggerSink("filtered", &MockLogger{}, []string{types.StatusLog}, []string{"prod"})
+	assert.True(t, filtered.Accepts(types.StatusLog, "prod"))
+	assert.False(t, filtered.Accepts(types.ResultLog, "prod"))
+	assert.False(t, filtered.Accepts(types.StatusLog, "dev"))
+}
+
+func TestLoggerTLS_FanOut(t *testing.T) {
+	siem := &MockLogger{}
+	admin := &MockLogger{}
+	l := &LoggerTLS{}
+	l.AddSink(NewLoggerSink(config.LoggingKafka, siem, nil, nil))
+	l.AddSink(NewLoggerSink(config.LoggingDB, admin, []string{types.StatusLog, types.QueryLog}, []string{"prod"}))
+	assert.Equal(t, "kafka,db", l.Logging)
+
+	l.Log(types.StatusLog, []byte("[]"), "prod", "uuid", false)
+	l.Log(types.ResultLog, []byte("[]"), "prod", "uuid", false)
+	l.Log(types.StatusLog, []byte("[]"), "dev", "uuid", false)
+	l.QueryLog(types.QueryLog, []byte("{}"), "prod", "uuid", "query1", 0, false)
+	l.QueryLog(types.QueryLog, []byte("{}"), "dev", "uuid", "query2", 0, false)
+
+	assert.Equal(t, []string{"status:prod", "result:prod", "status:dev"}, siem.Logs)
+	assert.Equal(t, []string{"query1:prod", "query2:dev"}, siem.Queries)
+	assert.Equal(t, []string{"status:prod"}, admin.Logs)
+	assert.Equal(t, []string{"query1:prod"}, admin.Queries)
+}
+
+func TestLegacySinks(t *testing.T) {
+	cfg := config.ServiceFlagParams{}
+	cfg.ConfigValues.Logger = config.LoggingKafka
+	sinks := legacySinks(cfg)
+	assert.Len(t, sinks, 1)
+
+	cfg.AlwaysLog = true
+	sinks = legacySinks(cfg)
+	assert.Len(t, sinks, 2)
+	assert.Equal(t, config.LoggingDB, sinks[1].Type)
+	assert.True(t, sinks[1].DBSame)
+	assert.Equal(t, []string{types.StatusLog, types.QueryLog}, sinks[1].LogTypes)
+
+	cfg.ConfigValues.Logger = config.LoggingDB
+	cfg.LoggerDBSame = true
+	sinks = legacySinks(cfg)
+	assert.Len(t, sinks, 1)
+}
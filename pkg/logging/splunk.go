@@ -90,6 +90,13 @@ func (logSP *LoggerSplunk) Settings(mgr *settings.Settings) {
 
 // Send - Function that sends JSON logs to Splunk HTTP Event Collector
 func (logSP *LoggerSplunk) Send(logType string, data []byte, environment, uuid string, debug bool) {
+	if err := logSP.SendLog(logType, data, environment, uuid, debug); err != nil {
+		log.Err(err).Msg("Error sending request")
+	}
+}
+
+// SendLog - Function that sends JSON logs to Splunk HTTP Event Collector and returns delivery errors
+func (logSP *LoggerSplunk) SendLog(logType string, data []byte, environment, uuid string, debug bool) error {
 	if debug {
 		log.Debug().Msgf("Send %s via splunk", logType)
 	}
@@ -133,6 +140,7 @@ func (logSP *LoggerSplunk) Send(logType string, data []byte, environment, uuid s
 	jsonEvents, err := json.Marshal(events)
 	if err != nil {
 		log.Err(err).Msgf("Error parsing data")
+		return nil
 	}
 	jsonParam := bytes.NewReader(jsonEvents)
 	if debug {
@@ -141,9 +149,20 @@ func (logSP *LoggerSplunk) Send(logType string, data []byte, environment, uuid s
 	// Send log with a POST to the Splunk URL
 	resp, body, err := utils.SendRequest(SplunkMethod, logSP.Configuration.URL, jsonParam, logSP.Headers)
 	if err != nil {
-		log.Err(err).Msgf("Error sending request")
+		return fmt.Errorf("error sending request to Splunk - %w", err)
 	}
 	if debug {
 		log.Debug().Msgf("HTTP %d %s", resp, body)
 	}
+	return checkResponseCode(resp, body)
+}
+
+// Log - Function that sends JSON result/status logs to Splunk
+func (logSP *LoggerSplunk) Log(logType string, data []byte, environment, uuid string, debug bool) {
+	logSP.Send(logType, data, environment, uuid, debug)
+}
+
+// Query - Function that sends JSON query logs to Splunk
+func (logSP *LoggerSplunk) Query(data []byte, environment, uuid, name string, status int, debug bool) {
+	logSP.Send(types.QueryLog, data, environment, uuid, debug)
 }
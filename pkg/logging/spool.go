@@ -0,0 +1,378 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmpsec/osctrl/pkg/settings"
+	"github.com/jmpsec/osctrl/pkg/types"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// SpoolSegmentExt is the extension for spool segment files
+	SpoolSegmentExt = ".spool"
+	// DefaultSpoolInitialBackoff is the first wait time after a failed drain
+	DefaultSpoolInitialBackoff = time.Second
+	// DefaultSpoolMaxBackoff is the maximum wait time between failed drains
+	DefaultSpoolMaxBackoff = 5 * time.Minute
+)
+
+// ErrSpoolFull is returned when the spool reached its maximum size
+var ErrSpoolFull = errors.New("spool is full")
+
+// SpoolEntry to hold each log spooled to disk
+type SpoolEntry struct {
+	Time        time.Time `json:"time"`
+	LogType     string    `json:"log_type"`
+	Environment string    `json:"environment"`
+	UUID        string    `json:"uuid"`
+	Data        []byte    `json:"data"`
+}
+
+// Spool will be used to keep logs on disk in segment files, while the sink is unavailable
+type Spool struct {
+	Name           string
+	Dir            string
+	MaxSegmentSize int64
+	MaxSize        int64
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	mu             sync.Mutex
+	segments       []string
+	sizes          map[string]int64
+	current        *os.File
+	notify         chan struct{}
+}
+
+// NewSpool to initialize a spool in a directory, loading any segments left from previous runs
+func NewSpool(name, dir string, maxSegmentSize, maxSize int64) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating spool directory - %w", err)
+	}
+	s := &Spool{
+		Name:           name,
+		Dir:            dir,
+		MaxSegmentSize: maxSegmentSize,
+		MaxSize:        maxSize,
+		InitialBackoff: DefaultSpoolInitialBackoff,
+		MaxBackoff:     DefaultSpoolMaxBackoff,
+		sizes:          make(map[string]int64),
+		notify:         make(chan struct{}, 1),
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading spool directory - %w", err)
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), SpoolSegmentExt) {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			return nil, fmt.Errorf("error reading spool segment - %w", err)
+		}
+		s.segments = append(s.segments, f.Name())
+		s.sizes[f.Name()] = info.Size()
+	}
+	sort.Strings(s.segments)
+	s.updateMetrics()
+	return s, nil
+}
+
+// Write - Function to append an entry to the newest segment, rotating it when it is too big
+func (s *Spool) Write(entry SpoolEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error serializing spool entry - %w", err)
+	}
+	line = append(line, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.MaxSize > 0 && s.size()+int64(len(line)) > s.MaxSize {
+		spoolDropped.WithLabelValues(s.Name).Inc()
+		return ErrSpoolFull
+	}
+	if s.current == nil || (s.MaxSegmentSize > 0 && s.sizes[s.currentName()] >= s.MaxSegmentSize) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.current.Write(line)
+	s.sizes[s.currentName()] += int64(n)
+	if err != nil {
+		return fmt.Errorf("error writing spool segment - %w", err)
+	}
+	s.updateMetrics()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Pending - Function to check if there are entries waiting in the spool
+func (s *Spool) Pending() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.segments) > 0
+}
+
+// Size - Function to get the total size in bytes of all the segments
+func (s *Spool) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size()
+}
+
+// Drain - Function to deliver all spooled entries in order until the context is done.
+// Failed deliveries and segment errors are retried with exponential backoff, and each
+// segment is removed once all its entries are delivered, so entries may be delivered
+// more than once.
+func (s *Spool) Drain(ctx context.Context, send func(entry SpoolEntry) error) {
+	backoff := s.InitialBackoff
+	for {
+		segment := s.oldest()
+		if segment == "" {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.notify:
+				continue
+			}
+		}
+		err := s.drainSegment(ctx, segment, send)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			backoff = s.InitialBackoff
+			continue
+		}
+		log.Err(err).Msgf("error draining spool segment %s, retrying in %s", segment, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
+	}
+}
+
+// Helper to deliver all entries of one segment, retrying each entry until it is delivered
+func (s *Spool) drainSegment(ctx context.Context, segment string, send func(entry SpoolEntry) error) error {
+	f, err := os.Open(filepath.Join(s.Dir, segment))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	backoff := s.InitialBackoff
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var entry SpoolEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			log.Err(err).Msgf("skipping invalid spool entry in %s", segment)
+			continue
+		}
+		for {
+			s.updateAge(entry.Time)
+			err := send(entry)
+			if err == nil {
+				backoff = s.InitialBackoff
+				break
+			}
+			log.Debug().Msgf("spool %s delivery failed, retrying in %s: %v", s.Name, backoff, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > s.MaxBackoff {
+				backoff = s.MaxBackoff
+			}
+		}
+	}
+	return s.remove(segment)
+}
+
+// Helper to get the oldest segment, closing it for writes if it is the current one
+func (s *Spool) oldest() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.segments) == 0 {
+		return ""
+	}
+	if s.current != nil && s.segments[0] == s.currentName() {
+		if err := s.current.Close(); err != nil {
+			log.Err(err).Msg("error closing spool segment")
+		}
+		s.current = nil
+	}
+	return s.segments[0]
+}
+
+// Helper to remove a segment that was fully delivered
+func (s *Spool) remove(segment string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(filepath.Join(s.Dir, segment)); err != nil {
+		return err
+	}
+	delete(s.sizes, segment)
+	for i, seg := range s.segments {
+		if seg == segment {
+			s.segments = append(s.segments[:i], s.segments[i+1:]...)
+			break
+		}
+	}
+	s.updateMetrics()
+	return nil
+}
+
+// Helper to open a new segment for writes, it must be called with the lock held
+func (s *Spool) rotate() error {
+	if s.current != nil {
+		if err := s.current.Close(); err != nil {
+			log.Err(err).Msg("error closing spool segment")
+		}
+		s.current = nil
+	}
+	name := fmt.Sprintf("%020d%s", time.Now().UnixNano(), SpoolSegmentExt)
+	f, err := os.OpenFile(filepath.Join(s.Dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("error creating spool segment - %w", err)
+	}
+	s.current = f
+	s.segments = append(s.segments, name)
+	s.sizes[name] = 0
+	return nil
+}
+
+// Helper to get the name of the segment open for writes
+func (s *Spool) currentName() string {
+	if s.current == nil {
+		return ""
+	}
+	return filepath.Base(s.current.Name())
+}
+
+// Helper to get the total size of all segments, it must be called with the lock held
+func (s *Spool) size() int64 {
+	var total int64
+	for _, size := range s.sizes {
+		total += size
+	}
+	return total
+}
+
+// Helper to update the spool metrics, it must be called with the lock held
+func (s *Spool) updateMetrics() {
+	spoolSize.WithLabelValues(s.Name).Set(float64(s.size()))
+	if len(s.segments) == 0 {
+		spoolOldestAge.WithLabelValues(s.Name).Set(0)
+		return
+	}
+	// Segments are named after their creation time
+	if ts, err := strconv.ParseInt(strings.TrimSuffix(s.segments[0], SpoolSegmentExt), 10, 64); err == nil {
+		spoolOldestAge.WithLabelValues(s.Name).Set(time.Since(time.Unix(0, ts)).Seconds())
+	}
+}
+
+// Helper to update the age of the oldest entry being delivered
+func (s *Spool) updateAge(oldest time.Time) {
+	spoolOldestAge.WithLabelValues(s.Name).Set(time.Since(oldest).Seconds())
+}
+
+// LogSender - Interface for loggers that return delivery errors, so failed logs can be spooled
+type LogSender interface {
+	Logger
+	// SendLog - Function to send logs returning any delivery error
+	SendLog(logType string, data []byte, environment, uuid string, debug bool) error
+}
+
+// LoggerSpool will be used to spool logs to disk when the wrapped logger fails to deliver them
+type LoggerSpool struct {
+	Sender LogSender
+	Spool  *Spool
+	cancel context.CancelFunc
+}
+
+// NewLoggerSpool to wrap a logger with a spool and start draining it in the background
+func NewLoggerSpool(sender LogSender, spool *Spool) *LoggerSpool {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &LoggerSpool{
+		Sender: sender,
+		Spool:  spool,
+		cancel: cancel,
+	}
+	go spool.Drain(ctx, l.deliver)
+	return l
+}
+
+// Stop - Function to stop draining the spool
+func (l *LoggerSpool) Stop() {
+	l.cancel()
+}
+
+// Settings - Function to prepare settings for the wrapped logger
+func (l *LoggerSpool) Settings(mgr *settings.Settings) {
+	l.Sender.Settings(mgr)
+}
+
+// Log - Function that sends JSON result/status logs, spooling them on failure
+func (l *LoggerSpool) Log(logType string, data []byte, environment, uuid string, debug bool) {
+	l.send(logType, data, environment, uuid, debug)
+}
+
+// Query - Function that sends JSON query logs, spooling them on failure
+func (l *LoggerSpool) Query(data []byte, environment, uuid, name string, status int, debug bool) {
+	l.send(types.QueryLog, data, environment, uuid, debug)
+}
+
+// Helper to send logs, or spool them if the sink is failing or there is a backlog to keep order
+func (l *LoggerSpool) send(logType string, data []byte, environment, uuid string, debug bool) {
+	if !l.Spool.Pending() {
+		err := l.Sender.SendLog(logType, data, environment, uuid, debug)
+		if err == nil {
+			return
+		}
+		log.Err(err).Msgf("error sending logs to %s, spooling", l.Spool.Name)
+	}
+	entry := SpoolEntry{
+		Time:        time.Now(),
+		LogType:     logType,
+		Environment: environment,
+		UUID:        uuid,
+		Data:        data,
+	}
+	if err := l.Spool.Write(entry); err != nil {
+		log.Err(err).Msgf("error spooling logs for %s, %d bytes lost", l.Spool.Name, len(data))
+	}
+}
+
+// Helper to deliver spooled entries to the wrapped logger
+func (l *LoggerSpool) deliver(entry SpoolEntry) error {
+	return l.Sender.SendLog(entry.LogType, entry.Data, entry.Environment, entry.UUID, false)
+}
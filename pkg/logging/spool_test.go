@@ -0,0 +1,150 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jmpsec/osctrl/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSink struct {
+	mu       sync.Mutex
+	down     atomic.Bool
+	received int
+}
+
+func (s *testSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.down.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	s.mu.Lock()
+	s.received++
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *testSink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.received
+}
+
+func testSplunk(url string) *LoggerSplunk {
+	return &LoggerSplunk{
+		Configuration: SlunkConfiguration{URL: url},
+		Headers:       map[string]string{},
+		Enabled:       true,
+	}
+}
+
+func TestSpoolWriteRotate(t *testing.T) {
+	spool, err := NewSpool("test", t.TempDir(), 100, 1000)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, spool.Write(SpoolEntry{Time: time.Now(), LogType: types.StatusLog, Data: []byte(`[{"a":"b"}]`)}))
+	}
+	assert.True(t, spool.Pending())
+	assert.Greater(t, len(spool.segments), 1)
+	files, err := os.ReadDir(spool.Dir)
+	require.NoError(t, err)
+	assert.Equal(t, len(spool.segments), len(files))
+}
+
+func TestSpoolFull(t *testing.T) {
+	spool, err := NewSpool("test", t.TempDir(), 0, 150)
+	require.NoError(t, err)
+	assert.NoError(t, spool.Write(SpoolEntry{Data: []byte("a")}))
+	assert.ErrorIs(t, spool.Write(SpoolEntry{Data: []byte("this entry does not fit anymore in the spool")}), ErrSpoolFull)
+}
+
+func TestSpoolReload(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := NewSpool("test", dir, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, spool.Write(SpoolEntry{Data: []byte("a")}))
+	reloaded, err := NewSpool("test", dir, 0, 0)
+	require.NoError(t, err)
+	assert.True(t, reloaded.Pending())
+	assert.Equal(t, spool.Size(), reloaded.Size())
+}
+
+func TestSpoolDrainRetry(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := NewSpool("test", dir, 0, 0)
+	require.NoError(t, err)
+	spool.InitialBackoff = 10 * time.Millisecond
+	spool.MaxBackoff = 50 * time.Millisecond
+	require.NoError(t, spool.Write(SpoolEntry{Data: []byte("a")}))
+	segment := spool.oldest()
+	require.NotEmpty(t, segment)
+	// Segment is missing, the first drain attempt fails
+	hidden := filepath.Join(dir, "hidden")
+	require.NoError(t, os.Rename(filepath.Join(dir, segment), hidden))
+
+	var delivered atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		spool.Drain(ctx, func(entry SpoolEntry) error {
+			delivered.Add(1)
+			return nil
+		})
+		close(done)
+	}()
+	time.Sleep(30 * time.Millisecond)
+	assert.True(t, spool.Pending())
+	assert.Equal(t, int32(0), delivered.Load())
+
+	// Segment is back, the drain keeps going and delivers it
+	require.NoError(t, os.Rename(hidden, filepath.Join(dir, segment)))
+	assert.Eventually(t, func() bool { return !spool.Pending() }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), delivered.Load())
+	cancel()
+	<-done
+}
+
+func TestLoggerSpoolOutage(t *testing.T) {
+	sink := &testSink{}
+	sink.down.Store(true)
+	server := httptest.NewServer(sink)
+	defer server.Close()
+
+	spool, err := NewSpool("splunk", t.TempDir(), 1024, 0)
+	require.NoError(t, err)
+	spool.InitialBackoff = 10 * time.Millisecond
+	spool.MaxBackoff = 50 * time.Millisecond
+	l := NewLoggerSpool(testSplunk(server.URL), spool)
+	defer l.Stop()
+
+	// Sink is down, everything goes to the spool
+	l.Log(types.StatusLog, []byte(`[{"status":"one"}]`), "env", "uuid", false)
+	l.Log(types.ResultLog, []byte(`[{"result":"two"}]`), "env", "uuid", false)
+	l.Query([]byte(`{"query":"three"}`), "env", "uuid", "query", 0, false)
+	assert.True(t, spool.Pending())
+	assert.Equal(t, 0, sink.count())
+
+	// Sink recovers and the spool is drained
+	sink.down.Store(false)
+	assert.Eventually(t, func() bool { return !spool.Pending() }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 3, sink.count())
+	assert.Equal(t, int64(0), spool.Size())
+	files, err := os.ReadDir(spool.Dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	// Sink is up, logs are sent directly
+	l.Log(types.StatusLog, []byte(`[{"status":"four"}]`), "env", "uuid", false)
+	assert.Equal(t, 4, sink.count())
+	assert.False(t, spool.Pending())
+}
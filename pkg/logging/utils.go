@@ -1,6 +1,8 @@
 package logging
 
 import (
+	"fmt"
+
 	"github.com/jmpsec/osctrl/pkg/backend"
 )
 
@@ -16,6 +18,14 @@ func sameConfigDB(loggerOne, loggerTwo backend.JSONConfigurationDB) bool {
 	return (loggerOne.Host == loggerTwo.Host) && (loggerOne.Port == loggerTwo.Port) && (loggerOne.Name == loggerTwo.Name)
 }
 
+// Helper to convert non-successful HTTP responses from logging services into errors
+func checkResponseCode(code int, body []byte) error {
+	if code < 200 || code >= 300 {
+		return fmt.Errorf("HTTP %d %s", code, body)
+	}
+	return nil
+}
+
 // Helper to be used preparing metadata for each decorator
 func metadataVerification(dst, src string) string {
 	if src != dst {
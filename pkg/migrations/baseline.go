@@ -0,0 +1,381 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The baseline schema is frozen here with the tables and columns as they were in version 1, so
+// later changes to the models do not alter what the baseline creates. Columns added after version
+// 1 belong to their own migration and must not be added to these definitions.
+
+type baselineTLSEnvironment struct {
+	gorm.Model
+	UUID             string `gorm:"index"`
+	Name             string
+	Hostname         string
+	Secret           string
+	EnrollSecretPath string
+	EnrollExpire     time.Time
+	RemoveSecretPath string
+	RemoveExpire     time.Time
+	Type             string
+	DebPackage       string
+	RpmPackage       string
+	MsiPackage       string
+	PkgPackage       string
+	DebugHTTP        bool
+	Icon             string
+	Options          string
+	Schedule         string
+	Packs            string
+	Decorators       string
+	ATC              string
+	Configuration    string
+	Flags            string
+	Certificate      string
+	ConfigTLS        bool
+	ConfigInterval   int
+	LoggingTLS       bool
+	LogInterval      int
+	QueryTLS         bool
+	QueryInterval    int
+	CarvesTLS        bool
+	EnrollPath       string
+	LogPath          string
+	ConfigPath       string
+	QueryReadPath    string
+	QueryWritePath   string
+	CarverInitPath   string
+	CarverBlockPath  string
+	AcceptEnrolls    bool
+	UserID           uint
+}
+
+func (baselineTLSEnvironment) TableName() string {
+	return "tls_environments"
+}
+
+type baselineSettingValue struct {
+	gorm.Model
+	Name          string `gorm:"index"`
+	Service       string
+	EnvironmentID uint
+	JSON          bool
+	Type          string
+	String        string
+	Boolean       bool
+	Integer       int64
+	Info          string
+}
+
+func (baselineSettingValue) TableName() string {
+	return "setting_values"
+}
+
+type baselineAdminUser struct {
+	gorm.Model
+	Username      string `gorm:"index"`
+	Email         string
+	Fullname      string
+	PassHash      string
+	APIToken      string
+	TokenExpire   time.Time
+	Admin         bool
+	Service       bool
+	UUID          string
+	CSRFToken     string
+	LastIPAddress string
+	LastUserAgent string
+	LastAccess    time.Time
+	LastTokenUse  time.Time
+	EnvironmentID uint
+}
+
+func (baselineAdminUser) TableName() string {
+	return "admin_users"
+}
+
+type baselineUserPermission struct {
+	gorm.Model
+	Username      string `gorm:"index"`
+	AccessType    int
+	AccessValue   bool
+	Environment   string
+	EnvironmentID uint
+	GrantedBy     string
+}
+
+func (baselineUserPermission) TableName() string {
+	return "user_permissions"
+}
+
+type baselineOsqueryNode struct {
+	gorm.Model
+	NodeKey         string `gorm:"index"`
+	UUID            string `gorm:"index"`
+	Platform        string
+	PlatformVersion string
+	OsqueryVersion  string
+	Hostname        string
+	Localname       string
+	IPAddress       string
+	Username        string
+	OsqueryUser     string
+	Environment     string
+	CPU             string
+	Memory          string
+	HardwareSerial  string
+	DaemonHash      string
+	ConfigHash      string
+	BytesReceived   int
+	RawEnrollment   string
+	LastSeen        time.Time
+	UserID          uint
+	EnvironmentID   uint
+	ExtraData       string
+}
+
+func (baselineOsqueryNode) TableName() string {
+	return "osquery_nodes"
+}
+
+type baselineArchiveOsqueryNode struct {
+	gorm.Model
+	NodeKey         string `gorm:"index"`
+	UUID            string `gorm:"index"`
+	Trigger         string
+	Platform        string
+	PlatformVersion string
+	OsqueryVersion  string
+	Hostname        string
+	Localname       string
+	IPAddress       string
+	Username        string
+	OsqueryUser     string
+	Environment     string
+	CPU             string
+	Memory          string
+	HardwareSerial  string
+	ConfigHash      string
+	DaemonHash      string
+	BytesReceived   int
+	RawEnrollment   string
+	LastSeen        time.Time
+	UserID          uint
+	EnvironmentID   uint
+	ExtraData       string
+}
+
+func (baselineArchiveOsqueryNode) TableName() string {
+	return "archive_osquery_nodes"
+}
+
+type baselineAdminTag struct {
+	gorm.Model
+	Name          string `gorm:"index"`
+	Description   string
+	Color         string
+	Icon          string
+	CreatedBy     string
+	CustomTag     string
+	AutoTag       bool
+	EnvironmentID uint
+	TagType       uint
+	Cohort        bool
+}
+
+func (baselineAdminTag) TableName() string {
+	return "admin_tags"
+}
+
+type baselineTaggedNode struct {
+	gorm.Model
+	Tag        string
+	AdminTagID uint
+	NodeID     uint
+	AutoTag    bool
+	TaggedBy   string
+	UserID     uint
+}
+
+func (baselineTaggedNode) TableName() string {
+	return "tagged_nodes"
+}
+
+type baselineDistributedQuery struct {
+	gorm.Model
+	Name          string `gorm:"not null;unique;index"`
+	Creator       string
+	Query         string
+	Expected      int
+	Executions    int
+	Errors        int
+	Active        bool
+	Hidden        bool
+	Protected     bool
+	Completed     bool
+	Deleted       bool
+	Expired       bool
+	Type          string
+	Path          string
+	EnvironmentID uint
+	ExtraData     string
+	Expiration    time.Time
+	Target        string
+}
+
+func (baselineDistributedQuery) TableName() string {
+	return "distributed_queries"
+}
+
+type baselineNodeQuery struct {
+	gorm.Model
+	NodeID  uint   `gorm:"not null;index"`
+	QueryID uint   `gorm:"not null;index"`
+	Status  string `gorm:"type:varchar(10);default:'pending'"`
+}
+
+func (baselineNodeQuery) TableName() string {
+	return "node_queries"
+}
+
+type baselineDistributedQueryTarget struct {
+	gorm.Model
+	Name  string `gorm:"index"`
+	Type  string
+	Value string
+}
+
+func (baselineDistributedQueryTarget) TableName() string {
+	return "distributed_query_targets"
+}
+
+type baselineSavedQuery struct {
+	gorm.Model
+	Name          string
+	Creator       string
+	Query         string
+	EnvironmentID uint
+	ExtraData     string
+}
+
+func (baselineSavedQuery) TableName() string {
+	return "saved_queries"
+}
+
+type baselineRecurringQuery struct {
+	gorm.Model
+	Name          string `gorm:"index"`
+	Creator       string
+	Query         string
+	EnvironmentID uint `gorm:"index"`
+	Cron          string
+	Interval      int
+	ExpHours      int
+	Hidden        bool
+	Paused        bool
+	Targets       string
+	NextRun       time.Time `gorm:"index"`
+	LastRun       time.Time
+	Runs          int
+}
+
+func (baselineRecurringQuery) TableName() string {
+	return "recurring_queries"
+}
+
+type baselineRecurringQueryRun struct {
+	gorm.Model
+	RecurringQueryID uint `gorm:"index"`
+	QueryName        string
+	Expected         int
+	Error            string
+	RunAt            time.Time
+}
+
+func (baselineRecurringQueryRun) TableName() string {
+	return "recurring_query_runs"
+}
+
+type baselineCarvedFile struct {
+	gorm.Model
+	CarveID         string `gorm:"unique;index"`
+	RequestID       string
+	SessionID       string
+	QueryName       string
+	UUID            string `gorm:"index"`
+	NodeID          uint
+	Environment     string
+	Path            string
+	CarveSize       int
+	BlockSize       int
+	TotalBlocks     int
+	CompletedBlocks int
+	Status          string
+	CompletedAt     time.Time
+	Carver          string
+	Archived        bool
+	ArchivePath     string
+	EnvironmentID   uint
+}
+
+func (baselineCarvedFile) TableName() string {
+	return "carved_files"
+}
+
+type baselineCarvedBlock struct {
+	gorm.Model
+	RequestID     string `gorm:"index"`
+	SessionID     string `gorm:"index"`
+	Environment   string
+	BlockID       int
+	Data          string
+	Size          int
+	Carver        string
+	EnvironmentID uint
+}
+
+func (baselineCarvedBlock) TableName() string {
+	return "carved_blocks"
+}
+
+type baselineAuditLog struct {
+	gorm.Model
+	Service       string
+	Username      string
+	Line          string
+	LogType       uint
+	Severity      uint
+	SourceIP      string
+	EnvironmentID uint
+}
+
+func (baselineAuditLog) TableName() string {
+	return "audit_logs"
+}
+
+// Helper to get the tables of the baseline schema in the main database. Sessions are created by
+// the admin service and logs can be stored in a different database, so they are not included.
+func baselineModels() []interface{} {
+	return []interface{}{
+		&baselineTLSEnvironment{},
+		&baselineSettingValue{},
+		&baselineAdminUser{},
+		&baselineUserPermission{},
+		&baselineOsqueryNode{},
+		&baselineArchiveOsqueryNode{},
+		&baselineAdminTag{},
+		&baselineTaggedNode{},
+		&baselineDistributedQuery{},
+		&baselineNodeQuery{},
+		&baselineDistributedQueryTarget{},
+		&baselineSavedQuery{},
+		&baselineRecurringQuery{},
+		&baselineRecurringQueryRun{},
+		&baselineCarvedFile{},
+		&baselineCarvedBlock{},
+		&baselineAuditLog{},
+	}
+}
@@ -0,0 +1,236 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrSchemaMismatch is returned when the schema version in the database is not the latest known version
+var ErrSchemaMismatch = errors.New("schema version mismatch")
+
+// ErrBaselineRollback is returned when a rollback would revert the baseline, dropping all the tables
+var ErrBaselineRollback = errors.New("rollback of the baseline drops all tables")
+
+// SchemaVersion to keep track of the applied migrations
+type SchemaVersion struct {
+	Version   uint `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// TableName to use schema_version as table for the applied migrations
+func (SchemaVersion) TableName() string {
+	return "schema_version"
+}
+
+// Migration to hold one versioned change of the schema
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// MigrationStatus to display the state of each migration
+type MigrationStatus struct {
+	Version   uint      `json:"version"`
+	Name      string    `json:"name"`
+	Applied   bool      `json:"applied"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// Migrator to apply and rollback migrations
+type Migrator struct {
+	DB         *gorm.DB
+	Migrations []Migration
+}
+
+// CreateMigrator to initialize the migrator with all the known migrations and the schema_version table
+func CreateMigrator(backend *gorm.DB) (*Migrator, error) {
+	return CreateMigratorWith(backend, All)
+}
+
+// CreateMigratorWith to initialize the migrator with a list of migrations, sorted by version
+func CreateMigratorWith(backend *gorm.DB, migrations []Migration) (*Migrator, error) {
+	m := &Migrator{DB: backend, Migrations: migrations}
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version <= migrations[i-1].Version {
+			return nil, fmt.Errorf("migration %d is not sorted", migrations[i].Version)
+		}
+	}
+	// table schema_version
+	if err := backend.AutoMigrate(&SchemaVersion{}); err != nil {
+		return nil, fmt.Errorf("failed to AutoMigrate table (schema_version): %w", err)
+	}
+	return m, nil
+}
+
+// Applied to get the applied migrations, sorted by version
+func (m *Migrator) Applied() ([]SchemaVersion, error) {
+	var applied []SchemaVersion
+	if err := m.DB.Order("version").Find(&applied).Error; err != nil {
+		return applied, err
+	}
+	return applied, nil
+}
+
+// Current to get the current version of the schema, zero if no migrations were applied
+func (m *Migrator) Current() (uint, error) {
+	applied, err := m.Applied()
+	if err != nil {
+		return 0, err
+	}
+	if len(applied) == 0 {
+		return 0, nil
+	}
+	return applied[len(applied)-1].Version, nil
+}
+
+// Latest to get the version of the last known migration
+func (m *Migrator) Latest() uint {
+	if len(m.Migrations) == 0 {
+		return 0
+	}
+	return m.Migrations[len(m.Migrations)-1].Version
+}
+
+// Status to get the status of all known migrations
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	var status []MigrationStatus
+	applied, err := m.Applied()
+	if err != nil {
+		return status, err
+	}
+	byVersion := make(map[uint]SchemaVersion, len(applied))
+	for _, a := range applied {
+		byVersion[a.Version] = a
+	}
+	for _, mig := range m.Migrations {
+		s := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if a, ok := byVersion[mig.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = a.AppliedAt
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// Pending to get the migrations that are not applied yet
+func (m *Migrator) Pending() ([]Migration, error) {
+	var pending []Migration
+	status, err := m.Status()
+	if err != nil {
+		return pending, err
+	}
+	for i, s := range status {
+		if !s.Applied {
+			pending = append(pending, m.Migrations[i])
+		}
+	}
+	return pending, nil
+}
+
+// Check to verify that all known migrations are applied and the database is not ahead of this binary
+func (m *Migrator) Check() error {
+	pending, err := m.Pending()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %d pending migrations, latest is %d", ErrSchemaMismatch, len(pending), m.Latest())
+	}
+	current, err := m.Current()
+	if err != nil {
+		return err
+	}
+	if current > m.Latest() {
+		return fmt.Errorf("%w: database is at version %d, latest known is %d", ErrSchemaMismatch, current, m.Latest())
+	}
+	return nil
+}
+
+// Migrate to apply all pending migrations up to the target version, zero for the latest
+func (m *Migrator) Migrate(target uint) ([]Migration, error) {
+	var done []Migration
+	pending, err := m.Pending()
+	if err != nil {
+		return done, err
+	}
+	for _, mig := range pending {
+		if target > 0 && mig.Version > target {
+			break
+		}
+		if err := m.DB.Transaction(func(tx *gorm.DB) error {
+			if err := mig.Up(tx); err != nil {
+				return err
+			}
+			// Another replica could have applied the same migration at the same time
+			return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&SchemaVersion{
+				Version:   mig.Version,
+				Name:      mig.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		}); err != nil {
+			return done, fmt.Errorf("migration %d (%s) failed - %w", mig.Version, mig.Name, err)
+		}
+		log.Debug().Msgf("Applied migration %d (%s)", mig.Version, mig.Name)
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Rollback to revert the last applied migrations, as many as steps. The baseline is only reverted if force is set
+func (m *Migrator) Rollback(steps int, force bool) ([]Migration, error) {
+	var done []Migration
+	applied, err := m.Applied()
+	if err != nil {
+		return done, err
+	}
+	byVersion := make(map[uint]Migration, len(m.Migrations))
+	for _, mig := range m.Migrations {
+		byVersion[mig.Version] = mig
+	}
+	for i := len(applied) - 1; i >= 0 && len(done) < steps; i-- {
+		mig, ok := byVersion[applied[i].Version]
+		if !ok {
+			return done, fmt.Errorf("%w: unknown migration %d", ErrSchemaMismatch, applied[i].Version)
+		}
+		if i == 0 && mig.Version == m.Migrations[0].Version && !force {
+			return done, fmt.Errorf("%w: migration %d (%s) requires force", ErrBaselineRollback, mig.Version, mig.Name)
+		}
+		if err := m.DB.Transaction(func(tx *gorm.DB) error {
+			if err := mig.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaVersion{}, mig.Version).Error
+		}); err != nil {
+			return done, fmt.Errorf("rollback %d (%s) failed - %w", mig.Version, mig.Name, err)
+		}
+		log.Debug().Msgf("Rolled back migration %d (%s)", mig.Version, mig.Name)
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Startup - Function to prepare the schema when a service starts. If check is enabled the service
+// must refuse to start when the schema is not at the latest version, otherwise pending migrations are applied
+func Startup(backend *gorm.DB, check bool) error {
+	m, err := CreateMigrator(backend)
+	if err != nil {
+		return err
+	}
+	if check {
+		return m.Check()
+	}
+	done, err := m.Migrate(0)
+	for _, mig := range done {
+		log.Info().Msgf("Applied migration %d (%s)", mig.Version, mig.Name)
+	}
+	return err
+}
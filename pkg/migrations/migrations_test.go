@@ -0,0 +1,188 @@
+package migrations
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jmpsec/osctrl/pkg/auditlog"
+	"github.com/jmpsec/osctrl/pkg/carves"
+	"github.com/jmpsec/osctrl/pkg/environments"
+	"github.com/jmpsec/osctrl/pkg/nodes"
+	"github.com/jmpsec/osctrl/pkg/queries"
+	"github.com/jmpsec/osctrl/pkg/settings"
+	"github.com/jmpsec/osctrl/pkg/tags"
+	"github.com/jmpsec/osctrl/pkg/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// testDB creates an in-memory SQLite database for testing
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err, "Failed to open in-memory database")
+	return db
+}
+
+func TestMigrateAll(t *testing.T) {
+	db := testDB(t)
+	m, err := CreateMigrator(db)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, m.Check(), ErrSchemaMismatch)
+	done, err := m.Migrate(0)
+	require.NoError(t, err)
+	assert.Len(t, done, len(All))
+	assert.NoError(t, m.Check())
+
+	current, err := m.Current()
+	require.NoError(t, err)
+	assert.Equal(t, m.Latest(), current)
+	assert.True(t, db.Migrator().HasTable(&queries.DistributedQuery{}))
+	assert.True(t, db.Migrator().HasColumn(&queries.NodeQuery{}, "Message"))
+
+	// Migrating again does nothing
+	done, err = m.Migrate(0)
+	require.NoError(t, err)
+	assert.Empty(t, done)
+}
+
+func TestRollback(t *testing.T) {
+	db := testDB(t)
+	m, err := CreateMigrator(db)
+	require.NoError(t, err)
+	_, err = m.Migrate(0)
+	require.NoError(t, err)
+
+	// Rollback everything but the baseline
+	done, err := m.Rollback(len(All)-1, false)
+	require.NoError(t, err)
+	require.Len(t, done, len(All)-1)
+	assert.Equal(t, m.Latest(), done[0].Version)
+	assert.Equal(t, uint(2), done[len(done)-1].Version)
+	assert.False(t, db.Migrator().HasColumn(&queries.DistributedQuery{}, "Urgent"))
+	assert.False(t, db.Migrator().HasColumn(&queries.NodeQuery{}, "Message"))
+	assert.ErrorIs(t, m.Check(), ErrSchemaMismatch)
+
+	status, err := m.Status()
+	require.NoError(t, err)
+	require.Len(t, status, len(All))
+	assert.True(t, status[0].Applied)
+	for _, s := range status[1:] {
+		assert.False(t, s.Applied)
+	}
+
+	// Migrate only up to version 2
+	done, err = m.Migrate(2)
+	require.NoError(t, err)
+	require.Len(t, done, 1)
+	current, err := m.Current()
+	require.NoError(t, err)
+	assert.Equal(t, uint(2), current)
+	assert.True(t, db.Migrator().HasColumn(&queries.NodeQuery{}, "Message"))
+}
+
+func TestRollbackFailure(t *testing.T) {
+	db := testDB(t)
+	failing := []Migration{
+		{
+			Version: 1,
+			Name:    "first",
+			Up:      func(tx *gorm.DB) error { return nil },
+			Down:    func(tx *gorm.DB) error { return errors.New("can not rollback") },
+		},
+	}
+	m, err := CreateMigratorWith(db, failing)
+	require.NoError(t, err)
+	_, err = m.Migrate(0)
+	require.NoError(t, err)
+
+	_, err = m.Rollback(1, true)
+	assert.Error(t, err)
+	// The version is kept when the rollback fails
+	current, err := m.Current()
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), current)
+}
+
+func TestRollbackBaseline(t *testing.T) {
+	db := testDB(t)
+	m, err := CreateMigrator(db)
+	require.NoError(t, err)
+	_, err = m.Migrate(1)
+	require.NoError(t, err)
+
+	// The baseline drops every table, so it is only reverted when forced
+	done, err := m.Rollback(1, false)
+	assert.ErrorIs(t, err, ErrBaselineRollback)
+	assert.Empty(t, done)
+	assert.True(t, db.Migrator().HasTable(&users.AdminUser{}))
+	done, err = m.Rollback(1, true)
+	require.NoError(t, err)
+	assert.Len(t, done, 1)
+	assert.False(t, db.Migrator().HasTable(&users.AdminUser{}))
+}
+
+func TestSchemaMatchesModels(t *testing.T) {
+	db := testDB(t)
+	require.NoError(t, Startup(db, false))
+
+	// Every column of the models must be created by the baseline or a later migration
+	models := []interface{}{
+		&environments.TLSEnvironment{},
+		&environments.EnrollToken{},
+		&environments.ClientCert{},
+		&settings.SettingValue{},
+		&users.AdminUser{},
+		&users.UserPermission{},
+		&users.UserToken{},
+		&nodes.OsqueryNode{},
+		&nodes.ArchiveOsqueryNode{},
+		&tags.AdminTag{},
+		&tags.TaggedNode{},
+		&queries.DistributedQuery{},
+		&queries.NodeQuery{},
+		&queries.DistributedQueryTarget{},
+		&queries.SavedQuery{},
+		&queries.RecurringQuery{},
+		&queries.RecurringQueryRun{},
+		&carves.CarvedFile{},
+		&carves.CarvedBlock{},
+		&auditlog.AuditLog{},
+	}
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		require.NoError(t, stmt.Parse(model))
+		require.True(t, db.Migrator().HasTable(model), "missing table %s", stmt.Schema.Table)
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			assert.True(t, db.Migrator().HasColumn(model, field.DBName), "missing column %s.%s", stmt.Schema.Table, field.DBName)
+		}
+		for _, idx := range stmt.Schema.ParseIndexes() {
+			assert.True(t, db.Migrator().HasIndex(model, idx.Name), "missing index %s.%s", stmt.Schema.Table, idx.Name)
+		}
+	}
+}
+
+func TestCheckDatabaseAhead(t *testing.T) {
+	db := testDB(t)
+	m, err := CreateMigrator(db)
+	require.NoError(t, err)
+	_, err = m.Migrate(0)
+	require.NoError(t, err)
+
+	// A binary that knows fewer migrations than the database must refuse to start
+	older, err := CreateMigratorWith(db, All[:1])
+	require.NoError(t, err)
+	assert.ErrorIs(t, older.Check(), ErrSchemaMismatch)
+	assert.NoError(t, Startup(db, true))
+}
+
+func TestUnsortedMigrations(t *testing.T) {
+	_, err := CreateMigratorWith(testDB(t), []Migration{All[1], All[0]})
+	assert.Error(t, err)
+}
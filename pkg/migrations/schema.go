@@ -0,0 +1,262 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// All known migrations, sorted by version. New changes to the schema must be appended here
+// with the next version, never modifying a migration that has been released.
+var All = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(baselineModels()...)
+		},
+		Down: func(tx *gorm.DB) error {
+			models := baselineModels()
+			for i := len(models) - 1; i >= 0; i-- {
+				if err := tx.Migrator().DropTable(models[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 2,
+		Name:    "node_queries_message",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &v2NodeQuery{}, "Message")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &v2NodeQuery{}, "Message")
+		},
+	},
+	{
+		Version: 3,
+		Name:    "distributed_queries_urgent",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &v3DistributedQuery{}, "Urgent", "AccelerateUntil")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &v3DistributedQuery{}, "Urgent", "AccelerateUntil")
+		},
+	},
+	{
+		Version: 4,
+		Name:    "carves_integrity",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &v4CarvedFile{}, "SHA256", "Integrity", "IntegrityReason"); err != nil {
+				return err
+			}
+			return addColumns(tx, &v4CarvedBlock{}, "SHA256")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumns(tx, &v4CarvedBlock{}, "SHA256"); err != nil {
+				return err
+			}
+			return dropColumns(tx, &v4CarvedFile{}, "SHA256", "Integrity", "IntegrityReason")
+		},
+	},
+	{
+		Version: 5,
+		Name:    "environments_carve_limits",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &v5TLSEnvironment{}, "CarveMaxSize", "CarveQuota", "CarveRetention")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &v5TLSEnvironment{}, "CarveMaxSize", "CarveQuota", "CarveRetention")
+		},
+	},
+	{
+		Version: 6,
+		Name:    "carves_stale",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &v6CarvedFile{}, "StatusReason", "ReissueQuery", "Reissues")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &v6CarvedFile{}, "StatusReason", "ReissueQuery", "Reissues")
+		},
+	},
+	{
+		Version: 7,
+		Name:    "environments_node_lifecycle",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &v7TLSEnvironment{}, "NodeArchiveDays", "NodePurgeDays")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &v7TLSEnvironment{}, "NodeArchiveDays", "NodePurgeDays")
+		},
+	},
+	{
+		Version: 8,
+		Name:    "environments_duplicate_policy",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &v8TLSEnvironment{}, "DuplicatePolicy")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &v8TLSEnvironment{}, "DuplicatePolicy")
+		},
+	},
+	{
+		Version: 9,
+		Name:    "user_tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v9UserToken{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v9UserToken{})
+		},
+	},
+	{
+		Version: 10,
+		Name:    "users_mfa",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &v10AdminUser{}, "MFAEnabled", "MFASecret", "MFARecoveryCodes", "MFALastStep")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &v10AdminUser{}, "MFAEnabled", "MFASecret", "MFARecoveryCodes", "MFALastStep")
+		},
+	},
+	{
+		Version: 11,
+		Name:    "users_lockout",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &v11AdminUser{}, "FailedLogins", "LastFailedLogin", "LockedUntil")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &v11AdminUser{}, "FailedLogins", "LastFailedLogin", "LockedUntil")
+		},
+	},
+	{
+		Version: 12,
+		Name:    "enroll_tokens",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&v12EnrollToken{}); err != nil {
+				return err
+			}
+			if err := addColumns(tx, &v12TLSEnvironment{}, "EnrollTokens", "EnrollApproval"); err != nil {
+				return err
+			}
+			return addColumns(tx, &v12OsqueryNode{}, "Pending")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&v12EnrollToken{}); err != nil {
+				return err
+			}
+			if err := dropColumns(tx, &v12TLSEnvironment{}, "EnrollTokens", "EnrollApproval"); err != nil {
+				return err
+			}
+			return dropColumns(tx, &v12OsqueryNode{}, "Pending")
+		},
+	},
+	{
+		Version: 13,
+		Name:    "client_certs",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&v13ClientCert{}); err != nil {
+				return err
+			}
+			if err := addColumns(tx, &v13TLSEnvironment{}, "ClientCerts", "ClientCACert", "ClientCAKey"); err != nil {
+				return err
+			}
+			return addColumns(tx, &v13OsqueryNode{}, "CertFingerprint")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&v13ClientCert{}); err != nil {
+				return err
+			}
+			if err := dropColumns(tx, &v13TLSEnvironment{}, "ClientCerts", "ClientCACert", "ClientCAKey"); err != nil {
+				return err
+			}
+			return dropColumns(tx, &v13OsqueryNode{}, "CertFingerprint")
+		},
+	},
+	{
+		Version: 14,
+		Name:    "users_sso_only",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &v14AdminUser{}, "SSOOnly")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &v14AdminUser{}, "SSOOnly")
+		},
+	},
+	{
+		Version: 15,
+		Name:    "osquery_nodes_cert_fingerprint_index",
+		Up: func(tx *gorm.DB) error {
+			return addIndexes(tx, &v15OsqueryNode{}, "idx_osquery_nodes_cert_fingerprint")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropIndexes(tx, &v15OsqueryNode{}, "idx_osquery_nodes_cert_fingerprint")
+		},
+	},
+	{
+		Version: 16,
+		Name:    "carved_blocks_unique",
+		Up: func(tx *gorm.DB) error {
+			// Remove blocks stored more than once before making them unique
+			if err := tx.Exec("DELETE FROM carved_blocks WHERE id NOT IN (SELECT id FROM (SELECT MIN(id) AS id FROM carved_blocks GROUP BY session_id, block_id) AS first_blocks)").Error; err != nil {
+				return err
+			}
+			return addIndexes(tx, &v16CarvedBlock{}, "idx_carved_blocks_session_block")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropIndexes(tx, &v16CarvedBlock{}, "idx_carved_blocks_session_block")
+		},
+	},
+}
+
+// Helper to add columns of a model if they do not exist yet
+func addColumns(tx *gorm.DB, model interface{}, fields ...string) error {
+	for _, f := range fields {
+		if tx.Migrator().HasColumn(model, f) {
+			continue
+		}
+		if err := tx.Migrator().AddColumn(model, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper to drop columns of a model if they exist
+func dropColumns(tx *gorm.DB, model interface{}, fields ...string) error {
+	for _, f := range fields {
+		if !tx.Migrator().HasColumn(model, f) {
+			continue
+		}
+		if err := tx.Migrator().DropColumn(model, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper to create indexes of a model if they do not exist yet
+func addIndexes(tx *gorm.DB, model interface{}, names ...string) error {
+	for _, n := range names {
+		if tx.Migrator().HasIndex(model, n) {
+			continue
+		}
+		if err := tx.Migrator().CreateIndex(model, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper to drop indexes of a model if they exist
+func dropIndexes(tx *gorm.DB, model interface{}, names ...string) error {
+	for _, n := range names {
+		if !tx.Migrator().HasIndex(model, n) {
+			continue
+		}
+		if err := tx.Migrator().DropIndex(model, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
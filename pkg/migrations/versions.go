@@ -0,0 +1,219 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The changes of each migration after the baseline are frozen here, with only the columns and
+// indexes added by that version, so later changes to the models do not alter released migrations.
+// Definitions are named after the version that uses them and must not be modified once released.
+
+type v2NodeQuery struct {
+	Message string
+}
+
+func (v2NodeQuery) TableName() string {
+	return "node_queries"
+}
+
+type v3DistributedQuery struct {
+	Urgent          bool
+	AccelerateUntil time.Time
+}
+
+func (v3DistributedQuery) TableName() string {
+	return "distributed_queries"
+}
+
+type v4CarvedFile struct {
+	SHA256          string
+	Integrity       string
+	IntegrityReason string
+}
+
+func (v4CarvedFile) TableName() string {
+	return "carved_files"
+}
+
+type v4CarvedBlock struct {
+	SHA256 string
+}
+
+func (v4CarvedBlock) TableName() string {
+	return "carved_blocks"
+}
+
+type v5TLSEnvironment struct {
+	CarveMaxSize   int64
+	CarveQuota     int64
+	CarveRetention int
+}
+
+func (v5TLSEnvironment) TableName() string {
+	return "tls_environments"
+}
+
+type v6CarvedFile struct {
+	StatusReason string
+	ReissueQuery string
+	Reissues     int
+}
+
+func (v6CarvedFile) TableName() string {
+	return "carved_files"
+}
+
+type v7TLSEnvironment struct {
+	NodeArchiveDays int
+	NodePurgeDays   int
+}
+
+func (v7TLSEnvironment) TableName() string {
+	return "tls_environments"
+}
+
+type v8TLSEnvironment struct {
+	DuplicatePolicy string
+}
+
+func (v8TLSEnvironment) TableName() string {
+	return "tls_environments"
+}
+
+type v9UserToken struct {
+	gorm.Model
+	TokenID       string `gorm:"uniqueIndex"`
+	Username      string `gorm:"index"`
+	Name          string
+	TokenHash     string
+	Environment   string
+	Scope         string
+	ExpiresAt     time.Time
+	LastUsed      time.Time
+	LastIPAddress string
+	Revoked       bool
+	RevokedAt     time.Time
+	RevokedBy     string
+}
+
+func (v9UserToken) TableName() string {
+	return "user_tokens"
+}
+
+type v10AdminUser struct {
+	MFAEnabled       bool
+	MFASecret        string
+	MFARecoveryCodes string
+	MFALastStep      int64
+}
+
+func (v10AdminUser) TableName() string {
+	return "admin_users"
+}
+
+type v11AdminUser struct {
+	FailedLogins    int
+	LastFailedLogin time.Time
+	LockedUntil     time.Time
+}
+
+func (v11AdminUser) TableName() string {
+	return "admin_users"
+}
+
+type v12EnrollToken struct {
+	gorm.Model
+	TokenID       string `gorm:"uniqueIndex"`
+	EnvironmentID uint   `gorm:"index"`
+	TokenHash     string `gorm:"index"`
+	Hostname      string
+	Serial        string
+	MaxUses       int
+	Uses          int
+	ExpiresAt     time.Time
+	LastUsed      time.Time
+	LastUsedBy    string
+	CreatedBy     string
+	Revoked       bool
+}
+
+func (v12EnrollToken) TableName() string {
+	return "enroll_tokens"
+}
+
+type v12TLSEnvironment struct {
+	EnrollTokens   bool
+	EnrollApproval bool
+}
+
+func (v12TLSEnvironment) TableName() string {
+	return "tls_environments"
+}
+
+type v12OsqueryNode struct {
+	Pending bool
+}
+
+func (v12OsqueryNode) TableName() string {
+	return "osquery_nodes"
+}
+
+type v13ClientCert struct {
+	gorm.Model
+	EnvironmentID uint   `gorm:"index"`
+	UUID          string `gorm:"index"`
+	SerialNumber  string
+	Fingerprint   string `gorm:"uniqueIndex"`
+	NotAfter      time.Time
+	Revoked       bool
+	RevokedBy     string
+}
+
+func (v13ClientCert) TableName() string {
+	return "client_certs"
+}
+
+type v13TLSEnvironment struct {
+	ClientCerts  bool
+	ClientCACert string
+	ClientCAKey  string
+}
+
+func (v13TLSEnvironment) TableName() string {
+	return "tls_environments"
+}
+
+type v13OsqueryNode struct {
+	CertFingerprint string
+}
+
+func (v13OsqueryNode) TableName() string {
+	return "osquery_nodes"
+}
+
+type v14AdminUser struct {
+	SSOOnly bool
+}
+
+func (v14AdminUser) TableName() string {
+	return "admin_users"
+}
+
+type v15OsqueryNode struct {
+	CertFingerprint string `gorm:"index"`
+}
+
+func (v15OsqueryNode) TableName() string {
+	return "osquery_nodes"
+}
+
+type v16CarvedBlock struct {
+	SessionID string `gorm:"uniqueIndex:idx_carved_blocks_session_block"`
+	BlockID   int    `gorm:"uniqueIndex:idx_carved_blocks_session_block"`
+}
+
+func (v16CarvedBlock) TableName() string {
+	return "carved_blocks"
+}
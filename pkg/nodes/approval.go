@@ -0,0 +1,50 @@
+package nodes
+
+import (
+	"fmt"
+
+	"github.com/jmpsec/osctrl/pkg/cache"
+)
+
+// GetPending to retrieve the nodes of an environment waiting for approval
+func (n *NodeManager) GetPending(envID uint) ([]OsqueryNode, error) {
+	var nodes []OsqueryNode
+	if err := n.DB.Where("environment_id = ? AND pending = ?", envID, true).Order("created_at").Find(&nodes).Error; err != nil {
+		return nodes, err
+	}
+	return nodes, nil
+}
+
+// CountPending to count the nodes of an environment waiting for approval
+func (n *NodeManager) CountPending(envID uint) (int64, error) {
+	var count int64
+	if err := n.DB.Model(&OsqueryNode{}).Where("environment_id = ? AND pending = ?", envID, true).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("count pending %w", err)
+	}
+	return count, nil
+}
+
+// GetApprovedIDs to filter a list of node IDs, keeping only the nodes not waiting for approval
+func (n *NodeManager) GetApprovedIDs(ids []uint) ([]uint, error) {
+	var approved []uint
+	if len(ids) == 0 {
+		return approved, nil
+	}
+	// Nodes enrolled before approvals were added have no value for pending
+	if err := n.DB.Model(&OsqueryNode{}).Where("id IN ? AND (pending = ? OR pending IS NULL)", ids, false).Order("id").Pluck("id", &approved).Error; err != nil {
+		return approved, err
+	}
+	return approved, nil
+}
+
+// Approve to approve a pending node, so it starts receiving configuration and queries
+func (n *NodeManager) Approve(node OsqueryNode) error {
+	if !node.Pending {
+		return fmt.Errorf("node %s is not pending approval", node.UUID)
+	}
+	if err := n.DB.Model(&node).Update("pending", false).Error; err != nil {
+		return fmt.Errorf("update %w", err)
+	}
+	cache.PublishInvalidation(n.Bus, cache.TopicNodes, node.NodeKey)
+	return nil
+}
@@ -0,0 +1,55 @@
+package nodes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestApproval(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&OsqueryNode{}, &ArchiveOsqueryNode{}))
+	n := CreateNodes(db)
+	defer n.Cache.Close()
+
+	existing := []OsqueryNode{
+		{UUID: "UUID-1", NodeKey: "k1", EnvironmentID: 1, Pending: true},
+		{UUID: "UUID-2", NodeKey: "k2", EnvironmentID: 1},
+		{UUID: "UUID-3", NodeKey: "k3", EnvironmentID: 2, Pending: true},
+	}
+	for i := range existing {
+		require.NoError(t, db.Create(&existing[i]).Error)
+	}
+	approvedIDs, err := n.GetApprovedIDs([]uint{1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, []uint{2}, approvedIDs)
+	pending, err := n.GetPending(1)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "UUID-1", pending[0].UUID)
+
+	require.NoError(t, n.Approve(pending[0]))
+	count, err := n.CountPending(1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+	count, err = n.CountPending(2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	approved, err := n.GetByUUIDEnv("UUID-1", 1)
+	require.NoError(t, err)
+	assert.False(t, approved.Pending)
+	assert.Error(t, n.Approve(approved))
+
+	// Enrolling again clears the approval and the certificate binding when they are not needed
+	require.NoError(t, db.Model(&OsqueryNode{}).Where("uuid = ?", "UUID-3").Update("cert_fingerprint", "abc").Error)
+	require.NoError(t, n.UpdateByUUID(OsqueryNode{NodeKey: "k4", Pending: false, CertFingerprint: ""}, "UUID-3"))
+	reenrolled, err := n.GetByUUIDEnv("UUID-3", 2)
+	require.NoError(t, err)
+	assert.Equal(t, "k4", reenrolled.NodeKey)
+	assert.False(t, reenrolled.Pending)
+	assert.Empty(t, reenrolled.CertFingerprint)
+}
@@ -0,0 +1,17 @@
+package nodes
+
+import (
+	"fmt"
+
+	"github.com/jmpsec/osctrl/pkg/cache"
+)
+
+// UnbindCert to remove the client certificate bound to a node, so its requests are rejected
+// until it enrolls again with a valid client certificate
+func (n *NodeManager) UnbindCert(node OsqueryNode) error {
+	if err := n.DB.Model(&node).Update("cert_fingerprint", "").Error; err != nil {
+		return fmt.Errorf("update %w", err)
+	}
+	cache.PublishInvalidation(n.Bus, cache.TopicNodes, node.NodeKey)
+	return nil
+}
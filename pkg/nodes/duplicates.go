@@ -0,0 +1,127 @@
+package nodes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// DuplicateMerge to merge the history of duplicated nodes into the enrolling node
+	DuplicateMerge string = "merge"
+	// DuplicateKeep to keep duplicated nodes as separate nodes
+	DuplicateKeep string = "keep"
+	// DuplicateReject to reject the enrollment of nodes that are duplicated
+	DuplicateReject string = "reject"
+)
+
+const (
+	// DuplicateByUUID for nodes sharing the same UUID
+	DuplicateByUUID string = "uuid"
+	// DuplicateBySerial for nodes sharing the same hardware serial
+	DuplicateBySerial string = "hardware_serial"
+	// DuplicateByHostname for nodes sharing the same hostname
+	DuplicateByHostname string = "hostname"
+)
+
+// Hardware serials reported by virtual machines and generic hardware that do not identify a host
+var ignoredSerials = []string{"", "0", "none", "n/a", "not specified", "not available", "default string", "system serial number", "to be filled by o.e.m."}
+
+// DuplicateGroup to hold nodes of an environment that share the same value for an identifying field
+type DuplicateGroup struct {
+	Field string        `json:"field"`
+	Value string        `json:"value"`
+	Nodes []OsqueryNode `json:"nodes"`
+}
+
+// ValidDuplicatePolicy - Function to check if a duplicate policy is valid, empty keeps the default behavior
+func ValidDuplicatePolicy(policy string) bool {
+	switch policy {
+	case "", DuplicateMerge, DuplicateKeep, DuplicateReject:
+		return true
+	}
+	return false
+}
+
+// IdentifyingSerial - Function to check if a hardware serial can be used to identify a host
+func IdentifyingSerial(serial string) bool {
+	s := strings.ToLower(strings.TrimSpace(serial))
+	for _, i := range ignoredSerials {
+		if s == i {
+			return false
+		}
+	}
+	return true
+}
+
+// FindDuplicates to get the nodes in the same environment that share UUID, hardware serial or hostname
+// with the provided node, excluding the node itself
+func (n *NodeManager) FindDuplicates(node OsqueryNode) ([]OsqueryNode, error) {
+	var nodes []OsqueryNode
+	conditions := []string{"uuid = ?"}
+	values := []interface{}{strings.ToUpper(node.UUID)}
+	if IdentifyingSerial(node.HardwareSerial) {
+		conditions = append(conditions, "hardware_serial = ?")
+		values = append(values, node.HardwareSerial)
+	}
+	if node.Hostname != "" {
+		conditions = append(conditions, "hostname = ?")
+		values = append(values, node.Hostname)
+	}
+	query := n.DB.Where("("+strings.Join(conditions, " OR ")+")", values...).Where("environment_id = ?", node.EnvironmentID)
+	if node.ID != 0 {
+		query = query.Where("id <> ?", node.ID)
+	}
+	if err := query.Find(&nodes).Error; err != nil {
+		return nodes, err
+	}
+	return nodes, nil
+}
+
+// DuplicateField - Function to get the field that makes two nodes duplicated, in order of confidence
+func DuplicateField(node, duplicate OsqueryNode) string {
+	if strings.EqualFold(node.UUID, duplicate.UUID) {
+		return DuplicateByUUID
+	}
+	if IdentifyingSerial(node.HardwareSerial) && node.HardwareSerial == duplicate.HardwareSerial {
+		return DuplicateBySerial
+	}
+	if node.Hostname != "" && node.Hostname == duplicate.Hostname {
+		return DuplicateByHostname
+	}
+	return ""
+}
+
+// GetDuplicates to get all the groups of suspected duplicated nodes in an environment
+func (n *NodeManager) GetDuplicates(envID uint) ([]DuplicateGroup, error) {
+	groups := []DuplicateGroup{}
+	for _, field := range []string{DuplicateByUUID, DuplicateBySerial, DuplicateByHostname} {
+		var values []string
+		if err := n.DB.Model(&OsqueryNode{}).Where("environment_id = ? AND "+field+" <> ''", envID).Group(field).Having("COUNT(*) > 1").Pluck(field, &values).Error; err != nil {
+			return groups, fmt.Errorf("error getting duplicated %s - %w", field, err)
+		}
+		sort.Strings(values)
+		for _, v := range values {
+			if field == DuplicateBySerial && !IdentifyingSerial(v) {
+				continue
+			}
+			var nodes []OsqueryNode
+			if err := n.DB.Where("environment_id = ? AND "+field+" = ?", envID, v).Order("last_seen desc").Find(&nodes).Error; err != nil {
+				return groups, fmt.Errorf("error getting nodes with %s %s - %w", field, v, err)
+			}
+			groups = append(groups, DuplicateGroup{Field: field, Value: v, Nodes: nodes})
+		}
+	}
+	return groups, nil
+}
+
+// MergeInto to add the received bytes of a duplicated node to the target node and archive the duplicated node
+func (n *NodeManager) MergeInto(target, duplicate OsqueryNode) error {
+	if target.ID == duplicate.ID {
+		return fmt.Errorf("can not merge node %d into itself", target.ID)
+	}
+	if err := n.IncreaseBytes(target, duplicate.BytesReceived); err != nil {
+		return err
+	}
+	return n.ArchiveDelete(duplicate, "merge")
+}
@@ -0,0 +1,64 @@
+package nodes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestIdentifyingSerial(t *testing.T) {
+	assert.True(t, IdentifyingSerial("C02XK1ZZJGH5"))
+	assert.False(t, IdentifyingSerial(""))
+	assert.False(t, IdentifyingSerial(" To be filled by O.E.M. "))
+	assert.False(t, IdentifyingSerial("0"))
+}
+
+func TestDuplicates(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&OsqueryNode{}, &ArchiveOsqueryNode{}))
+	n := CreateNodes(db)
+	defer n.Cache.Close()
+
+	existing := []OsqueryNode{
+		{UUID: "UUID-1", NodeKey: "k1", Hostname: "web-1", HardwareSerial: "SERIAL-1", EnvironmentID: 1},
+		{UUID: "UUID-2", NodeKey: "k2", Hostname: "web-2", HardwareSerial: "SERIAL-1", EnvironmentID: 1},
+		{UUID: "UUID-3", NodeKey: "k3", Hostname: "web-2", HardwareSerial: "0", EnvironmentID: 1},
+		{UUID: "UUID-4", NodeKey: "k4", Hostname: "web-4", HardwareSerial: "0", EnvironmentID: 1},
+		{UUID: "UUID-1", NodeKey: "k5", Hostname: "web-1", HardwareSerial: "SERIAL-1", EnvironmentID: 2},
+	}
+	for i := range existing {
+		require.NoError(t, db.Create(&existing[i]).Error)
+	}
+
+	// Enrolling node matching by serial and hostname, in the same environment only
+	dups, err := n.FindDuplicates(OsqueryNode{UUID: "uuid-9", Hostname: "web-2", HardwareSerial: "SERIAL-1", EnvironmentID: 1})
+	require.NoError(t, err)
+	assert.Len(t, dups, 3)
+	// Generic serials do not match
+	dups, err = n.FindDuplicates(OsqueryNode{UUID: "UUID-9", Hostname: "web-9", HardwareSerial: "0", EnvironmentID: 1})
+	require.NoError(t, err)
+	assert.Empty(t, dups)
+	// Existing nodes exclude themselves
+	dups, err = n.FindDuplicates(existing[0])
+	require.NoError(t, err)
+	require.Len(t, dups, 1)
+	assert.Equal(t, DuplicateBySerial, DuplicateField(existing[0], dups[0]))
+
+	groups, err := n.GetDuplicates(1)
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	assert.Equal(t, DuplicateBySerial, groups[0].Field)
+	assert.Equal(t, "SERIAL-1", groups[0].Value)
+	assert.Len(t, groups[0].Nodes, 2)
+	assert.Equal(t, DuplicateByHostname, groups[1].Field)
+	assert.Equal(t, "web-2", groups[1].Value)
+
+	// Merging archives the duplicated node
+	require.NoError(t, n.MergeInto(existing[0], existing[1]))
+	assert.False(t, n.CheckByUUID("UUID-2"))
+	assert.Error(t, n.MergeInto(existing[0], existing[0]))
+}
@@ -0,0 +1,86 @@
+package nodes
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TriggerInactive for nodes archived by the lifecycle policy of the environment
+const TriggerInactive string = "inactive"
+
+// LifecycleResult to hold the nodes archived and purged by the lifecycle policy of an environment
+type LifecycleResult struct {
+	Archived []OsqueryNode        `json:"archived"`
+	Purged   []ArchiveOsqueryNode `json:"purged"`
+}
+
+// GetUnseen to get the nodes of an environment not seen for the provided days
+func (n *NodeManager) GetUnseen(envID uint, days int) ([]OsqueryNode, error) {
+	var nodes []OsqueryNode
+	if days <= 0 {
+		return nodes, nil
+	}
+	older := time.Now().AddDate(0, 0, -days)
+	if err := n.DB.Where("environment_id = ? AND last_seen < ?", envID, older).Find(&nodes).Error; err != nil {
+		return nodes, err
+	}
+	return nodes, nil
+}
+
+// GetArchivedOlder to get the archived nodes of an environment that were archived before the provided days
+func (n *NodeManager) GetArchivedOlder(envID uint, days int) ([]ArchiveOsqueryNode, error) {
+	var archived []ArchiveOsqueryNode
+	if days <= 0 {
+		return archived, nil
+	}
+	older := time.Now().AddDate(0, 0, -days)
+	if err := n.DB.Where("environment_id = ? AND created_at < ?", envID, older).Find(&archived).Error; err != nil {
+		return archived, err
+	}
+	return archived, nil
+}
+
+// PurgeArchived to permanently delete an archived node
+func (n *NodeManager) PurgeArchived(archived ArchiveOsqueryNode) error {
+	if err := n.DB.Unscoped().Delete(&archived).Error; err != nil {
+		return fmt.Errorf("delete %w", err)
+	}
+	return nil
+}
+
+// ApplyLifecycle to archive the nodes not seen for archiveDays and purge the nodes archived for more
+// than purgeDays, zero disables each policy. With dryRun nothing is changed, just reported.
+func (n *NodeManager) ApplyLifecycle(envID uint, archiveDays, purgeDays int, dryRun bool) (LifecycleResult, error) {
+	var res LifecycleResult
+	// Nodes archived in this run are not purged yet, so archived nodes are retrieved first
+	purge, err := n.GetArchivedOlder(envID, purgeDays)
+	if err != nil {
+		return res, fmt.Errorf("error getting archived nodes - %w", err)
+	}
+	unseen, err := n.GetUnseen(envID, archiveDays)
+	if err != nil {
+		return res, fmt.Errorf("error getting unseen nodes - %w", err)
+	}
+	if dryRun {
+		res.Archived = unseen
+		res.Purged = purge
+		return res, nil
+	}
+	var errs []error
+	for _, node := range unseen {
+		if err := n.ArchiveDelete(node, TriggerInactive); err != nil {
+			errs = append(errs, fmt.Errorf("archive %s - %w", node.UUID, err))
+			continue
+		}
+		res.Archived = append(res.Archived, node)
+	}
+	for _, archived := range purge {
+		if err := n.PurgeArchived(archived); err != nil {
+			errs = append(errs, fmt.Errorf("purge %s - %w", archived.UUID, err))
+			continue
+		}
+		res.Purged = append(res.Purged, archived)
+	}
+	return res, errors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+package nodes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestApplyLifecycle(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&OsqueryNode{}, &ArchiveOsqueryNode{}))
+	n := CreateNodes(db)
+	defer n.Cache.Close()
+
+	now := time.Now()
+	require.NoError(t, db.Create(&OsqueryNode{UUID: "GONE", NodeKey: "gone", EnvironmentID: 1, LastSeen: now.AddDate(0, 0, -40)}).Error)
+	require.NoError(t, db.Create(&OsqueryNode{UUID: "ALIVE", NodeKey: "alive", EnvironmentID: 1, LastSeen: now}).Error)
+	require.NoError(t, db.Create(&OsqueryNode{UUID: "OTHER", NodeKey: "other", EnvironmentID: 2, LastSeen: now.AddDate(0, 0, -40)}).Error)
+	old := ArchiveOsqueryNode{UUID: "OLD", NodeKey: "old", EnvironmentID: 1}
+	require.NoError(t, db.Create(&old).Error)
+	require.NoError(t, db.Model(&old).UpdateColumn("created_at", now.AddDate(0, 0, -100)).Error)
+
+	// Dry run only reports
+	res, err := n.ApplyLifecycle(1, 30, 90, true)
+	require.NoError(t, err)
+	require.Len(t, res.Archived, 1)
+	assert.Equal(t, "GONE", res.Archived[0].UUID)
+	require.Len(t, res.Purged, 1)
+	assert.Equal(t, "OLD", res.Purged[0].UUID)
+	assert.True(t, n.CheckByUUID("GONE"))
+
+	res, err = n.ApplyLifecycle(1, 30, 90, false)
+	require.NoError(t, err)
+	assert.Len(t, res.Archived, 1)
+	assert.Len(t, res.Purged, 1)
+	assert.False(t, n.CheckByUUID("GONE"))
+	assert.True(t, n.CheckByUUID("ALIVE"))
+	assert.True(t, n.CheckByUUID("OTHER"))
+	var archived []ArchiveOsqueryNode
+	require.NoError(t, db.Find(&archived).Error)
+	require.Len(t, archived, 1)
+	assert.Equal(t, "GONE", archived[0].UUID)
+	assert.Equal(t, TriggerInactive, archived[0].Trigger)
+
+	// Disabled policies do nothing
+	res, err = n.ApplyLifecycle(1, 0, 0, false)
+	require.NoError(t, err)
+	assert.Empty(t, res.Archived)
+	assert.Empty(t, res.Purged)
+}
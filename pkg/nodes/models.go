@@ -31,6 +31,8 @@ type OsqueryNode struct {
 	UserID          uint
 	EnvironmentID   uint
 	ExtraData       string
+	Pending         bool
+	CertFingerprint string `gorm:"index"`
 }
 
 // ArchiveOsqueryNode as abstraction of an archived node
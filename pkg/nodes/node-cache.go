@@ -72,6 +72,18 @@ func (nc *NodeCache) UpdateNodeInCache(ctx context.Context, node OsqueryNode) {
 	nc.cache.Set(ctx, node.NodeKey, node, defaultTTL)
 }
 
+// Subscribe evicts nodes from the cache when changes are published to the bus,
+// removing all of them if the invalidation has no key
+func (nc *NodeCache) Subscribe(bus cache.InvalidationBus) {
+	bus.Subscribe(cache.TopicNodes, func(ctx context.Context, inv cache.Invalidation) {
+		if inv.Key == "" {
+			nc.InvalidateAll(ctx)
+			return
+		}
+		nc.InvalidateNode(ctx, inv.Key)
+	})
+}
+
 // Close stops the cleanup goroutine and releases resources
 func (nc *NodeCache) Close() {
 	if nc.cache != nil {
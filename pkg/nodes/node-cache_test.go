@@ -0,0 +1,52 @@
+package nodes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmpsec/osctrl/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestNodeCacheInvalidation(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&OsqueryNode{}, &ArchiveOsqueryNode{}))
+	bus := cache.NewLocalBus()
+
+	// Two managers with their own cache, as two replicas sharing the same database
+	replica := CreateNodes(db)
+	defer replica.Cache.Close()
+	replica.SetInvalidationBus(bus)
+	admin := CreateNodes(db)
+	defer admin.Cache.Close()
+	admin.SetInvalidationBus(bus)
+
+	node := OsqueryNode{UUID: "CACHE-UUID", NodeKey: "cache-key", Hostname: "cached"}
+	require.NoError(t, db.Create(&node).Error)
+
+	cached, err := replica.GetByKey("cache-key")
+	require.NoError(t, err)
+	assert.Equal(t, "cached", cached.Hostname)
+	assert.Equal(t, 1, replica.Cache.cache.ItemCount())
+
+	// Updating the node in one manager evicts it from the other
+	require.NoError(t, admin.UpdateByUUID(OsqueryNode{Hostname: "updated"}, "CACHE-UUID"))
+	assert.Equal(t, 0, replica.Cache.cache.ItemCount())
+	updated, err := replica.GetByKey("cache-key")
+	require.NoError(t, err)
+	assert.Equal(t, "updated", updated.Hostname)
+
+	// Deleting the node stops serving it from the cache
+	require.NoError(t, admin.ArchiveDeleteByUUID("CACHE-UUID"))
+	_, err = replica.GetByKey("cache-key")
+	assert.Error(t, err)
+
+	// Invalidations without key evict all the nodes
+	replica.Cache.UpdateNodeInCache(context.Background(), node)
+	require.NoError(t, bus.Publish(context.Background(), cache.TopicNodes, ""))
+	assert.Equal(t, 0, replica.Cache.cache.ItemCount())
+}
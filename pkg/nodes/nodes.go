@@ -6,7 +6,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/rs/zerolog/log"
+	"github.com/jmpsec/osctrl/pkg/cache"
 	"gorm.io/gorm"
 )
 
@@ -34,26 +34,26 @@ type StatsData struct {
 type NodeManager struct {
 	DB    *gorm.DB
 	Cache *NodeCache
+	// Bus to publish changes so cached nodes are evicted in all replicas
+	Bus cache.InvalidationBus
 }
 
-// CreateNodes to initialize the nodes struct and its tables
+// CreateNodes to initialize the nodes struct, tables are created by migrations
 func CreateNodes(backend *gorm.DB) *NodeManager {
 	var n *NodeManager = &NodeManager{
 		DB: backend,
 	}
-	// table osquery_nodes
-	if err := backend.AutoMigrate(&OsqueryNode{}); err != nil {
-		log.Fatal().Msgf("Failed to AutoMigrate table (osquery_nodes): %v", err)
-	}
-	// table archive_osquery_nodes
-	if err := backend.AutoMigrate(&ArchiveOsqueryNode{}); err != nil {
-		log.Fatal().Msgf("Failed to AutoMigrate table (archive_osquery_nodes): %v", err)
-	}
 	// Create and initialize the cache
 	n.Cache = NewNodeCache(n)
 	return n
 }
 
+// SetInvalidationBus to publish node changes to the bus and evict cached nodes when changes are received
+func (n *NodeManager) SetInvalidationBus(bus cache.InvalidationBus) {
+	n.Bus = bus
+	n.Cache.Subscribe(bus)
+}
+
 // CheckByUUID to check if node exists by UUID
 // UUID is expected uppercase
 func (n *NodeManager) CheckByUUID(uuid string) bool {
@@ -345,6 +345,47 @@ func (n *NodeManager) GetByPlatform(envID uint, platform, target string, hours i
 	return nodes, nil
 }
 
+// GetByEnvIDSelector to retrieve nodes by environment filtered with the conditions of a selector evaluated in the database
+func (n *NodeManager) GetByEnvIDSelector(envID uint, pushdown SelectorPushdown, target string, hours int64) ([]OsqueryNode, error) {
+	var nodes []OsqueryNode
+	// Build query with base condition
+	query := n.DB.Where("environment_id = ?", envID)
+	if pushdown.Clause != "" {
+		query = query.Where(pushdown.Clause, pushdown.Args...)
+	}
+	// Apply active/inactive filtering
+	query = ApplyNodeTarget(query, target, hours)
+	// Execute query
+	if err := query.Find(&nodes).Error; err != nil {
+		return nodes, err
+	}
+	return nodes, nil
+}
+
+// GetEnvIDFieldValues to get the different values of a field of the nodes in an environment
+func (n *NodeManager) GetEnvIDFieldValues(envID uint, field string) ([]string, error) {
+	var values []string
+	if !SelectorPushdownFields[field] {
+		return values, fmt.Errorf("invalid field %s", field)
+	}
+	if err := n.DB.Model(&OsqueryNode{}).Where("environment_id = ?", envID).Distinct().Pluck("COALESCE("+field+", '')", &values).Error; err != nil {
+		return values, err
+	}
+	return values, nil
+}
+
+// GetByIDs to retrieve nodes by a list of IDs, sorted by hostname
+func (n *NodeManager) GetByIDs(ids []uint) ([]OsqueryNode, error) {
+	var nodes []OsqueryNode
+	if len(ids) == 0 {
+		return nodes, nil
+	}
+	if err := n.DB.Where("id IN ?", ids).Order("hostname, uuid").Find(&nodes).Error; err != nil {
+		return nodes, err
+	}
+	return nodes, nil
+}
+
 // GetAllPlatforms to get all different platform with nodes in them
 func (n *NodeManager) GetAllPlatforms() ([]string, error) {
 	var platforms []string
@@ -479,6 +520,16 @@ func (n *NodeManager) UpdateByUUID(data OsqueryNode, uuid string) error {
 	if err := n.DB.Model(&node).Updates(data).Error; err != nil {
 		return fmt.Errorf("in UpdateByUUID %w", err)
 	}
+	// Zero values are skipped when updating with a struct, so the approval and the client certificate
+	// are always updated to allow clearing them when the node enrolls again
+	toUpdate := map[string]interface{}{
+		"pending":          data.Pending,
+		"cert_fingerprint": data.CertFingerprint,
+	}
+	if err := n.DB.Model(&node).Updates(toUpdate).Error; err != nil {
+		return fmt.Errorf("in UpdateByUUID %w", err)
+	}
+	cache.PublishInvalidation(n.Bus, cache.TopicNodes, node.NodeKey)
 	return nil
 }
 
@@ -488,13 +539,19 @@ func (n *NodeManager) ArchiveDeleteByUUID(uuid string) error {
 	if err != nil {
 		return fmt.Errorf("getNodeByUUID %w", err)
 	}
-	archivedNode := nodeArchiveFromNode(node, "delete")
+	return n.ArchiveDelete(node, "delete")
+}
+
+// ArchiveDelete to archive and delete an existing node record with the provided trigger
+func (n *NodeManager) ArchiveDelete(node OsqueryNode, trigger string) error {
+	archivedNode := nodeArchiveFromNode(node, trigger)
 	if err := n.DB.Create(&archivedNode).Error; err != nil {
 		return fmt.Errorf("create %w", err)
 	}
 	if err := n.DB.Unscoped().Delete(&node).Error; err != nil {
 		return fmt.Errorf("delete %w", err)
 	}
+	cache.PublishInvalidation(n.Bus, cache.TopicNodes, node.NodeKey)
 	return nil
 }
 
@@ -0,0 +1,648 @@
+package nodes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Selector language to target nodes, made of conditions joined with AND, OR, NOT and parenthesis:
+//
+//	platform=darwin AND tag=prod AND osquery_version>=5.10 AND last_seen<1h
+//
+// Conditions compare a node field with a value, that can be quoted when it has spaces or symbols.
+// String fields support = and != (case insensitive) and ~ (contains), version fields also support
+// <, <=, > and >= comparing each numeric part, and time fields compare the age with a duration.
+
+const (
+	// SelectorTag is the field to target nodes by tag
+	SelectorTag = "tag"
+	// SelectorLastSeen is the field to target nodes by time since last seen
+	SelectorLastSeen = "last_seen"
+	// SelectorFirstSeen is the field to target nodes by time since enrolled
+	SelectorFirstSeen = "first_seen"
+)
+
+const (
+	selectorEq       = "="
+	selectorNotEq    = "!="
+	selectorLess     = "<"
+	selectorLessEq   = "<="
+	selectorGreater  = ">"
+	selectorGreatEq  = ">="
+	selectorContains = "~"
+)
+
+// selectorFieldKind to know how values of a field are compared
+type selectorFieldKind int
+
+const (
+	selectorString selectorFieldKind = iota
+	selectorVersion
+	selectorAge
+	selectorTagged
+)
+
+// SelectorFields with all the fields supported by the selector language
+var SelectorFields = map[string]selectorFieldKind{
+	"uuid":             selectorString,
+	"platform":         selectorString,
+	"platform_version": selectorVersion,
+	"osquery_version":  selectorVersion,
+	"hostname":         selectorString,
+	"localname":        selectorString,
+	"ip_address":       selectorString,
+	"username":         selectorString,
+	"osquery_user":     selectorString,
+	"environment":      selectorString,
+	"cpu":              selectorString,
+	"memory":           selectorString,
+	"hardware_serial":  selectorString,
+	"daemon_hash":      selectorString,
+	"config_hash":      selectorString,
+	SelectorTag:        selectorTagged,
+	SelectorLastSeen:   selectorAge,
+	SelectorFirstSeen:  selectorAge,
+}
+
+// TargetSelector to match nodes with a parsed selector expression
+type TargetSelector struct {
+	expr selectorExpr
+	tags []string
+}
+
+// selectorExpr is one node of the parsed expression
+type selectorExpr interface {
+	match(node OsqueryNode, tags map[string]bool, now time.Time) bool
+}
+
+type selectorAnd struct{ left, right selectorExpr }
+
+func (e selectorAnd) match(node OsqueryNode, tags map[string]bool, now time.Time) bool {
+	return e.left.match(node, tags, now) && e.right.match(node, tags, now)
+}
+
+type selectorOr struct{ left, right selectorExpr }
+
+func (e selectorOr) match(node OsqueryNode, tags map[string]bool, now time.Time) bool {
+	return e.left.match(node, tags, now) || e.right.match(node, tags, now)
+}
+
+type selectorNot struct{ expr selectorExpr }
+
+func (e selectorNot) match(node OsqueryNode, tags map[string]bool, now time.Time) bool {
+	return !e.expr.match(node, tags, now)
+}
+
+// selectorCond is a single condition comparing a field with a value
+type selectorCond struct {
+	field string
+	kind  selectorFieldKind
+	op    string
+	value string
+	age   time.Duration
+}
+
+func (c selectorCond) match(node OsqueryNode, tags map[string]bool, now time.Time) bool {
+	switch c.kind {
+	case selectorTagged:
+		tagged := tags[strings.ToLower(c.value)]
+		if c.op == selectorNotEq {
+			return !tagged
+		}
+		return tagged
+	case selectorAge:
+		seen := node.LastSeen
+		if c.field == SelectorFirstSeen {
+			seen = node.CreatedAt
+		}
+		if seen.IsZero() {
+			return false
+		}
+		return compareOrdered(int64(now.Sub(seen)), int64(c.age), c.op)
+	}
+	return c.matchValue(nodeFieldValue(node, c.field))
+}
+
+// Helper to compare the value of a string or version field with the condition
+func (c selectorCond) matchValue(value string) bool {
+	switch c.op {
+	case selectorEq:
+		return strings.EqualFold(value, c.value)
+	case selectorNotEq:
+		return !strings.EqualFold(value, c.value)
+	case selectorContains:
+		return strings.Contains(strings.ToLower(value), strings.ToLower(c.value))
+	}
+	if value == "" {
+		return false
+	}
+	return compareOrdered(int64(CompareVersions(value, c.value)), 0, c.op)
+}
+
+// Helper to compare two ordered values with an operator
+func compareOrdered(a, b int64, op string) bool {
+	switch op {
+	case selectorEq:
+		return a == b
+	case selectorNotEq:
+		return a != b
+	case selectorLess:
+		return a < b
+	case selectorLessEq:
+		return a <= b
+	case selectorGreater:
+		return a > b
+	case selectorGreatEq:
+		return a >= b
+	}
+	return false
+}
+
+// Helper to get the value of a string field of a node
+func nodeFieldValue(node OsqueryNode, field string) string {
+	switch field {
+	case "uuid":
+		return node.UUID
+	case "platform":
+		return node.Platform
+	case "platform_version":
+		return node.PlatformVersion
+	case "osquery_version":
+		return node.OsqueryVersion
+	case "hostname":
+		return node.Hostname
+	case "localname":
+		return node.Localname
+	case "ip_address":
+		return node.IPAddress
+	case "username":
+		return node.Username
+	case "osquery_user":
+		return node.OsqueryUser
+	case "environment":
+		return node.Environment
+	case "cpu":
+		return node.CPU
+	case "memory":
+		return node.Memory
+	case "hardware_serial":
+		return node.HardwareSerial
+	case "daemon_hash":
+		return node.DaemonHash
+	case "config_hash":
+		return node.ConfigHash
+	}
+	return ""
+}
+
+// CompareVersions - Function to compare two versions part by part, numeric parts are compared as numbers.
+// It returns -1, 0 or 1 if a is lower, equal or greater than b. Missing parts count as zero.
+func CompareVersions(a, b string) int {
+	split := func(v string) []string {
+		return strings.FieldsFunc(v, func(r rune) bool {
+			return r == '.' || r == '-' || r == '_' || r == '+'
+		})
+	}
+	pa, pb := split(a), split(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		sa, sb := "0", "0"
+		if i < len(pa) {
+			sa = pa[i]
+		}
+		if i < len(pb) {
+			sb = pb[i]
+		}
+		na, errA := strconv.ParseInt(sa, 10, 64)
+		nb, errB := strconv.ParseInt(sb, 10, 64)
+		if errA == nil && errB == nil {
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+			continue
+		}
+		if c := strings.Compare(sa, sb); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// ParseSelectorAge - Function to parse the age used with time fields, supporting days besides Go durations
+func ParseSelectorAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		d, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %s", value)
+		}
+		return time.Duration(d) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %s", value)
+	}
+	return d, nil
+}
+
+// ParseTargetSelector - Function to parse a selector expression to target nodes
+func ParseTargetSelector(selector string) (*TargetSelector, error) {
+	tokens, err := lexSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty selector")
+	}
+	p := &selectorParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %s at position %d", p.tokens[p.pos].value, p.tokens[p.pos].offset)
+	}
+	return &TargetSelector{expr: expr, tags: p.tags}, nil
+}
+
+// Tags returns the names of the tags used in the selector, to load the tags of the nodes
+func (s *TargetSelector) Tags() []string {
+	return s.tags
+}
+
+// Match returns true if the node, with the names of its tags, matches the selector
+func (s *TargetSelector) Match(node OsqueryNode, tags []string, now time.Time) bool {
+	tagged := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		tagged[strings.ToLower(t)] = true
+	}
+	return s.expr.match(node, tagged, now)
+}
+
+// SelectorPushdownFields with the fields compared in the database, all of them with few distinct values
+var SelectorPushdownFields = map[string]bool{
+	"platform":         true,
+	"platform_version": true,
+	"osquery_version":  true,
+}
+
+// SelectorPushdown to hold the conditions of a selector evaluated in the database, with the
+// rest of the selector that has to be matched in memory
+type SelectorPushdown struct {
+	Clause string
+	Args   []interface{}
+	rest   selectorExpr
+	tags   []string
+}
+
+// Tags returns the names of the tags used in the part of the selector matched in memory
+func (p SelectorPushdown) Tags() []string {
+	return p.tags
+}
+
+// Match returns true if the node, with the names of its tags, matches the part of the selector
+// matched in memory. Nodes must be filtered first with the clause
+func (p SelectorPushdown) Match(node OsqueryNode, tags []string, now time.Time) bool {
+	if p.rest == nil {
+		return true
+	}
+	tagged := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		tagged[strings.ToLower(t)] = true
+	}
+	return p.rest.match(node, tagged, now)
+}
+
+// Pushdown splits the selector in conditions evaluated in the database, for tags and for the fields
+// with few distinct values, and the rest to be matched in memory. Values returns the distinct values
+// of a field in the nodes, to be compared in memory, and tagIDs has the IDs of the tags by lowercase name
+func (s *TargetSelector) Pushdown(values func(field string) ([]string, error), tagIDs map[string][]uint) (SelectorPushdown, error) {
+	b := &pushdownBuilder{values: values, tagIDs: tagIDs, cache: make(map[string][]string)}
+	clauses, args, rest, err := b.split(s.expr)
+	if err != nil {
+		return SelectorPushdown{}, err
+	}
+	p := SelectorPushdown{Clause: strings.Join(clauses, " AND "), Args: args, rest: rest}
+	if rest != nil {
+		p.tags = exprTags(rest)
+	}
+	return p, nil
+}
+
+// pushdownBuilder to generate the SQL conditions of a selector
+type pushdownBuilder struct {
+	values func(field string) ([]string, error)
+	tagIDs map[string][]uint
+	cache  map[string][]string
+}
+
+// Helper to split an expression in SQL conditions joined with AND and the rest of the expression
+func (b *pushdownBuilder) split(e selectorExpr) ([]string, []interface{}, selectorExpr, error) {
+	clause, args, ok, err := b.sql(e)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if ok {
+		return []string{clause}, args, nil, nil
+	}
+	and, isAnd := e.(selectorAnd)
+	if !isAnd {
+		return nil, nil, e, nil
+	}
+	lClauses, lArgs, lRest, err := b.split(and.left)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rClauses, rArgs, rRest, err := b.split(and.right)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rest := lRest
+	if rest == nil {
+		rest = rRest
+	} else if rRest != nil {
+		rest = selectorAnd{left: lRest, right: rRest}
+	}
+	return append(lClauses, rClauses...), append(lArgs, rArgs...), rest, nil
+}
+
+// Helper to generate the SQL condition of an expression, only if it can be fully evaluated in SQL
+func (b *pushdownBuilder) sql(e selectorExpr) (string, []interface{}, bool, error) {
+	switch e := e.(type) {
+	case selectorAnd:
+		return b.sqlJoin(e.left, e.right, "AND")
+	case selectorOr:
+		return b.sqlJoin(e.left, e.right, "OR")
+	case selectorNot:
+		clause, args, ok, err := b.sql(e.expr)
+		if err != nil || !ok {
+			return "", nil, false, err
+		}
+		return "NOT (" + clause + ")", args, true, nil
+	case selectorCond:
+		return b.sqlCond(e)
+	}
+	return "", nil, false, nil
+}
+
+// Helper to join the SQL conditions of two expressions, only if both can be evaluated in SQL
+func (b *pushdownBuilder) sqlJoin(left, right selectorExpr, op string) (string, []interface{}, bool, error) {
+	lClause, lArgs, ok, err := b.sql(left)
+	if err != nil || !ok {
+		return "", nil, false, err
+	}
+	rClause, rArgs, ok, err := b.sql(right)
+	if err != nil || !ok {
+		return "", nil, false, err
+	}
+	return "(" + lClause + " " + op + " " + rClause + ")", append(lArgs, rArgs...), true, nil
+}
+
+// Helper to generate the SQL condition of a tag or a field with few distinct values
+func (b *pushdownBuilder) sqlCond(c selectorCond) (string, []interface{}, bool, error) {
+	if c.kind == selectorTagged {
+		ids := b.tagIDs[strings.ToLower(c.value)]
+		if len(ids) == 0 {
+			// Tag does not exist, so no node has it
+			if c.op == selectorNotEq {
+				return "1 = 1", nil, true, nil
+			}
+			return "1 = 0", nil, true, nil
+		}
+		in := "id IN"
+		if c.op == selectorNotEq {
+			in = "id NOT IN"
+		}
+		return in + " (SELECT node_id FROM tagged_nodes WHERE admin_tag_id IN ? AND deleted_at IS NULL)", []interface{}{ids}, true, nil
+	}
+	if !SelectorPushdownFields[c.field] {
+		return "", nil, false, nil
+	}
+	values, ok := b.cache[c.field]
+	if !ok {
+		var err error
+		if values, err = b.values(c.field); err != nil {
+			return "", nil, false, fmt.Errorf("error getting values of %s - %w", c.field, err)
+		}
+		b.cache[c.field] = values
+	}
+	// Compare all the distinct values in memory, so the result is the same as matching each node
+	var matched []string
+	empty := false
+	for _, v := range values {
+		if c.matchValue(v) {
+			matched = append(matched, v)
+			empty = empty || v == ""
+		}
+	}
+	if len(matched) == 0 {
+		return "1 = 0", nil, true, nil
+	}
+	// Empty values include nodes without value
+	if empty {
+		return "(" + c.field + " IN ? OR " + c.field + " IS NULL)", []interface{}{matched}, true, nil
+	}
+	return c.field + " IN ?", []interface{}{matched}, true, nil
+}
+
+// Helper to get the names of the tags used in an expression
+func exprTags(e selectorExpr) []string {
+	switch e := e.(type) {
+	case selectorAnd:
+		return append(exprTags(e.left), exprTags(e.right)...)
+	case selectorOr:
+		return append(exprTags(e.left), exprTags(e.right)...)
+	case selectorNot:
+		return exprTags(e.expr)
+	case selectorCond:
+		if e.kind == selectorTagged {
+			return []string{e.value}
+		}
+	}
+	return nil
+}
+
+// selectorToken is one token of a selector expression
+type selectorToken struct {
+	value  string
+	op     bool
+	quoted bool
+	offset int
+}
+
+// Helper to split a selector expression into tokens
+func lexSelector(selector string) ([]selectorToken, error) {
+	var tokens []selectorToken
+	runes := []rune(selector)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, selectorToken{value: string(r), op: true, offset: i})
+			i++
+		case strings.ContainsRune("=!<>~", r):
+			start := i
+			i++
+			if i < len(runes) && runes[i] == '=' && r != '=' && r != '~' {
+				i++
+			}
+			op := string(runes[start:i])
+			if op == "!" {
+				return nil, fmt.Errorf("invalid operator ! at position %d", start)
+			}
+			tokens = append(tokens, selectorToken{value: op, op: true, offset: start})
+		case r == '"' || r == '\'':
+			start := i
+			i++
+			var b strings.Builder
+			for i < len(runes) && runes[i] != r {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				b.WriteRune(runes[i])
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++
+			tokens = append(tokens, selectorToken{value: b.String(), quoted: true, offset: start})
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("()=!<>~\"'", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, selectorToken{value: string(runes[start:i]), offset: start})
+		}
+	}
+	return tokens, nil
+}
+
+// selectorParser to parse tokens with precedence NOT, AND, OR
+type selectorParser struct {
+	tokens []selectorToken
+	pos    int
+	tags   []string
+}
+
+// Helper to check if the next token is a keyword
+func (p *selectorParser) keyword(k string) bool {
+	if p.pos >= len(p.tokens) {
+		return false
+	}
+	t := p.tokens[p.pos]
+	return !t.op && !t.quoted && strings.EqualFold(t.value, k)
+}
+
+// Helper to check if the next token is a given symbol
+func (p *selectorParser) symbol(s string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].op && p.tokens[p.pos].value == s
+}
+
+func (p *selectorParser) parseOr() (selectorExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = selectorOr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *selectorParser) parseAnd() (selectorExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = selectorAnd{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *selectorParser) parseNot() (selectorExpr, error) {
+	if p.keyword("NOT") {
+		p.pos++
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return selectorNot{expr: expr}, nil
+	}
+	if p.symbol("(") {
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.symbol(")") {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return expr, nil
+	}
+	return p.parseCond()
+}
+
+func (p *selectorParser) parseCond() (selectorExpr, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of selector")
+	}
+	fieldTok := p.tokens[p.pos]
+	if fieldTok.op || fieldTok.quoted {
+		return nil, fmt.Errorf("expected field at position %d", fieldTok.offset)
+	}
+	field := strings.ToLower(fieldTok.value)
+	kind, ok := SelectorFields[field]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %s", fieldTok.value)
+	}
+	if p.pos+1 >= len(p.tokens) || !p.tokens[p.pos+1].op || strings.ContainsAny(p.tokens[p.pos+1].value, "()") {
+		return nil, fmt.Errorf("expected operator after %s", fieldTok.value)
+	}
+	op := p.tokens[p.pos+1].value
+	if p.pos+2 >= len(p.tokens) || (p.tokens[p.pos+2].op && !p.tokens[p.pos+2].quoted) {
+		return nil, fmt.Errorf("expected value after %s%s", fieldTok.value, op)
+	}
+	value := p.tokens[p.pos+2].value
+	p.pos += 3
+	cond := selectorCond{field: field, kind: kind, op: op, value: value}
+	switch kind {
+	case selectorTagged:
+		if op != selectorEq && op != selectorNotEq {
+			return nil, fmt.Errorf("operator %s not supported for %s", op, field)
+		}
+		p.tags = append(p.tags, value)
+	case selectorAge:
+		if op == selectorContains {
+			return nil, fmt.Errorf("operator %s not supported for %s", op, field)
+		}
+		age, err := ParseSelectorAge(value)
+		if err != nil {
+			return nil, err
+		}
+		cond.age = age
+	case selectorString:
+		if op != selectorEq && op != selectorNotEq && op != selectorContains {
+			return nil, fmt.Errorf("operator %s not supported for %s", op, field)
+		}
+	}
+	return cond, nil
+}
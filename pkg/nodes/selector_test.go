@@ -0,0 +1,195 @@
+package nodes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"5.10.2", "5.10", 1},
+		{"5.10", "5.10.0", 0},
+		{"5.9.1", "5.10", -1},
+		{"5.12.1", "5.12.1", 0},
+		{"14.4.1", "14.5", -1},
+		{"5.11.0-rc1", "5.11.0-rc2", -1},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, CompareVersions(tt.a, tt.b), "%s vs %s", tt.a, tt.b)
+	}
+}
+
+func TestParseTargetSelector(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	mac := OsqueryNode{
+		UUID:           "AAAA-1111",
+		Platform:       "darwin",
+		OsqueryVersion: "5.12.1",
+		Hostname:       "mac-prod-01",
+		LastSeen:       now.Add(-10 * time.Minute),
+	}
+	mac.CreatedAt = now.Add(-72 * time.Hour)
+	oldMac := OsqueryNode{
+		UUID:           "BBBB-2222",
+		Platform:       "darwin",
+		OsqueryVersion: "5.9.1",
+		Hostname:       "mac-dev-02",
+		LastSeen:       now.Add(-3 * time.Hour),
+	}
+	linux := OsqueryNode{
+		UUID:           "CCCC-3333",
+		Platform:       "ubuntu",
+		OsqueryVersion: "5.10.2",
+		Hostname:       "web server",
+		LastSeen:       now.Add(-30 * time.Minute),
+	}
+	tagged := map[string][]string{
+		mac.UUID:    {"prod"},
+		oldMac.UUID: {"dev"},
+		linux.UUID:  {"prod", "web"},
+	}
+	tests := []struct {
+		selector string
+		expected []string
+	}{
+		{"platform=darwin AND tag=prod AND osquery_version>=5.10 AND last_seen<1h", []string{mac.UUID}},
+		{"platform=darwin", []string{mac.UUID, oldMac.UUID}},
+		{"PLATFORM = Darwin", []string{mac.UUID, oldMac.UUID}},
+		{"osquery_version>=5.10", []string{mac.UUID, linux.UUID}},
+		{"osquery_version<5.10", []string{oldMac.UUID}},
+		{"last_seen>1h", []string{oldMac.UUID}},
+		{"first_seen>2d", []string{mac.UUID}},
+		{"tag!=prod", []string{oldMac.UUID}},
+		{"hostname~mac", []string{mac.UUID, oldMac.UUID}},
+		{`hostname="web server"`, []string{linux.UUID}},
+		{"platform=ubuntu OR tag=dev", []string{oldMac.UUID, linux.UUID}},
+		{"NOT platform=darwin", []string{linux.UUID}},
+		{"(platform=ubuntu OR platform=darwin) AND tag=prod", []string{mac.UUID, linux.UUID}},
+		{"tag=prod and not tag=web", []string{mac.UUID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			s, err := ParseTargetSelector(tt.selector)
+			require.NoError(t, err)
+			var matched []string
+			for _, n := range []OsqueryNode{mac, oldMac, linux} {
+				if s.Match(n, tagged[n.UUID], now) {
+					matched = append(matched, n.UUID)
+				}
+			}
+			assert.Equal(t, tt.expected, matched)
+		})
+	}
+}
+
+func TestParseTargetSelectorTags(t *testing.T) {
+	s, err := ParseTargetSelector("tag=prod OR (tag=web AND platform=linux)")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prod", "web"}, s.Tags())
+}
+
+func TestParseTargetSelectorErrors(t *testing.T) {
+	invalid := []string{
+		"",
+		"platform",
+		"platform=",
+		"unknown=value",
+		"platform>darwin",
+		"tag>=prod",
+		"last_seen<soon",
+		"platform=darwin AND",
+		"(platform=darwin",
+		"platform=darwin)",
+		`hostname="unterminated`,
+		"platform!darwin",
+	}
+	for _, selector := range invalid {
+		_, err := ParseTargetSelector(selector)
+		assert.Error(t, err, selector)
+	}
+}
+
+func TestSelectorPushdown(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&OsqueryNode{}))
+	require.NoError(t, db.Exec("CREATE TABLE tagged_nodes (id integer PRIMARY KEY, admin_tag_id integer, node_id integer, deleted_at datetime)").Error)
+	n := CreateNodes(db)
+	defer n.Cache.Close()
+
+	now := time.Now()
+	all := []OsqueryNode{
+		{UUID: "AAAA-1111", NodeKey: "k1", EnvironmentID: 1, Platform: "darwin", OsqueryVersion: "5.12.1", Hostname: "mac-prod-01", LastSeen: now.Add(-10 * time.Minute)},
+		{UUID: "BBBB-2222", NodeKey: "k2", EnvironmentID: 1, Platform: "Darwin", OsqueryVersion: "5.9.1", Hostname: "mac-dev-02", LastSeen: now.Add(-3 * time.Hour)},
+		{UUID: "CCCC-3333", NodeKey: "k3", EnvironmentID: 1, Platform: "ubuntu", OsqueryVersion: "5.10.2", Hostname: "web server", LastSeen: now.Add(-30 * time.Minute)},
+		{UUID: "DDDD-4444", NodeKey: "k4", EnvironmentID: 1, Hostname: "unknown", LastSeen: now.Add(-5 * time.Minute)},
+		{UUID: "EEEE-5555", NodeKey: "k5", EnvironmentID: 2, Platform: "darwin", OsqueryVersion: "5.12.1", Hostname: "other-env"},
+	}
+	for i := range all {
+		require.NoError(t, db.Create(&all[i]).Error)
+	}
+	// Tag 1 is prod and tag 2 is web, tag 3 was removed from the node
+	require.NoError(t, db.Exec("INSERT INTO tagged_nodes (admin_tag_id, node_id) VALUES (1, ?), (1, ?), (2, ?)", all[0].ID, all[2].ID, all[2].ID).Error)
+	require.NoError(t, db.Exec("INSERT INTO tagged_nodes (admin_tag_id, node_id, deleted_at) VALUES (3, ?, ?)", all[1].ID, now).Error)
+	tagIDs := map[string][]uint{"prod": {1}, "web": {2}, "gone": {3}}
+	tagged := map[uint][]string{all[0].ID: {"prod"}, all[2].ID: {"prod", "web"}}
+
+	tests := []struct {
+		selector string
+		inMemory bool
+	}{
+		{"platform=darwin", false},
+		{"platform!=darwin", false},
+		{"platform~bun", false},
+		{"osquery_version>=5.10", false},
+		{"osquery_version<5.10 OR platform=ubuntu", false},
+		{"NOT osquery_version>=5.10", false},
+		{"tag=prod", false},
+		{"tag!=prod", false},
+		{"tag=missing", false},
+		{"tag!=missing", false},
+		{"tag=gone", false},
+		{"tag=prod and not tag=web", false},
+		{"platform=darwin AND tag=prod AND osquery_version>=5.10 AND last_seen<1h", true},
+		{"hostname~mac AND osquery_version<5.10", true},
+		{"hostname~web OR tag=prod", true},
+		{"(platform=ubuntu OR hostname=unknown) AND NOT tag=web", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			s, err := ParseTargetSelector(tt.selector)
+			require.NoError(t, err)
+			var expected []string
+			for _, node := range all[:4] {
+				if s.Match(node, tagged[node.ID], now) {
+					expected = append(expected, node.UUID)
+				}
+			}
+			pushdown, err := s.Pushdown(func(field string) ([]string, error) {
+				return n.GetEnvIDFieldValues(1, field)
+			}, tagIDs)
+			require.NoError(t, err)
+			assert.Equal(t, tt.inMemory, pushdown.rest != nil)
+			filtered, err := n.GetByEnvIDSelector(1, pushdown, AllNodes, 0)
+			require.NoError(t, err)
+			var matched []string
+			for _, node := range filtered {
+				if pushdown.Match(node, tagged[node.ID], now) {
+					matched = append(matched, node.UUID)
+				}
+			}
+			assert.Equal(t, expected, matched)
+		})
+	}
+
+	_, err = n.GetEnvIDFieldValues(1, "hostname")
+	assert.Error(t, err)
+}
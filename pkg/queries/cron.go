@@ -0,0 +1,160 @@
+package queries
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule to hold a parsed cron expression, with one bit set per allowed value
+type CronSchedule struct {
+	Minute     uint64
+	Hour       uint64
+	DayOfMonth uint64
+	Month      uint64
+	DayOfWeek  uint64
+	// Day of month and day of week restricted, so a day matching any of them is valid
+	anyDay bool
+}
+
+// Bounds for each field of the cron expression
+type cronBounds struct {
+	min, max int
+}
+
+var (
+	cronMinute     = cronBounds{0, 59}
+	cronHour       = cronBounds{0, 23}
+	cronDayOfMonth = cronBounds{1, 31}
+	cronMonth      = cronBounds{1, 12}
+	cronDayOfWeek  = cronBounds{0, 7}
+)
+
+// Supported cron shortcuts
+var cronShortcuts = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+}
+
+// ParseCron - Function to parse a standard 5 fields cron expression (minute hour day-of-month month day-of-week)
+func ParseCron(expr string) (CronSchedule, error) {
+	var s CronSchedule
+	expr = strings.TrimSpace(expr)
+	if shortcut, ok := cronShortcuts[expr]; ok {
+		expr = shortcut
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return s, fmt.Errorf("invalid cron expression %q, expected 5 fields", expr)
+	}
+	var err error
+	if s.Minute, err = parseCronField(fields[0], cronMinute); err != nil {
+		return s, fmt.Errorf("invalid minute - %w", err)
+	}
+	if s.Hour, err = parseCronField(fields[1], cronHour); err != nil {
+		return s, fmt.Errorf("invalid hour - %w", err)
+	}
+	if s.DayOfMonth, err = parseCronField(fields[2], cronDayOfMonth); err != nil {
+		return s, fmt.Errorf("invalid day of month - %w", err)
+	}
+	if s.Month, err = parseCronField(fields[3], cronMonth); err != nil {
+		return s, fmt.Errorf("invalid month - %w", err)
+	}
+	if s.DayOfWeek, err = parseCronField(fields[4], cronDayOfWeek); err != nil {
+		return s, fmt.Errorf("invalid day of week - %w", err)
+	}
+	// Sunday can be 0 or 7
+	if s.DayOfWeek&(1<<7) != 0 {
+		s.DayOfWeek |= 1
+	}
+	s.anyDay = !strings.HasPrefix(fields[2], "*") && !strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+// Helper to parse one field of the cron expression, supporting lists, ranges and steps
+func parseCronField(field string, b cronBounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:i]
+		}
+		start, end := b.min, b.max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			start, err1 = strconv.Atoi(bounds[0])
+			end, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			v, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			start = v
+			// A single value with a step runs until the maximum
+			if step == 1 {
+				end = v
+			}
+		}
+		if start < b.min || end > b.max || start > end {
+			return 0, fmt.Errorf("value out of range in %q", part)
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next - Function to get the first time matching the schedule strictly after the provided time.
+// It returns a zero time if nothing matches in the next five years.
+func (s CronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.Month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.Hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.Minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// Helper to check the day of month and day of week fields
+func (s CronSchedule) dayMatches(t time.Time) bool {
+	dom := s.DayOfMonth&(1<<uint(t.Day())) != 0
+	dow := s.DayOfWeek&(1<<uint(t.Weekday())) != 0
+	if s.anyDay {
+		return dom || dow
+	}
+	return dom && dow
+}
@@ -5,7 +5,6 @@ import (
 	"time"
 
 	"github.com/jmpsec/osctrl/pkg/nodes"
-	"github.com/rs/zerolog/log"
 	"gorm.io/gorm"
 )
 
@@ -50,6 +49,8 @@ const (
 	TargetExpired string = "expired"
 	// TargetSaved for saved queries
 	TargetSaved string = "saved"
+	// TargetRecurring for recurring queries
+	TargetRecurring string = "recurring"
 	// TargetHiddenCompleted for hidden completed queries
 	TargetHiddenCompleted string = "hidden-completed"
 	// TargetDeleted for deleted queries
@@ -86,6 +87,9 @@ type DistributedQuery struct {
 	ExtraData     string
 	Expiration    time.Time
 	Target        string
+	Urgent        bool
+	// Nodes with this query pending use accelerated polling until this time, if urgent
+	AccelerateUntil time.Time
 }
 
 // NodeQuery links a node to a query
@@ -94,6 +98,7 @@ type NodeQuery struct {
 	NodeID  uint   `gorm:"not null;index"`
 	QueryID uint   `gorm:"not null;index"`
 	Status  string `gorm:"type:varchar(10);default:'pending'"`
+	Message string
 }
 
 // DistributedQueryTarget to keep target logic for queries
@@ -117,34 +122,20 @@ func CreateQueries(backend *gorm.DB) *Queries {
 	// var q *Queries
 	q := &Queries{DB: backend}
 
-	// table node_queries
-	if err := backend.AutoMigrate(&NodeQuery{}); err != nil {
-		log.Fatal().Msgf("Failed to AutoMigrate table (node_queries): %v", err)
-	}
-	// table distributed_queries
-	if err := backend.AutoMigrate(&DistributedQuery{}); err != nil {
-		log.Fatal().Msgf("Failed to AutoMigrate table (distributed_queries): %v", err)
-	}
-	// table distributed_query_targets
-	if err := backend.AutoMigrate(&DistributedQueryTarget{}); err != nil {
-		log.Fatal().Msgf("Failed to AutoMigrate table (distributed_query_targets): %v", err)
-	}
-	// table saved_queries
-	if err := backend.AutoMigrate(&SavedQuery{}); err != nil {
-		log.Fatal().Msgf("Failed to AutoMigrate table (saved_queries): %v", err)
-	}
 	return q
 }
 
 func (q *Queries) NodeQueries(node nodes.OsqueryNode) (QueryReadQueries, bool, error) {
 
 	var results []struct {
-		Name  string
-		Query string
+		Name            string
+		Query           string
+		Urgent          bool
+		AccelerateUntil time.Time
 	}
 
 	q.DB.Table("distributed_queries dq").
-		Select("dq.name, dq.query").
+		Select("dq.name, dq.query, dq.urgent, dq.accelerate_until").
 		Joins("JOIN node_queries nq ON dq.id = nq.query_id").
 		Where("nq.node_id = ? AND nq.status = ?", node.ID, DistributedQueryStatusPending).
 		Scan(&results)
@@ -153,12 +144,24 @@ func (q *Queries) NodeQueries(node nodes.OsqueryNode) (QueryReadQueries, bool, e
 		return QueryReadQueries{}, false, nil
 	}
 
+	// Accelerate while any pending urgent query is within its window
+	accelerate := false
+	now := time.Now()
 	qs := make(QueryReadQueries)
 	for _, _q := range results {
 		qs[_q.Name] = _q.Query
+		if _q.Urgent && now.Before(_q.AccelerateUntil) {
+			accelerate = true
+		}
 	}
 
-	return qs, false, nil
+	return qs, accelerate, nil
+}
+
+// SetUrgent to mark a query as urgent, so target nodes use accelerated polling during the window
+func (d *DistributedQuery) SetUrgent(window time.Duration) {
+	d.Urgent = true
+	d.AccelerateUntil = time.Now().Add(window)
 }
 
 // Gets all queries by target (active/completed/all/all-full/deleted/hidden/expired)
@@ -475,6 +478,11 @@ func (q *Queries) SetExpected(name string, expected int, envid uint) error {
 
 // UpdateQueryStatus to update the status of each query
 func (q *Queries) UpdateQueryStatus(queryName string, nodeID uint, statusCode int) error {
+	return q.UpdateQueryStatusMessage(queryName, nodeID, statusCode, "")
+}
+
+// UpdateQueryStatusMessage to update the status of each query, keeping the error message reported by the node
+func (q *Queries) UpdateQueryStatusMessage(queryName string, nodeID uint, statusCode int, message string) error {
 
 	var result string
 	if statusCode == 0 {
@@ -496,12 +504,20 @@ func (q *Queries) UpdateQueryStatus(queryName string, nodeID uint, statusCode in
 	if err := q.DB.Where("node_id = ? AND query_id = ?", nodeID, query.ID).Find(&nodeQuery).Error; err != nil {
 		return err
 	}
-	if err := q.DB.Model(&nodeQuery).Updates(map[string]interface{}{"status": result}).Error; err != nil {
+	if err := q.DB.Model(&nodeQuery).Updates(map[string]interface{}{"status": result, "message": message}).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
+// MoveNodeQueries to move the on-demand queries history of a node to another node
+func (q *Queries) MoveNodeQueries(fromID, toID uint) error {
+	if err := q.DB.Model(&NodeQuery{}).Where("node_id = ?", fromID).Update("node_id", toID).Error; err != nil {
+		return fmt.Errorf("Update %w", err)
+	}
+	return nil
+}
+
 // SetNodeQueriesAsExpired marks all pending node queries for a specific distributed query as expired
 func (q *Queries) SetNodeQueriesAsExpired(queryID uint) error {
 
@@ -17,6 +17,7 @@ func testDB(t *testing.T) *gorm.DB {
 	t.Helper()
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err, "Failed to open in-memory database")
+	require.NoError(t, db.AutoMigrate(&queries.NodeQuery{}, &queries.DistributedQuery{}, &queries.DistributedQueryTarget{}, &queries.SavedQuery{}, &queries.RecurringQuery{}, &queries.RecurringQueryRun{}, &nodes.OsqueryNode{}))
 
 	// Initialize the tables
 	q := queries.CreateQueries(db)
@@ -95,6 +96,37 @@ func TestNodeQueries(t *testing.T) {
 	})
 }
 
+func TestNodeQueriesAccelerate(t *testing.T) {
+	db := testDB(t)
+	q, nodes, query := setupTestData(t, db)
+	require.NoError(t, q.CreateNodeQueries([]uint{nodes[0].ID, nodes[1].ID}, query.ID))
+
+	// Regular queries do not accelerate
+	_, accelerate, err := q.NodeQueries(nodes[0])
+	require.NoError(t, err)
+	assert.False(t, accelerate, "Expected no acceleration for regular queries")
+
+	// Urgent queries accelerate during the window
+	query.SetUrgent(10 * time.Minute)
+	require.NoError(t, db.Save(query).Error)
+	_, accelerate, err = q.NodeQueries(nodes[0])
+	require.NoError(t, err)
+	assert.True(t, accelerate, "Expected acceleration for urgent queries")
+
+	// Nodes that already answered fall back
+	require.NoError(t, q.UpdateQueryStatus(query.Name, nodes[1].ID, 0))
+	_, accelerate, err = q.NodeQueries(nodes[1])
+	require.NoError(t, err)
+	assert.False(t, accelerate, "Expected no acceleration without pending queries")
+
+	// Urgent queries fall back after the window
+	query.SetUrgent(-time.Minute)
+	require.NoError(t, db.Save(query).Error)
+	_, accelerate, err = q.NodeQueries(nodes[0])
+	require.NoError(t, err)
+	assert.False(t, accelerate, "Expected no acceleration after the window")
+}
+
 func TestUpdateQueryStatus(t *testing.T) {
 	db := testDB(t)
 	q, nodes, query := setupTestData(t, db)
@@ -0,0 +1,219 @@
+package queries
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmpsec/osctrl/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// RecurringQuery as abstraction of a query definition that creates a new distributed query on every run
+type RecurringQuery struct {
+	gorm.Model
+	Name          string `gorm:"index"`
+	Creator       string
+	Query         string
+	EnvironmentID uint `gorm:"index"`
+	// Cron expression in UTC, used instead of the interval when set
+	Cron string
+	// Interval in minutes between runs
+	Interval int
+	// Expiration in hours for each run, zero for no expiration
+	ExpHours int
+	Hidden   bool
+	Paused   bool
+	// Targets serialized as JSON
+	Targets string
+	NextRun time.Time `gorm:"index"`
+	LastRun time.Time
+	Runs    int
+}
+
+// RecurringQueryTargets to keep the saved targets for each run of a recurring query
+type RecurringQueryTargets struct {
+	Environments []string `json:"environment_list"`
+	Platforms    []string `json:"platform_list"`
+	UUIDs        []string `json:"uuid_list"`
+	Hosts        []string `json:"host_list"`
+	Tags         []string `json:"tag_list"`
+	Selector     string   `json:"selector,omitempty"`
+}
+
+// RecurringQueryRun to keep the history of runs for a recurring query
+type RecurringQueryRun struct {
+	gorm.Model
+	RecurringQueryID uint `gorm:"index"`
+	QueryName        string
+	Expected         int
+	Error            string
+	RunAt            time.Time
+}
+
+// RecurringQueryDetails to show a recurring query with the history of runs
+type RecurringQueryDetails struct {
+	Query RecurringQuery      `json:"query"`
+	Runs  []RecurringQueryRun `json:"runs"`
+}
+
+// Helper to generate a random recurring query name
+func GenRecurringQueryName() string {
+	return "recurring_" + utils.RandomForNames()
+}
+
+// GetTargets - Function to deserialize the saved targets
+func (r RecurringQuery) GetTargets() (RecurringQueryTargets, error) {
+	var t RecurringQueryTargets
+	if r.Targets == "" {
+		return t, nil
+	}
+	if err := json.Unmarshal([]byte(r.Targets), &t); err != nil {
+		return t, fmt.Errorf("error parsing targets - %w", err)
+	}
+	return t, nil
+}
+
+// SetTargets - Function to serialize the targets to be saved
+func (r *RecurringQuery) SetTargets(t RecurringQueryTargets) error {
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("error serializing targets - %w", err)
+	}
+	r.Targets = string(raw)
+	return nil
+}
+
+// NextRunAfter - Function to calculate the next run of the recurring query after the provided time
+func (r RecurringQuery) NextRunAfter(t time.Time) (time.Time, error) {
+	if r.Cron != "" {
+		s, err := ParseCron(r.Cron)
+		if err != nil {
+			return time.Time{}, err
+		}
+		next := s.Next(t.UTC())
+		if next.IsZero() {
+			return next, fmt.Errorf("cron expression %q never runs", r.Cron)
+		}
+		return next, nil
+	}
+	if r.Interval <= 0 {
+		return time.Time{}, fmt.Errorf("cron expression or interval are required")
+	}
+	return t.Add(time.Duration(r.Interval) * time.Minute), nil
+}
+
+// CreateRecurring to create a new recurring query, calculating the first run
+func (q *Queries) CreateRecurring(rq *RecurringQuery) error {
+	if q.ExistsRecurring(rq.Name, rq.EnvironmentID) {
+		return fmt.Errorf("recurring query %s already exists", rq.Name)
+	}
+	next, err := rq.NextRunAfter(time.Now())
+	if err != nil {
+		return err
+	}
+	rq.NextRun = next
+	if err := q.DB.Create(rq).Error; err != nil {
+		return fmt.Errorf("Create RecurringQuery %w", err)
+	}
+	return nil
+}
+
+// ExistsRecurring checks if a recurring query exists in an environment
+func (q *Queries) ExistsRecurring(name string, envid uint) bool {
+	var count int64
+	q.DB.Model(&RecurringQuery{}).Where("name = ? AND environment_id = ?", name, envid).Count(&count)
+	return (count > 0)
+}
+
+// GetRecurring to get a recurring query by name and environment
+func (q *Queries) GetRecurring(name string, envid uint) (RecurringQuery, error) {
+	var rq RecurringQuery
+	if err := q.DB.Where("name = ? AND environment_id = ?", name, envid).First(&rq).Error; err != nil {
+		return rq, err
+	}
+	return rq, nil
+}
+
+// GetAllRecurring to get all recurring queries by environment
+func (q *Queries) GetAllRecurring(envid uint) ([]RecurringQuery, error) {
+	var rqs []RecurringQuery
+	if err := q.DB.Where("environment_id = ?", envid).Find(&rqs).Error; err != nil {
+		return rqs, err
+	}
+	return rqs, nil
+}
+
+// PauseRecurring to pause or resume a recurring query, resuming calculates the next run from now
+func (q *Queries) PauseRecurring(name string, envid uint, paused bool) error {
+	rq, err := q.GetRecurring(name, envid)
+	if err != nil {
+		return err
+	}
+	updates := map[string]interface{}{"paused": paused}
+	if !paused {
+		next, err := rq.NextRunAfter(time.Now())
+		if err != nil {
+			return err
+		}
+		updates["next_run"] = next
+	}
+	if err := q.DB.Model(&rq).Updates(updates).Error; err != nil {
+		return fmt.Errorf("Updates %w", err)
+	}
+	return nil
+}
+
+// DeleteRecurring to delete a recurring query, the history of runs is kept
+func (q *Queries) DeleteRecurring(name string, envid uint) error {
+	rq, err := q.GetRecurring(name, envid)
+	if err != nil {
+		return err
+	}
+	if err := q.DB.Delete(&rq).Error; err != nil {
+		return fmt.Errorf("Delete %w", err)
+	}
+	return nil
+}
+
+// GetRecurringRuns to get the history of runs for a recurring query, newest first
+func (q *Queries) GetRecurringRuns(id uint) ([]RecurringQueryRun, error) {
+	var runs []RecurringQueryRun
+	if err := q.DB.Where("recurring_query_id = ?", id).Order("run_at desc").Find(&runs).Error; err != nil {
+		return runs, err
+	}
+	return runs, nil
+}
+
+// GetDueRecurring to get all recurring queries not paused that must run at the provided time
+func (q *Queries) GetDueRecurring(now time.Time) ([]RecurringQuery, error) {
+	var rqs []RecurringQuery
+	if err := q.DB.Where("paused = ? AND next_run <= ?", false, now).Find(&rqs).Error; err != nil {
+		return rqs, err
+	}
+	return rqs, nil
+}
+
+// ClaimRecurring to move the next run of a due recurring query forward, returning false if another
+// service already claimed this run, so each run is only created once with multiple replicas
+func (q *Queries) ClaimRecurring(rq RecurringQuery, now time.Time) (bool, error) {
+	next, err := rq.NextRunAfter(now)
+	if err != nil {
+		return false, err
+	}
+	res := q.DB.Model(&RecurringQuery{}).
+		Where("id = ? AND next_run = ?", rq.ID, rq.NextRun).
+		Updates(map[string]interface{}{"next_run": next, "last_run": now, "runs": gorm.Expr("runs + 1")})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// CreateRecurringRun to add a run to the history of a recurring query
+func (q *Queries) CreateRecurringRun(run *RecurringQueryRun) error {
+	if err := q.DB.Create(run).Error; err != nil {
+		return fmt.Errorf("Create RecurringQueryRun %w", err)
+	}
+	return nil
+}
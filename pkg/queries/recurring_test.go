@@ -0,0 +1,97 @@
+package queries_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmpsec/osctrl/pkg/queries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecurringQueryNextRunAfter(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 10, 7, 0, 0, time.UTC)
+	next, err := queries.RecurringQuery{Interval: 90}.NextRunAfter(now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(90*time.Minute), next)
+
+	next, err = queries.RecurringQuery{Cron: "0 * * * *", Interval: 90}.NextRunAfter(now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, time.March, 15, 11, 0, 0, 0, time.UTC), next)
+
+	_, err = queries.RecurringQuery{}.NextRunAfter(now)
+	assert.Error(t, err)
+	_, err = queries.RecurringQuery{Cron: "invalid"}.NextRunAfter(now)
+	assert.Error(t, err)
+}
+
+func TestRecurringQueryTargets(t *testing.T) {
+	rq := queries.RecurringQuery{}
+	targets, err := rq.GetTargets()
+	require.NoError(t, err)
+	assert.Empty(t, targets.Platforms)
+
+	require.NoError(t, rq.SetTargets(queries.RecurringQueryTargets{Platforms: []string{"darwin"}, Tags: []string{"prod"}}))
+	targets, err = rq.GetTargets()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"darwin"}, targets.Platforms)
+	assert.Equal(t, []string{"prod"}, targets.Tags)
+}
+
+func TestRecurringQueryLifecycle(t *testing.T) {
+	q := queries.CreateQueries(testDB(t))
+
+	rq := queries.RecurringQuery{Name: "hunt", Query: "SELECT * FROM processes;", EnvironmentID: 1, Interval: 60}
+	require.NoError(t, q.CreateRecurring(&rq))
+	assert.True(t, q.ExistsRecurring("hunt", 1))
+	assert.False(t, q.ExistsRecurring("hunt", 2))
+	assert.Error(t, q.CreateRecurring(&queries.RecurringQuery{Name: "hunt", EnvironmentID: 1, Interval: 60}))
+
+	// Not due until the next run
+	due, err := q.GetDueRecurring(time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, due)
+	due, err = q.GetDueRecurring(rq.NextRun)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+
+	// Only the first claim of the same run succeeds
+	claimed, err := q.ClaimRecurring(due[0], rq.NextRun)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = q.ClaimRecurring(due[0], rq.NextRun)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	stored, err := q.GetRecurring("hunt", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.Runs)
+	assert.True(t, stored.NextRun.After(rq.NextRun))
+
+	// Run history, newest first
+	first := queries.RecurringQueryRun{RecurringQueryID: stored.ID, QueryName: "run1", RunAt: time.Now().Add(-time.Hour)}
+	second := queries.RecurringQueryRun{RecurringQueryID: stored.ID, QueryName: "run2", RunAt: time.Now()}
+	require.NoError(t, q.CreateRecurringRun(&first))
+	require.NoError(t, q.CreateRecurringRun(&second))
+	runs, err := q.GetRecurringRuns(stored.ID)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, "run2", runs[0].QueryName)
+
+	// Paused queries are never due
+	require.NoError(t, q.PauseRecurring("hunt", 1, true))
+	due, err = q.GetDueRecurring(time.Now().Add(24 * time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, due)
+	require.NoError(t, q.PauseRecurring("hunt", 1, false))
+	due, err = q.GetDueRecurring(time.Now().Add(24 * time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, due, 1)
+
+	// Deleted queries keep the history of runs
+	require.NoError(t, q.DeleteRecurring("hunt", 1))
+	assert.False(t, q.ExistsRecurring("hunt", 1))
+	runs, err = q.GetRecurringRuns(stored.ID)
+	require.NoError(t, err)
+	assert.Len(t, runs, 2)
+}
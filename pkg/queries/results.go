@@ -0,0 +1,183 @@
+package queries
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultNodeQueriesLimit for the page size of target nodes when not provided
+	DefaultNodeQueriesLimit = 25
+	// MaxNodeQueriesLimit to cap the page size of target nodes
+	MaxNodeQueriesLimit = 500
+)
+
+// NodeQueryStatuses to validate the status used to filter target nodes
+var NodeQueryStatuses = map[string]bool{
+	DistributedQueryStatusPending:   true,
+	DistributedQueryStatusCompleted: true,
+	DistributedQueryStatusError:     true,
+	DistributedQueryStatusExpired:   true,
+}
+
+// NodeQueryFilter to filter the target nodes of a distributed query
+type NodeQueryFilter struct {
+	// UUID of the node, case insensitive
+	UUID string
+	// Hostname or localname of the node
+	Hostname string
+	// Status of the query in the node
+	Status string
+	// Search matches UUID, hostname or localname partially
+	Search string
+}
+
+// NodeQueryTarget to hold the status of a distributed query in one target node
+type NodeQueryTarget struct {
+	NodeID    uint      `json:"-"`
+	UUID      string    `json:"uuid"`
+	Hostname  string    `json:"hostname"`
+	Localname string    `json:"localname"`
+	Status    string    `json:"status"`
+	Message   string    `json:"message,omitempty"`
+	LastSeen  time.Time `json:"last_seen"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NodeQueryStatusList to hold a page of target nodes of a distributed query with the count of nodes by status
+type NodeQueryStatusList struct {
+	Name       string            `json:"name"`
+	Expected   int               `json:"expected"`
+	Executions int               `json:"executions"`
+	Errors     int               `json:"errors"`
+	Statuses   map[string]int64  `json:"statuses"`
+	Total      int64             `json:"total"`
+	Filtered   int64             `json:"filtered"`
+	Offset     int               `json:"offset"`
+	Limit      int               `json:"limit"`
+	Nodes      []NodeQueryTarget `json:"nodes"`
+}
+
+// Helper to build the query for the target nodes of a distributed query with filters
+func (q *Queries) nodeQueriesFiltered(queryID uint, filter NodeQueryFilter) (*gorm.DB, error) {
+	query := q.DB.Table("node_queries").
+		Joins("JOIN osquery_nodes ON osquery_nodes.id = node_queries.node_id").
+		Where("node_queries.query_id = ? AND node_queries.deleted_at IS NULL", queryID)
+	if filter.UUID != "" {
+		query = query.Where("UPPER(osquery_nodes.uuid) = ?", strings.ToUpper(filter.UUID))
+	}
+	if filter.Hostname != "" {
+		query = query.Where("(osquery_nodes.hostname = ? OR osquery_nodes.localname = ?)", filter.Hostname, filter.Hostname)
+	}
+	if filter.Status != "" {
+		if !NodeQueryStatuses[filter.Status] {
+			return nil, fmt.Errorf("invalid status %s", filter.Status)
+		}
+		query = query.Where("node_queries.status = ?", filter.Status)
+	}
+	if filter.Search != "" {
+		like := "%" + filter.Search + "%"
+		query = query.Where("(osquery_nodes.uuid LIKE ? OR osquery_nodes.hostname LIKE ? OR osquery_nodes.localname LIKE ?)", like, like, like)
+	}
+	return query, nil
+}
+
+// CountNodeQueries to count the target nodes of a distributed query matching the filter
+func (q *Queries) CountNodeQueries(queryID uint, filter NodeQueryFilter) (int64, error) {
+	var count int64
+	query, err := q.nodeQueriesFiltered(queryID, filter)
+	if err != nil {
+		return 0, err
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("CountNodeQueries %w", err)
+	}
+	return count, nil
+}
+
+// GetNodeQueriesPage to get a page of target nodes of a distributed query matching the filter, sorted by hostname
+func (q *Queries) GetNodeQueriesPage(queryID uint, filter NodeQueryFilter, offset, limit int) ([]NodeQueryTarget, error) {
+	var targets []NodeQueryTarget
+	if limit <= 0 {
+		limit = DefaultNodeQueriesLimit
+	}
+	if limit > MaxNodeQueriesLimit {
+		limit = MaxNodeQueriesLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	query, err := q.nodeQueriesFiltered(queryID, filter)
+	if err != nil {
+		return targets, err
+	}
+	if err := query.
+		Select("node_queries.node_id, osquery_nodes.uuid, osquery_nodes.hostname, osquery_nodes.localname, node_queries.status, node_queries.message, osquery_nodes.last_seen, node_queries.updated_at").
+		Order("osquery_nodes.hostname, osquery_nodes.uuid").
+		Offset(offset).Limit(limit).
+		Scan(&targets).Error; err != nil {
+		return targets, fmt.Errorf("GetNodeQueriesPage %w", err)
+	}
+	return targets, nil
+}
+
+// CountNodeQueriesByStatus to count the target nodes of a distributed query by status
+func (q *Queries) CountNodeQueriesByStatus(queryID uint) (map[string]int64, error) {
+	counts := make(map[string]int64, len(NodeQueryStatuses))
+	for status := range NodeQueryStatuses {
+		counts[status] = 0
+	}
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := q.DB.Model(&NodeQuery{}).
+		Select("status, COUNT(*) AS count").
+		Where("query_id = ?", queryID).
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		return counts, fmt.Errorf("CountNodeQueriesByStatus %w", err)
+	}
+	for _, r := range rows {
+		counts[r.Status] = r.Count
+	}
+	return counts, nil
+}
+
+// GetNodeQueryStatus to get the status of a distributed query with a page of target nodes matching the filter
+func (q *Queries) GetNodeQueryStatus(query DistributedQuery, filter NodeQueryFilter, offset, limit int) (NodeQueryStatusList, error) {
+	if limit <= 0 {
+		limit = DefaultNodeQueriesLimit
+	}
+	if limit > MaxNodeQueriesLimit {
+		limit = MaxNodeQueriesLimit
+	}
+	list := NodeQueryStatusList{
+		Name:       query.Name,
+		Expected:   query.Expected,
+		Executions: query.Executions,
+		Errors:     query.Errors,
+		Offset:     offset,
+		Limit:      limit,
+		Nodes:      []NodeQueryTarget{},
+	}
+	var err error
+	if list.Statuses, err = q.CountNodeQueriesByStatus(query.ID); err != nil {
+		return list, err
+	}
+	for _, c := range list.Statuses {
+		list.Total += c
+	}
+	if list.Filtered, err = q.CountNodeQueries(query.ID, filter); err != nil {
+		return list, err
+	}
+	targets, err := q.GetNodeQueriesPage(query.ID, filter, offset, limit)
+	if err != nil {
+		return list, err
+	}
+	list.Nodes = append(list.Nodes, targets...)
+	return list, nil
+}
@@ -0,0 +1,103 @@
+package queries_test
+
+import (
+	"testing"
+
+	"github.com/jmpsec/osctrl/pkg/nodes"
+	"github.com/jmpsec/osctrl/pkg/queries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetNodeQueriesPage(t *testing.T) {
+	db := testDB(t)
+	q, _, query := setupTestData(t, db)
+	require.NoError(t, db.Model(&nodes.OsqueryNode{}).Where("id = ?", 1).Updates(map[string]interface{}{"uuid": "AAAA-1111", "hostname": "alpha"}).Error)
+	require.NoError(t, db.Model(&nodes.OsqueryNode{}).Where("id = ?", 2).Updates(map[string]interface{}{"uuid": "BBBB-2222", "hostname": "bravo"}).Error)
+	require.NoError(t, db.Model(&nodes.OsqueryNode{}).Where("id = ?", 3).Updates(map[string]interface{}{"uuid": "CCCC-3333", "hostname": "charlie", "localname": "charlie.local"}).Error)
+	require.NoError(t, q.CreateNodeQueries([]uint{1, 2, 3}, query.ID))
+	require.NoError(t, q.UpdateQueryStatus(query.Name, 2, 0))
+	require.NoError(t, q.UpdateQueryStatus(query.Name, 3, 1))
+
+	count, err := q.CountNodeQueries(query.ID, queries.NodeQueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	// Pagination sorted by hostname
+	page, err := q.GetNodeQueriesPage(query.ID, queries.NodeQueryFilter{}, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "bravo", page[0].Hostname)
+	assert.Equal(t, queries.DistributedQueryStatusCompleted, page[0].Status)
+
+	tests := []struct {
+		name     string
+		filter   queries.NodeQueryFilter
+		expected []string
+	}{
+		{"uuid case insensitive", queries.NodeQueryFilter{UUID: "aaaa-1111"}, []string{"alpha"}},
+		{"hostname", queries.NodeQueryFilter{Hostname: "bravo"}, []string{"bravo"}},
+		{"localname", queries.NodeQueryFilter{Hostname: "charlie.local"}, []string{"charlie"}},
+		{"status", queries.NodeQueryFilter{Status: queries.DistributedQueryStatusPending}, []string{"alpha"}},
+		{"search", queries.NodeQueryFilter{Search: "a"}, []string{"alpha", "bravo", "charlie"}},
+		{"combined", queries.NodeQueryFilter{Status: queries.DistributedQueryStatusError, Search: "char"}, []string{"charlie"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, err := q.GetNodeQueriesPage(query.ID, tt.filter, 0, 0)
+			require.NoError(t, err)
+			var hostnames []string
+			for _, n := range targets {
+				hostnames = append(hostnames, n.Hostname)
+			}
+			assert.Equal(t, tt.expected, hostnames)
+		})
+	}
+
+	_, err = q.GetNodeQueriesPage(query.ID, queries.NodeQueryFilter{Status: "unknown"}, 0, 0)
+	assert.Error(t, err)
+
+	// Deleted node queries are ignored
+	require.NoError(t, db.Where("node_id = ?", 1).Delete(&queries.NodeQuery{}).Error)
+	count, err = q.CountNodeQueries(query.ID, queries.NodeQueryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestGetNodeQueryStatus(t *testing.T) {
+	db := testDB(t)
+	q, _, query := setupTestData(t, db)
+	require.NoError(t, db.Model(&nodes.OsqueryNode{}).Where("id = ?", 1).Updates(map[string]interface{}{"uuid": "AAAA-1111", "hostname": "alpha"}).Error)
+	require.NoError(t, db.Model(&nodes.OsqueryNode{}).Where("id = ?", 2).Updates(map[string]interface{}{"uuid": "BBBB-2222", "hostname": "bravo"}).Error)
+	require.NoError(t, db.Model(&nodes.OsqueryNode{}).Where("id = ?", 3).Updates(map[string]interface{}{"uuid": "CCCC-3333", "hostname": "charlie"}).Error)
+	require.NoError(t, q.CreateNodeQueries([]uint{1, 2, 3}, query.ID))
+	require.NoError(t, q.UpdateQueryStatusMessage(query.Name, 2, 0, ""))
+	require.NoError(t, q.UpdateQueryStatusMessage(query.Name, 3, 1, "no such table: foo"))
+
+	list, err := q.GetNodeQueryStatus(*query, queries.NodeQueryFilter{}, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), list.Total)
+	assert.Equal(t, int64(3), list.Filtered)
+	assert.Equal(t, queries.DefaultNodeQueriesLimit, list.Limit)
+	assert.Equal(t, int64(1), list.Statuses[queries.DistributedQueryStatusPending])
+	assert.Equal(t, int64(1), list.Statuses[queries.DistributedQueryStatusCompleted])
+	assert.Equal(t, int64(1), list.Statuses[queries.DistributedQueryStatusError])
+	assert.Equal(t, int64(0), list.Statuses[queries.DistributedQueryStatusExpired])
+	require.Len(t, list.Nodes, 3)
+
+	// Errored nodes keep the message reported by osquery
+	list, err = q.GetNodeQueryStatus(*query, queries.NodeQueryFilter{Status: queries.DistributedQueryStatusError}, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), list.Filtered)
+	require.Len(t, list.Nodes, 1)
+	assert.Equal(t, "charlie", list.Nodes[0].Hostname)
+	assert.Equal(t, "no such table: foo", list.Nodes[0].Message)
+
+	// Pending nodes become expired with the query
+	require.NoError(t, q.SetNodeQueriesAsExpired(query.ID))
+	list, err = q.GetNodeQueryStatus(*query, queries.NodeQueryFilter{Status: queries.DistributedQueryStatusExpired}, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, list.Nodes, 1)
+	assert.Equal(t, "alpha", list.Nodes[0].Hostname)
+	assert.Equal(t, int64(0), list.Statuses[queries.DistributedQueryStatusPending])
+}
@@ -5,6 +5,7 @@ import (
 
 	"gorm.io/gorm"
 
+	"github.com/jmpsec/osctrl/pkg/cache"
 	"github.com/jmpsec/osctrl/pkg/config"
 	"github.com/rs/zerolog/log"
 )
@@ -42,6 +43,8 @@ const (
 	CarveDelete   string = QueryDelete
 	CarveExpire   string = QueryExpire
 	CarveComplete string = QueryComplete
+	QueryPause    string = "pause"
+	QueryResume   string = "resume"
 )
 
 // Types of package
@@ -82,8 +85,18 @@ const (
 	MetricsProtocol    string = "metrics_protocol"
 	InactiveHours      string = "inactive_hours"
 	AcceleratedSeconds string = "accelerated_seconds"
+	AcceleratedWindow  string = "accelerated_window"
 	NodeDashboard      string = "node_dashboard"
 	OnelinerExpiration string = "oneliner_expiration"
+	StaleCarves        string = "stale_carves"
+	ReissueCarves      string = "reissue_carves"
+	RequireMFA         string = "require_mfa"
+	PasswordMinLength  string = "password_min_length"
+	PasswordMinClasses string = "password_min_classes"
+	LockoutAttempts    string = "lockout_attempts"
+	LockoutMinutes     string = "lockout_minutes"
+	ThrottleAttempts   string = "throttle_attempts"
+	ThrottleMinutes    string = "throttle_minutes"
 )
 
 // Names for the values that are read from the JSON config file
@@ -97,6 +110,28 @@ const (
 	JSONSessionKey string = "json_sessionkey"
 )
 
+// DefaultAcceleratedWindow in seconds for urgent queries when the setting is not available
+const DefaultAcceleratedWindow int64 = 600
+
+// DefaultStaleCarves in seconds without new blocks for a carve to be stale, disabled unless configured
+const DefaultStaleCarves int64 = 0
+
+// Defaults for login security when the settings are not available
+const (
+	// DefaultPasswordMinLength as minimum length of passwords
+	DefaultPasswordMinLength int64 = 8
+	// DefaultPasswordMinClasses as minimum character classes of passwords
+	DefaultPasswordMinClasses int64 = 1
+	// DefaultLockoutAttempts as failed logins before a user is locked, zero disables it
+	DefaultLockoutAttempts int64 = 5
+	// DefaultLockoutMinutes as duration of the lockout of a user
+	DefaultLockoutMinutes int64 = 15
+	// DefaultThrottleAttempts as login attempts allowed per IP in each window, zero disables it
+	DefaultThrottleAttempts int64 = 20
+	// DefaultThrottleMinutes as duration of the login throttling window
+	DefaultThrottleMinutes int64 = 1
+)
+
 // Values for generic IDs
 const (
 	NoEnvironmentID = iota
@@ -122,6 +157,8 @@ type MapSettings map[string]SettingValue
 // Settings keeps all settings values
 type Settings struct {
 	DB *gorm.DB
+	// Bus to publish changes so settings are reloaded in all replicas
+	Bus cache.InvalidationBus
 }
 
 // ValidTypes to check validity of settings type
@@ -138,13 +175,9 @@ var ValidServices = map[string]struct{}{
 	config.ServiceAPI:   {},
 }
 
-// NewSettings to initialize the access to settings and table
+// NewSettings to initialize the access to settings, the table is created by migrations
 func NewSettings(backend *gorm.DB) *Settings {
 	var s *Settings = &Settings{DB: backend}
-	// table setting_values
-	if err := backend.AutoMigrate(&SettingValue{}); err != nil {
-		log.Fatal().Msgf("Failed to AutoMigrate table (setting_values): %v", err)
-	}
 	return s
 }
 
@@ -179,6 +212,7 @@ func (conf *Settings) NewValue(service, name, typeValue string, value interface{
 	if err := conf.DB.Create(&entry).Error; err != nil {
 		return fmt.Errorf("Create NewValue %w", err)
 	}
+	cache.PublishInvalidation(conf.Bus, cache.TopicSettings, service)
 	return nil
 }
 
@@ -192,6 +226,7 @@ func (conf *Settings) NewJSON(service, name, value string, envID uint) error {
 	if err := conf.DB.Create(&entry).Error; err != nil {
 		return fmt.Errorf("Create NewJSON %w", err)
 	}
+	cache.PublishInvalidation(conf.Bus, cache.TopicSettings, service)
 	return nil
 }
 
@@ -231,6 +266,7 @@ func (conf *Settings) DeleteValue(service, name string, envID uint) error {
 	if err := conf.DB.Unscoped().Delete(&value).Error; err != nil {
 		return fmt.Errorf("Delete %w", err)
 	}
+	cache.PublishInvalidation(conf.Bus, cache.TopicSettings, service)
 	return nil
 }
 
@@ -422,6 +458,7 @@ func (conf *Settings) SetInteger(intValue int64, service, name string, envID uin
 		return fmt.Errorf("Updates %w", err)
 	}
 	log.Debug().Msgf("SetInteger %d %s %s", intValue, service, name)
+	cache.PublishInvalidation(conf.Bus, cache.TopicSettings, service)
 	return nil
 }
 
@@ -446,6 +483,7 @@ func (conf *Settings) SetBoolean(boolValue bool, service, name string, envID uin
 		return fmt.Errorf("Updates %w", err)
 	}
 	log.Debug().Msgf("SetBoolean %v %s %s", boolValue, service, name)
+	cache.PublishInvalidation(conf.Bus, cache.TopicSettings, service)
 	return nil
 }
 
@@ -488,6 +526,7 @@ func (conf *Settings) SetString(strValue string, service, name string, _json boo
 		return fmt.Errorf("Updates %w", err)
 	}
 	log.Debug().Msgf("SetString %s %s %s", strValue, service, name)
+	cache.PublishInvalidation(conf.Bus, cache.TopicSettings, service)
 	return nil
 }
 
@@ -512,6 +551,7 @@ func (conf *Settings) SetInfo(info string, service, name string, envID uint) err
 		return fmt.Errorf("Updates %w", err)
 	}
 	log.Debug().Msgf("SetInfo %s %s %s", info, service, name)
+	cache.PublishInvalidation(conf.Bus, cache.TopicSettings, service)
 	return nil
 }
 
@@ -572,6 +612,96 @@ func (conf *Settings) InactiveHours(envID uint) int64 {
 	return value.Integer
 }
 
+// AcceleratedWindow gets the value in seconds for nodes to stay accelerated with urgent queries
+func (conf *Settings) AcceleratedWindow(envID uint) int64 {
+	value, err := conf.RetrieveValue(config.ServiceAdmin, AcceleratedWindow, envID)
+	if err != nil {
+		return DefaultAcceleratedWindow
+	}
+	return value.Integer
+}
+
+// StaleCarves gets the value in seconds without new blocks for a carve to be stale, zero disables it
+func (conf *Settings) StaleCarves() int64 {
+	value, err := conf.RetrieveValue(config.ServiceAdmin, StaleCarves, NoEnvironmentID)
+	if err != nil {
+		return DefaultStaleCarves
+	}
+	return value.Integer
+}
+
+// ReissueCarves gets how many times a stale carve is re-issued automatically, zero disables it
+func (conf *Settings) ReissueCarves() int64 {
+	value, err := conf.RetrieveValue(config.ServiceAdmin, ReissueCarves, NoEnvironmentID)
+	if err != nil {
+		return 0
+	}
+	return value.Integer
+}
+
+// RequireMFA checks if a second factor is enforced for all users with DB logins
+func (conf *Settings) RequireMFA() bool {
+	value, err := conf.RetrieveValue(config.ServiceAdmin, RequireMFA, NoEnvironmentID)
+	if err != nil {
+		return false
+	}
+	return value.Boolean
+}
+
+// PasswordMinLength gets the minimum length of passwords
+func (conf *Settings) PasswordMinLength() int64 {
+	value, err := conf.RetrieveValue(config.ServiceAdmin, PasswordMinLength, NoEnvironmentID)
+	if err != nil {
+		return DefaultPasswordMinLength
+	}
+	return value.Integer
+}
+
+// PasswordMinClasses gets how many character classes are required in passwords
+func (conf *Settings) PasswordMinClasses() int64 {
+	value, err := conf.RetrieveValue(config.ServiceAdmin, PasswordMinClasses, NoEnvironmentID)
+	if err != nil {
+		return DefaultPasswordMinClasses
+	}
+	return value.Integer
+}
+
+// LockoutAttempts gets how many failed logins lock a user, zero disables it
+func (conf *Settings) LockoutAttempts() int64 {
+	value, err := conf.RetrieveValue(config.ServiceAdmin, LockoutAttempts, NoEnvironmentID)
+	if err != nil {
+		return DefaultLockoutAttempts
+	}
+	return value.Integer
+}
+
+// LockoutMinutes gets for how many minutes a user is locked after too many failed logins
+func (conf *Settings) LockoutMinutes() int64 {
+	value, err := conf.RetrieveValue(config.ServiceAdmin, LockoutMinutes, NoEnvironmentID)
+	if err != nil {
+		return DefaultLockoutMinutes
+	}
+	return value.Integer
+}
+
+// ThrottleAttempts gets how many login attempts are allowed per IP in each window, zero disables it
+func (conf *Settings) ThrottleAttempts() int64 {
+	value, err := conf.RetrieveValue(config.ServiceAdmin, ThrottleAttempts, NoEnvironmentID)
+	if err != nil {
+		return DefaultThrottleAttempts
+	}
+	return value.Integer
+}
+
+// ThrottleMinutes gets the duration in minutes of the login throttling window
+func (conf *Settings) ThrottleMinutes() int64 {
+	value, err := conf.RetrieveValue(config.ServiceAdmin, ThrottleMinutes, NoEnvironmentID)
+	if err != nil {
+		return DefaultThrottleMinutes
+	}
+	return value.Integer
+}
+
 // NodeDashboard checks if display dashboard per node is enabled
 func (conf *Settings) NodeDashboard(envID uint) bool {
 	value, err := conf.RetrieveValue(config.ServiceAdmin, NodeDashboard, envID)
@@ -5,7 +5,6 @@ import (
 	"strings"
 
 	"github.com/jmpsec/osctrl/pkg/nodes"
-	"github.com/rs/zerolog/log"
 	"gorm.io/gorm"
 )
 
@@ -86,17 +85,9 @@ type TagManager struct {
 	DB *gorm.DB
 }
 
-// CreateTagManager to initialize the tags struct and tables
+// CreateTagManager to initialize the tags struct, tables are created by migrations
 func CreateTagManager(backend *gorm.DB) *TagManager {
 	var t *TagManager = &TagManager{DB: backend}
-	// table admin_tags
-	if err := backend.AutoMigrate(&AdminTag{}); err != nil {
-		log.Fatal().Msgf("Failed to AutoMigrate table (admin_tags): %v", err)
-	}
-	// table tagged_nodes
-	if err := backend.AutoMigrate(&TaggedNode{}); err != nil {
-		log.Fatal().Msgf("Failed to AutoMigrate table (tagged_nodes): %v", err)
-	}
 	return t
 }
 
@@ -472,6 +463,26 @@ func (m *TagManager) UntagNode(name string, node nodes.OsqueryNode) error {
 	return nil
 }
 
+// MoveNodeTags to move the tags of a node to another node, skipping the tags the other node has already
+func (m *TagManager) MoveNodeTags(fromID, toID uint) error {
+	var tagged []TaggedNode
+	if err := m.DB.Where("node_id = ?", fromID).Find(&tagged).Error; err != nil {
+		return fmt.Errorf("TaggedNode %w", err)
+	}
+	for _, t := range tagged {
+		if m.IsTaggedID(t.Tag, toID) {
+			if err := m.DB.Unscoped().Delete(&t).Error; err != nil {
+				return fmt.Errorf("Delete %w", err)
+			}
+			continue
+		}
+		if err := m.DB.Model(&t).Update("node_id", toID).Error; err != nil {
+			return fmt.Errorf("Update %w", err)
+		}
+	}
+	return nil
+}
+
 // GetTags to retrieve the tags of a given node
 func (m *TagManager) GetTags(node nodes.OsqueryNode) ([]AdminTag, error) {
 	var tags []AdminTag
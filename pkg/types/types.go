@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 // OsqueryTable to show tables to query
 type OsqueryTable struct {
 	Name      string   `json:"name"`
@@ -20,11 +22,28 @@ type FlagsRequest struct {
 	Secret     string `json:"secret"`
 	SecrefFile string `json:"secretFile"`
 	CertFile   string `json:"certFile"`
+	// Client certificate and key files, for environments using mTLS
+	ClientCertFile string `json:"clientCertFile"`
+	ClientKeyFile  string `json:"clientKeyFile"`
 }
 
 // CertRequest to retrieve certificate
 type CertRequest FlagsRequest
 
+// ClientCertRequest to request a client certificate for a node, for environments using mTLS
+type ClientCertRequest struct {
+	Secret         string `json:"secret"`
+	UUID           string `json:"uuid"`
+	Hostname       string `json:"hostname"`
+	HardwareSerial string `json:"hardware_serial"`
+}
+
+// ClientCertResponse for client certificate requests from osctrld
+type ClientCertResponse struct {
+	Certificate string `json:"certificate"`
+	Key         string `json:"key"`
+}
+
 // VerifyRequest to verify nodes
 type VerifyRequest FlagsRequest
 
@@ -50,9 +69,27 @@ type ApiDistributedQueryRequest struct {
 	Environments []string `json:"environment_list"`
 	Hosts        []string `json:"host_list"`
 	Tags         []string `json:"tag_list"`
+	Selector     string   `json:"selector"`
 	Query        string   `json:"query"`
 	Path         string   `json:"path"`
 	Hidden       bool     `json:"hidden"`
+	Urgent       bool     `json:"urgent"`
+	ExpHours     int      `json:"exp_hours"`
+}
+
+// ApiRecurringQueryRequest to receive recurring query requests
+type ApiRecurringQueryRequest struct {
+	Name         string   `json:"name"`
+	UUIDs        []string `json:"uuid_list"`
+	Platforms    []string `json:"platform_list"`
+	Environments []string `json:"environment_list"`
+	Hosts        []string `json:"host_list"`
+	Tags         []string `json:"tag_list"`
+	Selector     string   `json:"selector"`
+	Query        string   `json:"query"`
+	Cron         string   `json:"cron"`
+	Interval     int      `json:"interval"`
+	Hidden       bool     `json:"hidden"`
 	ExpHours     int      `json:"exp_hours"`
 }
 
@@ -69,11 +106,23 @@ type ApiNodeTagRequest struct {
 	Custom string `json:"custom"`
 }
 
+// ApiNodeMergeRequest to receive merge node requests, with the IDs of the nodes
+type ApiNodeMergeRequest struct {
+	Target     uint   `json:"target"`
+	Duplicates []uint `json:"duplicates"`
+}
+
+// ApiNodeApproveRequest to receive requests to approve nodes pending approval, with their UUIDs
+type ApiNodeApproveRequest struct {
+	UUIDs []string `json:"uuids"`
+}
+
 // ApiLoginRequest to receive login requests
 type ApiLoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	ExpHours int    `json:"exp_hours"`
+	OTP      string `json:"otp"`
 }
 
 // ApiErrorResponse to be returned to API requests with the error message
@@ -101,6 +150,36 @@ type ApiLoginResponse struct {
 	Token string `json:"token"`
 }
 
+// ApiUserTokenRequest to receive requests to create named API tokens for users
+type ApiUserTokenRequest struct {
+	Name        string `json:"name"`
+	Environment string `json:"environment"`
+	Scope       string `json:"scope"`
+	ExpHours    int    `json:"exp_hours"`
+}
+
+// ApiUserTokenResponse to be returned to API requests to create named tokens
+type ApiUserTokenResponse struct {
+	Token     string    `json:"token"`
+	TokenID   string    `json:"token_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ApiEnrollTokenRequest to receive requests to create enrollment tokens
+type ApiEnrollTokenRequest struct {
+	Hostname string `json:"hostname"`
+	Serial   string `json:"serial"`
+	MaxUses  int    `json:"max_uses"`
+	ExpHours int    `json:"exp_hours"`
+}
+
+// ApiEnrollTokenResponse to be returned to API requests to create enrollment tokens
+type ApiEnrollTokenResponse struct {
+	Token     string    `json:"token"`
+	TokenID   string    `json:"token_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
 // ApiActionsRequest to receive action requests
 type ApiActionsRequest struct {
 	Certificate string `json:"certificate"`
@@ -138,4 +217,6 @@ type ApiUserRequest struct {
 	NotService   bool     `json:"not_service"`
 	API          bool     `json:"api"`
 	Environments []string `json:"environments"`
+	ResetMFA     bool     `json:"reset_mfa"`
+	Unlock       bool     `json:"unlock"`
 }
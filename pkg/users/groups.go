@@ -0,0 +1,88 @@
+package users
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/jmpsec/osctrl/pkg/environments"
+)
+
+const (
+	// AllEnvironments to map a group to every environment
+	AllEnvironments = "*"
+)
+
+// GroupMapping to map an IdP group to access in environments, by name or UUID
+type GroupMapping struct {
+	Group        string    `json:"group"`
+	Environments []string  `json:"environments"`
+	Access       EnvAccess `json:"access"`
+}
+
+// AccessChange to hold the previous and new access of a user in one environment
+type AccessChange struct {
+	Environment environments.TLSEnvironment
+	Previous    EnvAccess
+	Current     EnvAccess
+}
+
+// MapGroupsAccess - Function to generate the access for a list of groups, merging all the matching mappings
+func MapGroupsAccess(mappings []GroupMapping, groups []string, envs []environments.TLSEnvironment) UserAccess {
+	access := make(UserAccess)
+	for _, m := range mappings {
+		if !slices.Contains(groups, m.Group) {
+			continue
+		}
+		for _, env := range envs {
+			if !slices.Contains(m.Environments, AllEnvironments) && !slices.Contains(m.Environments, env.Name) && !slices.Contains(m.Environments, env.UUID) {
+				continue
+			}
+			acs := access[env.UUID]
+			acs = GenEnvAccess(acs.Admin || m.Access.Admin, acs.Carve || m.Access.Carve, acs.Query || m.Access.Query, acs.User || m.Access.User)
+			access[env.UUID] = acs
+		}
+	}
+	return access
+}
+
+// SyncAccess - Function to make the permissions of a user in all environments match the access provided,
+// removing access to any environment not included. It returns the environments where access changed.
+func (m *UserManager) SyncAccess(username, granted string, access UserAccess, envs []environments.TLSEnvironment) ([]AccessChange, error) {
+	var changes []AccessChange
+	if !m.Exists(username) {
+		return changes, fmt.Errorf("user %s does not exist", username)
+	}
+	current, err := m.GetAccess(username)
+	if err != nil {
+		return changes, fmt.Errorf("error getting access - %w", err)
+	}
+	for _, env := range envs {
+		prev, exists := current[env.UUID]
+		next := access[env.UUID]
+		if SameAccess(prev, next) {
+			continue
+		}
+		switch {
+		case next == (EnvAccess{}):
+			if err := m.DeleteEnvPermissions(username, env.UUID); err != nil {
+				return changes, err
+			}
+		case !exists:
+			perms := m.GenPermissions(username, granted, m.GenUserAccess(env, next))
+			if err := m.CreatePermissions(perms); err != nil {
+				return changes, err
+			}
+		default:
+			if err := m.ChangeAccess(username, env.UUID, next); err != nil {
+				return changes, err
+			}
+		}
+		changes = append(changes, AccessChange{Environment: env, Previous: prev, Current: next})
+	}
+	return changes, nil
+}
+
+// String - Function to format access for logs
+func (a EnvAccess) String() string {
+	return fmt.Sprintf("user=%t query=%t carve=%t admin=%t", a.User, a.Query, a.Carve, a.Admin)
+}
@@ -0,0 +1,90 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/jmpsec/osctrl/pkg/environments"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMapGroupsAccess(t *testing.T) {
+	envs := []environments.TLSEnvironment{
+		{Name: "dev", UUID: "uuid-dev"},
+		{Name: "prod", UUID: "uuid-prod"},
+	}
+	mappings := []GroupMapping{
+		{Group: "readers", Environments: []string{AllEnvironments}, Access: EnvAccess{User: true}},
+		{Group: "responders", Environments: []string{"prod"}, Access: EnvAccess{Query: true, Carve: true}},
+		{Group: "dev-admins", Environments: []string{"uuid-dev"}, Access: EnvAccess{Admin: true}},
+	}
+
+	access := MapGroupsAccess(mappings, []string{"readers", "responders"}, envs)
+	assert.Equal(t, EnvAccess{User: true}, access["uuid-dev"])
+	assert.Equal(t, EnvAccess{User: true, Query: true, Carve: true}, access["uuid-prod"])
+
+	access = MapGroupsAccess(mappings, []string{"dev-admins"}, envs)
+	assert.Equal(t, EnvAccess{User: true, Query: true, Carve: true, Admin: true}, access["uuid-dev"])
+	_, ok := access["uuid-prod"]
+	assert.False(t, ok)
+
+	access = MapGroupsAccess(mappings, []string{"unknown"}, envs)
+	assert.Empty(t, access)
+}
+
+func TestSyncAccess(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&AdminUser{}, &UserPermission{}))
+	m := CreateUserManager(db, &config.JSONConfigurationJWT{JWTSecret: "test", HoursToExpire: 1})
+	user, err := m.NewSSO("tester", "", "", false)
+	require.NoError(t, err)
+	require.NoError(t, m.Create(user))
+	envs := []environments.TLSEnvironment{
+		{Name: "dev", UUID: "uuid-dev"},
+		{Name: "prod", UUID: "uuid-prod"},
+	}
+	mappings := []GroupMapping{
+		{Group: "readers", Environments: []string{AllEnvironments}, Access: EnvAccess{User: true}},
+		{Group: "responders", Environments: []string{"prod"}, Access: EnvAccess{Query: true, Carve: true}},
+	}
+
+	_, err = m.SyncAccess("unknown", "sso", MapGroupsAccess(mappings, []string{"readers"}, envs), envs)
+	assert.Error(t, err)
+
+	// Grant access from the groups
+	changes, err := m.SyncAccess("tester", "sso", MapGroupsAccess(mappings, []string{"readers", "responders"}, envs), envs)
+	require.NoError(t, err)
+	assert.Len(t, changes, 2)
+	access, err := m.GetAccess("tester")
+	require.NoError(t, err)
+	assert.Equal(t, EnvAccess{User: true}, access["uuid-dev"])
+	assert.Equal(t, EnvAccess{User: true, Query: true, Carve: true}, access["uuid-prod"])
+	assert.True(t, m.CheckPermissions("tester", CarveLevel, "uuid-prod"))
+
+	// Same groups do not change anything
+	changes, err = m.SyncAccess("tester", "sso", MapGroupsAccess(mappings, []string{"readers", "responders"}, envs), envs)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	// Removing a group revokes the access it granted
+	changes, err = m.SyncAccess("tester", "sso", MapGroupsAccess(mappings, []string{"readers"}, envs), envs)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "prod", changes[0].Environment.Name)
+	assert.Equal(t, EnvAccess{User: true, Query: true, Carve: true}, changes[0].Previous)
+	assert.Equal(t, EnvAccess{User: true}, changes[0].Current)
+	assert.False(t, m.CheckPermissions("tester", CarveLevel, "uuid-prod"))
+
+	// Removing all groups revokes all access
+	changes, err = m.SyncAccess("tester", "sso", MapGroupsAccess(mappings, nil, envs), envs)
+	require.NoError(t, err)
+	assert.Len(t, changes, 2)
+	access, err = m.GetAccess("tester")
+	require.NoError(t, err)
+	assert.Empty(t, access)
+	assert.False(t, m.CheckPermissions("tester", UserLevel, "uuid-dev"))
+}
@@ -0,0 +1,252 @@
+package users
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// MFADigits is the number of digits of TOTP codes
+	MFADigits int = 6
+	// MFAPeriod is the time step in seconds of TOTP codes
+	MFAPeriod int64 = 30
+	// MFASkew is how many time steps before and after the current one are accepted
+	MFASkew int64 = 1
+	// MFARecoveryCodes is how many recovery codes are generated for each user
+	MFARecoveryCodes int = 10
+	// Separator for the hashes of the recovery codes
+	recoverySeparator string = ","
+)
+
+var (
+	// ErrMFARequired when a login needs a second factor
+	ErrMFARequired = errors.New("second factor required")
+	// ErrMFAEnrollment when a login needs a second factor but the user has not enrolled yet
+	ErrMFAEnrollment = errors.New("second factor enrollment required")
+	// ErrMFAInvalid when the second factor provided in a login is not valid
+	ErrMFAInvalid = errors.New("invalid second factor")
+)
+
+// Encoding used for TOTP secrets, as expected by authenticator apps
+var mfaEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateMFASecret to generate a new random TOTP secret
+func GenerateMFASecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return mfaEncoding.EncodeToString(b), nil
+}
+
+// MFAURI to generate the otpauth URI used for the enrollment QR code
+func MFAURI(issuer, username, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("digits", fmt.Sprintf("%d", MFADigits))
+	v.Set("period", fmt.Sprintf("%d", MFAPeriod))
+	label := url.PathEscape(issuer + ":" + username)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// TOTPCode to generate the TOTP code (RFC 6238) for a secret in the time step of the provided time
+func TOTPCode(secret string, t time.Time) (string, error) {
+	return totpStepCode(secret, t.Unix()/MFAPeriod)
+}
+
+// Helper to generate the TOTP code for a time step
+func totpStepCode(secret string, step int64) (string, error) {
+	key, err := mfaEncoding.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+	if err != nil {
+		return "", fmt.Errorf("invalid secret %w", err)
+	}
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < MFADigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", MFADigits, value%mod), nil
+}
+
+// Helper to find the time step of a valid TOTP code, considering the allowed skew
+func totpValidStep(secret, code string, t time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != MFADigits {
+		return 0, false
+	}
+	current := t.Unix() / MFAPeriod
+	for step := current - MFASkew; step <= current+MFASkew; step++ {
+		expected, err := totpStepCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// Helper to hash recovery codes before storing them
+func hashRecoveryCode(code string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code)))))
+}
+
+// Helper to generate new random recovery codes and their hashes
+func generateRecoveryCodes() ([]string, []string, error) {
+	var codes, hashes []string
+	for i := 0; i < MFARecoveryCodes; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		code = code[:4] + "-" + code[4:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// MFANeeded checks if a second factor is needed for a user to login
+func MFANeeded(user AdminUser, required bool) bool {
+	return user.MFAEnabled || required
+}
+
+// RecoveryCodesLeft returns how many recovery codes have not been used yet
+func (u AdminUser) RecoveryCodesLeft() int {
+	if u.MFARecoveryCodes == "" {
+		return 0
+	}
+	return len(strings.Split(u.MFARecoveryCodes, recoverySeparator))
+}
+
+// NewMFASecret to generate and store a pending TOTP secret for a user, that needs to be confirmed
+func (m *UserManager) NewMFASecret(username string) (string, error) {
+	user, err := m.Get(username)
+	if err != nil {
+		return "", fmt.Errorf("error getting user %w", err)
+	}
+	if user.MFAEnabled {
+		return "", fmt.Errorf("MFA is already enabled for %s", username)
+	}
+	secret, err := GenerateMFASecret()
+	if err != nil {
+		return "", err
+	}
+	if err := m.DB.Model(&user).Update("mfa_secret", secret).Error; err != nil {
+		return "", fmt.Errorf("update %w", err)
+	}
+	return secret, nil
+}
+
+// EnableMFA to confirm the pending TOTP secret of a user with a valid code. It returns the recovery codes
+func (m *UserManager) EnableMFA(username, code string) ([]string, error) {
+	user, err := m.Get(username)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user %w", err)
+	}
+	if user.MFAEnabled {
+		return nil, fmt.Errorf("MFA is already enabled for %s", username)
+	}
+	if user.MFASecret == "" {
+		return nil, fmt.Errorf("MFA enrollment not started for %s", username)
+	}
+	step, valid := totpValidStep(user.MFASecret, code, time.Now())
+	if !valid {
+		return nil, fmt.Errorf("invalid code")
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.DB.Model(&user).Updates(map[string]interface{}{
+		"mfa_enabled":        true,
+		"mfa_recovery_codes": strings.Join(hashes, recoverySeparator),
+		"mfa_last_step":      step,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("Updates %w", err)
+	}
+	return codes, nil
+}
+
+// DisableMFA to remove the second factor of a user
+func (m *UserManager) DisableMFA(username string) error {
+	user, err := m.Get(username)
+	if err != nil {
+		return fmt.Errorf("error getting user %w", err)
+	}
+	if err := m.DB.Model(&user).Updates(map[string]interface{}{
+		"mfa_enabled":        false,
+		"mfa_secret":         "",
+		"mfa_recovery_codes": "",
+		"mfa_last_step":      0,
+	}).Error; err != nil {
+		return fmt.Errorf("Updates %w", err)
+	}
+	return nil
+}
+
+// NewRecoveryCodes to replace the recovery codes of a user with MFA enabled
+func (m *UserManager) NewRecoveryCodes(username string) ([]string, error) {
+	user, err := m.Get(username)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user %w", err)
+	}
+	if !user.MFAEnabled {
+		return nil, fmt.Errorf("MFA is not enabled for %s", username)
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.DB.Model(&user).Update("mfa_recovery_codes", strings.Join(hashes, recoverySeparator)).Error; err != nil {
+		return nil, fmt.Errorf("update %w", err)
+	}
+	return codes, nil
+}
+
+// CheckMFA to verify the second factor of a user, using a TOTP code or a recovery code.
+// Each TOTP code and recovery code can only be used once
+func (m *UserManager) CheckMFA(username, code string) bool {
+	user, err := m.Get(username)
+	if err != nil || !user.MFAEnabled {
+		return false
+	}
+	if step, valid := totpValidStep(user.MFASecret, code, time.Now()); valid {
+		if step <= user.MFALastStep {
+			return false
+		}
+		res := m.DB.Model(&AdminUser{}).Where("id = ? AND mfa_last_step < ?", user.ID, step).Update("mfa_last_step", step)
+		return res.Error == nil && res.RowsAffected == 1
+	}
+	if user.MFARecoveryCodes == "" {
+		return false
+	}
+	hashed := hashRecoveryCode(code)
+	hashes := strings.Split(user.MFARecoveryCodes, recoverySeparator)
+	for i, h := range hashes {
+		if hmac.Equal([]byte(h), []byte(hashed)) {
+			remaining := append(hashes[:i:i], hashes[i+1:]...)
+			res := m.DB.Model(&AdminUser{}).Where("id = ? AND mfa_recovery_codes = ?", user.ID, user.MFARecoveryCodes).Update("mfa_recovery_codes", strings.Join(remaining, recoverySeparator))
+			return res.Error == nil && res.RowsAffected == 1
+		}
+	}
+	return false
+}
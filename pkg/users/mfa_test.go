@@ -0,0 +1,80 @@
+package users
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 test vector for SHA1, truncated to 6 digits
+	code, err := TOTPCode("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", time.Unix(59, 0))
+	require.NoError(t, err)
+	assert.Equal(t, "287082", code)
+	_, err = TOTPCode("not base32!", time.Now())
+	assert.Error(t, err)
+}
+
+func TestMFA(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&AdminUser{}, &UserPermission{}, &UserToken{}))
+	m := CreateUserManager(db, &config.JSONConfigurationJWT{JWTSecret: "test", HoursToExpire: 1})
+	user, err := m.New("tester", "password", "", "", false, false)
+	require.NoError(t, err)
+	require.NoError(t, m.Create(user))
+	assert.False(t, MFANeeded(user, false))
+	assert.True(t, MFANeeded(user, true))
+
+	// Enrollment needs a valid code for the pending secret
+	_, err = m.EnableMFA("tester", "123456")
+	assert.Error(t, err)
+	secret, err := m.NewMFASecret("tester")
+	require.NoError(t, err)
+	_, err = m.EnableMFA("tester", "000000x")
+	assert.Error(t, err)
+	code, err := TOTPCode(secret, time.Now())
+	require.NoError(t, err)
+	recovery, err := m.EnableMFA("tester", code)
+	require.NoError(t, err)
+	assert.Len(t, recovery, MFARecoveryCodes)
+	user, err = m.Get("tester")
+	require.NoError(t, err)
+	assert.True(t, user.MFAEnabled)
+	assert.Equal(t, MFARecoveryCodes, user.RecoveryCodesLeft())
+	_, err = m.NewMFASecret("tester")
+	assert.Error(t, err)
+
+	// Codes used for the enrollment can not be used again
+	assert.False(t, m.CheckMFA("tester", code))
+	next, err := TOTPCode(secret, time.Now().Add(time.Duration(MFAPeriod)*time.Second))
+	require.NoError(t, err)
+	if next != code {
+		assert.True(t, m.CheckMFA("tester", next))
+		assert.False(t, m.CheckMFA("tester", next))
+	}
+
+	// Recovery codes can only be used once
+	assert.True(t, m.CheckMFA("tester", recovery[0]))
+	assert.False(t, m.CheckMFA("tester", recovery[0]))
+	user, err = m.Get("tester")
+	require.NoError(t, err)
+	assert.Equal(t, MFARecoveryCodes-1, user.RecoveryCodesLeft())
+	renewed, err := m.NewRecoveryCodes("tester")
+	require.NoError(t, err)
+	assert.False(t, m.CheckMFA("tester", recovery[1]))
+	assert.True(t, m.CheckMFA("tester", renewed[1]))
+
+	// Disabling removes the second factor
+	require.NoError(t, m.DisableMFA("tester"))
+	user, err = m.Get("tester")
+	require.NoError(t, err)
+	assert.False(t, user.MFAEnabled)
+	assert.Empty(t, user.MFASecret)
+	assert.False(t, m.CheckMFA("tester", renewed[2]))
+}
@@ -34,31 +34,6 @@ func setupTestManagerForPermissions(t *testing.T) (*UserManager, sqlmock.Sqlmock
 		t.Errorf("unable to create new postgres database: %v", err)
 	}
 
-	// Set up mock expectations for AutoMigrate before creating the manager
-	// admin_users table
-	mock.ExpectQuery(
-		regexp.QuoteMeta(`SELECT count(*) FROM information_schema.tables WHERE table_schema = CURRENT_SCHEMA() AND table_name = $1 AND table_type = $2`)).
-		WithArgs("admin_users", "BASE TABLE").
-		WillReturnRows(sqlmock.NewRows([]string{"count(*)"}).AddRow(0))
-	mock.ExpectExec(`CREATE TABLE "admin_users" .*`).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS .*`).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS .*`).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-
-	// user_permissions table
-	mock.ExpectQuery(
-		regexp.QuoteMeta(`SELECT count(*) FROM information_schema.tables WHERE table_schema = CURRENT_SCHEMA() AND table_name = $1 AND table_type = $2`)).
-		WithArgs("user_permissions", "BASE TABLE").
-		WillReturnRows(sqlmock.NewRows([]string{"count(*)"}).AddRow(0))
-	mock.ExpectExec(`CREATE TABLE "user_permissions" .*`).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS .*`).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS .*`).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-
 	manager := CreateUserManager(_postgres, &conf)
 	return manager, mock
 }
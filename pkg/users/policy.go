@@ -0,0 +1,212 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultPasswordMinLength is the default minimum length for passwords
+	DefaultPasswordMinLength int = 8
+	// DefaultPasswordMinClasses is the default number of character classes required in passwords
+	DefaultPasswordMinClasses int = 1
+	// DefaultLockoutAttempts is the default number of failed logins before locking a user
+	DefaultLockoutAttempts int = 5
+	// DefaultLockoutMinutes is the default duration of the lockout in minutes
+	DefaultLockoutMinutes int = 15
+)
+
+// ErrPasswordPolicy when a password does not follow the password policy
+var ErrPasswordPolicy = errors.New("password does not follow the policy")
+
+// PasswordPolicy to hold the rules that passwords must follow
+type PasswordPolicy struct {
+	// Minimum length of the password
+	MinLength int
+	// Minimum number of character classes (lowercase, uppercase, digits and symbols)
+	MinClasses int
+}
+
+// LockoutPolicy to hold how many failed logins lock a user and for how long
+type LockoutPolicy struct {
+	// Failed logins before locking the user, 0 disables lockouts
+	MaxAttempts int
+	// Duration of the lockout
+	Duration time.Duration
+}
+
+// DefaultPasswordPolicy returns the password policy used when none is configured
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:  DefaultPasswordMinLength,
+		MinClasses: DefaultPasswordMinClasses,
+	}
+}
+
+// DefaultLockoutPolicy returns the lockout policy used when none is configured
+func DefaultLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		MaxAttempts: DefaultLockoutAttempts,
+		Duration:    time.Duration(DefaultLockoutMinutes) * time.Minute,
+	}
+}
+
+// Validate to check if a password follows the policy
+func (p PasswordPolicy) Validate(password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("%w: it must be at least %d characters long", ErrPasswordPolicy, p.MinLength)
+	}
+	var lower, upper, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsDigit(c):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	if classes < p.MinClasses {
+		return fmt.Errorf("%w: it must contain at least %d of lowercase, uppercase, digits and symbols", ErrPasswordPolicy, p.MinClasses)
+	}
+	return nil
+}
+
+// IsLocked checks if the user is locked out because of failed logins
+func (u AdminUser) IsLocked() bool {
+	return time.Now().Before(u.LockedUntil)
+}
+
+// IsLocked checks if a user is locked out because of failed logins
+func (m *UserManager) IsLocked(username string) bool {
+	user, err := m.Get(username)
+	if err != nil {
+		return false
+	}
+	return user.IsLocked()
+}
+
+// LoginFailed to register a failed login for a user, returning true if the user has been locked out
+func (m *UserManager) LoginFailed(username string) (bool, error) {
+	user, err := m.Get(username)
+	if err != nil {
+		return false, fmt.Errorf("error getting user %w", err)
+	}
+	if err := m.DB.Model(&user).Updates(map[string]interface{}{
+		"failed_logins":     gorm.Expr("failed_logins + ?", 1),
+		"last_failed_login": time.Now(),
+	}).Error; err != nil {
+		return false, fmt.Errorf("Updates %w", err)
+	}
+	if m.LockoutPolicy.MaxAttempts <= 0 {
+		return false, nil
+	}
+	if err := m.DB.Where("id = ?", user.ID).First(&user).Error; err != nil {
+		return false, fmt.Errorf("error getting user %w", err)
+	}
+	locked := user.FailedLogins >= m.LockoutPolicy.MaxAttempts
+	if locked {
+		if err := m.DB.Model(&user).Updates(map[string]interface{}{
+			"failed_logins": 0,
+			"locked_until":  time.Now().Add(m.LockoutPolicy.Duration),
+		}).Error; err != nil {
+			return false, fmt.Errorf("Updates %w", err)
+		}
+	}
+	return locked, nil
+}
+
+// LoginSucceeded to reset the failed logins of a user
+func (m *UserManager) LoginSucceeded(username string) error {
+	user, err := m.Get(username)
+	if err != nil {
+		return fmt.Errorf("error getting user %w", err)
+	}
+	if user.FailedLogins == 0 {
+		return nil
+	}
+	if err := m.DB.Model(&user).Update("failed_logins", 0).Error; err != nil {
+		return fmt.Errorf("update %w", err)
+	}
+	return nil
+}
+
+// Unlock to remove the lockout and failed logins of a user
+func (m *UserManager) Unlock(username string) error {
+	user, err := m.Get(username)
+	if err != nil {
+		return fmt.Errorf("error getting user %w", err)
+	}
+	if err := m.DB.Model(&user).Updates(map[string]interface{}{
+		"failed_logins": 0,
+		"locked_until":  time.Time{},
+	}).Error; err != nil {
+		return fmt.Errorf("Updates %w", err)
+	}
+	return nil
+}
+
+// LoginThrottle to limit the login attempts from the same IP in a time window
+type LoginThrottle struct {
+	MaxAttempts int
+	Window      time.Duration
+	attempts    map[string]throttleEntry
+	lastSweep   time.Time
+	mutex       sync.Mutex
+}
+
+// Attempts from one IP in the current window
+type throttleEntry struct {
+	start time.Time
+	count int
+}
+
+// NewLoginThrottle to initialize the login throttling, 0 attempts disables it
+func NewLoginThrottle(maxAttempts int, window time.Duration) *LoginThrottle {
+	return &LoginThrottle{
+		MaxAttempts: maxAttempts,
+		Window:      window,
+		attempts:    make(map[string]throttleEntry),
+		lastSweep:   time.Now(),
+	}
+}
+
+// Allow to register a login attempt from an IP, returning false if it exceeds the allowed attempts
+func (t *LoginThrottle) Allow(ip string) bool {
+	if t == nil || t.MaxAttempts <= 0 {
+		return true
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	now := time.Now()
+	// Remove expired windows, so the map does not grow forever
+	if now.Sub(t.lastSweep) > t.Window {
+		for k, e := range t.attempts {
+			if now.Sub(e.start) > t.Window {
+				delete(t.attempts, k)
+			}
+		}
+		t.lastSweep = now
+	}
+	e, ok := t.attempts[ip]
+	if !ok || now.Sub(e.start) > t.Window {
+		e = throttleEntry{start: now}
+	}
+	e.count++
+	t.attempts[ip] = e
+	return e.count <= t.MaxAttempts
+}
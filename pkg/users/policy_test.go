@@ -0,0 +1,108 @@
+package users
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	p := PasswordPolicy{MinLength: 10, MinClasses: 3}
+	assert.ErrorIs(t, p.Validate("Sh0rt!"), ErrPasswordPolicy)
+	assert.ErrorIs(t, p.Validate("onlylowercase"), ErrPasswordPolicy)
+	assert.ErrorIs(t, p.Validate("lowerUPPERcase"), ErrPasswordPolicy)
+	assert.NoError(t, p.Validate("lowerUPPER123"))
+	assert.NoError(t, p.Validate("lower-case-123"))
+	assert.NoError(t, PasswordPolicy{}.Validate(""))
+}
+
+func TestLockout(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&AdminUser{}, &UserPermission{}, &UserToken{}))
+	m := CreateUserManager(db, &config.JSONConfigurationJWT{JWTSecret: "test", HoursToExpire: 1})
+	m.LockoutPolicy = LockoutPolicy{MaxAttempts: 3, Duration: time.Hour}
+	_, err = m.New("tester", "short", "", "", false, false)
+	assert.ErrorIs(t, err, ErrPasswordPolicy)
+	user, err := m.New("tester", "password", "", "", false, false)
+	require.NoError(t, err)
+	require.NoError(t, m.Create(user))
+	assert.ErrorIs(t, m.ChangePassword("tester", "short"), ErrPasswordPolicy)
+
+	// Successful logins reset the failed attempts
+	for i := 0; i < 2; i++ {
+		locked, err := m.LoginFailed("tester")
+		require.NoError(t, err)
+		assert.False(t, locked)
+	}
+	require.NoError(t, m.LoginSucceeded("tester"))
+	user, err = m.Get("tester")
+	require.NoError(t, err)
+	assert.Equal(t, 0, user.FailedLogins)
+
+	// Too many failed logins lock the user, even with valid credentials
+	var locked bool
+	for i := 0; i < 3; i++ {
+		locked, err = m.LoginFailed("tester")
+		require.NoError(t, err)
+	}
+	assert.True(t, locked)
+	assert.True(t, m.IsLocked("tester"))
+	access, _ := m.CheckLoginCredentials("tester", "password")
+	assert.False(t, access)
+	require.NoError(t, m.Unlock("tester"))
+	assert.False(t, m.IsLocked("tester"))
+	access, _ = m.CheckLoginCredentials("tester", "password")
+	assert.True(t, access)
+	_, err = m.LoginFailed("unknown")
+	assert.Error(t, err)
+}
+
+func TestLoginThrottle(t *testing.T) {
+	throttle := NewLoginThrottle(2, time.Minute)
+	assert.True(t, throttle.Allow("10.0.0.1"))
+	assert.True(t, throttle.Allow("10.0.0.1"))
+	assert.False(t, throttle.Allow("10.0.0.1"))
+	assert.True(t, throttle.Allow("10.0.0.2"))
+	disabled := NewLoginThrottle(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		assert.True(t, disabled.Allow("10.0.0.1"))
+	}
+	var missing *LoginThrottle
+	assert.True(t, missing.Allow("10.0.0.1"))
+}
+
+func TestLoginCredentialsSSO(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&AdminUser{}, &UserPermission{}, &UserToken{}))
+	m := CreateUserManager(db, &config.JSONConfigurationJWT{JWTSecret: "test", HoursToExpire: 1})
+	// Users provisioned by SSO can not login with credentials, not even with empty password
+	sso, err := m.NewSSO("sso-user", "sso@example.com", "SSO User", true)
+	require.NoError(t, err)
+	assert.True(t, sso.SSOOnly)
+	require.NoError(t, m.Create(sso))
+	access, _ := m.CheckLoginCredentials("sso-user", "")
+	assert.False(t, access)
+	// Users created without password get an unusable password
+	user, err := m.New("nopass", "", "", "", false, false)
+	require.NoError(t, err)
+	assert.False(t, user.SSOOnly)
+	require.NoError(t, m.Create(user))
+	access, _ = m.CheckLoginCredentials("nopass", "")
+	assert.False(t, access)
+	// Empty passwords are rejected even if the stored hash matches
+	hash, err := m.HashPasswordWithSalt("")
+	require.NoError(t, err)
+	require.NoError(t, db.Model(&AdminUser{}).Where("username = ?", "nopass").Update("pass_hash", hash).Error)
+	access, _ = m.CheckLoginCredentials("nopass", "")
+	assert.False(t, access)
+	require.NoError(t, m.ChangePassword("nopass", "password"))
+	access, _ = m.CheckLoginCredentials("nopass", "password")
+	assert.True(t, access)
+}
@@ -0,0 +1,205 @@
+package users
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jmpsec/osctrl/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// TokenScopeRead for tokens that can only read data
+	TokenScopeRead string = "read-only"
+	// TokenScopeQuery for tokens that can read data and run queries
+	TokenScopeQuery string = "query"
+	// TokenScopeCarve for tokens that can read data and run carves
+	TokenScopeCarve string = "carve"
+	// TokenScopeAdmin for tokens without restrictions in the actions
+	TokenScopeAdmin string = "admin"
+	// TokenAllEnvironments to be explicit when a token is not restricted to an environment
+	TokenAllEnvironments string = ""
+)
+
+// UserToken to hold the named API tokens of users. Only the hash of the token is stored
+type UserToken struct {
+	gorm.Model
+	TokenID       string `gorm:"uniqueIndex"`
+	Username      string `gorm:"index"`
+	Name          string
+	TokenHash     string `json:"-"`
+	Environment   string
+	Scope         string
+	ExpiresAt     time.Time
+	LastUsed      time.Time
+	LastIPAddress string
+	Revoked       bool
+	RevokedAt     time.Time
+	RevokedBy     string
+}
+
+// ValidTokenScope to check if a token scope is valid
+func ValidTokenScope(scope string) bool {
+	switch scope {
+	case TokenScopeRead, TokenScopeQuery, TokenScopeCarve, TokenScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// Helper to hash tokens before storing and looking them up
+func hashToken(tokenStr string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(tokenStr)))
+}
+
+// Allows to check if the token can perform an action with the provided scope in an environment
+func (t UserToken) Allows(scope, environment string) bool {
+	if t.Environment != TokenAllEnvironments && t.Environment != environment {
+		return false
+	}
+	switch t.Scope {
+	case TokenScopeAdmin:
+		return true
+	case TokenScopeQuery, TokenScopeCarve:
+		return scope == TokenScopeRead || scope == t.Scope
+	case TokenScopeRead:
+		return scope == TokenScopeRead
+	}
+	return false
+}
+
+// CreateUserToken to create a new named JWT token for a given user, with its own expiration and scope
+func (m *UserManager) CreateUserToken(username, name, issuer, environment, scope string, expHours int) (string, UserToken, error) {
+	if !ValidTokenScope(scope) {
+		return "", UserToken{}, fmt.Errorf("invalid token scope %s", scope)
+	}
+	if name == "" {
+		return "", UserToken{}, fmt.Errorf("token name can not be empty")
+	}
+	if m.TokenNameExists(username, name) {
+		return "", UserToken{}, fmt.Errorf("token %s already exists for %s", name, username)
+	}
+	if expHours == 0 {
+		expHours = m.JWTConfig.HoursToExpire
+	}
+	t := UserToken{
+		TokenID:     utils.GenUUID(),
+		Username:    username,
+		Name:        name,
+		Environment: environment,
+		Scope:       scope,
+		ExpiresAt:   time.Now().Add(time.Hour * time.Duration(expHours)),
+	}
+	claims := &TokenClaims{
+		Username: username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        t.TokenID,
+			ExpiresAt: jwt.NewNumericDate(t.ExpiresAt),
+			Issuer:    issuer,
+		},
+	}
+	tokenStr, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(m.JWTConfig.JWTSecret))
+	if err != nil {
+		return "", UserToken{}, err
+	}
+	t.TokenHash = hashToken(tokenStr)
+	if err := m.DB.Create(&t).Error; err != nil {
+		return "", UserToken{}, fmt.Errorf("Create UserToken %w", err)
+	}
+	return tokenStr, t, nil
+}
+
+// TokenNameExists checks if a user already has a token with the same name
+func (m *UserManager) TokenNameExists(username, name string) bool {
+	var results int64
+	m.DB.Model(&UserToken{}).Where("username = ? AND name = ?", username, name).Count(&results)
+	return (results > 0)
+}
+
+// GetUserToken to retrieve a token by its ID
+func (m *UserManager) GetUserToken(tokenID string) (UserToken, error) {
+	var t UserToken
+	if err := m.DB.Where("token_id = ?", tokenID).First(&t).Error; err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+// GetUserTokens to retrieve all the tokens for a user
+func (m *UserManager) GetUserTokens(username string) ([]UserToken, error) {
+	var tokens []UserToken
+	if err := m.DB.Where("username = ?", username).Order("created_at").Find(&tokens).Error; err != nil {
+		return tokens, err
+	}
+	return tokens, nil
+}
+
+// RevokeUserToken to revoke a token of a user, so it can not be used anymore
+func (m *UserManager) RevokeUserToken(username, tokenID, revokedBy string) error {
+	t, err := m.GetUserToken(tokenID)
+	if err != nil {
+		return fmt.Errorf("error getting token %w", err)
+	}
+	if t.Username != username {
+		return fmt.Errorf("token %s does not belong to %s", tokenID, username)
+	}
+	if err := m.DB.Model(&t).Updates(map[string]interface{}{
+		"revoked":    true,
+		"revoked_at": time.Now(),
+		"revoked_by": revokedBy,
+	}).Error; err != nil {
+		return fmt.Errorf("Updates %w", err)
+	}
+	return nil
+}
+
+// DeleteUserTokens to remove all the tokens for a user
+func (m *UserManager) DeleteUserTokens(username string) error {
+	if err := m.DB.Unscoped().Where("username = ?", username).Delete(&UserToken{}).Error; err != nil {
+		return fmt.Errorf("Delete %w", err)
+	}
+	return nil
+}
+
+// ValidateToken to verify that a token with a valid signature has not been revoked or replaced.
+// Tokens without ID are the legacy token of the user and must match the stored one
+func (m *UserManager) ValidateToken(claims TokenClaims, tokenStr string) (UserToken, error) {
+	if claims.ID == "" {
+		user, err := m.Get(claims.Username)
+		if err != nil {
+			return UserToken{}, fmt.Errorf("error getting user %w", err)
+		}
+		if user.APIToken == "" || user.APIToken != tokenStr {
+			return UserToken{}, fmt.Errorf("token for %s has been replaced", claims.Username)
+		}
+		return UserToken{Username: user.Username, Scope: TokenScopeAdmin, ExpiresAt: user.TokenExpire}, nil
+	}
+	t, err := m.GetUserToken(claims.ID)
+	if err != nil {
+		return t, fmt.Errorf("error getting token %w", err)
+	}
+	if t.TokenHash != hashToken(tokenStr) || t.Username != claims.Username {
+		return t, fmt.Errorf("token %s does not match", claims.ID)
+	}
+	if t.Revoked {
+		return t, fmt.Errorf("token %s has been revoked", claims.ID)
+	}
+	if time.Now().After(t.ExpiresAt) {
+		return t, fmt.Errorf("token %s has expired", claims.ID)
+	}
+	return t, nil
+}
+
+// UpdateUserTokenUse updates IP and last use for a token
+func (m *UserManager) UpdateUserTokenUse(tokenID, ipaddress string) error {
+	if err := m.DB.Model(&UserToken{}).Where("token_id = ?", tokenID).Updates(
+		UserToken{
+			LastIPAddress: ipaddress,
+			LastUsed:      time.Now(),
+		}).Error; err != nil {
+		return fmt.Errorf("Updates %w", err)
+	}
+	return nil
+}
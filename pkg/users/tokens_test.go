@@ -0,0 +1,82 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/jmpsec/osctrl/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestTokenAllows(t *testing.T) {
+	read := UserToken{Scope: TokenScopeRead}
+	assert.True(t, read.Allows(TokenScopeRead, "env1"))
+	assert.False(t, read.Allows(TokenScopeQuery, "env1"))
+	query := UserToken{Scope: TokenScopeQuery, Environment: "env1"}
+	assert.True(t, query.Allows(TokenScopeRead, "env1"))
+	assert.True(t, query.Allows(TokenScopeQuery, "env1"))
+	assert.False(t, query.Allows(TokenScopeCarve, "env1"))
+	assert.False(t, query.Allows(TokenScopeQuery, "env2"))
+	assert.False(t, query.Allows(TokenScopeRead, TokenAllEnvironments))
+	admin := UserToken{Scope: TokenScopeAdmin}
+	assert.True(t, admin.Allows(TokenScopeAdmin, TokenAllEnvironments))
+	assert.False(t, UserToken{Scope: "unknown"}.Allows(TokenScopeRead, "env1"))
+}
+
+func TestUserTokens(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&AdminUser{}, &UserPermission{}, &UserToken{}))
+	m := CreateUserManager(db, &config.JSONConfigurationJWT{JWTSecret: "test", HoursToExpire: 1})
+	user, err := m.New("tester", "password", "", "", false, false)
+	require.NoError(t, err)
+	require.NoError(t, m.Create(user))
+
+	_, _, err = m.CreateUserToken("tester", "ci", "osctrl", TokenAllEnvironments, "invalid", 0)
+	assert.Error(t, err)
+	tokenStr, token, err := m.CreateUserToken("tester", "ci", "osctrl", "env1", TokenScopeQuery, 24)
+	require.NoError(t, err)
+	assert.NotEqual(t, tokenStr, token.TokenHash)
+	_, _, err = m.CreateUserToken("tester", "ci", "osctrl", TokenAllEnvironments, TokenScopeRead, 0)
+	assert.Error(t, err)
+
+	// Token ID is included in the claims and the token is valid
+	claims, valid := m.CheckToken("test", tokenStr)
+	require.True(t, valid)
+	assert.Equal(t, token.TokenID, claims.ID)
+	validated, err := m.ValidateToken(claims, tokenStr)
+	require.NoError(t, err)
+	assert.Equal(t, "env1", validated.Environment)
+	require.NoError(t, m.UpdateUserTokenUse(token.TokenID, "127.0.0.1"))
+	tokens, err := m.GetUserTokens("tester")
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.Equal(t, "127.0.0.1", tokens[0].LastIPAddress)
+	assert.False(t, tokens[0].LastUsed.IsZero())
+
+	// Revoked tokens are not valid anymore
+	assert.Error(t, m.RevokeUserToken("other", token.TokenID, "admin"))
+	require.NoError(t, m.RevokeUserToken("tester", token.TokenID, "admin"))
+	_, err = m.ValidateToken(claims, tokenStr)
+	assert.Error(t, err)
+
+	// Legacy tokens must match the one stored for the user
+	legacy, exp, err := m.CreateToken("tester", "osctrl", 1)
+	require.NoError(t, err)
+	claims, valid = m.CheckToken("test", legacy)
+	require.True(t, valid)
+	_, err = m.ValidateToken(claims, legacy)
+	assert.Error(t, err)
+	require.NoError(t, m.UpdateToken("tester", legacy, exp))
+	validated, err = m.ValidateToken(claims, legacy)
+	require.NoError(t, err)
+	assert.Equal(t, TokenScopeAdmin, validated.Scope)
+
+	// Tokens are removed with the user
+	require.NoError(t, m.Delete("tester"))
+	tokens, err = m.GetUserTokens("tester")
+	require.NoError(t, err)
+	assert.Empty(t, tokens)
+}
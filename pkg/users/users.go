@@ -20,26 +20,36 @@ const (
 	ActionEdit string = "edit"
 	// ActionRemove as action to remove a user
 	ActionRemove string = "remove"
+	// DefaultUnusablePasswordLength is the length of the random passwords for users without password
+	DefaultUnusablePasswordLength int = 64
 )
 
 // AdminUser to hold all users
 type AdminUser struct {
 	gorm.Model
-	Username      string `gorm:"index"`
-	Email         string
-	Fullname      string
-	PassHash      string `json:"-"`
-	APIToken      string `json:"-"`
-	TokenExpire   time.Time
-	Admin         bool
-	Service       bool
-	UUID          string
-	CSRFToken     string `json:"-"`
-	LastIPAddress string
-	LastUserAgent string
-	LastAccess    time.Time
-	LastTokenUse  time.Time
-	EnvironmentID uint
+	Username         string `gorm:"index"`
+	Email            string
+	Fullname         string
+	PassHash         string `json:"-"`
+	APIToken         string `json:"-"`
+	TokenExpire      time.Time
+	Admin            bool
+	Service          bool
+	UUID             string
+	CSRFToken        string `json:"-"`
+	LastIPAddress    string
+	LastUserAgent    string
+	LastAccess       time.Time
+	LastTokenUse     time.Time
+	EnvironmentID    uint
+	MFAEnabled       bool
+	MFASecret        string `json:"-"`
+	MFARecoveryCodes string `json:"-"`
+	MFALastStep      int64  `json:"-"`
+	FailedLogins     int
+	LastFailedLogin  time.Time
+	LockedUntil      time.Time
+	SSOOnly          bool
 }
 
 // TokenClaims to hold user claims when using JWT
@@ -50,24 +60,23 @@ type TokenClaims struct {
 
 // UserManager have all users of the system
 type UserManager struct {
-	DB        *gorm.DB
-	JWTConfig *config.JSONConfigurationJWT
+	DB             *gorm.DB
+	JWTConfig      *config.JSONConfigurationJWT
+	PasswordPolicy PasswordPolicy
+	LockoutPolicy  LockoutPolicy
 }
 
-// CreateUserManager to initialize the users struct and tables
+// CreateUserManager to initialize the users struct, tables are created by migrations
 func CreateUserManager(backend *gorm.DB, jwtconfig *config.JSONConfigurationJWT) *UserManager {
 	// Check if JWT is not empty
 	if jwtconfig.JWTSecret == "" {
 		log.Fatal().Msgf("JWT Secret can not be empty")
 	}
-	var u *UserManager = &UserManager{DB: backend, JWTConfig: jwtconfig}
-	// table admin_users
-	if err := backend.AutoMigrate(&AdminUser{}); err != nil {
-		log.Fatal().Msgf("Failed to AutoMigrate table (admin_users): %v", err)
-	}
-	// table user_permissions
-	if err := backend.AutoMigrate(&UserPermission{}); err != nil {
-		log.Fatal().Msgf("Failed to AutoMigrate table (user_permissions): %v", err)
+	var u *UserManager = &UserManager{
+		DB:             backend,
+		JWTConfig:      jwtconfig,
+		PasswordPolicy: DefaultPasswordPolicy(),
+		LockoutPolicy:  DefaultLockoutPolicy(),
 	}
 	return u
 }
@@ -95,6 +104,14 @@ func (m *UserManager) CheckLoginCredentials(username, password string) (bool, Ad
 	if err != nil {
 		return false, AdminUser{}
 	}
+	// Users provisioned by SSO and empty passwords can not be used to login with credentials
+	if password == "" || user.SSOOnly {
+		return false, AdminUser{}
+	}
+	// Locked users can not login until the lockout expires
+	if user.IsLocked() {
+		return false, AdminUser{}
+	}
 	// Check for hash matching
 	p := []byte(password)
 	existing := []byte(user.PassHash)
@@ -191,9 +208,17 @@ func (m *UserManager) Create(user AdminUser) error {
 	return nil
 }
 
-// New empty user
+// New empty user, the password must follow the password policy. Users with empty password get an
+// unusable random password hash, so they can not login with credentials until a password is set
 func (m *UserManager) New(username, password, email, fullname string, admin, service bool) (AdminUser, error) {
 	if !m.Exists(username) {
+		if password != "" {
+			if err := m.PasswordPolicy.Validate(password); err != nil {
+				return AdminUser{}, err
+			}
+		} else {
+			password = utils.GenRandomString(DefaultUnusablePasswordLength)
+		}
 		passhash, err := m.HashPasswordWithSalt(password)
 		if err != nil {
 			return AdminUser{}, err
@@ -211,6 +236,16 @@ func (m *UserManager) New(username, password, email, fullname string, admin, ser
 	return AdminUser{}, fmt.Errorf("%s already exists", username)
 }
 
+// NewSSO empty user provisioned by SSO, that can only login using SSO
+func (m *UserManager) NewSSO(username, email, fullname string, admin bool) (AdminUser, error) {
+	user, err := m.New(username, "", email, fullname, admin, false)
+	if err != nil {
+		return user, err
+	}
+	user.SSOOnly = true
+	return user, nil
+}
+
 // Exists checks if user exists
 func (m *UserManager) Exists(username string) bool {
 	var results int64
@@ -299,15 +334,18 @@ func (m *UserManager) Delete(username string) error {
 	if err := m.DB.Unscoped().Delete(&user).Error; err != nil {
 		return fmt.Errorf("Delete %w", err)
 	}
-	return nil
+	return m.DeleteUserTokens(username)
 }
 
-// ChangePassword for user by username
+// ChangePassword for user by username, the new password must follow the password policy
 func (m *UserManager) ChangePassword(username, password string) error {
 	user, err := m.Get(username)
 	if err != nil {
 		return fmt.Errorf("error getting user %w", err)
 	}
+	if err := m.PasswordPolicy.Validate(password); err != nil {
+		return err
+	}
 	passhash, err := m.HashPasswordWithSalt(password)
 	if err != nil {
 		return err
@@ -34,31 +34,6 @@ func setupTestManager(t *testing.T) (*UserManager, sqlmock.Sqlmock) {
 		t.Errorf("unable to create new postgres database: %v", err)
 	}
 
-	// Set up mock expectations for AutoMigrate before creating the manager
-	// admin_users table
-	mock.ExpectQuery(
-		regexp.QuoteMeta(`SELECT count(*) FROM information_schema.tables WHERE table_schema = CURRENT_SCHEMA() AND table_name = $1 AND table_type = $2`)).
-		WithArgs("admin_users", "BASE TABLE").
-		WillReturnRows(sqlmock.NewRows([]string{"count(*)"}).AddRow(0))
-	mock.ExpectExec(`CREATE TABLE "admin_users" .*`).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS .*`).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS .*`).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-
-	// user_permissions table
-	mock.ExpectQuery(
-		regexp.QuoteMeta(`SELECT count(*) FROM information_schema.tables WHERE table_schema = CURRENT_SCHEMA() AND table_name = $1 AND table_type = $2`)).
-		WithArgs("user_permissions", "BASE TABLE").
-		WillReturnRows(sqlmock.NewRows([]string{"count(*)"}).AddRow(0))
-	mock.ExpectExec(`CREATE TABLE "user_permissions" .*`).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS .*`).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS .*`).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-
 	manager := CreateUserManager(_postgres, &conf)
 	return manager, mock
 }
@@ -151,8 +126,8 @@ func TestCreateUser(t *testing.T) {
 
 	mock.ExpectBegin()
 	mock.ExpectQuery(
-		regexp.QuoteMeta(`INSERT INTO "admin_users" ("created_at","updated_at","deleted_at","username","email","fullname","pass_hash","api_token","token_expire","admin","service","uuid","csrf_token","last_ip_address","last_user_agent","last_access","last_token_use","environment_id") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18) RETURNING "id"`)).
-		WithArgs(tt, tt, nil, user.Username, user.Email, user.Fullname, user.PassHash, user.APIToken, tt, user.Admin, user.Service, user.UUID, user.CSRFToken, user.LastIPAddress, user.LastUserAgent, tt, tt, user.EnvironmentID).
+		regexp.QuoteMeta(`INSERT INTO "admin_users" ("created_at","updated_at","deleted_at","username","email","fullname","pass_hash","api_token","token_expire","admin","service","uuid","csrf_token","last_ip_address","last_user_agent","last_access","last_token_use","environment_id","mfa_enabled","mfa_secret","mfa_recovery_codes","mfa_last_step","failed_logins","last_failed_login","locked_until","sso_only") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26) RETURNING "id"`)).
+		WithArgs(tt, tt, nil, user.Username, user.Email, user.Fullname, user.PassHash, user.APIToken, tt, user.Admin, user.Service, user.UUID, user.CSRFToken, user.LastIPAddress, user.LastUserAgent, tt, tt, user.EnvironmentID, user.MFAEnabled, user.MFASecret, user.MFARecoveryCodes, user.MFALastStep, user.FailedLogins, user.LastFailedLogin, user.LockedUntil, user.SSOOnly).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(456))
 	mock.ExpectCommit()
 	err := manager.Create(user)
@@ -411,6 +386,12 @@ func TestDeleteUser(t *testing.T) {
 		WithArgs(1).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(
+		regexp.QuoteMeta(`DELETE FROM "user_tokens" WHERE username = $1`)).
+		WithArgs("testUser").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
 
 	err := manager.Delete("testUser")
 